@@ -0,0 +1,156 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/orchestrator"
+)
+
+// enginePool keeps one Engine per repository for the MCP server, so switching
+// between recently used repositories does not reload their indexes. When more
+// than capacity repositories are loaded, the least recently used one is
+// dropped, releasing its elements, graphs and vector store.
+type enginePool struct {
+	cfg      orchestrator.Config
+	capacity int
+
+	mu      sync.Mutex
+	lru     *list.List // of *poolEntry, most recently used first
+	entries map[string]*list.Element
+	empty   *orchestrator.Engine // answers requests before any repository is loaded
+}
+
+// poolEntry is one repository's engine. once guards its first indexing so
+// concurrent requests for a new repository index it only once; ready, guarded
+// by the pool's mu, is set once that indexing has succeeded.
+type poolEntry struct {
+	repoPath string
+	engine   *orchestrator.Engine
+	once     sync.Once
+	err      error
+	ready    bool
+}
+
+// newEnginePool creates a pool holding at most capacity repositories
+// (at least one).
+func newEnginePool(cfg orchestrator.Config, capacity int) *enginePool {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &enginePool{
+		cfg:      cfg,
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		empty:    orchestrator.NewEngine(cfg),
+	}
+}
+
+// engineFor returns the engine for repoPath, indexing the repository (from
+// the disk cache when possible) the first time it is used.
+func (p *enginePool) engineFor(repoPath string) (*orchestrator.Engine, error) {
+	entry, err := p.entry(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	entry.once.Do(func() {
+		_, entry.err = entry.engine.Index(entry.repoPath, false)
+	})
+	if entry.err != nil {
+		p.remove(entry)
+		return nil, entry.err
+	}
+	p.markReady(entry)
+	return entry.engine, nil
+}
+
+// index (re)indexes repoPath in its engine and makes it the current one.
+func (p *enginePool) index(repoPath string, force bool) (*orchestrator.IndexResult, error) {
+	entry, err := p.entry(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	var result *orchestrator.IndexResult
+	indexed := false
+	entry.once.Do(func() {
+		result, entry.err = entry.engine.Index(entry.repoPath, force)
+		err = entry.err
+		indexed = true
+	})
+	if !indexed {
+		result, err = entry.engine.Index(entry.repoPath, force)
+	}
+	if err != nil {
+		p.remove(entry)
+		return nil, err
+	}
+	p.markReady(entry)
+	return result, nil
+}
+
+// current returns the engine of the most recently used repository that has
+// finished indexing, so a repository still being indexed is not served half
+// loaded.
+func (p *enginePool) current() *orchestrator.Engine {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for el := p.lru.Front(); el != nil; el = el.Next() {
+		if entry := el.Value.(*poolEntry); entry.ready {
+			return entry.engine
+		}
+	}
+	return p.empty
+}
+
+// markReady records that entry's repository has been indexed.
+func (p *enginePool) markReady(entry *poolEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry.ready = true
+}
+
+// loaded returns the number of repositories in the pool.
+func (p *enginePool) loaded() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lru.Len()
+}
+
+// entry returns the pool entry for repoPath, creating it and evicting the
+// least recently used repository if needed, and marks it most recently used.
+func (p *enginePool) entry(repoPath string) (*poolEntry, error) {
+	abs, err := filepath.Abs(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", repoPath, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if el, ok := p.entries[abs]; ok {
+		p.lru.MoveToFront(el)
+		return el.Value.(*poolEntry), nil
+	}
+
+	entry := &poolEntry{repoPath: abs, engine: orchestrator.NewEngine(p.cfg)}
+	p.entries[abs] = p.lru.PushFront(entry)
+	for p.lru.Len() > p.capacity {
+		oldest := p.lru.Back()
+		evicted := p.lru.Remove(oldest).(*poolEntry)
+		delete(p.entries, evicted.repoPath)
+		logger.Info("evicted repository from the engine pool", "repo", evicted.repoPath)
+	}
+	return entry, nil
+}
+
+// remove drops entry from the pool, e.g. after its repository failed to index.
+func (p *enginePool) remove(entry *poolEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if el, ok := p.entries[entry.repoPath]; ok && el.Value == entry {
+		p.lru.Remove(el)
+		delete(p.entries, entry.repoPath)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/orchestrator"
+)
+
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestEnginePoolEvictsLeastRecentlyUsed(t *testing.T) {
+	origKey := os.Getenv("OPENAI_API_KEY")
+	os.Unsetenv("OPENAI_API_KEY")
+	defer os.Setenv("OPENAI_API_KEY", origKey)
+
+	pool := newEnginePool(orchestrator.Config{CacheDir: t.TempDir(), NoEmbeddings: true}, 2)
+	a, b, c := newTestRepo(t), newTestRepo(t), newTestRepo(t)
+
+	engineA, err := pool.engineFor(a)
+	if err != nil {
+		t.Fatalf("engineFor(a): %v", err)
+	}
+	if _, err := pool.engineFor(b); err != nil {
+		t.Fatalf("engineFor(b): %v", err)
+	}
+	if again, _ := pool.engineFor(a); again != engineA {
+		t.Error("a was reloaded instead of reusing its warm engine")
+	}
+	if pool.current() != engineA {
+		t.Error("current engine should be the most recently used (a)")
+	}
+
+	// c pushes out b, the least recently used
+	if _, err := pool.index(c, false); err != nil {
+		t.Fatalf("index(c): %v", err)
+	}
+	if _, ok := pool.entries[b]; ok {
+		t.Error("b should have been evicted")
+	}
+	if _, ok := pool.entries[a]; !ok || len(pool.entries) != 2 {
+		t.Errorf("pool holds %d repositories, want a and c", len(pool.entries))
+	}
+	if len(pool.current().Files()) != 1 {
+		t.Error("current engine should be c's, with its file indexed")
+	}
+}
+
+func TestEnginePoolCurrentSkipsIndexing(t *testing.T) {
+	origKey := os.Getenv("OPENAI_API_KEY")
+	os.Unsetenv("OPENAI_API_KEY")
+	defer os.Setenv("OPENAI_API_KEY", origKey)
+
+	pool := newEnginePool(orchestrator.Config{CacheDir: t.TempDir(), NoEmbeddings: true}, 2)
+	if pool.current() != pool.empty {
+		t.Error("an empty pool should answer with the empty engine")
+	}
+	engineA, err := pool.engineFor(newTestRepo(t))
+	if err != nil {
+		t.Fatalf("engineFor(a): %v", err)
+	}
+
+	// b is the most recently used, but its indexing has not finished
+	b := newTestRepo(t)
+	if _, err := pool.entry(b); err != nil {
+		t.Fatalf("entry(b): %v", err)
+	}
+	if pool.current() != engineA {
+		t.Error("current should skip b while it is indexing")
+	}
+	engineB, err := pool.engineFor(b)
+	if err != nil {
+		t.Fatalf("engineFor(b): %v", err)
+	}
+	if pool.current() != engineB {
+		t.Error("current should be b once it is indexed")
+	}
+}
+
+func TestEnginePoolDropsFailedRepository(t *testing.T) {
+	pool := newEnginePool(orchestrator.Config{CacheDir: t.TempDir(), NoEmbeddings: true}, 2)
+	missing := filepath.Join(t.TempDir(), "missing")
+	if _, err := pool.engineFor(missing); err == nil {
+		t.Fatal("expected an error for a missing repository")
+	}
+	if len(pool.entries) != 0 {
+		t.Error("a repository that failed to index should not stay in the pool")
+	}
+	if _, err := pool.current().Query("anything"); err == nil {
+		t.Error("expected the empty engine to report that nothing is indexed")
+	}
+}
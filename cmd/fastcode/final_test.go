@@ -104,7 +104,7 @@ func TestQueryCmdRunENoIndex(t *testing.T) {
 	buf := new(bytes.Buffer)
 	cmd.SetOut(buf)
 	cmd.SetErr(buf)
-	cmd.SetArgs([]string{"query", "what is main?"})
+	cmd.SetArgs([]string{"query", "what is main?", "--no-auto-index"})
 
 	err := cmd.Execute()
 	// Should error because no index is loaded
@@ -154,7 +154,7 @@ func TestQueryCmdRunEMultipleWords(t *testing.T) {
 	buf := new(bytes.Buffer)
 	cmd.SetOut(buf)
 	cmd.SetErr(buf)
-	cmd.SetArgs([]string{"query", "how", "does", "the", "main", "function", "work?"})
+	cmd.SetArgs([]string{"query", "how", "does", "the", "main", "function", "work?", "--no-auto-index"})
 
 	err := cmd.Execute()
 	// Will likely error but we test the multi-word joining path
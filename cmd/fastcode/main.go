@@ -5,10 +5,21 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/duyhunghd6/fastcode-cli/internal/agent"
+	"github.com/duyhunghd6/fastcode-cli/internal/cache"
 	"github.com/duyhunghd6/fastcode-cli/internal/config"
+	"github.com/duyhunghd6/fastcode-cli/internal/diff"
+	"github.com/duyhunghd6/fastcode-cli/internal/graph"
+	"github.com/duyhunghd6/fastcode-cli/internal/index"
+	"github.com/duyhunghd6/fastcode-cli/internal/logging"
 	"github.com/duyhunghd6/fastcode-cli/internal/orchestrator"
+	"github.com/duyhunghd6/fastcode-cli/internal/session"
+	"github.com/duyhunghd6/fastcode-cli/internal/util"
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
 )
@@ -17,21 +28,47 @@ var version = "0.1.0-dev"
 var buildTime = "unknown"
 var gitCommit = "unknown"
 
+var logger = logging.New("cli")
+
 func main() {
-	fmt.Println("[init] Starting fastcode main execution...")
+	logger.Debug("starting", "version", version)
 	// Load global config from ~/.fastcode/config.yaml first
-	if _, err := config.Load(); err != nil {
-		log.Printf("warning: config load: %v", err)
+	if cfg, err := config.Load(); err != nil {
+		logger.Warn("config load failed", "err", err)
+	} else {
+		agent.SetKeywordOptions(keywordOptions(cfg))
 	}
 	// Then load local .env (overrides YAML since env vars take precedence)
 	_ = godotenv.Load()
 
 	rootCmd := buildRootCmd()
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	// Profiles are written even when the command fails
+	if perr := activeProfiler.stop(); perr != nil {
+		logger.Warn("writing profiles failed", "err", perr)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
 
+// activeProfiler is started by the root command's --profile/--memprofile
+// flags and stopped by main once the command returns.
+var activeProfiler *profiler
+
+// keywordOptions builds the query keyword extraction settings from the config
+// file: stop_words replaces the built-in stop words, extra_stop_words extends
+// them and stem_keywords enables stemming.
+func keywordOptions(cfg *config.FastCodeConfig) agent.KeywordOptions {
+	opts := agent.DefaultKeywordOptions()
+	if len(cfg.StopWords) > 0 {
+		opts.StopWords = cfg.StopWords
+	}
+	opts.StopWords = append(opts.StopWords, cfg.ExtraStopWords...)
+	opts.Stem = cfg.StemKeywords
+	return opts
+}
+
 // buildRootCmd creates the root cobra command with all subcommands.
 func buildRootCmd() *cobra.Command {
 	versionStr := fmt.Sprintf("%s (built: %s, commit: %s)", version, buildTime, gitCommit)
@@ -48,11 +85,71 @@ and LLM-powered iterative retrieval to answer questions about codebases.`,
 	// Shared flags
 	var cacheDir string
 	var embeddingModel string
+	var embeddingBaseURL string
 	var noEmbeddings bool
+	var embeddingDimensions int
+	var truncateEmbeddings bool
+	var embeddingMaxTokens int
+	var noRedact bool
+	var repoName string
+	var logLevel string
+	var bm25K1, bm25B, bm25FieldBoost float64
 
 	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Cache directory (default: ~/.fastcode/cache)")
 	rootCmd.PersistentFlags().StringVar(&embeddingModel, "embedding-model", "", "Embedding model name (default: from config)")
+	rootCmd.PersistentFlags().StringVar(&embeddingBaseURL, "embedding-base-url", "", "Embedding API base URL (default: $EMBEDDING_BASE_URL or $BASE_URL)")
 	rootCmd.PersistentFlags().BoolVar(&noEmbeddings, "no-embeddings", false, "Skip embedding generation (BM25 only)")
+	rootCmd.PersistentFlags().IntVar(&embeddingDimensions, "embedding-dimensions", 0, "Reduce embeddings to this many dimensions (default: $EMBEDDING_DIMENSIONS or the model's size)")
+	rootCmd.PersistentFlags().IntVar(&embeddingMaxTokens, "embedding-max-tokens", 0, "Embedding model input limit in tokens (default: $EMBEDDING_MAX_TOKENS or the model's known limit)")
+	rootCmd.PersistentFlags().BoolVar(&truncateEmbeddings, "truncate-embeddings", false, "Truncate embeddings client-side, for models without a dimensions parameter")
+	rootCmd.PersistentFlags().BoolVar(&noRedact, "no-redact", false, "Send code to the LLM without masking secrets (default: redact unless $FASTCODE_REDACT_SECRETS is false)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "Log level: debug, info, warn or error (default: $FASTCODE_LOG_LEVEL or warn)")
+	rootCmd.PersistentFlags().Float64Var(&bm25K1, "bm25-k1", index.DefaultBM25K1, "BM25 term saturation, above 0 and typically 1.2-2.0")
+	rootCmd.PersistentFlags().Float64Var(&bm25B, "bm25-b", index.DefaultBM25B, "BM25 length normalization, above 0 and at most 1")
+	rootCmd.PersistentFlags().Float64Var(&bm25FieldBoost, "bm25-field-boost", index.DefaultFieldBoost, "Weight of BM25 matches on element names and signatures, at least 1 (1 adds no boost)")
+	rootCmd.PersistentFlags().StringVar(&repoName, "repo-name", "", "Repository name for the index and its cache (default: directory name)")
+
+	// Hidden pprof flags for performance work, e.g. on slow indexing
+	var cpuProfile, memProfile string
+	rootCmd.PersistentFlags().StringVar(&cpuProfile, "profile", "", "Write a CPU profile to this file")
+	rootCmd.PersistentFlags().StringVar(&memProfile, "memprofile", "", "Write a heap profile to this file on exit")
+	rootCmd.PersistentFlags().MarkHidden("profile")
+	rootCmd.PersistentFlags().MarkHidden("memprofile")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		level, err := logging.LevelFromEnv()
+		if logLevel != "" {
+			level, err = logging.ParseLevel(logLevel)
+		}
+		if err != nil {
+			return err
+		}
+		logging.SetLevel(level)
+		if embeddingDimensions < 0 {
+			return fmt.Errorf("--embedding-dimensions must not be negative, got %d", embeddingDimensions)
+		}
+		if embeddingMaxTokens < 0 {
+			return fmt.Errorf("--embedding-max-tokens must not be negative, got %d", embeddingMaxTokens)
+		}
+		if bm25K1 <= 0 {
+			return fmt.Errorf("--bm25-k1 must be above 0, got %g", bm25K1)
+		}
+		if bm25B <= 0 || bm25B > 1 {
+			return fmt.Errorf("--bm25-b must be above 0 and at most 1, got %g", bm25B)
+		}
+		if bm25FieldBoost < 1 {
+			return fmt.Errorf("--bm25-field-boost must be at least 1, got %g", bm25FieldBoost)
+		}
+
+		if cpuProfile == "" && memProfile == "" {
+			return nil
+		}
+		p, err := startProfiler(cpuProfile, memProfile)
+		if err != nil {
+			return err
+		}
+		activeProfiler = p
+		return nil
+	}
 
 	buildConfig := func() orchestrator.Config {
 		cfg := orchestrator.DefaultConfig()
@@ -61,29 +158,73 @@ and LLM-powered iterative retrieval to answer questions about codebases.`,
 		}
 		if embeddingModel != "" {
 			cfg.EmbeddingModel = embeddingModel
+			cfg.ExplicitEmbeddingModel = true
 		}
+		cfg.EmbeddingBaseURL = embeddingBaseURL
 		cfg.NoEmbeddings = noEmbeddings
+		if embeddingDimensions > 0 {
+			cfg.EmbeddingDimensions = embeddingDimensions
+		}
+		if embeddingMaxTokens > 0 {
+			cfg.EmbeddingMaxTokens = embeddingMaxTokens
+		}
+		cfg.TruncateEmbeddings = truncateEmbeddings
+		cfg.NoRedactSecrets = cfg.NoRedactSecrets || noRedact
+		cfg.BM25K1 = bm25K1
+		cfg.BM25B = bm25B
+		cfg.BM25FieldBoost = bm25FieldBoost
+		cfg.RepoName = repoName
 		return cfg
 	}
 
 	// --- index command ---
 	var forceReindex bool
 	var jsonOutput bool
+	var includeGlobs, excludeGlobs []string
+	var jsArrowFunctions, markdownSections, dedup bool
+	var workspace, planOnly bool
+	var maxElementsPerFile, embedMaxLines int
+	var indexSince string
 
 	indexCmd := &cobra.Command{
-		Use:   "index <repo-path>",
+		Use:   "index <repo-path>...",
 		Short: "Index a local repository",
-		Long:  "Parse, analyze, and index a code repository for querying.",
-		Args:  cobra.ExactArgs(1),
+		Long: `Parse, analyze, and index a code repository for querying.
+With --workspace, index several repositories to be queried together.`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			repoPath := args[0]
+			if len(args) > 1 && !workspace {
+				return fmt.Errorf("index takes one repository; use --workspace to index several")
+			}
+			repoPath := strings.Join(args, ", ")
 			cfg := buildConfig()
+			cfg.Include = includeGlobs
+			cfg.Exclude = excludeGlobs
+			cfg.JSArrowFunctions = jsArrowFunctions
+			cfg.MarkdownSections = markdownSections
+			cfg.MaxElementsPerFile = maxElementsPerFile
+			if embedMaxLines < 0 {
+				return fmt.Errorf("--embed-max-lines must not be negative, got %d", embedMaxLines)
+			}
+			cfg.EmbedMaxElementLines = embedMaxLines
+			cfg.DedupElements = dedup
+			cfg.Since = indexSince
+			if !jsonOutput {
+				cfg.Progress = renderIndexProgress
+			}
 			engine := orchestrator.NewEngine(cfg)
+			if planOnly {
+				return runIndexPlan(engine, args, jsonOutput)
+			}
 
 			fmt.Printf("⚡ Indexing %s...\n", repoPath)
 			start := time.Now()
 
-			result, err := engine.Index(repoPath, forceReindex)
+			// Ctrl+C stops embedding at the next batch boundary
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			result, err := engine.IndexWorkspace(ctx, args, forceReindex)
 			if err != nil {
 				return fmt.Errorf("indexing failed: %w", err)
 			}
@@ -97,8 +238,28 @@ and LLM-powered iterative retrieval to answer questions about codebases.`,
 			}
 
 			fmt.Printf("\n✅ Indexed %s in %s\n", result.RepoName, elapsed.Round(time.Millisecond))
+			if len(result.Repos) > 0 {
+				fmt.Printf("   Repos:    %s\n", strings.Join(result.Repos, ", "))
+			}
 			fmt.Printf("   Files:    %d\n", result.TotalFiles)
 			fmt.Printf("   Elements: %d\n", result.TotalElements)
+			if result.DuplicateElements > 0 {
+				fmt.Printf("   Deduped:  %d identical functions/classes collapsed\n", result.DuplicateElements)
+			}
+			if result.EmbedSkipped > 0 {
+				fmt.Printf("   Embedded: %d elements, %d over --embed-max-lines searched by keyword only\n", result.Embedded, result.EmbedSkipped)
+			} else if result.Embedded > 0 {
+				fmt.Printf("   Embedded: %d elements\n", result.Embedded)
+			}
+			if result.PrimaryLanguage != "" {
+				fmt.Printf("   Language: %s (%d files)\n", result.PrimaryLanguage, result.Languages[result.PrimaryLanguage])
+			}
+			if result.ParseErrors > 0 {
+				fmt.Printf("   Partial:  %d files with syntax errors (--json lists them)\n", result.ParseErrors)
+			}
+			if len(result.TruncatedFiles) > 0 {
+				fmt.Printf("   Capped:   %d files over the per-file element limit (--json lists them)\n", len(result.TruncatedFiles))
+			}
 			if result.Cached {
 				fmt.Println("   Source:   cache (use --force to reindex)")
 			}
@@ -110,14 +271,65 @@ and LLM-powered iterative retrieval to answer questions about codebases.`,
 	}
 	indexCmd.Flags().BoolVar(&forceReindex, "force", false, "Force re-indexing (ignore cache)")
 	indexCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	indexCmd.Flags().StringSliceVar(&includeGlobs, "include", nil, "Only index files matching this glob, e.g. 'internal/**' (repeatable)")
+	indexCmd.Flags().StringSliceVar(&excludeGlobs, "exclude", nil, "Skip files matching this glob, e.g. 'testdata/**' (repeatable)")
+	indexCmd.Flags().BoolVar(&jsArrowFunctions, "js-arrow-functions", false, "Index JS/TS functions assigned to variables and object keys")
+	indexCmd.Flags().BoolVar(&workspace, "workspace", false, "Index several repositories as one workspace (query them with repeated --repo)")
+	indexCmd.Flags().BoolVar(&planOnly, "plan", false, "List the files that would be indexed, then exit without parsing or embedding")
+	indexCmd.Flags().BoolVar(&markdownSections, "markdown-sections", false, "Index Markdown '#' and '##' sections as searchable elements")
+	indexCmd.Flags().StringVar(&indexSince, "since", "", "Only index files changed since this git ref, plus their graph neighbors (not cached)")
+	indexCmd.Flags().BoolVar(&dedup, "dedup", false, "Collapse functions/classes with identical code into one element listing all copies")
+	indexCmd.Flags().IntVar(&maxElementsPerFile, "max-elements-per-file", 0,
+		fmt.Sprintf("Index at most N functions/classes per file, keeping the most complex (0: %d, -1: no limit)", index.DefaultMaxElementsPerFile))
+	indexCmd.Flags().IntVar(&embedMaxLines, "embed-max-lines", 0, "Only embed elements of at most N lines; larger ones are searched by keyword only (0: no limit)")
 	rootCmd.AddCommand(indexCmd)
 
+	// --- list command ---
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List repositories in the index cache",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := cache.NewIndexCache(buildConfig().CacheDir).List()
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(entries)
+			}
+			if len(entries) == 0 {
+				fmt.Println("No indexed repositories (use 'fastcode index <path>' to add one)")
+				return nil
+			}
+			fmt.Printf("%-32s %9s %9s  %-16s %s\n", "REPOSITORY", "ELEMENTS", "VECTORS", "INDEXED", "SIZE")
+			for _, e := range entries {
+				if e.Error != "" {
+					fmt.Printf("%-32s unreadable: %s\n", e.RepoName, e.Error)
+					continue
+				}
+				indexed := "unknown"
+				if !e.IndexedAt.IsZero() {
+					indexed = e.IndexedAt.Format("2006-01-02 15:04")
+				}
+				fmt.Printf("%-32s %9d %9d  %-16s %.1f MB\n", e.RepoName, e.Elements, e.Vectors,
+					indexed, float64(e.SizeBytes)/(1024*1024))
+			}
+			return nil
+		},
+	}
+	listCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	rootCmd.AddCommand(listCmd)
+
 	// --- query command ---
 	queryCmd := &cobra.Command{
 		Use:   "query <question>",
 		Short: "Query the indexed codebase",
-		Long:  "Ask a question about a previously indexed codebase.",
-		Args:  cobra.MinimumNArgs(1),
+		Long: `Ask a question about an indexed codebase. Without --repo the current
+directory is queried, and indexed first if it has no index yet (unless
+--no-auto-index is set).`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Join remaining args as the question
 			question := ""
@@ -128,35 +340,120 @@ and LLM-powered iterative retrieval to answer questions about codebases.`,
 				question += arg
 			}
 
-			repoPath, _ := cmd.Flags().GetString("repo")
+			repoPaths, _ := cmd.Flags().GetStringSlice("repo")
+			topK, _ := cmd.Flags().GetInt("top-k")
+			if topK <= 0 {
+				return fmt.Errorf("--top-k must be positive, got %d", topK)
+			}
+			checkStale, _ := cmd.Flags().GetBool("check-stale")
+			langFlags, _ := cmd.Flags().GetStringSlice("lang")
+			var languages []string
+			for _, l := range langFlags {
+				lang := util.NormalizeLanguage(l)
+				if lang == "" {
+					return fmt.Errorf("unsupported language %q for --lang", l)
+				}
+				languages = append(languages, lang)
+			}
+			opts := orchestrator.DefaultQueryOptions()
+			opts.TopK = topK
+			opts.Languages = languages
+			opts.File, _ = cmd.Flags().GetString("file")
+			opts.PublicOnly, _ = cmd.Flags().GetBool("public-only")
+			noTests, _ := cmd.Flags().GetBool("no-tests")
+			testsOnly, _ := cmd.Flags().GetBool("tests-only")
+			switch {
+			case noTests && testsOnly:
+				return fmt.Errorf("--no-tests and --tests-only cannot be combined")
+			case noTests:
+				opts.Tests = agent.TestsExclude
+			case testsOnly:
+				opts.Tests = agent.TestsOnly
+			}
+			if opts.ContextLines, _ = cmd.Flags().GetInt("context-lines"); opts.ContextLines < 0 {
+				return fmt.Errorf("--context-lines must not be negative, got %d", opts.ContextLines)
+			}
+			opts.CheckStaleness = checkStale
+			opts.SessionID, _ = cmd.Flags().GetString("session")
+			formatName, _ := cmd.Flags().GetString("format")
+			format, err := agent.ParseAnswerFormat(formatName)
+			if err != nil {
+				return err
+			}
+			opts.Format = format
+			verbosityName, _ := cmd.Flags().GetString("verbosity")
+			if opts.Verbosity, err = agent.ParseVerbosity(verbosityName); err != nil {
+				return err
+			}
+			tracePath, _ := cmd.Flags().GetString("trace")
+			opts.Trace = tracePath != ""
+			opts.IncludeCode, _ = cmd.Flags().GetBool("include-code")
+			opts.GraphNeighbors, _ = cmd.Flags().GetBool("graph-neighbors")
+			if opts.MinRoundsBeforeStop, _ = cmd.Flags().GetInt("min-rounds"); opts.MinRoundsBeforeStop < 0 {
+				return fmt.Errorf("--min-rounds must not be negative, got %d", opts.MinRoundsBeforeStop)
+			}
+			if opts.MaxAnswerTokens, _ = cmd.Flags().GetInt("max-answer-tokens"); opts.MaxAnswerTokens < 0 {
+				return fmt.Errorf("--max-answer-tokens must not be negative, got %d", opts.MaxAnswerTokens)
+			}
+			budget, _ := cmd.Flags().GetString("budget")
+			switch mode := agent.BudgetMode(budget); mode {
+			case agent.BudgetLines, agent.BudgetTokens:
+				opts.BudgetMode = mode
+			default:
+				return fmt.Errorf("--budget must be lines or tokens, got %q", budget)
+			}
 			cfg := buildConfig()
+			cfg.Since, _ = cmd.Flags().GetString("since")
+			cfg.IncludeFileDependencies, _ = cmd.Flags().GetBool("file-deps")
 			engine := orchestrator.NewEngine(cfg)
 
-			// Index first if repo is specified
-			if repoPath != "" {
-				fmt.Printf("⚡ Loading index for %s...\n", repoPath)
-				_, err := engine.Index(repoPath, false)
+			// Without --repo, ask about the current directory
+			if len(repoPaths) == 0 {
+				cwd, err := os.Getwd()
 				if err != nil {
-					return fmt.Errorf("index load failed: %w", err)
+					return fmt.Errorf("current directory: %w", err)
+				}
+				noAutoIndex, _ := cmd.Flags().GetBool("no-auto-index")
+				if !engine.IsIndexed(cwd) {
+					if noAutoIndex {
+						return fmt.Errorf("%s is not indexed — run 'fastcode index %s' first, or pass --repo", cwd, cwd)
+					}
+					fmt.Printf("📂 No index for %s yet, indexing it first\n", cwd)
 				}
+				repoPaths = []string{cwd}
+			}
+
+			// Load the index, indexing if needed; several repositories form a workspace
+			fmt.Printf("⚡ Loading index for %s...\n", strings.Join(repoPaths, ", "))
+			if _, err := engine.IndexWorkspace(cmd.Context(), repoPaths, false); err != nil {
+				return fmt.Errorf("index load failed: %w", err)
 			}
 
 			fmt.Printf("🔍 Querying: %s\n\n", question)
 			start := time.Now()
 
-			result, err := engine.Query(question)
+			result, err := engine.QueryWithOptions(question, opts)
 			if err != nil {
 				return fmt.Errorf("query failed: %w", err)
 			}
 
 			elapsed := time.Since(start)
 
+			if tracePath != "" {
+				if err := writeTrace(tracePath, question, result); err != nil {
+					return err
+				}
+			}
+
 			if jsonOutput {
 				enc := json.NewEncoder(os.Stdout)
 				enc.SetIndent("", "  ")
 				return enc.Encode(result)
 			}
 
+			if result.Warning != "" {
+				fmt.Fprintf(os.Stderr, "⚠️  %s\n\n", result.Warning)
+			}
 			fmt.Println(result.Answer)
 			fmt.Printf("\n---\n")
 			fmt.Printf("⏱  %s | 🎯 Confidence: %d%% | 🔄 Rounds: %d | 📦 Elements: %d | Stop: %s\n",
@@ -164,22 +461,449 @@ and LLM-powered iterative retrieval to answer questions about codebases.`,
 			return nil
 		},
 	}
-	queryCmd.Flags().String("repo", "", "Repository path to index/load")
+	queryCmd.Flags().StringSlice("repo", nil, "Repository path to index/load (repeat to query a workspace of several; default: the current directory)")
+	queryCmd.Flags().Bool("no-auto-index", false, "Without --repo, fail instead of indexing the current directory when it has no index")
+	queryCmd.Flags().String("session", "", "Continue this session: earlier turns are shown to the model and the question and answer are recorded")
+	queryCmd.Flags().Int("top-k", 10, "Number of search results to retrieve")
+	queryCmd.Flags().StringSlice("lang", nil, "Only retrieve code in this language, e.g. go or ts (repeatable)")
+	queryCmd.Flags().Int("context-lines", 0, "Include this many lines around each function or class found (at most 50)")
+	queryCmd.Flags().Bool("public-only", false, "Only retrieve exported functions and classes")
+	queryCmd.Flags().Bool("no-tests", false, "Leave out test files (*_test.go, test_*.py, *.spec.ts, __tests__/, ...)")
+	queryCmd.Flags().Bool("tests-only", false, "Only retrieve code from test files")
+	queryCmd.Flags().Bool("file-deps", false, "Add the indexed files each retrieved file imports to the answer context")
+	queryCmd.Flags().Bool("graph-neighbors", false, "Add the callers, callees and other direct graph neighbors of the most relevant code found each round")
+	queryCmd.Flags().String("since", "", "With --repo, only index files changed since this git ref, plus their graph neighbors")
+	queryCmd.Flags().String("file", "", "Ask about this file: start retrieval from it (direct search only searches it)")
+	queryCmd.Flags().String("format", "markdown", "Answer layout: markdown, plain or bullet")
+	queryCmd.Flags().String("verbosity", "normal", "Answer length: brief, normal or detailed")
+	queryCmd.Flags().Int("max-answer-tokens", 0, "Cap the tokens generated for the answer (default: $FASTCODE_MAX_ANSWER_TOKENS or the verbosity's limit)")
+	queryCmd.Flags().Int("min-rounds", 0, "Retrieval rounds the agent runs before it may stop on confidence (default 2; 1 trusts the first assessment)")
+	queryCmd.Flags().String("budget", "lines", "Budget gathered code by lines or estimated tokens")
+	queryCmd.Flags().String("trace", "", `Write the agent's per-round retrieval trace as JSON to this file ("-" for stderr)`)
+	queryCmd.Flags().Bool("check-stale", false, "Warn if repository files changed since the index was built")
 	queryCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	queryCmd.Flags().Bool("include-code", false, "With --json, add the code of each element the answer was based on")
 	rootCmd.AddCommand(queryCmd)
 
+	// --- sessions command ---
+	sessionsCmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Review saved query sessions",
+	}
+	sessionsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List saved sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sessions, err := session.NewStore(buildConfig().SessionDir).List()
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(sessions)
+			}
+			if len(sessions) == 0 {
+				fmt.Println("No sessions found (use 'fastcode query --session <id>' to start one)")
+				return nil
+			}
+			for _, sess := range sessions {
+				fmt.Printf("%-24s %-20s %3d turns  updated %s\n", sess.ID, sess.RepoName,
+					len(sess.Turns), sess.UpdatedAt.Format("2006-01-02 15:04"))
+			}
+			return nil
+		},
+	}
+	sessionsShowCmd := &cobra.Command{
+		Use:   "show <session-id>",
+		Short: "Show the questions and answers of a session",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sess, err := session.NewStore(buildConfig().SessionDir).Load(args[0])
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(sess)
+			}
+			fmt.Printf("Session %s (%s), %d turns\n", sess.ID, sess.RepoName, len(sess.Turns))
+			for i, turn := range sess.Turns {
+				fmt.Printf("\n[%d] %s — %s\n", i+1, turn.Time.Format("2006-01-02 15:04"), turn.Question)
+				fmt.Println(turn.Answer)
+				fmt.Printf("🎯 Confidence: %d%% | 📦 Elements: %d\n", turn.Confidence, len(turn.ElementIDs))
+			}
+			return nil
+		},
+	}
+	sessionsListCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	sessionsShowCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd)
+	rootCmd.AddCommand(sessionsCmd)
+
+	// --- path command ---
+	var pathGraphs []string
+	pathCmd := &cobra.Command{
+		Use:   "path <repo-path> <from> <to>",
+		Short: "Show how one code element reaches another",
+		Long: `Find the shortest chain of calls, imports or inheritance edges leading
+from one code element to another. Elements can be given by name, file path or ID.`,
+		Args: cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repoPath, from, to := args[0], args[1], args[2]
+
+			var graphTypes []graph.GraphType
+			for _, g := range pathGraphs {
+				t := graph.GraphType(g)
+				switch t {
+				case graph.DependencyGraph, graph.InheritanceGraph, graph.CallGraph:
+					graphTypes = append(graphTypes, t)
+				default:
+					return fmt.Errorf("unknown graph %q (want dependency, inheritance or call)", g)
+				}
+			}
+
+			cfg := buildConfig()
+			engine := orchestrator.NewEngine(cfg)
+			if _, err := engine.Index(repoPath, false); err != nil {
+				return fmt.Errorf("index load failed: %w", err)
+			}
+
+			path, err := engine.FindPath(from, to, graphTypes)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(path)
+			}
+
+			if path == nil {
+				fmt.Printf("No path found from %s to %s\n", from, to)
+				return nil
+			}
+			for i, elem := range path {
+				prefix := "   "
+				if i > 0 {
+					prefix = "→  "
+				}
+				fmt.Printf("%s[%s] %s (%s:%d)\n", prefix, elem.Type, elem.Name, elem.RelativePath, elem.StartLine)
+			}
+			return nil
+		},
+	}
+	pathCmd.Flags().StringSliceVar(&pathGraphs, "graph", nil, "Graphs to traverse: dependency, inheritance, call (default: all)")
+	pathCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	rootCmd.AddCommand(pathCmd)
+
+	// --- important command ---
+	var importantGraph string
+	var importantTop int
+	importantCmd := &cobra.Command{
+		Use:   "important <repo-path>",
+		Short: "List the most central code elements",
+		Long: `Rank code elements by PageRank over the call or dependency graph to find the
+central functions and files of an unfamiliar repository.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := buildConfig()
+			engine := orchestrator.NewEngine(cfg)
+			if _, err := engine.Index(args[0], false); err != nil {
+				return fmt.Errorf("index load failed: %w", err)
+			}
+
+			ranked, err := engine.ImportantElements(graph.GraphType(importantGraph), importantTop)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(ranked)
+			}
+
+			if len(ranked) == 0 {
+				fmt.Printf("No %s graph edges found\n", importantGraph)
+				return nil
+			}
+			for i, r := range ranked {
+				fmt.Printf("%3d. %.4f  [%s] %s (%s:%d)\n", i+1, r.Score,
+					r.Element.Type, r.Element.Name, r.Element.RelativePath, r.Element.StartLine)
+			}
+			return nil
+		},
+	}
+	importantCmd.Flags().StringVar(&importantGraph, "graph", string(graph.CallGraph), "Graph to rank over: call, dependency or inheritance")
+	importantCmd.Flags().IntVar(&importantTop, "top", 20, "Number of elements to list")
+	importantCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	rootCmd.AddCommand(importantCmd)
+
+	// --- summarize command ---
+	var summarizeTop int
+	summarizeCmd := &cobra.Command{
+		Use:   "summarize <repo-path>",
+		Short: "Write an architecture overview of a repository",
+		Long: `Index the repository, pick its most central files (by PageRank over the
+dependency graph, then by how much they define) and ask the LLM for an
+overview of their signatures: entry points, key modules and notable types.
+Without an API key only the central files are listed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if summarizeTop <= 0 {
+				return fmt.Errorf("--top must be positive, got %d", summarizeTop)
+			}
+			engine := orchestrator.NewEngine(buildConfig())
+			if _, err := engine.Index(args[0], false); err != nil {
+				return fmt.Errorf("index load failed: %w", err)
+			}
+
+			summary, err := engine.Summarize(summarizeTop)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(summary)
+			}
+
+			if summary.Warning != "" {
+				fmt.Fprintf(os.Stderr, "⚠️  %s\n\n", summary.Warning)
+			}
+			fmt.Printf("# %s\n", summary.RepoName)
+			if o := summary.Overview; o != nil {
+				fmt.Printf("\n%s\n", o.Summary)
+				printOverviewSection("Entry points", o.EntryPoints)
+				printOverviewSection("Key modules", o.KeyModules)
+				printOverviewSection("Notable types", o.NotableTypes)
+			}
+			fmt.Printf("\n## Central files\n")
+			for i, f := range summary.Files {
+				fmt.Printf("%3d. %s (%s, %d elements, score %.4f)\n", i+1, f.Path, f.Language, f.Elements, f.Score)
+			}
+			return nil
+		},
+	}
+	summarizeCmd.Flags().IntVar(&summarizeTop, "top", 15, "Number of central files to summarize")
+	summarizeCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	rootCmd.AddCommand(summarizeCmd)
+
+	// --- explain command ---
+	explainCmd := &cobra.Command{
+		Use:   "explain <repo-path> <file>",
+		Short: "Explain what a single file does",
+		Long: `Index the repository, find the file (exact path, path suffix, then closest
+fuzzy match) and ask the LLM to explain it from its signatures, docstrings,
+imports and dependency graph edges: purpose, public API, key types and
+notable dependencies. Without an API key only the file's definitions are
+listed.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			engine := orchestrator.NewEngine(buildConfig())
+			if _, err := engine.Index(args[0], false); err != nil {
+				return fmt.Errorf("index load failed: %w", err)
+			}
+
+			summary, err := engine.Explain(args[1])
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(summary)
+			}
+
+			if summary.Warning != "" {
+				fmt.Fprintf(os.Stderr, "⚠️  %s\n\n", summary.Warning)
+			}
+			f := summary.File
+			fmt.Printf("# %s (%s, %d lines)\n", f.Path, f.Language, f.Lines)
+			if x := summary.Explanation; x != nil {
+				fmt.Printf("\n%s\n", x.Purpose)
+				printOverviewSection("Public API", x.PublicAPI)
+				printOverviewSection("Key types", x.KeyTypes)
+				printOverviewSection("Dependencies", x.Dependencies)
+				return nil
+			}
+			fmt.Printf("\n## Definitions\n")
+			for _, sig := range f.Signatures {
+				fmt.Printf("- %s\n", sig)
+			}
+			if len(f.UsedBy) > 0 {
+				fmt.Printf("\n## Imported by\n")
+				for _, p := range f.UsedBy {
+					fmt.Printf("- %s\n", p)
+				}
+			}
+			return nil
+		},
+	}
+	explainCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	rootCmd.AddCommand(explainCmd)
+
+	// --- find command ---
+	var findType string
+	var findExact bool
+	var findPublicOnly bool
+	findCmd := &cobra.Command{
+		Use:   "find <repo-path> <symbol>",
+		Short: "Locate where a symbol is defined",
+		Long: `List the code elements whose name contains the symbol (or equals it with
+--exact), with their file and line. This is a direct index lookup that needs no
+API key.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repoPath, symbol := args[0], args[1]
+			switch findType {
+			case "", "function", "class", "file":
+			default:
+				return fmt.Errorf("unknown type %q (want function, class or file)", findType)
+			}
+
+			engine := orchestrator.NewEngine(buildConfig())
+			if _, err := engine.Index(repoPath, false); err != nil {
+				return fmt.Errorf("index load failed: %w", err)
+			}
+
+			found, err := engine.FindSymbol(symbol, findType, findExact, findPublicOnly)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(found)
+			}
+
+			if len(found) == 0 {
+				fmt.Printf("No elements found for %s\n", symbol)
+				return nil
+			}
+			for _, elem := range found {
+				fmt.Printf("[%s] %s (%s:%d)\n", elem.Type, elem.Name, elem.RelativePath, elem.StartLine)
+			}
+			return nil
+		},
+	}
+	findCmd.Flags().StringVar(&findType, "type", "", "Only list elements of this type: function, class or file")
+	findCmd.Flags().BoolVar(&findExact, "exact", false, "Only list elements named exactly <symbol>")
+	findCmd.Flags().BoolVar(&findPublicOnly, "public-only", false, "Skip unexported functions and classes")
+	findCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	rootCmd.AddCommand(findCmd)
+
+	// --- refs command ---
+	refsCmd := &cobra.Command{
+		Use:   "refs <repo-path> <symbol>",
+		Short: "List the references to a symbol",
+		Long: `List the functions that call the symbol and the files that import it, with
+their file and line. Like find, this is a direct index lookup.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repoPath, symbol := args[0], args[1]
+			engine := orchestrator.NewEngine(buildConfig())
+			if _, err := engine.Index(repoPath, false); err != nil {
+				return fmt.Errorf("index load failed: %w", err)
+			}
+
+			refs, err := engine.FindReferences(symbol)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(refs)
+			}
+
+			if len(refs) == 0 {
+				fmt.Printf("No references to %s found\n", symbol)
+				return nil
+			}
+			for _, elem := range refs {
+				fmt.Printf("[%s] %s (%s:%d)\n", elem.Type, elem.Name, elem.RelativePath, elem.StartLine)
+			}
+			return nil
+		},
+	}
+	refsCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	rootCmd.AddCommand(refsCmd)
+
+	// --- diff command ---
+	diffCmd := &cobra.Command{
+		Use:   "diff <old-repo-path> <new-repo-path>",
+		Short: "Compare the code elements of two checkouts",
+		Long: `Parse two checkouts of a repository (for example two revisions) and list the
+functions and classes that were added, removed, had their signature changed or
+had their body modified.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			engine := orchestrator.NewEngine(buildConfig())
+			result, err := engine.Diff(args[0], args[1])
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(result)
+			}
+
+			if result.Empty() {
+				fmt.Println("No element changes")
+				return nil
+			}
+			printChanges := func(title, marker string, changes []diff.Change, showSignatures bool) {
+				if len(changes) == 0 {
+					return
+				}
+				fmt.Printf("%s (%d):\n", title, len(changes))
+				for _, c := range changes {
+					fmt.Printf("  %s [%s] %s (%s)\n", marker, c.Type, c.Name, c.RelativePath)
+					if showSignatures {
+						fmt.Printf("      - %s\n      + %s\n", c.OldSignature, c.NewSignature)
+					}
+				}
+			}
+			printChanges("Added", "+", result.Added, false)
+			printChanges("Removed", "-", result.Removed, false)
+			printChanges("Signature changed", "~", result.SignatureChanged, true)
+			printChanges("Modified", "*", result.Modified, false)
+			return nil
+		},
+	}
+	diffCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+	rootCmd.AddCommand(diffCmd)
+
 	// --- serve-mcp command ---
 	serveMCPCmd := &cobra.Command{
 		Use:   "serve-mcp",
 		Short: "Start MCP (Model Context Protocol) server",
 		Long:  "Start a JSON-RPC server implementing the Model Context Protocol for IDE integration.",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			host, _ := cmd.Flags().GetString("host")
 			port, _ := cmd.Flags().GetInt("port")
+			maxRepos, _ := cmd.Flags().GetInt("max-repos")
+			if repoName != "" {
+				return fmt.Errorf("--repo-name names a single repository and cannot be used with serve-mcp")
+			}
 			cfg := buildConfig()
-			return serveMCP(cfg, port)
+			return serveMCP(cmd.Context(), cfg, host, port, maxRepos)
 		},
 	}
-	serveMCPCmd.Flags().Int("port", 9999, "Port to listen on")
+	serveMCPCmd.Flags().String("host", "127.0.0.1", "Address to bind; use 0.0.0.0 to accept remote connections")
+	serveMCPCmd.Flags().Int("port", 9999, "Port to listen on (0 picks a free port)")
+	serveMCPCmd.Flags().Int("max-repos", 4, "Repositories kept loaded; the least recently used is dropped beyond this")
 	rootCmd.AddCommand(serveMCPCmd)
 
 	// --- completion command ---
@@ -226,3 +950,143 @@ PowerShell:
 
 	return rootCmd
 }
+
+// queryTrace is the JSON document written by query --trace.
+type queryTrace struct {
+	Query      string             `json:"query"`
+	Mode       string             `json:"mode"`
+	StopReason string             `json:"stop_reason"`
+	Confidence int                `json:"confidence"`
+	Rounds     []agent.RoundTrace `json:"rounds"`
+}
+
+// printOverviewSection prints one section of a repository overview, if it
+// has any items.
+func printOverviewSection(title string, items []agent.OverviewItem) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Printf("\n## %s\n", title)
+	for _, item := range items {
+		if item.Location != "" {
+			fmt.Printf("- %s (%s): %s\n", item.Name, item.Location, item.Description)
+		} else {
+			fmt.Printf("- %s: %s\n", item.Name, item.Description)
+		}
+	}
+}
+
+// writeTrace writes the retrieval trace of result to path, or to stderr when
+// path is "-".
+func writeTrace(path, question string, result *orchestrator.QueryResult) error {
+	trace := queryTrace{
+		Query:      question,
+		Mode:       result.Mode,
+		StopReason: result.StopReason,
+		Confidence: result.Confidence,
+		Rounds:     result.Trace,
+	}
+	if trace.Rounds == nil {
+		trace.Rounds = []agent.RoundTrace{}
+	}
+	out := os.Stderr
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("create trace file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(trace); err != nil {
+		return fmt.Errorf("write trace: %w", err)
+	}
+	return nil
+}
+
+// runIndexPlan prints the files index would load from each repository, with
+// counts by language and total size, without parsing anything.
+func runIndexPlan(engine *orchestrator.Engine, repoPaths []string, jsonOutput bool) error {
+	var plans []*orchestrator.IndexPlan
+	for _, repoPath := range repoPaths {
+		plan, err := engine.Plan(repoPath)
+		if err != nil {
+			return fmt.Errorf("planning failed: %w", err)
+		}
+		plans = append(plans, plan)
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if len(plans) == 1 {
+			return enc.Encode(plans[0])
+		}
+		return enc.Encode(plans)
+	}
+
+	for _, plan := range plans {
+		fmt.Printf("📋 %s (%s)\n\n", plan.RepoName, plan.RootPath)
+		for _, f := range plan.Files {
+			fmt.Printf("   %-60s %-12s %10s\n", f.RelativePath, f.Language, formatBytes(f.Size))
+		}
+
+		langs := make([]string, 0, len(plan.Languages))
+		for lang := range plan.Languages {
+			langs = append(langs, lang)
+		}
+		sort.Slice(langs, func(i, j int) bool {
+			if plan.Languages[langs[i]] != plan.Languages[langs[j]] {
+				return plan.Languages[langs[i]] > plan.Languages[langs[j]]
+			}
+			return langs[i] < langs[j]
+		})
+		fmt.Println()
+		for _, lang := range langs {
+			fmt.Printf("   %-12s %d files\n", lang, plan.Languages[lang])
+		}
+		fmt.Printf("   Total:       %d files, %s\n\n", len(plan.Files), formatBytes(plan.TotalSize))
+	}
+	fmt.Println("Nothing was parsed; run without --plan to index.")
+	return nil
+}
+
+// formatBytes renders a byte count as B, KB or MB.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// renderIndexProgress draws one progress line per indexing phase on stderr.
+func renderIndexProgress(p orchestrator.IndexProgress) {
+	switch p.Phase {
+	case orchestrator.PhaseLoaded:
+		fmt.Fprintf(os.Stderr, "   files      %d\n", p.Total)
+	case orchestrator.PhaseGraphs:
+		fmt.Fprintf(os.Stderr, "   graphs     built (%d elements)\n", p.Elements)
+	case orchestrator.PhaseParsing, orchestrator.PhaseEmbedding:
+		fmt.Fprintf(os.Stderr, "\r   %-10s %s %d/%d", p.Phase, progressBar(p.Done, p.Total, 30), p.Done, p.Total)
+		if p.Phase == orchestrator.PhaseParsing {
+			fmt.Fprintf(os.Stderr, " (%d elements)", p.Elements)
+		}
+		if p.Done == p.Total {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
+
+// progressBar renders done/total as a fixed-width bar such as "[=====     ]".
+func progressBar(done, total, width int) string {
+	filled := width
+	if total > 0 {
+		filled = done * width / total
+	}
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", width-filled) + "]"
+}
@@ -2,9 +2,17 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/agent"
+	"github.com/duyhunghd6/fastcode-cli/internal/config"
+	"github.com/duyhunghd6/fastcode-cli/internal/logging"
 )
 
 // === buildRootCmd Tests ===
@@ -28,7 +36,7 @@ func TestBuildRootCmdSubcommands(t *testing.T) {
 	for _, sub := range cmd.Commands() {
 		names[sub.Name()] = true
 	}
-	for _, expected := range []string{"index", "query", "serve-mcp"} {
+	for _, expected := range []string{"index", "list", "query", "sessions", "path", "important", "find", "refs", "diff", "serve-mcp"} {
 		if !names[expected] {
 			t.Errorf("missing subcommand: %s", expected)
 		}
@@ -63,6 +71,32 @@ func TestRootCmdVersion(t *testing.T) {
 	}
 }
 
+func TestRootCmdLogLevel(t *testing.T) {
+	t.Cleanup(func() { logging.SetLevel(logging.DefaultLevel) })
+	run := func(args ...string) error {
+		cmd := buildRootCmd()
+		cmd.SetOut(new(bytes.Buffer))
+		cmd.SetErr(new(bytes.Buffer))
+		cmd.SetArgs(append([]string{"list", "--cache-dir", t.TempDir()}, args...))
+		return cmd.Execute()
+	}
+
+	if err := run("--log-level", "debug"); err != nil {
+		t.Errorf("--log-level debug: %v", err)
+	}
+	if err := run("--log-level", "loud"); err == nil || !strings.Contains(err.Error(), "loud") {
+		t.Errorf("--log-level loud: err = %v, want an unknown level error", err)
+	}
+	t.Setenv(logging.EnvLevel, "loud")
+	if err := run(); err == nil || !strings.Contains(err.Error(), logging.EnvLevel) {
+		t.Errorf("%s=loud: err = %v, want an unknown level error", logging.EnvLevel, err)
+	}
+	// The flag takes precedence over the environment
+	if err := run("--log-level", "error"); err != nil {
+		t.Errorf("--log-level error with a bad %s: %v", logging.EnvLevel, err)
+	}
+}
+
 // === Index Command Tests ===
 
 func TestIndexCmdSuccess(t *testing.T) {
@@ -204,6 +238,36 @@ func TestQueryCmdWithRepo(t *testing.T) {
 	}
 }
 
+func TestQueryCmdTrace(t *testing.T) {
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\nfunc main() {}\n"), 0644)
+	tracePath := filepath.Join(t.TempDir(), "trace.json")
+	t.Setenv("OPENAI_API_KEY", "")
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"query", "what is main?", "--repo", repoDir, "--cache-dir", t.TempDir(), "--no-embeddings", "--trace", tracePath})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("query cmd with trace: %v", err)
+	}
+
+	data, err := os.ReadFile(tracePath)
+	if err != nil {
+		t.Fatalf("trace file: %v", err)
+	}
+	var trace struct {
+		Query  string            `json:"query"`
+		Mode   string            `json:"mode"`
+		Rounds []json.RawMessage `json:"rounds"`
+	}
+	if err := json.Unmarshal(data, &trace); err != nil {
+		t.Fatalf("trace is not JSON: %v\n%s", err, data)
+	}
+	// Direct search runs no agent rounds
+	if trace.Query != "what is main?" || trace.Mode != "direct" || trace.Rounds == nil || len(trace.Rounds) != 0 {
+		t.Errorf("trace = %s", data)
+	}
+}
+
 func TestQueryCmdMultiWordQuestion(t *testing.T) {
 	repoDir, _ := os.MkdirTemp("", "fastcode-qry-multi-*")
 	defer os.RemoveAll(repoDir)
@@ -233,13 +297,30 @@ func TestQueryCmdNoIndex(t *testing.T) {
 	defer os.Setenv("OPENAI_API_KEY", origKey)
 
 	cmd := buildRootCmd()
-	cmd.SetArgs([]string{"query", "test question", "--cache-dir", cacheDir, "--no-embeddings"})
+	cmd.SetArgs([]string{"query", "test question", "--cache-dir", cacheDir, "--no-embeddings", "--no-auto-index"})
 	err := cmd.Execute()
 	if err == nil {
 		t.Error("expected error when querying without index")
 	}
 }
 
+func TestQueryCmdAutoIndexesCurrentDir(t *testing.T) {
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\nfunc main() {}\n"), 0644)
+	cacheDir := t.TempDir()
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Chdir(repoDir)
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"query", "what is main?", "--cache-dir", cacheDir, "--no-embeddings"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("query in an unindexed directory: %v", err)
+	}
+	if entries, _ := os.ReadDir(cacheDir); len(entries) == 0 {
+		t.Error("query did not cache the index of the current directory")
+	}
+}
+
 func TestQueryCmdInvalidRepo(t *testing.T) {
 	cacheDir, _ := os.MkdirTemp("", "fastcode-qry-err-cache-*")
 	defer os.RemoveAll(cacheDir)
@@ -312,6 +393,37 @@ func TestPersistentFlagsEmbeddingModel(t *testing.T) {
 	}
 }
 
+func TestPersistentFlagsBM25(t *testing.T) {
+	run := func(args ...string) error {
+		cmd := buildRootCmd()
+		cmd.SetOut(new(bytes.Buffer))
+		cmd.SetErr(new(bytes.Buffer))
+		cmd.SetArgs(append([]string{"list", "--cache-dir", t.TempDir()}, args...))
+		return cmd.Execute()
+	}
+
+	if err := run("--bm25-k1", "1.2", "--bm25-b", "0.5"); err != nil {
+		t.Errorf("valid BM25 parameters: %v", err)
+	}
+	if err := run("--bm25-k1", "-1"); err == nil || !strings.Contains(err.Error(), "--bm25-k1") {
+		t.Errorf("--bm25-k1 -1: err = %v, want a range error", err)
+	}
+	for _, b := range []string{"1.5", "0"} {
+		if err := run("--bm25-b", b); err == nil || !strings.Contains(err.Error(), "--bm25-b") {
+			t.Errorf("--bm25-b %s: err = %v, want a range error", b, err)
+		}
+	}
+	if err := run("--bm25-k1", "0"); err == nil || !strings.Contains(err.Error(), "--bm25-k1") {
+		t.Errorf("--bm25-k1 0: err = %v, want a range error", err)
+	}
+	if err := run("--bm25-field-boost", "2"); err != nil {
+		t.Errorf("valid field boost: %v", err)
+	}
+	if err := run("--bm25-field-boost", "0.5"); err == nil || !strings.Contains(err.Error(), "--bm25-field-boost") {
+		t.Errorf("--bm25-field-boost 0.5: err = %v, want a range error", err)
+	}
+}
+
 func TestPersistentFlagsNoEmbeddings(t *testing.T) {
 	cmd := buildRootCmd()
 	flag := cmd.PersistentFlags().Lookup("no-embeddings")
@@ -344,6 +456,61 @@ func TestIndexFlagJSON(t *testing.T) {
 	}
 }
 
+func TestIndexFlagsIncludeExclude(t *testing.T) {
+	cmd := buildRootCmd()
+	indexCmd, _, _ := cmd.Find([]string{"index"})
+	for _, name := range []string{"include", "exclude"} {
+		if indexCmd.Flags().Lookup(name) == nil {
+			t.Errorf("%s flag not found on index command", name)
+		}
+	}
+}
+
+func TestIndexFlagJSArrowFunctions(t *testing.T) {
+	cmd := buildRootCmd()
+	indexCmd, _, _ := cmd.Find([]string{"index"})
+	flag := indexCmd.Flags().Lookup("js-arrow-functions")
+	if flag == nil {
+		t.Fatal("js-arrow-functions flag not found on index command")
+	}
+	if flag.DefValue != "false" {
+		t.Errorf("js-arrow-functions default = %q, want false", flag.DefValue)
+	}
+}
+
+func TestIndexFlagMarkdownSections(t *testing.T) {
+	cmd := buildRootCmd()
+	indexCmd, _, _ := cmd.Find([]string{"index"})
+	if flag := indexCmd.Flags().Lookup("markdown-sections"); flag == nil || flag.DefValue != "false" {
+		t.Errorf("markdown-sections flag = %+v, want default false", flag)
+	}
+}
+
+func TestIndexCmdPlan(t *testing.T) {
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\nfunc main() {}\n"), 0644)
+	cacheDir := t.TempDir()
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"index", repoDir, "--plan", "--cache-dir", cacheDir, "--no-embeddings"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("index --plan: %v", err)
+	}
+	// A plan must not parse the repository or write its index
+	if entries, _ := os.ReadDir(cacheDir); len(entries) != 0 {
+		t.Errorf("index --plan wrote %d cache entries", len(entries))
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{512: "512 B", 2048: "2.0 KB", 3 * 1024 * 1024: "3.0 MB"}
+	for n, want := range cases {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
 func TestQueryFlagRepo(t *testing.T) {
 	cmd := buildRootCmd()
 	queryCmd, _, _ := cmd.Find([]string{"query"})
@@ -356,6 +523,90 @@ func TestQueryFlagRepo(t *testing.T) {
 	}
 }
 
+func TestQueryFlagTopK(t *testing.T) {
+	cmd := buildRootCmd()
+	queryCmd, _, _ := cmd.Find([]string{"query"})
+	flag := queryCmd.Flags().Lookup("top-k")
+	if flag == nil {
+		t.Fatal("top-k flag not found on query command")
+	}
+	if flag.DefValue != "10" {
+		t.Errorf("top-k default = %s, want 10", flag.DefValue)
+	}
+
+	cmd = buildRootCmd()
+	cmd.SetArgs([]string{"query", "--top-k", "0", "anything"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--top-k must be positive") {
+		t.Errorf("expected --top-k validation error, got %v", err)
+	}
+}
+
+func TestFindCmdInvalidType(t *testing.T) {
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"find", t.TempDir(), "main", "--type", "module"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "unknown type") {
+		t.Errorf("expected --type validation error, got %v", err)
+	}
+}
+
+func TestQueryFlagBudget(t *testing.T) {
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"query", "--budget", "words", "anything"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--budget must be lines or tokens") {
+		t.Errorf("expected --budget validation error, got %v", err)
+	}
+}
+
+func TestQueryFlagMinRounds(t *testing.T) {
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"query", "--min-rounds", "-1", "anything"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--min-rounds must not be negative") {
+		t.Errorf("expected --min-rounds validation error, got %v", err)
+	}
+}
+
+func TestQueryFlagMaxAnswerTokens(t *testing.T) {
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"query", "--max-answer-tokens", "-5", "anything"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--max-answer-tokens must not be negative") {
+		t.Errorf("expected --max-answer-tokens validation error, got %v", err)
+	}
+}
+
+func TestQueryFlagFileDeps(t *testing.T) {
+	cmd := buildRootCmd()
+	queryCmd, _, _ := cmd.Find([]string{"query"})
+	for _, name := range []string{"file-deps", "graph-neighbors"} {
+		flag := queryCmd.Flags().Lookup(name)
+		if flag == nil {
+			t.Fatalf("%s flag not found on query command", name)
+		}
+		if flag.DefValue != "false" {
+			t.Errorf("%s default = %q, want false", name, flag.DefValue)
+		}
+	}
+}
+
+func TestQueryFlagVerbosity(t *testing.T) {
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"query", "--verbosity", "verbose", "anything"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "unknown verbosity") {
+		t.Errorf("expected --verbosity validation error, got %v", err)
+	}
+}
+
 func TestServeMCPFlagPort(t *testing.T) {
 	cmd := buildRootCmd()
 	serveCmd, _, _ := cmd.Find([]string{"serve-mcp"})
@@ -369,6 +620,9 @@ func TestServeMCPFlagPort(t *testing.T) {
 	if flag.DefValue != "9999" {
 		t.Errorf("port default = %q, want 9999", flag.DefValue)
 	}
+	if host := serveCmd.Flags().Lookup("host"); host == nil || host.DefValue != "127.0.0.1" {
+		t.Errorf("host flag = %+v, want default 127.0.0.1", host)
+	}
 }
 
 // === Custom Embedding Model Flag ===
@@ -393,5 +647,58 @@ func TestIndexWithCustomEmbeddingModel(t *testing.T) {
 	}
 }
 
-// Note: serveMCP is a thin wrapper around buildMCPMux (100% covered)
-// + http.ListenAndServe which blocks and cannot be unit tested.
+// Note: serveMCP is a thin wrapper around listenMCP, buildMCPMux and
+// runMCPServer (all covered) that also installs the signal handler.
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		done, total int
+		want        string
+	}{
+		{0, 4, "[    ]"},
+		{2, 4, "[==  ]"},
+		{4, 4, "[====]"},
+		{0, 0, "[====]"},
+	}
+	for _, tt := range tests {
+		if got := progressBar(tt.done, tt.total, 4); got != tt.want {
+			t.Errorf("progressBar(%d, %d) = %q, want %q", tt.done, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestKeywordOptions(t *testing.T) {
+	opts := keywordOptions(&config.FastCodeConfig{ExtraStopWords: []string{"repo"}, StemKeywords: true})
+	if !opts.Stem {
+		t.Error("Stem = false, want true")
+	}
+	if n := len(agent.DefaultStopWords()); len(opts.StopWords) != n+1 || opts.StopWords[n] != "repo" {
+		t.Errorf("extra stop words not appended to the defaults: %v", opts.StopWords)
+	}
+
+	opts = keywordOptions(&config.FastCodeConfig{StopWords: []string{"the"}, ExtraStopWords: []string{"repo"}})
+	if fmt.Sprint(opts.StopWords) != "[the repo]" || opts.Stem {
+		t.Errorf("opts = %+v, want stop words [the repo] without stemming", opts)
+	}
+}
+
+func TestIndexCmdSeveralReposNeedWorkspace(t *testing.T) {
+	cmd := buildRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"index", t.TempDir(), t.TempDir(), "--no-embeddings"})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--workspace") {
+		t.Errorf("err = %v, want a hint to use --workspace", err)
+	}
+}
+
+func TestServeMCPRejectsRepoName(t *testing.T) {
+	cmd := buildRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"serve-mcp", "--repo-name", "svc", "--port", "0"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--repo-name") {
+		t.Errorf("err = %v, want --repo-name error", err)
+	}
+}
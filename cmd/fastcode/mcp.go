@@ -1,31 +1,98 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/duyhunghd6/fastcode-cli/internal/agent"
 	"github.com/duyhunghd6/fastcode-cli/internal/orchestrator"
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
 )
 
-// serveMCP starts a JSON-RPC server implementing the Model Context Protocol.
-func serveMCP(cfg orchestrator.Config, port int) error {
-	engine := orchestrator.NewEngine(cfg)
-	mux := buildMCPMux(engine)
+// mcpShutdownTimeout is how long a stopping MCP server waits for requests
+// in flight, e.g. a query waiting on the LLM, before closing them.
+const mcpShutdownTimeout = 30 * time.Second
 
-	addr := fmt.Sprintf(":%d", port)
-	log.Printf("🚀 FastCode MCP server listening on http://localhost%s", addr)
-	log.Printf("   MCP endpoint: http://localhost%s/mcp/", addr)
-	return http.ListenAndServe(addr, mux)
+// serveMCP starts a JSON-RPC server implementing the Model Context Protocol
+// on host:port, keeping up to maxRepos repositories loaded. Port 0 picks a
+// free port; the chosen address is logged. It runs until ctx is cancelled or
+// the process receives SIGINT or SIGTERM, then shuts down gracefully.
+func serveMCP(ctx context.Context, cfg orchestrator.Config, host string, port, maxRepos int) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := listenMCP(host, port)
+	if err != nil {
+		return err
+	}
+	mux := buildMCPMux(newEnginePool(cfg, maxRepos))
+
+	addr := ln.Addr().String()
+	fmt.Fprintf(os.Stderr, "🚀 FastCode MCP server listening on http://%s\n", addr)
+	fmt.Fprintf(os.Stderr, "   MCP endpoint: http://%s/mcp/\n", addr)
+	if os.Getenv(mcpTokenEnv) != "" {
+		fmt.Fprintf(os.Stderr, "   Authentication: bearer token from %s\n", mcpTokenEnv)
+	}
+	return runMCPServer(ctx, ln, mux, mcpShutdownTimeout)
 }
 
-// buildMCPMux creates the HTTP handler mux with all MCP endpoints.
-func buildMCPMux(engine *orchestrator.Engine) *http.ServeMux {
-	mux := http.NewServeMux()
+// runMCPServer serves handler on ln until ctx is done, then stops accepting
+// connections and waits up to timeout for active requests to finish. It
+// returns nil after a clean shutdown.
+func runMCPServer(ctx context.Context, ln net.Listener, handler http.Handler, timeout time.Duration) error {
+	srv := &http.Server{Handler: handler}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Fprintln(os.Stderr, "Shutting down MCP server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("MCP server shutdown timed out, closing open connections", "timeout", timeout, "err", err)
+		srv.Close()
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// listenMCP opens the TCP listener for the MCP server.
+func listenMCP(host string, port int) (net.Listener, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s port %d: %w", host, port, err)
+	}
+	return ln, nil
+}
+
+// buildMCPMux creates the HTTP handler mux with all MCP endpoints. Requests
+// naming a repository use its engine from pool; others use the most recently
+// used one. If FASTCODE_MCP_TOKEN is set, the /mcp/ endpoints require it as a
+// bearer token; /health stays open.
+func buildMCPMux(pool *enginePool) *http.ServeMux {
+	api := http.NewServeMux()
 
 	// MCP initialize
-	mux.HandleFunc("/mcp/initialize", func(w http.ResponseWriter, r *http.Request) {
+	api.HandleFunc("/mcp/initialize", func(w http.ResponseWriter, r *http.Request) {
 		resp := map[string]any{
 			"protocolVersion": "2024-11-05",
 			"serverInfo": map[string]string{
@@ -36,56 +103,22 @@ func buildMCPMux(engine *orchestrator.Engine) *http.ServeMux {
 				"tools": map[string]bool{
 					"listChanged": false,
 				},
+				"resources": map[string]bool{
+					"subscribe":   false,
+					"listChanged": false,
+				},
 			},
 		}
 		writeJSON(w, resp)
 	})
 
 	// MCP tools/list
-	mux.HandleFunc("/mcp/tools/list", func(w http.ResponseWriter, r *http.Request) {
-		tools := []map[string]any{
-			{
-				"name":        "index_repository",
-				"description": "Index a local code repository for querying",
-				"inputSchema": map[string]any{
-					"type": "object",
-					"properties": map[string]any{
-						"path":  map[string]string{"type": "string", "description": "Path to the repository"},
-						"force": map[string]string{"type": "boolean", "description": "Force re-indexing"},
-					},
-					"required": []string{"path"},
-				},
-			},
-			{
-				"name":        "query_codebase",
-				"description": "Ask a question about an indexed codebase",
-				"inputSchema": map[string]any{
-					"type": "object",
-					"properties": map[string]any{
-						"question": map[string]string{"type": "string", "description": "The question to ask"},
-						"repo":     map[string]string{"type": "string", "description": "Repository path (optional if already indexed)"},
-					},
-					"required": []string{"question"},
-				},
-			},
-			{
-				"name":        "search_code",
-				"description": "Search for code elements matching a query",
-				"inputSchema": map[string]any{
-					"type": "object",
-					"properties": map[string]any{
-						"query": map[string]string{"type": "string", "description": "Search query"},
-						"top_k": map[string]string{"type": "integer", "description": "Number of results (default: 10)"},
-					},
-					"required": []string{"query"},
-				},
-			},
-		}
-		writeJSON(w, map[string]any{"tools": tools})
+	api.HandleFunc("/mcp/tools/list", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"tools": mcpTools()})
 	})
 
 	// MCP tools/call
-	mux.HandleFunc("/mcp/tools/call", func(w http.ResponseWriter, r *http.Request) {
+	api.HandleFunc("/mcp/tools/call", func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
 			Name   string         `json:"name"`
 			Params map[string]any `json:"arguments"`
@@ -103,7 +136,7 @@ func buildMCPMux(engine *orchestrator.Engine) *http.ServeMux {
 				writeError(w, "path is required", 400)
 				return
 			}
-			result, err := engine.Index(path, force)
+			result, err := pool.index(path, force)
 			if err != nil {
 				writeError(w, err.Error(), 500)
 				return
@@ -113,17 +146,65 @@ func buildMCPMux(engine *orchestrator.Engine) *http.ServeMux {
 		case "query_codebase":
 			question, _ := req.Params["question"].(string)
 			repo, _ := req.Params["repo"].(string)
+			formatName, _ := req.Params["format"].(string)
+			verbosityName, _ := req.Params["verbosity"].(string)
 			if question == "" {
 				writeError(w, "question is required", 400)
 				return
 			}
+			opts := orchestrator.DefaultQueryOptions()
+			opts.GraphNeighbors, _ = req.Params["graph_neighbors"].(bool)
+			format, err := agent.ParseAnswerFormat(formatName)
+			if err != nil {
+				writeError(w, err.Error(), 400)
+				return
+			}
+			opts.Format = format
+			if opts.Verbosity, err = agent.ParseVerbosity(verbosityName); err != nil {
+				writeError(w, err.Error(), 400)
+				return
+			}
+			engine := pool.current()
 			if repo != "" {
-				if _, err := engine.Index(repo, false); err != nil {
+				if engine, err = pool.engineFor(repo); err != nil {
 					writeError(w, err.Error(), 500)
 					return
 				}
 			}
-			result, err := engine.Query(question)
+			result, err := engine.QueryWithOptions(question, opts)
+			if err != nil {
+				writeError(w, err.Error(), 500)
+				return
+			}
+			writeToolResult(w, result)
+
+		case "search_code":
+			query, _ := req.Params["query"].(string)
+			repo, _ := req.Params["repo"].(string)
+			if query == "" {
+				writeError(w, "query is required", 400)
+				return
+			}
+			topK, offset := 10, 0
+			if v, ok := req.Params["top_k"].(float64); ok {
+				topK = int(v)
+			}
+			if v, ok := req.Params["offset"].(float64); ok {
+				offset = int(v)
+			}
+			if topK < 1 || offset < 0 {
+				writeError(w, "top_k must be positive and offset not negative", 400)
+				return
+			}
+			engine := pool.current()
+			if repo != "" {
+				var err error
+				if engine, err = pool.engineFor(repo); err != nil {
+					writeError(w, err.Error(), 500)
+					return
+				}
+			}
+			result, err := engine.Search(query, offset, topK)
 			if err != nil {
 				writeError(w, err.Error(), 500)
 				return
@@ -135,14 +216,341 @@ func buildMCPMux(engine *orchestrator.Engine) *http.ServeMux {
 		}
 	})
 
-	// Health check
+	// MCP resources/list: every indexed file
+	api.HandleFunc("/mcp/resources/list", func(w http.ResponseWriter, r *http.Request) {
+		resources := []mcpResource{}
+		for _, f := range pool.current().Files() {
+			resources = append(resources, mcpResource{
+				URI:      fileResourceURI(f),
+				Name:     f.RelativePath,
+				MimeType: "text/plain",
+			})
+		}
+		writeJSON(w, map[string]any{"resources": resources})
+	})
+
+	// MCP resources/read: a file's full content
+	api.HandleFunc("/mcp/resources/read", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URI string `json:"uri"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request body", 400)
+			return
+		}
+		for _, f := range pool.current().Files() {
+			if fileResourceURI(f) == req.URI {
+				writeJSON(w, map[string]any{
+					"contents": []map[string]string{
+						{"uri": req.URI, "mimeType": "text/plain", "text": fileResourceText(f)},
+					},
+				})
+				return
+			}
+		}
+		writeError(w, fmt.Sprintf("Unknown resource: %s", req.URI), 404)
+	})
+
+	// MCP prompts/list
+	api.HandleFunc("/mcp/prompts/list", func(w http.ResponseWriter, r *http.Request) {
+		prompts := make([]mcpPrompt, len(mcpPrompts))
+		for i, p := range mcpPrompts {
+			prompts[i] = p.mcpPrompt
+		}
+		writeJSON(w, map[string]any{"prompts": prompts})
+	})
+
+	// MCP prompts/get
+	api.HandleFunc("/mcp/prompts/get", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "Invalid request body", 400)
+			return
+		}
+
+		prompt := findMCPPrompt(req.Name)
+		if prompt == nil {
+			writeError(w, fmt.Sprintf("Unknown prompt: %s", req.Name), 404)
+			return
+		}
+		for _, arg := range prompt.Arguments {
+			if arg.Required && req.Arguments[arg.Name] == "" {
+				writeError(w, fmt.Sprintf("%s is required", arg.Name), 400)
+				return
+			}
+		}
+
+		engine := pool.current()
+		if repo := req.Arguments["repo"]; repo != "" {
+			var err error
+			if engine, err = pool.engineFor(repo); err != nil {
+				writeError(w, err.Error(), 500)
+				return
+			}
+		}
+
+		text := prompt.render(req.Arguments)
+		// Point the client at the most relevant code, found by search alone so
+		// rendering a prompt never runs an LLM query; without an index the
+		// prompt stands alone
+		if engine.Status().Indexed {
+			found, err := engine.Search(prompt.searchQuery(req.Arguments, text), 0, promptContextHits)
+			if err != nil {
+				writeError(w, err.Error(), 500)
+				return
+			}
+			text += promptContext(found)
+		}
+
+		writeJSON(w, map[string]any{
+			"description": prompt.Description,
+			"messages": []map[string]any{
+				{"role": "user", "content": map[string]string{"type": "text", "text": text}},
+			},
+		})
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp/", requireToken(os.Getenv(mcpTokenEnv), api))
+
+	// Health check: "ok" whenever the server is up; "ready" tells whether the
+	// current repository is indexed so queries can be answered
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, map[string]string{"status": "ok", "version": version})
+		status := pool.current().Status()
+		writeJSON(w, struct {
+			Status  string `json:"status"`
+			Version string `json:"version"`
+			Ready   bool   `json:"ready"`
+			Repos   int    `json:"repos_loaded"`
+			orchestrator.EngineStatus
+		}{"ok", version, status.Indexed, pool.loaded(), status})
 	})
 
 	return mux
 }
 
+// mcpResource is a resource definition as returned by resources/list in the MCP spec.
+type mcpResource struct {
+	URI      string `json:"uri"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+}
+
+// fileResourceURI names an indexed file as fastcode://<repo>/<relative-path>.
+func fileResourceURI(f types.CodeElement) string {
+	return "fastcode://" + f.RepoName + "/" + filepath.ToSlash(f.RelativePath)
+}
+
+// fileResourceText returns the content of an indexed file, read from disk
+// so it is complete: the index keeps only the start of large files, which is
+// served, ending in a truncation marker, if the file can no longer be read.
+func fileResourceText(f types.CodeElement) string {
+	if data, err := os.ReadFile(f.FilePath); err == nil {
+		return string(data)
+	}
+	return f.Code
+}
+
+// mcpTool is a tool definition as returned by tools/list in the MCP spec.
+type mcpTool struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	InputSchema jsonSchema `json:"inputSchema"`
+}
+
+// jsonSchema is the subset of JSON Schema used to describe tool arguments.
+type jsonSchema struct {
+	Type                 string                `json:"type"`
+	Description          string                `json:"description,omitempty"`
+	Properties           map[string]jsonSchema `json:"properties,omitempty"`
+	Required             []string              `json:"required,omitempty"`
+	Default              any                   `json:"default,omitempty"`
+	Minimum              *int                  `json:"minimum,omitempty"`
+	AdditionalProperties *bool                 `json:"additionalProperties,omitempty"`
+}
+
+// objectSchema builds an object schema that rejects unknown arguments.
+func objectSchema(properties map[string]jsonSchema, required ...string) jsonSchema {
+	closed := false
+	return jsonSchema{
+		Type:                 "object",
+		Properties:           properties,
+		Required:             required,
+		AdditionalProperties: &closed,
+	}
+}
+
+// mcpTools returns the tools advertised by tools/list.
+func mcpTools() []mcpTool {
+	minTopK, minOffset := 1, 0
+	return []mcpTool{
+		{
+			Name:        "index_repository",
+			Description: "Index a local code repository for querying",
+			InputSchema: objectSchema(map[string]jsonSchema{
+				"path":  {Type: "string", Description: "Path to the repository"},
+				"force": {Type: "boolean", Description: "Force re-indexing", Default: false},
+			}, "path"),
+		},
+		{
+			Name:        "query_codebase",
+			Description: "Ask a question about an indexed codebase",
+			InputSchema: objectSchema(map[string]jsonSchema{
+				"question":        {Type: "string", Description: "The question to ask"},
+				"repo":            {Type: "string", Description: "Repository path (optional if already indexed)"},
+				"format":          {Type: "string", Description: "Answer layout: markdown, plain or bullet", Default: "markdown"},
+				"verbosity":       {Type: "string", Description: "Answer length: brief, normal or detailed", Default: "normal"},
+				"graph_neighbors": {Type: "boolean", Description: "Add the direct graph neighbors (callers, callees, ...) of the most relevant code found", Default: false},
+			}, "question"),
+		},
+		{
+			Name:        "search_code",
+			Description: "Search for code elements matching a query",
+			InputSchema: objectSchema(map[string]jsonSchema{
+				"query":  {Type: "string", Description: "Search query"},
+				"top_k":  {Type: "integer", Description: "Number of results per page", Default: 10, Minimum: &minTopK},
+				"offset": {Type: "integer", Description: "Rank of the first result, e.g. next_offset of the previous page", Default: 0, Minimum: &minOffset},
+				"repo":   {Type: "string", Description: "Repository path (optional if already indexed)"},
+			}, "query"),
+		},
+	}
+}
+
+// mcpPrompt is a prompt definition as returned by prompts/list in the MCP spec.
+type mcpPrompt struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Arguments   []mcpPromptArgument `json:"arguments,omitempty"`
+}
+
+// mcpPromptArgument describes one argument accepted by a prompt.
+type mcpPromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+// promptTemplate pairs a prompt definition with a function filling in its arguments.
+type promptTemplate struct {
+	mcpPrompt
+	render func(args map[string]string) string
+}
+
+var repoPromptArg = mcpPromptArgument{Name: "repo", Description: "Repository path (optional if already indexed)"}
+
+// searchQuery returns the text prompts/get searches the index with: the
+// prompt's own arguments, which name what the user is after, or the rendered
+// prompt when it takes none besides the repository.
+func (p *promptTemplate) searchQuery(args map[string]string, rendered string) string {
+	var terms []string
+	for _, arg := range p.Arguments {
+		if arg.Name != repoPromptArg.Name && args[arg.Name] != "" {
+			terms = append(terms, args[arg.Name])
+		}
+	}
+	if len(terms) == 0 {
+		return rendered
+	}
+	return strings.Join(terms, " ")
+}
+
+// promptContextHits is how many search hits prompts/get lists with a prompt.
+const promptContextHits = 8
+
+// promptContext lists the locations of search hits to append to a prompt,
+// or returns "" when there are none.
+func promptContext(found *orchestrator.SearchResult) string {
+	if len(found.Results) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nContext from FastCode (most relevant indexed code):\n")
+	for _, hit := range found.Results {
+		sb.WriteString(fmt.Sprintf("- %s:%d-%d %s %s\n", hit.RelativePath, hit.StartLine, hit.EndLine, hit.Type, hit.Name))
+	}
+	return sb.String()
+}
+
+// mcpPrompts are the canned prompts served by prompts/list and prompts/get.
+var mcpPrompts = []promptTemplate{
+	{
+		mcpPrompt: mcpPrompt{
+			Name:        "explain_file",
+			Description: "Explain what a file does and how it fits into the codebase",
+			Arguments: []mcpPromptArgument{
+				{Name: "file", Description: "Path of the file relative to the repository root", Required: true},
+				repoPromptArg,
+			},
+		},
+		render: func(args map[string]string) string {
+			return fmt.Sprintf("Explain what the file %s does: its main types and functions, "+
+				"what it depends on, and how the rest of the codebase uses it.", args["file"])
+		},
+	},
+	{
+		mcpPrompt: mcpPrompt{
+			Name:        "find_bug",
+			Description: "Locate the likely cause of a described bug",
+			Arguments: []mcpPromptArgument{
+				{Name: "description", Description: "Symptoms of the bug", Required: true},
+				{Name: "file", Description: "File where the bug is suspected (optional)"},
+				repoPromptArg,
+			},
+		},
+		render: func(args map[string]string) string {
+			q := fmt.Sprintf("Find the bug causing the following behaviour: %s.", args["description"])
+			if args["file"] != "" {
+				q += fmt.Sprintf(" Start by looking at %s.", args["file"])
+			}
+			return q + " Point to the responsible code and suggest a fix."
+		},
+	},
+	{
+		mcpPrompt: mcpPrompt{
+			Name:        "summarize_architecture",
+			Description: "Summarize the overall architecture of the repository",
+			Arguments:   []mcpPromptArgument{repoPromptArg},
+		},
+		render: func(args map[string]string) string {
+			return "Give an overview of the architecture of this codebase: its main packages or modules, " +
+				"their responsibilities, and how they interact."
+		},
+	},
+}
+
+// findMCPPrompt returns the prompt with the given name, or nil.
+func findMCPPrompt(name string) *promptTemplate {
+	for i := range mcpPrompts {
+		if mcpPrompts[i].Name == name {
+			return &mcpPrompts[i]
+		}
+	}
+	return nil
+}
+
+// mcpTokenEnv names the environment variable holding the MCP bearer token.
+const mcpTokenEnv = "FASTCODE_MCP_TOKEN"
+
+// requireToken rejects requests without an "Authorization: Bearer <token>"
+// header with 401. An empty token disables the check.
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fastcode"`)
+			writeError(w, "missing or invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func writeJSON(w http.ResponseWriter, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/orchestrator"
 )
@@ -123,7 +128,7 @@ func main() {
 		BatchSize:    32,
 		NoEmbeddings: true,
 	}
-	handler := buildMCPMux(orchestrator.NewEngine(cfg))
+	handler := buildMCPMux(newEnginePool(cfg, 4))
 	server := httptest.NewServer(handler)
 
 	cleanup := func() {
@@ -201,6 +206,64 @@ func TestMCPToolsList(t *testing.T) {
 	}
 }
 
+func TestMCPToolsInputSchema(t *testing.T) {
+	server, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	resp, err := http.Get(server.URL + "/mcp/tools/list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Tools []struct {
+			Name        string `json:"name"`
+			InputSchema struct {
+				Type       string `json:"type"`
+				Properties map[string]struct {
+					Type string `json:"type"`
+				} `json:"properties"`
+				Required []string `json:"required"`
+			} `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode tools/list: %v", err)
+	}
+
+	want := map[string]struct {
+		props    map[string]string
+		required string
+	}{
+		"index_repository": {map[string]string{"path": "string", "force": "boolean"}, "path"},
+		"query_codebase":   {map[string]string{"question": "string", "repo": "string", "graph_neighbors": "boolean"}, "question"},
+		"search_code":      {map[string]string{"query": "string", "top_k": "integer"}, "query"},
+	}
+	for _, tool := range result.Tools {
+		w, ok := want[tool.Name]
+		if !ok {
+			continue
+		}
+		schema := tool.InputSchema
+		if schema.Type != "object" {
+			t.Errorf("%s: schema type = %q, want object", tool.Name, schema.Type)
+		}
+		for prop, typ := range w.props {
+			if got := schema.Properties[prop].Type; got != typ {
+				t.Errorf("%s.%s: type = %q, want %q", tool.Name, prop, got, typ)
+			}
+		}
+		if len(schema.Required) != 1 || schema.Required[0] != w.required {
+			t.Errorf("%s: required = %v, want [%s]", tool.Name, schema.Required, w.required)
+		}
+		delete(want, tool.Name)
+	}
+	for name := range want {
+		t.Errorf("missing tool: %s", name)
+	}
+}
+
 func TestMCPHealth(t *testing.T) {
 	server, _, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -215,7 +278,7 @@ func TestMCPHealth(t *testing.T) {
 		t.Errorf("status = %d, want 200", resp.StatusCode)
 	}
 
-	var result map[string]string
+	var result map[string]any
 	json.NewDecoder(resp.Body).Decode(&result)
 	if result["status"] != "ok" {
 		t.Errorf("status = %q, want ok", result["status"])
@@ -223,6 +286,12 @@ func TestMCPHealth(t *testing.T) {
 	if result["version"] != version {
 		t.Errorf("version = %q, want %s", result["version"], version)
 	}
+	if result["ready"] != false || result["indexed"] != false || result["repos_loaded"] != 0.0 {
+		t.Errorf("before indexing: %v", result)
+	}
+	if _, ok := result["llm_configured"].(bool); !ok {
+		t.Errorf("llm_configured missing: %v", result)
+	}
 }
 
 func TestMCPToolsCallIndexRepository(t *testing.T) {
@@ -248,6 +317,114 @@ func TestMCPToolsCallIndexRepository(t *testing.T) {
 	}
 }
 
+func TestMCPResources(t *testing.T) {
+	server, repoDir, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	// A file longer than the content kept in the index is still read whole
+	mainPath := filepath.Join(repoDir, "main.go")
+	code, _ := os.ReadFile(mainPath)
+	code = append(code, "\n// "+strings.Repeat("long comment ", 500)+"\n"...)
+	os.WriteFile(mainPath, code, 0644)
+
+	body := fmt.Sprintf(`{"name":"index_repository","arguments":{"path":"%s","force":true}}`, repoDir)
+	resp, err := http.Post(server.URL+"/mcp/tools/call", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/mcp/resources/list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list struct {
+		Resources []mcpResource `json:"resources"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list.Resources) != 1 || list.Resources[0].Name != "main.go" {
+		t.Fatalf("resources = %+v, want main.go", list.Resources)
+	}
+	uri := list.Resources[0].URI
+	if !strings.HasPrefix(uri, "fastcode://") || !strings.HasSuffix(uri, "/main.go") {
+		t.Errorf("uri = %q", uri)
+	}
+
+	resp, err = http.Post(server.URL+"/mcp/resources/read", "application/json", strings.NewReader(fmt.Sprintf(`{"uri":%q}`, uri)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read struct {
+		Contents []map[string]string `json:"contents"`
+	}
+	json.NewDecoder(resp.Body).Decode(&read)
+	resp.Body.Close()
+	if len(read.Contents) != 1 || read.Contents[0]["text"] != string(code) {
+		t.Errorf("contents = %+v, want the file's full code", read.Contents)
+	}
+
+	resp, err = http.Post(server.URL+"/mcp/resources/read", "application/json", strings.NewReader(`{"uri":"fastcode://nope/x.go"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 404 {
+		t.Errorf("unknown resource: status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestMCPConcurrentIndexAndQuery(t *testing.T) {
+	server, repoDir, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	call := func(body string) {
+		resp, err := http.Post(server.URL+"/mcp/tools/call", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	}
+	index := fmt.Sprintf(`{"name":"index_repository","arguments":{"path":"%s","force":true}}`, repoDir)
+	call(index)
+
+	// Reindexing while querying must not race (run with -race)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() { defer wg.Done(); call(index) }()
+		go func() { defer wg.Done(); call(`{"name":"query_codebase","arguments":{"question":"Server Start"}}`) }()
+		go func() {
+			defer wg.Done()
+			if resp, err := http.Get(server.URL + "/mcp/resources/list"); err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMCPToolsCallSearchInvalid(t *testing.T) {
+	server, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	for _, body := range []string{
+		`{"name":"search_code","arguments":{}}`,
+		`{"name":"search_code","arguments":{"query":"Start","offset":-1}}`,
+		`{"name":"search_code","arguments":{"query":"Start","top_k":0}}`,
+	} {
+		resp, err := http.Post(server.URL+"/mcp/tools/call", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 400 {
+			t.Errorf("%s: status = %d, want 400", body, resp.StatusCode)
+		}
+	}
+}
+
 func TestMCPToolsCallIndexMissingPath(t *testing.T) {
 	server, _, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -330,6 +507,41 @@ func TestMCPToolsCallQueryWithRepo(t *testing.T) {
 	}
 }
 
+func TestMCPToolsCallQueryReportsDirectMode(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	server, repoDir, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	body := fmt.Sprintf(`{"name":"query_codebase","arguments":{"question":"what functions exist?","repo":"%s"}}`, repoDir)
+	resp, err := http.Post(server.URL+"/mcp/tools/call", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var out struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || len(out.Content) == 0 {
+		t.Fatalf("decode response: %v", err)
+	}
+	var result struct {
+		Mode    string `json:"mode"`
+		Warning string `json:"warning"`
+	}
+	if err := json.Unmarshal([]byte(out.Content[0].Text), &result); err != nil {
+		t.Fatalf("decode query result: %v", err)
+	}
+	if result.Mode != "direct" || result.Warning == "" {
+		t.Errorf("mode = %q, warning = %q; want direct mode with a warning", result.Mode, result.Warning)
+	}
+}
+
 func TestMCPToolsCallQueryWithInvalidRepo(t *testing.T) {
 	server, _, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -346,6 +558,131 @@ func TestMCPToolsCallQueryWithInvalidRepo(t *testing.T) {
 	}
 }
 
+func TestMCPBearerToken(t *testing.T) {
+	t.Setenv(mcpTokenEnv, "s3cret")
+	server, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	get := func(path, auth string) int {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := get("/mcp/tools/list", ""); code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want 401", code)
+	}
+	if code := get("/mcp/tools/list", "Bearer wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", code)
+	}
+	if code := get("/mcp/tools/list", "Bearer s3cret"); code != http.StatusOK {
+		t.Errorf("valid token: status = %d, want 200", code)
+	}
+	if code := get("/health", ""); code != http.StatusOK {
+		t.Errorf("health without token: status = %d, want 200", code)
+	}
+}
+
+func TestListenMCPFreePort(t *testing.T) {
+	ln, err := listenMCP("127.0.0.1", 0)
+	if err != nil {
+		t.Fatalf("listenMCP: %v", err)
+	}
+	defer ln.Close()
+	addr := ln.Addr().(*net.TCPAddr)
+	if !addr.IP.IsLoopback() || addr.Port == 0 {
+		t.Errorf("listening on %s, want a loopback address with a chosen port", addr)
+	}
+
+	if _, err := listenMCP("127.0.0.1", addr.Port); err == nil {
+		t.Error("expected an error listening on a port already in use")
+	}
+}
+
+func TestRunMCPServerShutdown(t *testing.T) {
+	ln, err := listenMCP("127.0.0.1", 0)
+	if err != nil {
+		t.Fatalf("listenMCP: %v", err)
+	}
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- runMCPServer(ctx, ln, handler, 5*time.Second) }()
+
+	type reply struct {
+		body string
+		err  error
+	}
+	replies := make(chan reply, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			replies <- reply{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		replies <- reply{string(body), err}
+	}()
+
+	// Shutting down while the request is in flight lets it finish
+	<-started
+	cancel()
+	if r := <-replies; r.err != nil || r.body != "done" {
+		t.Errorf("in-flight request = %q, %v; want it to complete", r.body, r.err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("runMCPServer = %v, want nil after a clean shutdown", err)
+	}
+	if _, err := http.Get("http://" + ln.Addr().String() + "/slow"); err == nil {
+		t.Error("expected the server to stop accepting requests")
+	}
+}
+
+func TestMCPToolsCallQueryInvalidFormat(t *testing.T) {
+	server, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	body := `{"name":"query_codebase","arguments":{"question":"test","format":"html"}}`
+	resp, err := http.Post(server.URL+"/mcp/tools/call", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 400 {
+		t.Errorf("status = %d, want 400 for an unknown answer format", resp.StatusCode)
+	}
+}
+
+func TestMCPToolsCallQueryInvalidVerbosity(t *testing.T) {
+	server, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	body := `{"name":"query_codebase","arguments":{"question":"test","verbosity":"verbose"}}`
+	resp, err := http.Post(server.URL+"/mcp/tools/call", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 400 {
+		t.Errorf("status = %d, want 400 for an unknown verbosity", resp.StatusCode)
+	}
+}
+
 func TestMCPToolsCallUnknownTool(t *testing.T) {
 	server, _, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -432,3 +769,95 @@ func TestMCPToolsCallIndexWithForce(t *testing.T) {
 		t.Errorf("force reindex status = %d", resp2.StatusCode)
 	}
 }
+
+func TestMCPPromptsList(t *testing.T) {
+	server, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	resp, err := http.Get(server.URL + "/mcp/prompts/list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Prompts []mcpPrompt `json:"prompts"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	names := make(map[string]bool)
+	for _, p := range result.Prompts {
+		names[p.Name] = true
+	}
+	for _, expected := range []string{"explain_file", "find_bug", "summarize_architecture"} {
+		if !names[expected] {
+			t.Errorf("missing prompt: %s", expected)
+		}
+	}
+}
+
+func TestMCPPromptsGet(t *testing.T) {
+	server, repoDir, cleanup := setupTestServer(t)
+	defer cleanup()
+	// A few more files so search can tell main.go apart from the rest
+	for _, name := range []string{"config", "handler", "store"} {
+		src := fmt.Sprintf("package %s\n\nfunc Setup() int {\n\treturn 1\n}\n", name)
+		os.MkdirAll(filepath.Join(repoDir, name), 0755)
+		os.WriteFile(filepath.Join(repoDir, name, name+".go"), []byte(src), 0644)
+	}
+
+	body := fmt.Sprintf(`{"name":"explain_file","arguments":{"file":"main.go","repo":%q}}`, repoDir)
+	resp, err := http.Post(server.URL+"/mcp/prompts/get", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var result struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if len(result.Messages) != 1 || result.Messages[0].Role != "user" {
+		t.Fatalf("unexpected messages: %+v", result.Messages)
+	}
+	text := result.Messages[0].Content.Text
+	if !strings.Contains(text, "main.go") {
+		t.Errorf("prompt should have the file argument filled in: %q", text)
+	}
+	_, context, ok := strings.Cut(text, "Context from FastCode")
+	if !ok || !strings.Contains(context, "- main.go:") {
+		t.Errorf("prompt should list matching code from the index: %q", text)
+	}
+}
+
+func TestMCPPromptsGetErrors(t *testing.T) {
+	server, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cases := []struct {
+		body string
+		want int
+	}{
+		{`{"name":"explain_file","arguments":{}}`, 400},
+		{`{"name":"no_such_prompt"}`, 404},
+		{`not json`, 400},
+	}
+	for _, c := range cases {
+		resp, err := http.Post(server.URL+"/mcp/prompts/get", "application/json", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.want {
+			t.Errorf("%s: status = %d, want %d", c.body, resp.StatusCode, c.want)
+		}
+	}
+}
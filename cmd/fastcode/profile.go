@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// profiler writes the pprof profiles requested with the hidden --profile and
+// --memprofile flags: a CPU profile covering the command and a heap profile
+// taken when it finishes.
+type profiler struct {
+	cpuFile *os.File
+	memPath string
+}
+
+// startProfiler starts CPU profiling into cpuPath and arranges for a heap
+// profile to be written to memPath on stop. Empty paths disable either one.
+func startProfiler(cpuPath, memPath string) (*profiler, error) {
+	p := &profiler{memPath: memPath}
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, fmt.Errorf("create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("start CPU profile: %w", err)
+		}
+		p.cpuFile = f
+	}
+	return p, nil
+}
+
+// stop ends CPU profiling and writes the heap profile. It is safe to call on
+// a nil profiler and more than once.
+func (p *profiler) stop() error {
+	if p == nil {
+		return nil
+	}
+	var errs []error
+	if p.cpuFile != nil {
+		pprof.StopCPUProfile()
+		errs = append(errs, p.cpuFile.Close())
+		p.cpuFile = nil
+	}
+	if p.memPath != "" {
+		errs = append(errs, writeHeapProfile(p.memPath))
+		p.memPath = ""
+	}
+	return errors.Join(errs...)
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create memory profile: %w", err)
+	}
+	defer f.Close()
+	runtime.GC() // report up-to-date allocation statistics
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("write memory profile: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+	dir := t.TempDir()
+	cpu, mem := filepath.Join(dir, "cpu.prof"), filepath.Join(dir, "mem.prof")
+	p, err := startProfiler(cpu, mem)
+	if err != nil {
+		t.Fatalf("startProfiler: %v", err)
+	}
+	if err := p.stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if err := p.stop(); err != nil {
+		t.Errorf("second stop: %v", err)
+	}
+	for _, path := range []string{cpu, mem} {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("profile %s not written: %v", filepath.Base(path), err)
+		}
+	}
+
+	if _, err := startProfiler(filepath.Join(dir, "missing", "cpu.prof"), ""); err == nil {
+		t.Error("expected an error for an uncreatable profile path")
+	}
+	if err := (*profiler)(nil).stop(); err != nil {
+		t.Errorf("nil profiler stop: %v", err)
+	}
+}
+
+func TestProfileFlagsHidden(t *testing.T) {
+	cmd := buildRootCmd()
+	for _, name := range []string{"profile", "memprofile"} {
+		flag := cmd.PersistentFlags().Lookup(name)
+		if flag == nil || !flag.Hidden {
+			t.Errorf("%s flag = %+v, want a hidden persistent flag", name, flag)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/duyhunghd6/fastcode-cli/internal/index"
 	"github.com/duyhunghd6/fastcode-cli/internal/llm"
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
 )
@@ -11,11 +12,124 @@ import (
 // AnswerGenerator uses gathered context and an LLM to generate answers.
 type AnswerGenerator struct {
 	client *llm.Client
+
+	// FewShotExamples maps a query type ("debug", "howto", ...) to an example
+	// exchange included in the prompt. Replace or delete entries to override
+	// the defaults; query types without an entry get no examples.
+	FewShotExamples map[string]string
+
+	// Format selects the answer layout (default: AnswerFormatMarkdown).
+	Format AnswerFormat
+
+	// Verbosity selects how long an answer the model is asked for
+	// (default: VerbosityNormal).
+	Verbosity Verbosity
+
+	// MaxAnswerTokens caps the tokens the model may generate. 0 uses the
+	// limit of the verbosity level.
+	MaxAnswerTokens int
+
+	// RedactSecrets masks what looks like a secret (see llm.RedactSecrets)
+	// in the code put in the prompt (default: true).
+	RedactSecrets bool
+
+	// History holds the earlier turns of the conversation, put before the
+	// question so follow-ups can refer to them.
+	History []ConversationTurn
+}
+
+// Verbosity is the requested length of a generated answer.
+type Verbosity string
+
+// Supported verbosity levels.
+const (
+	VerbosityBrief    Verbosity = "brief"    // a few sentences for quick lookups
+	VerbosityNormal   Verbosity = "normal"   // the model's usual length
+	VerbosityDetailed Verbosity = "detailed" // in-depth, e.g. for architecture questions
+)
+
+// verbosityInstructions tells the model how long to make the answer. Normal
+// answers get no extra instruction.
+var verbosityInstructions = map[Verbosity]string{
+	VerbosityBrief:    " Keep the answer brief: a few sentences that answer the question directly, citing only the most relevant file and lines.",
+	VerbosityNormal:   "",
+	VerbosityDetailed: " Give a detailed answer: explain the overall design, how the relevant components interact and the flow of control, citing files and line ranges throughout.",
+}
+
+// verbosityMaxTokens is the generation limit of each verbosity level.
+var verbosityMaxTokens = map[Verbosity]int{
+	VerbosityBrief:    1000,
+	VerbosityNormal:   20000,
+	VerbosityDetailed: 32000,
+}
+
+// ParseVerbosity validates a verbosity name; "" selects normal.
+func ParseVerbosity(name string) (Verbosity, error) {
+	if name == "" {
+		return VerbosityNormal, nil
+	}
+	verbosity := Verbosity(strings.ToLower(name))
+	if _, ok := verbosityInstructions[verbosity]; !ok {
+		return "", fmt.Errorf("unknown verbosity %q (want brief, normal or detailed)", name)
+	}
+	return verbosity, nil
+}
+
+// AnswerFormat is the layout requested for a generated answer.
+type AnswerFormat string
+
+// Supported answer formats.
+const (
+	AnswerFormatMarkdown AnswerFormat = "markdown" // Markdown with fenced code blocks
+	AnswerFormatPlain    AnswerFormat = "plain"    // plain text for terminals
+	AnswerFormatBullet   AnswerFormat = "bullet"   // a terse bullet summary
+)
+
+// answerFormatInstructions tells the model how to lay out each format.
+var answerFormatInstructions = map[AnswerFormat]string{
+	AnswerFormatMarkdown: "Format the answer as Markdown and put code in fenced code blocks tagged with their language.",
+	AnswerFormatPlain:    "Format the answer as plain text without any Markdown syntax (no headings, bold text or code fences); indent code by four spaces instead.",
+	AnswerFormatBullet:   "Answer with a terse bullet list of at most seven one-line items, each naming the relevant file and lines, with no introduction, conclusion or code blocks.",
+}
+
+// ParseAnswerFormat validates a format name; "" selects markdown.
+func ParseAnswerFormat(name string) (AnswerFormat, error) {
+	if name == "" {
+		return AnswerFormatMarkdown, nil
+	}
+	format := AnswerFormat(strings.ToLower(name))
+	if _, ok := answerFormatInstructions[format]; !ok {
+		return "", fmt.Errorf("unknown answer format %q (want markdown, plain or bullet)", name)
+	}
+	return format, nil
 }
 
 // NewAnswerGenerator creates a new answer generator.
 func NewAnswerGenerator(client *llm.Client) *AnswerGenerator {
-	return &AnswerGenerator{client: client}
+	return &AnswerGenerator{
+		client:          client,
+		FewShotExamples: DefaultFewShotExamples(),
+		RedactSecrets:   true,
+	}
+}
+
+// DefaultFewShotExamples returns the built-in answer exemplars per query type.
+func DefaultFewShotExamples() map[string]string {
+	return map[string]string{
+		"debug": `Question: Why does login fail with "token expired" right after signing in?
+Answer: Tokens are checked by validateToken in auth/session.go (lines 42-58), which compares exp against time.Now().Unix(), but issueToken in auth/issue.go sets exp in milliseconds.
+1. Symptom: every freshly issued token is treated as expired.
+2. Root cause: unit mismatch between issuer and validator.
+3. Fix: issue tokens with time.Now().Add(ttl).Unix().
+4. Verify: add a test that validates a token immediately after issuing it.`,
+
+		"howto": `Question: How do I add a new CLI subcommand?
+Answer: Subcommands are registered in cmd/app/main.go via rootCmd.AddCommand.
+1. Create cmd/app/export.go with an exportCmd() constructor, following queryCmd in cmd/app/query.go.
+2. Define the flags inside the constructor and read them in RunE.
+3. Register it with rootCmd.AddCommand(exportCmd()).
+4. Run "app export --help" to confirm it is wired up.`,
+	}
 }
 
 // GenerateAnswer produces a natural-language answer given the query and retrieved context.
@@ -27,7 +141,7 @@ func (ag *AnswerGenerator) GenerateAnswer(query string, pq *ProcessedQuery, elem
 
 	answer, err := ag.client.ChatCompletion([]llm.ChatMessage{
 		{Role: "user", Content: fullPrompt},
-	}, 0.4, 20000)
+	}, 0.4, ag.maxTokens())
 	if err != nil {
 		return "", fmt.Errorf("generate answer: %w", err)
 	}
@@ -38,6 +152,9 @@ func (ag *AnswerGenerator) GenerateAnswer(query string, pq *ProcessedQuery, elem
 func (ag *AnswerGenerator) buildPrompt(query string, pq *ProcessedQuery, elements []types.CodeElement) string {
 	var sb strings.Builder
 
+	if history := formatHistory(ag.History); history != "" {
+		sb.WriteString(history + "\n")
+	}
 	sb.WriteString(fmt.Sprintf("**Current Question**: %s\n", query))
 
 	sb.WriteString("\n**Relevant Code Context**:\n\n")
@@ -69,7 +186,10 @@ func (ag *AnswerGenerator) buildPrompt(query string, pq *ProcessedQuery, element
 		if elem.Code != "" {
 			code := elem.Code
 			if len(code) > 100000 {
-				code = code[:100000] + "\n... (truncated)"
+				code = code[:100000] + index.TruncatedMarker
+			}
+			if ag.RedactSecrets {
+				code = llm.RedactSecrets(code)
 			}
 			sb.WriteString(fmt.Sprintf("**Code**:\n```%s\n%s\n```\n", elem.Language, code))
 		}
@@ -89,12 +209,41 @@ func (ag *AnswerGenerator) buildPrompt(query string, pq *ProcessedQuery, element
 		}
 	}
 
+	if pq != nil {
+		if example := ag.FewShotExamples[pq.QueryType]; example != "" {
+			sb.WriteString(fmt.Sprintf("\n**Example Answer (%s question)**:\n%s\n", pq.QueryType, example))
+		}
+	}
+
 	instruction := "\n**Instructions**: Please answer the question using the code snippets above only if they are relevant. The code may not always be helpful, so focus on the question itself and refer to specific files or code elements only when necessary. "
 	sb.WriteString(instruction)
+	sb.WriteString(ag.formatInstruction())
+	sb.WriteString(verbosityInstructions[ag.Verbosity])
 
 	return sb.String()
 }
 
+// formatInstruction returns the layout instruction for ag.Format, falling back
+// to markdown for unset or unknown formats.
+func (ag *AnswerGenerator) formatInstruction() string {
+	if instruction, ok := answerFormatInstructions[ag.Format]; ok {
+		return instruction
+	}
+	return answerFormatInstructions[AnswerFormatMarkdown]
+}
+
+// maxTokens returns the generation limit: MaxAnswerTokens if set, otherwise
+// the limit of ag.Verbosity (normal for unset or unknown levels).
+func (ag *AnswerGenerator) maxTokens() int {
+	if ag.MaxAnswerTokens > 0 {
+		return ag.MaxAnswerTokens
+	}
+	if n, ok := verbosityMaxTokens[ag.Verbosity]; ok {
+		return n
+	}
+	return verbosityMaxTokens[VerbosityNormal]
+}
+
 func answerSystemPrompt() string {
 	return `You are a helpful AI assistant specialized in code understanding and explanation. 
 Your task is to answer questions about code repositories based on the relevant code snippets provided.
@@ -64,6 +64,16 @@ func TestBuildPromptNoElements(t *testing.T) {
 	}
 }
 
+func TestBuildPromptHistory(t *testing.T) {
+	ag := NewAnswerGenerator(llm.NewClientWith("key", "model", "http://localhost"))
+	ag.History = []ConversationTurn{{Question: "How are tokens refreshed?", Answer: "By refreshToken in auth.go."}}
+	result := ag.buildPrompt("Where is it called?", nil, nil)
+	history := strings.Index(result, "Q1: How are tokens refreshed?\nA1: By refreshToken in auth.go.")
+	if history < 0 || history > strings.Index(result, "**Current Question**: Where is it called?") {
+		t.Errorf("expected the earlier turn before the question, got:\n%s", result)
+	}
+}
+
 func TestBuildPromptWithElements(t *testing.T) {
 	client := llm.NewClientWith("key", "model", "http://localhost")
 	ag := NewAnswerGenerator(client)
@@ -90,6 +100,19 @@ func TestBuildPromptWithElements(t *testing.T) {
 	}
 }
 
+func TestBuildPromptRedactsSecrets(t *testing.T) {
+	ag := NewAnswerGenerator(llm.NewClientWith("key", "model", "http://localhost"))
+	elements := []types.CodeElement{{Type: "function", Name: "connect", Code: `dsn := "postgres://app:pa55word@db/app"`}}
+
+	if result := ag.buildPrompt("how do we connect?", nil, elements); strings.Contains(result, "pa55word") {
+		t.Errorf("prompt leaks the password:\n%s", result)
+	}
+	ag.RedactSecrets = false
+	if result := ag.buildPrompt("how do we connect?", nil, elements); !strings.Contains(result, "pa55word") {
+		t.Errorf("prompt without redaction misses the code:\n%s", result)
+	}
+}
+
 func TestBuildPromptManyElements(t *testing.T) {
 	client := llm.NewClientWith("key", "model", "http://localhost")
 	ag := NewAnswerGenerator(client)
@@ -111,6 +134,133 @@ func TestBuildPromptManyElements(t *testing.T) {
 	}
 }
 
+func TestBuildPromptFewShotByQueryType(t *testing.T) {
+	client := llm.NewClientWith("key", "model", "http://localhost")
+	ag := NewAnswerGenerator(client)
+
+	debugPQ := ProcessQuery("why is there an error when saving")
+	if debugPQ.QueryType != "debug" {
+		t.Fatalf("expected debug query, got %q", debugPQ.QueryType)
+	}
+	debugPrompt := ag.buildPrompt(debugPQ.Original, debugPQ, nil)
+	if !strings.Contains(debugPrompt, "Root cause") {
+		t.Error("debug prompt should include the debug exemplar")
+	}
+	if strings.Contains(debugPrompt, "new CLI subcommand") {
+		t.Error("debug prompt should not include the howto exemplar")
+	}
+
+	howtoPQ := ProcessQuery("how do I add caching")
+	if howtoPQ.QueryType != "howto" {
+		t.Fatalf("expected howto query, got %q", howtoPQ.QueryType)
+	}
+	howtoPrompt := ag.buildPrompt(howtoPQ.Original, howtoPQ, nil)
+	if !strings.Contains(howtoPrompt, "new CLI subcommand") {
+		t.Error("howto prompt should include the howto exemplar")
+	}
+	if strings.Contains(howtoPrompt, "Root cause") {
+		t.Error("howto prompt should not include the debug exemplar")
+	}
+
+	// Overriding replaces the default exemplar
+	ag.FewShotExamples["debug"] = "Question: custom\nAnswer: custom debug example"
+	if p := ag.buildPrompt(debugPQ.Original, debugPQ, nil); !strings.Contains(p, "custom debug example") {
+		t.Error("overridden exemplar should be used")
+	}
+}
+
+func TestBuildPromptAnswerFormat(t *testing.T) {
+	ag := NewAnswerGenerator(llm.NewClientWith("key", "model", "http://localhost"))
+	pq := ProcessQuery("how does auth work")
+
+	if p := ag.buildPrompt(pq.Original, pq, nil); !strings.Contains(p, "fenced code blocks") {
+		t.Error("default prompt should ask for markdown")
+	}
+	ag.Format = AnswerFormatPlain
+	if p := ag.buildPrompt(pq.Original, pq, nil); !strings.Contains(p, "without any Markdown") {
+		t.Error("plain prompt should forbid markdown")
+	}
+	ag.Format = AnswerFormatBullet
+	if p := ag.buildPrompt(pq.Original, pq, nil); !strings.Contains(p, "bullet list") || strings.Contains(p, "fenced code blocks") {
+		t.Error("bullet prompt should ask only for a bullet list")
+	}
+}
+
+func TestParseAnswerFormat(t *testing.T) {
+	for name, want := range map[string]AnswerFormat{"": AnswerFormatMarkdown, "Plain": AnswerFormatPlain, "bullet": AnswerFormatBullet} {
+		if got, err := ParseAnswerFormat(name); err != nil || got != want {
+			t.Errorf("ParseAnswerFormat(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseAnswerFormat("html"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestBuildPromptVerbosity(t *testing.T) {
+	ag := NewAnswerGenerator(llm.NewClientWith("key", "model", "http://localhost"))
+	pq := ProcessQuery("how does auth work")
+
+	normal := ag.buildPrompt(pq.Original, pq, nil)
+	ag.Verbosity = VerbosityBrief
+	if p := ag.buildPrompt(pq.Original, pq, nil); !strings.Contains(p, "Keep the answer brief") {
+		t.Error("brief prompt should ask for a short answer")
+	}
+	ag.Verbosity = VerbosityDetailed
+	if p := ag.buildPrompt(pq.Original, pq, nil); !strings.Contains(p, "detailed answer") {
+		t.Error("detailed prompt should ask for an in-depth answer")
+	}
+	if strings.Contains(normal, "brief") || strings.Contains(normal, "detailed answer") {
+		t.Error("normal prompt should not add a length instruction")
+	}
+}
+
+func TestParseVerbosity(t *testing.T) {
+	for name, want := range map[string]Verbosity{"": VerbosityNormal, "Brief": VerbosityBrief, "detailed": VerbosityDetailed} {
+		if got, err := ParseVerbosity(name); err != nil || got != want {
+			t.Errorf("ParseVerbosity(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseVerbosity("verbose"); err == nil {
+		t.Error("expected an error for an unknown verbosity")
+	}
+}
+
+func TestGenerateAnswerMaxTokens(t *testing.T) {
+	var maxTokens int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			MaxTokens int `json:"max_tokens"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		maxTokens = req.MaxTokens
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": "ok"}}},
+		})
+	}))
+	defer server.Close()
+
+	ag := NewAnswerGenerator(llm.NewClientWith("key", "model", server.URL))
+	pq := ProcessQuery("where is auth?")
+	for _, c := range []struct {
+		verbosity Verbosity
+		max, want int
+	}{
+		{"", 0, 20000},
+		{VerbosityBrief, 0, 1000},
+		{VerbosityDetailed, 0, 32000},
+		{VerbosityBrief, 500, 500},
+	} {
+		ag.Verbosity, ag.MaxAnswerTokens = c.verbosity, c.max
+		if _, err := ag.GenerateAnswer(pq.Original, pq, nil); err != nil {
+			t.Fatalf("GenerateAnswer: %v", err)
+		}
+		if maxTokens != c.want {
+			t.Errorf("verbosity %q, MaxAnswerTokens %d: max_tokens = %d, want %d", c.verbosity, c.max, maxTokens, c.want)
+		}
+	}
+}
+
 func TestGenerateAnswer(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := map[string]any{
@@ -155,3 +305,82 @@ func TestGenerateAnswerError(t *testing.T) {
 		t.Error("expected error from failed LLM call")
 	}
 }
+
+func TestGenerateOverview(t *testing.T) {
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []llm.ChatMessage `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt = req.Messages[0].Content
+		content := "```json\n" + `{"summary": "A CLI.", "entry_points": [{"name": "main", "location": "cmd/app/main.go:10", "description": "starts the CLI"}]}` + "\n```"
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer server.Close()
+
+	ag := NewAnswerGenerator(llm.NewClientWith("test-key", "test-model", server.URL))
+	files := []OverviewFile{{Path: "cmd/app/main.go", Language: "go", Elements: 1, Signatures: []string{"func main() (line 10)"},
+		Summary: "Connects to postgres://app:pa55word@db/app."}}
+	overview, err := ag.GenerateOverview("app", files)
+	if err != nil {
+		t.Fatalf("GenerateOverview error: %v", err)
+	}
+	if !strings.Contains(prompt, "cmd/app/main.go") || !strings.Contains(prompt, "func main() (line 10)") {
+		t.Errorf("prompt misses the central files:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "pa55word") {
+		t.Errorf("prompt leaks the password:\n%s", prompt)
+	}
+	if overview.Summary != "A CLI." || len(overview.EntryPoints) != 1 || overview.EntryPoints[0].Location != "cmd/app/main.go:10" {
+		t.Errorf("overview = %+v", overview)
+	}
+
+	if got := parseOverview("Just prose."); got.Summary != "Just prose." || got.EntryPoints != nil {
+		t.Errorf("parseOverview(prose) = %+v", got)
+	}
+}
+
+func TestExplainFile(t *testing.T) {
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []llm.ChatMessage `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt = req.Messages[0].Content
+		content := `{"purpose": "Checks sessions.", "public_api": [{"name": "Require", "location": "auth/middleware.go:8", "description": "wraps handlers"}], "dependencies": [{"name": "session", "description": "loads sessions"}]}`
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer server.Close()
+
+	ag := NewAnswerGenerator(llm.NewClientWith("test-key", "test-model", server.URL))
+	file := ExplainedFile{
+		Path: "auth/middleware.go", Language: "go", Lines: 30, Summary: `Signs with api_key = "sk-live-4f9a8b7c6d5e".`,
+		Imports: []string{"net/http"}, UsedBy: []string{"cmd/app/main.go"},
+		Signatures: []string{"func Require(next http.Handler) http.Handler (line 8) — Require rejects anonymous requests."},
+	}
+	explanation, err := ag.ExplainFile("app", file)
+	if err != nil {
+		t.Fatalf("ExplainFile error: %v", err)
+	}
+	for _, want := range []string{"auth/middleware.go (go, 30 lines)", "- net/http", "- cmd/app/main.go", "Require rejects anonymous requests."} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt misses %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "sk-live-4f9a8b7c6d5e") {
+		t.Errorf("prompt leaks the API key:\n%s", prompt)
+	}
+	if explanation.Purpose != "Checks sessions." || len(explanation.PublicAPI) != 1 || len(explanation.Dependencies) != 1 {
+		t.Errorf("explanation = %+v", explanation)
+	}
+
+	if got := parseFileExplanation("Just prose."); got.Purpose != "Just prose." || got.PublicAPI != nil {
+		t.Errorf("parseFileExplanation(prose) = %+v", got)
+	}
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/llm"
+)
+
+// ExplainedFile is what an explanation of a single file is written from:
+// the signatures and docstrings it defines and the files it is connected to.
+type ExplainedFile struct {
+	Path       string   `json:"path"`
+	Language   string   `json:"language"`
+	Lines      int      `json:"lines"`
+	Summary    string   `json:"summary,omitempty"`
+	Imports    []string `json:"imports,omitempty"`    // Modules the file imports, as written
+	DependsOn  []string `json:"depends_on,omitempty"` // Indexed files it imports
+	UsedBy     []string `json:"used_by,omitempty"`    // Indexed files that import it
+	Signatures []string `json:"signatures,omitempty"`
+}
+
+// FileExplanation is a structured explanation of what a file does.
+type FileExplanation struct {
+	Purpose      string         `json:"purpose"`
+	PublicAPI    []OverviewItem `json:"public_api"`
+	KeyTypes     []OverviewItem `json:"key_types"`
+	Dependencies []OverviewItem `json:"dependencies"`
+}
+
+// explainMaxTokens is the generation limit of a file explanation.
+const explainMaxTokens = 3000
+
+// ExplainFile asks the model to explain a file from its skimmed signatures,
+// docstrings and import edges. A response that is not the requested JSON is
+// kept whole as the Purpose. Secrets in the docstrings and summary are masked
+// when RedactSecrets is set.
+func (ag *AnswerGenerator) ExplainFile(repoName string, file ExplainedFile) (*FileExplanation, error) {
+	prompt := buildExplainPrompt(repoName, file)
+	if ag.RedactSecrets {
+		prompt = llm.RedactSecrets(prompt)
+	}
+	response, err := ag.client.ChatCompletion([]llm.ChatMessage{
+		{Role: "user", Content: prompt},
+	}, 0.2, explainMaxTokens)
+	if err != nil {
+		return nil, fmt.Errorf("explain file: %w", err)
+	}
+	return parseFileExplanation(response), nil
+}
+
+func buildExplainPrompt(repoName string, file ExplainedFile) string {
+	var sb strings.Builder
+	sb.WriteString("You are a senior engineer explaining one file of the repository ")
+	sb.WriteString(fmt.Sprintf("`%s` to a new team member.\n\n", repoName))
+	sb.WriteString(fmt.Sprintf("## %s (%s, %d lines)\n", file.Path, file.Language, file.Lines))
+	if file.Summary != "" {
+		sb.WriteString(file.Summary + "\n")
+	}
+	writeList := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		sb.WriteString("\n**" + title + "**:\n")
+		for _, item := range items {
+			sb.WriteString("- " + item + "\n")
+		}
+	}
+	writeList("Imports", file.Imports)
+	writeList("Repository files it imports", file.DependsOn)
+	writeList("Repository files that import it", file.UsedBy)
+	writeList("Definitions (signature, line and first docstring line)", file.Signatures)
+
+	sb.WriteString(`
+**Instructions**: Explain the file using only the information above. Respond with a JSON object and nothing else:
+{
+  "purpose": "two or three sentences on what the file is for and how it fits in the repository",
+  "public_api": [{"name": "...", "location": "path:line", "description": "what callers use it for"}],
+  "key_types": [{"name": "...", "location": "path:line", "description": "..."}],
+  "dependencies": [{"name": "module or file", "description": "what the file uses it for"}]
+}
+List at most eight public API entries, six key types and eight notable dependencies.`)
+	return sb.String()
+}
+
+// parseFileExplanation reads the model's JSON explanation, falling back to
+// the raw response as the purpose.
+func parseFileExplanation(response string) *FileExplanation {
+	var explanation FileExplanation
+	jsonStr := extractJSON(response)
+	if jsonStr == "" || json.Unmarshal([]byte(jsonStr), &explanation) != nil {
+		return &FileExplanation{Purpose: strings.TrimSpace(response)}
+	}
+	return &explanation
+}
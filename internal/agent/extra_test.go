@@ -2,8 +2,12 @@ package agent
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -314,6 +318,45 @@ func TestBuildRoundNPromptManyGatheredElements(t *testing.T) {
 	}
 }
 
+func TestFormatElementsPrioritizesKeepFiles(t *testing.T) {
+	cfg := DefaultAgentConfig()
+	cfg.MaxPromptElements = 3
+	agent := NewIterativeAgent(nil, nil, nil, cfg)
+	for i := 0; i < 5; i++ {
+		agent.gatheredElements = append(agent.gatheredElements, types.CodeElement{
+			Type: "file", RelativePath: fmt.Sprintf("file%d.go", i), StartLine: 1, EndLine: 5,
+		})
+	}
+	agent.keepFiles = []string{"file4.go"}
+
+	out := agent.formatElementsWithMetadata()
+	if !strings.Contains(out, "1. repo/file4.go") {
+		t.Errorf("kept file should be listed first:\n%s", out)
+	}
+	if !strings.Contains(out, "3. repo/file1.go") || strings.Contains(out, "file2.go") {
+		t.Errorf("expected file4, file0 and file1 only:\n%s", out)
+	}
+	if !strings.Contains(out, "... and 2 more elements") {
+		t.Errorf("expected 2 elided elements:\n%s", out)
+	}
+}
+
+func TestFormatElementsShowsSource(t *testing.T) {
+	agent := NewIterativeAgent(nil, nil, nil, DefaultAgentConfig())
+	bm25Hit := types.CodeElement{ID: "a", Type: "file", RelativePath: "a.go"}
+	graphHit := types.CodeElement{ID: "b", Type: "file", RelativePath: "b.go"}
+	agent.tagSource([]types.CodeElement{bm25Hit}, "bm25")
+	agent.tagSource([]types.CodeElement{bm25Hit, graphHit}, "graph")
+	agent.gatheredElements = []types.CodeElement{bm25Hit, graphHit, {ID: "c", Type: "file", RelativePath: "c.go"}}
+
+	out := agent.formatElementsWithMetadata()
+	for _, want := range []string{"a.go\n   Repo: repo\n   Type: file\n   Source: bm25", "b.go\n   Repo: repo\n   Type: file\n   Source: graph", "Source: retrieval"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
 func TestNewIterativeAgentWithZeroConfig(t *testing.T) {
 	client := llm.NewClientWith("key", "model", "http://localhost")
 	vs := index.NewVectorStore()
@@ -463,6 +506,210 @@ func TestRetrieveToolCallExecution(t *testing.T) {
 	}
 }
 
+func TestRetrieveMinRoundsBeforeStop(t *testing.T) {
+	run := func(round1 string, minRounds int) *RetrievalResult {
+		t.Helper()
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			content := round1
+			if calls == 2 {
+				// Confident, but still asking to read a file
+				content = `{"confidence": 97, "reasoning": "likely main", "tool_calls": [{"tool": "browse_file", "parameters": {"path": "main.go"}}]}`
+			} else if calls > 2 {
+				content = `{"confidence": 97, "reasoning": "found"}`
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": content}}},
+			})
+		}))
+		defer server.Close()
+
+		hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+		elements := []types.CodeElement{
+			{ID: "e1", Name: "main", Type: "function", RelativePath: "main.go", Code: "func main() {}"},
+			{ID: "f1", Name: "main.go", Type: "file", RelativePath: "main.go", Code: "package main"},
+		}
+		_ = hr.IndexElements(elements, nil)
+		cfg := DefaultAgentConfig()
+		if minRounds > 0 {
+			cfg.MinRoundsBeforeStop = minRounds
+		}
+		agent := NewIterativeAgent(llm.NewClientWith("key", "model", server.URL), NewToolExecutor(hr, nil, elements), nil, cfg)
+
+		pq := &ProcessedQuery{Original: "find main", Cleaned: "find main", Complexity: 50, QueryType: "locate", Keywords: []string{"main"}}
+		result, err := agent.Retrieve("find main", pq)
+		if err != nil {
+			t.Fatalf("Retrieve: %v", err)
+		}
+		return result
+	}
+	needsCode := `{"confidence": 50, "query_complexity": 50, "reasoning": "need code", "tool_calls": [{"tool": "search_codebase", "parameters": {"search_term": "main"}}]}`
+	general := `{"confidence": 99, "query_complexity": 50, "reasoning": "general knowledge"}`
+
+	if r := run(needsCode, 0); r.Rounds != 3 || r.StopReason != "confidence_threshold_reached" {
+		t.Errorf("default: rounds = %d, stop = %q; want round 2's tools run before stopping in round 3", r.Rounds, r.StopReason)
+	}
+	if r := run(needsCode, 1); r.Rounds != 2 || r.StopReason != "confidence_threshold_reached" {
+		t.Errorf("min 1 round: rounds = %d, stop = %q; want a confident stop in round 2", r.Rounds, r.StopReason)
+	}
+	if r := run(needsCode, 2); r.Rounds != 3 || r.StopReason != "confidence_threshold_reached" {
+		t.Errorf("min 2 rounds: rounds = %d, stop = %q; want round 2's tools run before stopping in round 3", r.Rounds, r.StopReason)
+	}
+	if r := run(general, 3); r.Rounds != 2 || r.StopReason != "confidence_threshold_reached" {
+		t.Errorf("general knowledge: rounds = %d, stop = %q; want a confident stop in round 2", r.Rounds, r.StopReason)
+	}
+}
+
+func TestRetrieveTrace(t *testing.T) {
+	responses := []string{
+		`{"confidence": 50, "query_complexity": 50, "tool_calls": [{"tool": "search_codebase", "parameters": {"search_term": "main"}}]}`,
+		`{"confidence": 70, "keep_files": ["main.go"], "tool_calls": [{"tool": "browse_file", "arg": "main.go"}]}`,
+		`{"confidence": 97}`,
+	}
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content := responses[min(calls, len(responses)-1)]
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer server.Close()
+
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	elements := []types.CodeElement{
+		{ID: "e1", Name: "main", Type: "function", RelativePath: "main.go", StartLine: 2, EndLine: 2, Code: "func main() {}"},
+		{ID: "f1", Name: "main.go", Type: "file", RelativePath: "main.go", StartLine: 1, EndLine: 2, Code: "package main\nfunc main() {}"},
+	}
+	_ = hr.IndexElements(elements, nil)
+	agent := NewIterativeAgent(llm.NewClientWith("key", "model", server.URL), NewToolExecutor(hr, nil, elements), nil, DefaultAgentConfig())
+
+	pq := &ProcessedQuery{Original: "find main", Cleaned: "find main", Complexity: 50, QueryType: "locate", Keywords: []string{"main"}}
+	result, err := agent.Retrieve("find main", pq)
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if len(result.Trace) != result.Rounds || result.Rounds != 3 {
+		t.Fatalf("trace has %d rounds, result %d; want 3", len(result.Trace), result.Rounds)
+	}
+
+	r1, r2, r3 := result.Trace[0], result.Trace[1], result.Trace[2]
+	if r1.Round != 1 || r1.Confidence != 50 || len(r1.ToolCalls) != 1 || r1.ToolCalls[0].Tool != "search_codebase" {
+		t.Errorf("round 1 trace = %+v", r1)
+	}
+	if len(r2.ToolCalls) != 1 || r2.ToolCalls[0].Parameters["arg"] != "main.go" || r2.Candidates == 0 ||
+		len(r2.KeepFiles) != 1 || len(r2.Files) != 1 || r2.Files[0] != "main.go" || r2.TotalLines == 0 || r2.BudgetUsage <= 0 {
+		t.Errorf("round 2 trace = %+v", r2)
+	}
+	if r2.StopReason != "" || r3.StopReason != result.StopReason || r3.Confidence != 97 {
+		t.Errorf("stop reasons = %q, %q; want only the last round to carry %q", r2.StopReason, r3.StopReason, result.StopReason)
+	}
+}
+
+func TestRetrieveOverviewSeedsDocs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": `{"confidence": 99, "query_complexity": 50}`}}},
+		})
+	}))
+	defer server.Close()
+
+	elements := []types.CodeElement{
+		{ID: "d1", Name: "docs/design.md: Layers", Type: "documentation", Language: "markdown", RelativePath: "docs/design.md", StartLine: 1, EndLine: 2, Code: "# Layers"},
+		{ID: "d2", Name: "README.md: Demo", Type: "documentation", Language: "markdown", RelativePath: "README.md", StartLine: 1, EndLine: 2, Code: "# Demo"},
+		{ID: "d3", Name: "Documentation: main.py", Type: "documentation", Language: "python", RelativePath: "main.py", Code: "entry point"},
+		{ID: "e1", Name: "main", Type: "function", Language: "go", RelativePath: "main.go", Code: "func main() {}"},
+	}
+	te := NewToolExecutor(index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75)), nil, elements)
+	if docs := te.projectDocs(8); len(docs) != 2 || docs[0].ID != "d2" || docs[1].ID != "d1" {
+		t.Fatalf("projectDocs = %+v, want the README section, then docs/", docs)
+	}
+
+	retrieve := func(question string, cfg AgentConfig) map[string]string {
+		agent := NewIterativeAgent(llm.NewClientWith("key", "model", server.URL), te, nil, cfg)
+		result, err := agent.Retrieve(question, ProcessQuery(question))
+		if err != nil {
+			t.Fatalf("Retrieve: %v", err)
+		}
+		return result.Metadata["element_sources"].(map[string]string)
+	}
+	if sources := retrieve("explain the architecture", DefaultAgentConfig()); sources["d1"] != "docs" || sources["d2"] != "docs" {
+		t.Errorf("overview sources = %v, want both doc sections seeded", sources)
+	}
+	if sources := retrieve("where is main defined", DefaultAgentConfig()); sources["d2"] != "" {
+		t.Errorf("locate query should not be seeded with docs, got %v", sources)
+	}
+	cfg := DefaultAgentConfig()
+	cfg.OverviewDocs = false
+	if sources := retrieve("explain the architecture", cfg); sources["d2"] != "" {
+		t.Errorf("OverviewDocs=false should not seed docs, got %v", sources)
+	}
+}
+
+func TestRetrieveConverged(t *testing.T) {
+	// The model keeps asking for the same search at low confidence
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content := `{"confidence": 50, "query_complexity": 50, "reasoning": "need more", "tool_calls": [{"tool": "search_codebase", "parameters": {"search_term": "main"}}]}`
+		resp := map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]string{"role": "assistant", "content": content}},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := llm.NewClientWith("key", "model", server.URL)
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	elements := []types.CodeElement{
+		{ID: "e1", Name: "main", Type: "function", Code: "func main() {}"},
+	}
+	_ = hr.IndexElements(elements, nil)
+	te := NewToolExecutor(hr, nil, elements)
+
+	cfg := DefaultAgentConfig()
+	cfg.MaxRounds = 5
+	agent := NewIterativeAgent(client, te, nil, cfg)
+
+	pq := &ProcessedQuery{Original: "find main", Cleaned: "find main", Complexity: 50, QueryType: "locate", Keywords: []string{"main"}}
+	result, err := agent.Retrieve("find main", pq)
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if result.StopReason != "converged" {
+		t.Errorf("StopReason = %q, want converged", result.StopReason)
+	}
+	if result.Rounds != 2 {
+		t.Errorf("Rounds = %d, want 2", result.Rounds)
+	}
+}
+
+func TestIDSetChange(t *testing.T) {
+	set := func(ids ...string) map[string]bool {
+		m := map[string]bool{}
+		for _, id := range ids {
+			m[id] = true
+		}
+		return m
+	}
+	tests := []struct {
+		prev, cur map[string]bool
+		want      float64
+	}{
+		{set(), set(), 0},
+		{set("a", "b"), set("a", "b"), 0},
+		{set("a", "b"), set("c"), 1},
+		{set("a", "b", "c"), set("a", "b", "c", "d"), 0.25},
+		{set("a", "b"), set("a"), 0.5},
+	}
+	for _, tt := range tests {
+		if got := idSetChange(tt.prev, tt.cur); got != tt.want {
+			t.Errorf("idSetChange(%v, %v) = %v, want %v", tt.prev, tt.cur, got, tt.want)
+		}
+	}
+}
+
 func TestDeduplicateElements(t *testing.T) {
 	elements := []types.CodeElement{
 		{ID: "e1", Name: "foo"},
@@ -521,3 +768,270 @@ func TestToolCallGetToolName(t *testing.T) {
 		t.Errorf("GetArg = %q, want audio", tc3.GetArg())
 	}
 }
+
+func TestToolCallTypedArgsMixedShapes(t *testing.T) {
+	// Both "arg" and tool-specific parameters: parameters win
+	tc := ToolCall{Name: "search_codebase", Arg: "fallback",
+		Parameters: map[string]any{"search_term": "Render", "file_pattern": "*.go", "use_regex": "true"}}
+	args := tc.SearchArgs()
+	if args.SearchTerm != "Render" || len(args.FilePatterns) != 1 || args.FilePatterns[0] != "*.go" || !args.UseRegex {
+		t.Errorf("SearchArgs = %+v", args)
+	}
+	if tc.GetArg() != "Render" {
+		t.Errorf("GetArg = %q, want Render", tc.GetArg())
+	}
+
+	// Search parameters carrying an unrelated "path" key must not leak into the search term
+	tc = ToolCall{Tool: "search_code", Parameters: map[string]any{"path": "src", "query": "auth"}}
+	if got := tc.GetArg(); got != "auth" {
+		t.Errorf("search GetArg = %q, want auth", got)
+	}
+	if args := tc.SearchArgs(); len(args.FilePatterns) != 1 || args.FilePatterns[0] != "*" || args.UseRegex {
+		t.Errorf("expected default pattern and literal search, got %+v", args)
+	}
+
+	// Path tools read "path" even when a search_term is present
+	tc = ToolCall{Tool: "list_directory", Parameters: map[string]any{"search_term": "x", "path": "internal"}}
+	if got := tc.GetArg(); got != "internal" {
+		t.Errorf("list_directory GetArg = %q, want internal", got)
+	}
+	tc = ToolCall{Name: "browse_file", Arg: "main.go", Parameters: map[string]any{"search_term": "x"}}
+	if got := tc.PathArgs().Path; got != "main.go" {
+		t.Errorf("browse_file path = %q, want main.go from arg", got)
+	}
+	tc = ToolCall{Tool: "skim_file", Parameters: map[string]any{"file_path": "a/b.py"}}
+	if got := tc.GetArg(); got != "a/b.py" {
+		t.Errorf("skim_file GetArg = %q, want a/b.py", got)
+	}
+
+	tc = ToolCall{Tool: "resolve_symbol", Parameters: map[string]any{"symbol": "helper", "search_term": "x"}}
+	if got := tc.GetArg(); got != "helper" {
+		t.Errorf("resolve_symbol GetArg = %q, want helper", got)
+	}
+
+	// Non-string parameter values are ignored rather than stringified
+	tc = ToolCall{Tool: "search_codebase", Arg: "main", Parameters: map[string]any{"search_term": 42}}
+	if got := tc.GetArg(); got != "main" {
+		t.Errorf("GetArg = %q, want main", got)
+	}
+}
+
+func TestSearchCodebaseMultiplePatterns(t *testing.T) {
+	// The array form arrives from JSON as []any
+	var tc ToolCall
+	raw := `{"tool": "search_codebase", "parameters": {"search_term": "handler", "file_pattern": ["*.go", "", "**/*.py"]}}`
+	if err := json.Unmarshal([]byte(raw), &tc); err != nil {
+		t.Fatal(err)
+	}
+	args := tc.SearchArgs()
+	if len(args.FilePatterns) != 2 || args.FilePatterns[0] != "*.go" || args.FilePatterns[1] != "**/*.py" {
+		t.Fatalf("FilePatterns = %v", args.FilePatterns)
+	}
+
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":        "func handler() {}",
+		"app/views.py":   "def handler(): pass",
+		"web/index.ts":   "const handler = 1",
+		"docs/readme.md": "no match here",
+	}
+	for rel, content := range files {
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	te := NewToolExecutor(nil, nil, nil)
+	te.SetRepoRoot(root, "repo")
+
+	got := map[string]bool{}
+	for _, c := range te.ExecuteSearchCodebase("handler", args.FilePatterns, false) {
+		got[c.FilePath] = true
+	}
+	if len(got) != 2 || !got["main.go"] || !got["app/views.py"] {
+		t.Errorf("matched files = %v, want main.go and app/views.py", got)
+	}
+
+	// No patterns searches every file
+	if n := len(te.ExecuteSearchCodebase("handler", nil, false)); n != 3 {
+		t.Errorf("unfiltered search matched %d files, want 3", n)
+	}
+}
+
+func TestSearchCodebaseLineMatches(t *testing.T) {
+	root := t.TempDir()
+	content := "package auth\n\nfunc Login() {}\n\n// Login twice: Login\nfunc Logout() {}\n"
+	if err := os.WriteFile(filepath.Join(root, "auth.go"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	te := NewToolExecutor(nil, nil, nil)
+	te.SetRepoRoot(root, "repo")
+
+	got := te.ExecuteSearchCodebase("login", nil, false)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 candidate, got %d", len(got))
+	}
+	c := got[0]
+	if c.MatchCount != 3 {
+		t.Errorf("MatchCount = %d, want 3", c.MatchCount)
+	}
+	// Two hits on line 5 collapse into one entry
+	want := []LineMatch{{Line: 3, Excerpt: "func Login() {}"}, {Line: 5, Excerpt: "// Login twice: Login"}}
+	if len(c.Matches) != len(want) {
+		t.Fatalf("Matches = %+v, want %+v", c.Matches, want)
+	}
+	for i := range want {
+		if c.Matches[i] != want[i] {
+			t.Errorf("Matches[%d] = %+v, want %+v", i, c.Matches[i], want[i])
+		}
+	}
+}
+
+func TestLineMatchesLimit(t *testing.T) {
+	data := []byte(strings.Repeat("x\n", 10))
+	var locs [][]int
+	for i := 0; i < 10; i++ {
+		locs = append(locs, []int{i * 2, i*2 + 1})
+	}
+	got := lineMatches(data, locs, 3)
+	if len(got) != 3 || got[0].Line != 1 || got[2].Line != 3 {
+		t.Errorf("lineMatches = %+v", got)
+	}
+}
+
+func TestToolExecutorLanguageFilter(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "g", Name: "Serve", Type: "function", Language: "go", RelativePath: "server/serve.go", Code: "func Serve() { handle request }"},
+		{ID: "t", Name: "serve", Type: "function", Language: "typescript", RelativePath: "web/serve.ts", Code: "function serve() { handle request }"},
+		{ID: "c1", Name: "Close", Type: "function", Language: "go", RelativePath: "db/db.go", Code: "func Close() {}"},
+		{ID: "c2", Name: "Open", Type: "function", Language: "go", RelativePath: "db/db.go", Code: "func Open() {}"},
+		{ID: "c3", Name: "Migrate", Type: "function", Language: "go", RelativePath: "db/db.go", Code: "func Migrate() {}"},
+	}
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	_ = hr.IndexElements(elements, nil)
+	te := NewToolExecutor(hr, nil, elements)
+	te.Languages = []string{"go"}
+
+	res, err := te.Execute("search_codebase", "handle request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Elements) != 1 || res.Elements[0].ID != "g" {
+		t.Errorf("search returned %v, want only the Go element", res.Elements)
+	}
+	if got := te.FindElementsForFile("web/serve.ts"); len(got) != 0 {
+		t.Errorf("FindElementsForFile returned filtered-out elements: %v", got)
+	}
+
+	root := t.TempDir()
+	for _, rel := range []string{"server/serve.go", "web/serve.ts"} {
+		p := filepath.Join(root, rel)
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		os.WriteFile(p, []byte("handle request"), 0o644)
+	}
+	te.SetRepoRoot(root, "repo")
+	files := te.ExecuteSearchCodebase("handle", nil, false)
+	if len(files) != 1 || files[0].FilePath != "server/serve.go" {
+		t.Errorf("ExecuteSearchCodebase = %v, want only server/serve.go", files)
+	}
+}
+
+func TestToolExecutorTestFilter(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "s", Name: "Expire", Type: "function", Language: "go", RelativePath: "session/expire.go", Code: "func Expire() { expire session }"},
+		{ID: "st", Name: "TestExpire", Type: "function", Language: "go", RelativePath: "session/expire_test.go", Code: "func TestExpire() { expire session }"},
+		{ID: "w", Name: "expire", Type: "function", Language: "typescript", RelativePath: "web/__tests__/expire.ts", Code: "function expire() { expire session }"},
+		{ID: "c1", Name: "Close", Type: "function", Language: "go", RelativePath: "db/db.go", Code: "func Close() {}"},
+		{ID: "c2", Name: "Open", Type: "function", Language: "go", RelativePath: "db/db.go", Code: "func Open() {}"},
+		{ID: "c3", Name: "Migrate", Type: "function", Language: "go", RelativePath: "db/db.go", Code: "func Migrate() {}"},
+		{ID: "c4", Name: "Seed", Type: "function", Language: "go", RelativePath: "db/db.go", Code: "func Seed() {}"},
+		{ID: "c5", Name: "Dump", Type: "function", Language: "go", RelativePath: "db/db.go", Code: "func Dump() {}"},
+		{ID: "c6", Name: "Vacuum", Type: "function", Language: "go", RelativePath: "db/db.go", Code: "func Vacuum() {}"},
+	}
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	_ = hr.IndexElements(elements, nil)
+	te := NewToolExecutor(hr, nil, elements)
+
+	for _, tt := range []struct {
+		tests TestFilter
+		want  []string
+	}{
+		{TestsInclude, []string{"s", "st", "w"}},
+		{TestsExclude, []string{"s"}},
+		{TestsOnly, []string{"st", "w"}},
+	} {
+		te.Tests = tt.tests
+		res, err := te.Execute("search_codebase", "expire session")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range res.Elements {
+			got = append(got, e.ID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Tests=%q: search returned %v, want %v", tt.tests, got, tt.want)
+		}
+	}
+
+	te.Tests = TestsExclude
+	if got := te.FindElementsForFile("session/expire_test.go"); len(got) != 0 {
+		t.Errorf("FindElementsForFile returned test elements: %v", got)
+	}
+	root := t.TempDir()
+	for _, rel := range []string{"session/expire.go", "session/expire_test.go"} {
+		p := filepath.Join(root, rel)
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		os.WriteFile(p, []byte("expire session"), 0o644)
+	}
+	te.SetRepoRoot(root, "repo")
+	files := te.ExecuteSearchCodebase("expire", nil, false)
+	if len(files) != 1 || files[0].FilePath != "session/expire.go" {
+		t.Errorf("ExecuteSearchCodebase = %v, want only session/expire.go", files)
+	}
+}
+
+func TestRoundNSearchCodebaseFilePattern(t *testing.T) {
+	responses := []string{
+		`{"confidence": 40, "query_complexity": 50, "reasoning": "need code"}`,
+		`{"confidence": 60, "reasoning": "look in Python", "tool_calls": [{"tool": "search_codebase", "parameters": {"search_term": "handler", "file_pattern": ["*.py"]}}]}`,
+		`{"confidence": 97, "reasoning": "found"}`,
+	}
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content := responses[min(calls, len(responses)-1)]
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("func handler() {}\n"), 0o644)
+	os.WriteFile(filepath.Join(root, "views.py"), []byte("def handler(): pass\n"), 0o644)
+	elements := []types.CodeElement{
+		{ID: "go", Name: "main.go", Type: "file", RelativePath: "main.go", Code: "package main"},
+		{ID: "py", Name: "views.py", Type: "file", RelativePath: "views.py", Code: "views"},
+	}
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	_ = hr.IndexElements(elements, nil)
+	te := NewToolExecutor(hr, nil, elements)
+	te.SetRepoRoot(root, "repo")
+	agent := NewIterativeAgent(llm.NewClientWith("key", "model", server.URL), te, nil, DefaultAgentConfig())
+
+	pq := &ProcessedQuery{Original: "where is routing", Cleaned: "where is routing", Complexity: 50, QueryType: "locate"}
+	if _, err := agent.Retrieve("where is routing", pq); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if agent.elementSources["py"] != "search_codebase" || agent.elementSources["go"] == "search_codebase" {
+		t.Errorf("element sources = %v, want only views.py from the *.py search", agent.elementSources)
+	}
+	if len(agent.searchMatches["views.py"]) == 0 {
+		t.Error("expected the matched line of views.py to be recorded")
+	}
+}
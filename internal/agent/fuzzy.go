@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+// maxFuzzyMatches caps the files returned when a path has no exact or
+// substring match.
+const maxFuzzyMatches = 5
+
+// fuzzyPathScore rates how closely query resembles the file path relPath,
+// returning a lower score for a better match and ok=false when they are
+// unrelated. Typos in the file name (edit distance within a third of its
+// length) rank first, then queries whose characters appear in order in the
+// path ("srvgo" for "server.go"), then file names abbreviating the query
+// ("srv.go" for "server").
+func fuzzyPathScore(query, relPath string) (score int, ok bool) {
+	query = strings.ToLower(query)
+	relPath = strings.ToLower(relPath)
+	if query == "" {
+		return 0, false
+	}
+
+	qBase, base := path.Base(query), path.Base(relPath)
+	if d := levenshtein(qBase, base); d <= max(1, len(base)/3) {
+		return d, true
+	}
+	if isSubsequence(query, relPath) {
+		return 10 + len(relPath) - len(query), true
+	}
+	qStem := strings.TrimSuffix(qBase, path.Ext(qBase))
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	if len(stem) >= 3 && qStem != "" && stem[0] == qStem[0] && isSubsequence(stem, qStem) {
+		return 100 + len(qStem) - len(stem), true
+	}
+	return 0, false
+}
+
+// fuzzyFileMatches returns up to limit file elements whose paths resemble
+// query, best match first.
+func (te *ToolExecutor) fuzzyFileMatches(query string, limit int) []types.CodeElement {
+	type scored struct {
+		elem  *types.CodeElement
+		score int
+	}
+	var matches []scored
+	for _, elem := range te.ordered {
+		if elem.Type != "file" || !te.Allowed(elem) {
+			continue
+		}
+		if score, ok := fuzzyPathScore(query, elem.RelativePath); ok {
+			matches = append(matches, scored{elem, score})
+		}
+	}
+	// te.ordered is sorted by path, so ties keep path order
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+
+	var files []types.CodeElement
+	for _, m := range matches {
+		if len(files) == limit {
+			break
+		}
+		files = append(files, *m.elem)
+	}
+	return files
+}
+
+// isSubsequence reports whether the characters of sub appear in s in order.
+func isSubsequence(sub, s string) bool {
+	i := 0
+	for j := 0; i < len(sub) && j < len(s); j++ {
+		if sub[i] == s[j] {
+			i++
+		}
+	}
+	return i == len(sub)
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(min(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
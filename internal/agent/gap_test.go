@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
@@ -9,13 +10,13 @@ import (
 // === parseRound1Response Tests ===
 
 func TestParseRound1ResponseNoJSON(t *testing.T) {
-	ia := &IterativeAgent{}
+	ia := &IterativeAgent{config: DefaultAgentConfig()}
 	result, err := ia.parseRound1Response("This has no JSON at all, just text.")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if result.Confidence != 90 {
-		t.Errorf("confidence = %d, want 90 (fallback)", result.Confidence)
+	if result.Confidence != 50 {
+		t.Errorf("confidence = %d, want 50 (fallback)", result.Confidence)
 	}
 	if result.Reasoning != "This has no JSON at all, just text." {
 		t.Errorf("reasoning should be the full response")
@@ -23,13 +24,23 @@ func TestParseRound1ResponseNoJSON(t *testing.T) {
 }
 
 func TestParseRound1ResponseInvalidJSONFallback(t *testing.T) {
-	ia := &IterativeAgent{}
+	ia := &IterativeAgent{config: DefaultAgentConfig()}
 	result, err := ia.parseRound1Response(`{"confidence": "not_a_number"}`)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if result.Confidence != 90 {
-		t.Errorf("confidence = %d, want 90 (fallback)", result.Confidence)
+	if result.Confidence != 50 {
+		t.Errorf("confidence = %d, want 50 (fallback)", result.Confidence)
+	}
+}
+
+func TestParseRound1ResponseCustomFallback(t *testing.T) {
+	cfg := DefaultAgentConfig()
+	cfg.Round1FallbackConfidence = 20
+	ia := &IterativeAgent{config: cfg}
+	result, _ := ia.parseRound1Response("no json here")
+	if result.Confidence != 20 {
+		t.Errorf("confidence = %d, want 20 (configured fallback)", result.Confidence)
 	}
 }
 
@@ -75,6 +86,18 @@ func TestParseRoundNResponseKeepFiles(t *testing.T) {
 	}
 }
 
+func TestParseRoundNResponseFallbackBelowThreshold(t *testing.T) {
+	cfg := DefaultAgentConfig()
+	ia := &IterativeAgent{config: cfg}
+	result, err := ia.parseRoundNResponse(`{"confidence": 97, "keep_files": [`, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Confidence >= cfg.ConfidenceThreshold {
+		t.Errorf("fallback confidence %d should stay below threshold %d", result.Confidence, cfg.ConfidenceThreshold)
+	}
+}
+
 // === extractJSON Tests ===
 
 func TestExtractJSONFromMarkdown(t *testing.T) {
@@ -282,8 +305,33 @@ func TestInitializeAdaptiveParamsSimple(t *testing.T) {
 	if ia.confidenceThreshold != 95 {
 		t.Errorf("simple query threshold = %d, want 95", ia.confidenceThreshold)
 	}
-	if ia.adaptiveLineBudget > 8000 {
-		t.Errorf("simple query budget = %d, want <= 8000", ia.adaptiveLineBudget)
+	if ia.adaptiveBudget > 8000 {
+		t.Errorf("simple query budget = %d, want <= 8000", ia.adaptiveBudget)
+	}
+}
+
+func TestInitializeAdaptiveParamsTokenBudget(t *testing.T) {
+	cfg := DefaultAgentConfig()
+	cfg.BudgetMode = BudgetTokens
+	ia := &IterativeAgent{config: cfg}
+	ia.initializeAdaptiveParams(85)
+	if ia.adaptiveBudget != cfg.MaxTotalTokens {
+		t.Errorf("complex query token budget = %d, want %d", ia.adaptiveBudget, cfg.MaxTotalTokens)
+	}
+
+	// One minified line costs far more tokens than lines
+	dense := types.CodeElement{StartLine: 1, EndLine: 1, Code: strings.Repeat("x", 40000)}
+	if used := ia.budgetUsed([]types.CodeElement{dense}); used != 10000 {
+		t.Errorf("token budget used = %d, want 10000", used)
+	}
+	if usage := ia.budgetUsage([]types.CodeElement{dense}); usage != 10 {
+		t.Errorf("token budget usage = %.1f%%, want 10%%", usage)
+	}
+
+	ia.gatheredElements = []types.CodeElement{dense}
+	prompt := ia.buildRoundNPrompt("q", ProcessQuery("q"), 2)
+	if !strings.Contains(prompt, "Current code tokens: 10000 / 100000") {
+		t.Error("round N prompt should report usage in tokens")
 	}
 }
 
@@ -293,7 +341,7 @@ func TestInitializeAdaptiveParamsComplex(t *testing.T) {
 	if ia.confidenceThreshold > 92 {
 		t.Errorf("complex query threshold = %d, want <= 92", ia.confidenceThreshold)
 	}
-	if ia.adaptiveLineBudget < 10000 {
-		t.Errorf("complex query budget = %d, want >= 10000", ia.adaptiveLineBudget)
+	if ia.adaptiveBudget < 10000 {
+		t.Errorf("complex query budget = %d, want >= 10000", ia.adaptiveBudget)
 	}
 }
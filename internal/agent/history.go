@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConversationTurn is an earlier question of the conversation a query
+// continues, with the answer it got.
+type ConversationTurn struct {
+	Question string
+	Answer   string
+}
+
+// maxHistoryTurns is how many of the latest turns are put in prompts, and
+// maxHistoryAnswerChars how much of each answer.
+const (
+	maxHistoryTurns       = 5
+	maxHistoryAnswerChars = 1500
+)
+
+// formatHistory renders the latest turns of history for a prompt, oldest
+// first, or returns "" when there are none.
+func formatHistory(history []ConversationTurn) string {
+	if len(history) == 0 {
+		return ""
+	}
+	if len(history) > maxHistoryTurns {
+		history = history[len(history)-maxHistoryTurns:]
+	}
+	var sb strings.Builder
+	sb.WriteString("**Conversation History** (earlier questions of this conversation, oldest first):\n")
+	for i, turn := range history {
+		sb.WriteString(fmt.Sprintf("Q%d: %s\n", i+1, turn.Question))
+		sb.WriteString(fmt.Sprintf("A%d: %s\n", i+1, truncateStr(strings.TrimSpace(turn.Answer), maxHistoryAnswerChars)))
+	}
+	return sb.String()
+}
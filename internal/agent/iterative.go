@@ -3,16 +3,19 @@ package agent
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/graph"
 	"github.com/duyhunghd6/fastcode-cli/internal/llm"
+	"github.com/duyhunghd6/fastcode-cli/internal/logging"
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
+	"github.com/duyhunghd6/fastcode-cli/internal/util"
 )
 
+var logger = logging.New("agent")
+
 // IterativeAgent manages multi-round retrieval with confidence and cost control.
 type IterativeAgent struct {
 	client       *llm.Client
@@ -28,11 +31,22 @@ type IterativeAgent struct {
 	// Adaptive parameters (set per query, mirroring Python)
 	maxIterations       int
 	confidenceThreshold int
-	adaptiveLineBudget  int
+	adaptiveBudget      int // in lines or tokens, per config.BudgetMode
 
 	// History tracking (mirroring Python)
 	toolCallHistory  []toolCallRecord
-	iterationHistory []map[string]any
+	iterationHistory []RoundTrace
+
+	// Line hits from search_codebase, keyed by file path, shown with gathered elements
+	searchMatches map[string][]LineMatch
+
+	// keep_files of the latest round, listed first in round N prompts
+	keepFiles []string
+
+	// How each gathered element was first surfaced, keyed by element ID:
+	// "bm25", "search_codebase", "file_selection", "graph", "file_dependency"
+	// or the name of a round N tool
+	elementSources map[string]string
 }
 
 // toolCallRecord tracks a tool call for history display in prompts.
@@ -48,10 +62,81 @@ type AgentConfig struct {
 	ConfidenceThreshold int     // Base confidence threshold (default: 95)
 	MaxTokenBudget      int     // Maximum tokens to consume (default: 50000)
 	MaxTotalLines       int     // Maximum total lines budget (default: 12000)
+	MaxTotalTokens      int     // Maximum total tokens budget in BudgetTokens mode (default: 100000)
 	Temperature         float64 // LLM temperature (default: 0.2)
 	MaxTokensAgent      int     // Max tokens for agent LLM calls (default: 8000)
+
+	// IncludeFileDependencies adds the files a retrieved file imports (as resolved
+	// in the dependency graph) to the gathered context (default: false).
+	IncludeFileDependencies bool
+
+	// Confidence assumed when an LLM response cannot be parsed as JSON.
+	// Kept below ConfidenceThreshold so malformed output never ends retrieval
+	// as if the agent were certain.
+	Round1FallbackConfidence int // Round 1 parse-failure confidence (default: 50)
+	RoundNFallbackConfidence int // Round 2+ parse-failure confidence (default: 50)
+
+	// PrimaryLanguage is the repository's dominant source language (e.g. "go").
+	// When set, the prompts point the model at that language's files.
+	PrimaryLanguage string
+
+	// ConvergenceThreshold stops retrieval with reason "converged" when the set
+	// of gathered element IDs changes by at most this fraction between two
+	// consecutive rounds (default: 0.05; 0 stops only on an identical set).
+	ConvergenceThreshold float64
+
+	// MaxPromptElements caps how many gathered elements are listed in round
+	// 2+ prompts (default: 20; 0 uses the default). Elements from the latest
+	// keep_files are listed first.
+	MaxPromptElements int
+
+	// BudgetMode selects whether gathered code is budgeted by line count
+	// (BudgetLines, the default) or by estimated tokens (BudgetTokens), which
+	// keeps dense or minified files from overrunning the context window.
+	BudgetMode BudgetMode
+
+	// MinRoundsBeforeStop is the number of retrieval rounds whose tool calls
+	// must run before a confident assessment may end retrieval (default: 2,
+	// round 1's search and the tools the first assessment with code asks
+	// for, so a model confident before reading what it requested still reads
+	// it; 1 lets that assessment stop at once). The model still stops early
+	// when round 1 is confident without requesting any tools, as for
+	// general-knowledge questions, or when it requests nothing further.
+	MinRoundsBeforeStop int
+
+	// OverviewDocs seeds round 1 of overview questions with up to
+	// MaxOverviewDocs sections of the README and docs/ files (default: true).
+	OverviewDocs    bool
+	MaxOverviewDocs int // default: 8
+
+	// GraphNeighbors adds, after each round 2+ tool execution, the direct
+	// graph neighbors (callers, callees, imports, related classes) of the
+	// most relevant gathered elements: those in keep_files first. At most
+	// MaxGraphNeighbors are added per round, and none beyond the adaptive
+	// budget (default: off; MaxGraphNeighbors default: 10).
+	GraphNeighbors    bool
+	MaxGraphNeighbors int
+
+	// FocusFile is the indexed path of the file the question is about, if
+	// any (see ToolExecutor.ResolveFile). Its elements seed round 1, the
+	// prompts name it, and keep_files filtering never drops it.
+	FocusFile string
+
+	// History holds the earlier turns of the conversation the query
+	// continues; the round 1 prompt shows them so the query can be rewritten
+	// with its references resolved.
+	History []ConversationTurn
 }
 
+// BudgetMode is the unit the agent budgets gathered code in.
+type BudgetMode string
+
+// Budget modes for AgentConfig.BudgetMode.
+const (
+	BudgetLines  BudgetMode = "lines"
+	BudgetTokens BudgetMode = "tokens"
+)
+
 // DefaultAgentConfig returns sensible defaults matching Python.
 func DefaultAgentConfig() AgentConfig {
 	return AgentConfig{
@@ -59,8 +144,19 @@ func DefaultAgentConfig() AgentConfig {
 		ConfidenceThreshold: 95,
 		MaxTokenBudget:      50000,
 		MaxTotalLines:       12000,
+		MaxTotalTokens:      100000,
 		Temperature:         0.2,
 		MaxTokensAgent:      8000,
+
+		Round1FallbackConfidence: 50,
+		RoundNFallbackConfidence: 50,
+		ConvergenceThreshold:     0.05,
+		MaxPromptElements:        20,
+		BudgetMode:               BudgetLines,
+		MinRoundsBeforeStop:      2,
+		OverviewDocs:             true,
+		MaxOverviewDocs:          8,
+		MaxGraphNeighbors:        10,
 	}
 }
 
@@ -95,21 +191,138 @@ func (tc ToolCall) GetToolName() string {
 	return tc.Name
 }
 
-// GetArg returns the effective argument string for tool execution.
+// SearchArgs are the arguments of search_codebase / search_code.
+type SearchArgs struct {
+	SearchTerm   string
+	FilePatterns []string // ORed together; defaults to ["*"]
+	UseRegex     bool
+}
+
+// PathArgs are the arguments of the path-based tools
+// (list_directory, browse_file, skim_file, outline_file, find_dependents).
+type PathArgs struct {
+	Path string
+}
+
+// SymbolArgs are the arguments of resolve_symbol.
+type SymbolArgs struct {
+	Symbol string
+}
+
+// SearchArgs decodes the call's arguments as a search. Tool-specific
+// parameters take precedence over the simple "arg" field.
+func (tc ToolCall) SearchArgs() SearchArgs {
+	args := SearchArgs{
+		SearchTerm:   firstStringParam(tc.Parameters, "search_term", "query"),
+		FilePatterns: stringsParam(tc.Parameters, "file_pattern"),
+		UseRegex:     boolParam(tc.Parameters, "use_regex"),
+	}
+	if args.SearchTerm == "" {
+		args.SearchTerm = tc.Arg
+	}
+	if len(args.FilePatterns) == 0 {
+		args.FilePatterns = []string{"*"}
+	}
+	return args
+}
+
+// SymbolArgs decodes the call's arguments as an identifier. Tool-specific
+// parameters take precedence over the simple "arg" field.
+func (tc ToolCall) SymbolArgs() SymbolArgs {
+	symbol := firstStringParam(tc.Parameters, "symbol", "name")
+	if symbol == "" {
+		symbol = tc.Arg
+	}
+	return SymbolArgs{Symbol: symbol}
+}
+
+// PathArgs decodes the call's arguments as a path. Tool-specific parameters
+// take precedence over the simple "arg" field.
+func (tc ToolCall) PathArgs() PathArgs {
+	path := firstStringParam(tc.Parameters, "path", "file_path")
+	if path == "" {
+		path = tc.Arg
+	}
+	return PathArgs{Path: path}
+}
+
+// GetArg returns the effective argument string for tool execution, decoded
+// according to the tool: the search term for searches, the path for path-based
+// tools, the identifier for resolve_symbol. Unknown tools fall back to
+// search_term, then path.
 func (tc ToolCall) GetArg() string {
+	switch name := tc.GetToolName(); {
+	case name == "search_codebase" || name == "search_code":
+		return tc.SearchArgs().SearchTerm
+	case name == "resolve_symbol":
+		return tc.SymbolArgs().Symbol
+	case isPathTool(name):
+		return tc.PathArgs().Path
+	}
 	if tc.Arg != "" {
 		return tc.Arg
 	}
-	// Build arg from parameters
-	if st, ok := tc.Parameters["search_term"]; ok {
-		return fmt.Sprintf("%v", st)
+	if st := firstStringParam(tc.Parameters, "search_term"); st != "" {
+		return st
 	}
-	if p, ok := tc.Parameters["path"]; ok {
-		return fmt.Sprintf("%v", p)
+	return firstStringParam(tc.Parameters, "path")
+}
+
+// isPathTool reports whether the tool takes a single path argument.
+func isPathTool(name string) bool {
+	switch name {
+	case "list_directory", "list_files", "browse_file", "skim_file", "outline_file", "find_dependents":
+		return true
+	}
+	return false
+}
+
+// firstStringParam returns the first non-empty string value among keys.
+func firstStringParam(params map[string]any, keys ...string) string {
+	for _, k := range keys {
+		if v, ok := params[k].(string); ok && v != "" {
+			return v
+		}
 	}
 	return ""
 }
 
+// stringsParam reads a parameter given either as a single string or as a
+// JSON array of strings, dropping empty values.
+func stringsParam(params map[string]any, key string) []string {
+	var out []string
+	switch v := params[key].(type) {
+	case string:
+		if v != "" {
+			out = append(out, v)
+		}
+	case []string:
+		for _, s := range v {
+			if s != "" {
+				out = append(out, s)
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// boolParam reads a boolean parameter, accepting JSON booleans and "true"/"false" strings.
+func boolParam(params map[string]any, key string) bool {
+	switch v := params[key].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
 // RetrievalResult holds the final output of the iterative retrieval.
 type RetrievalResult struct {
 	Elements   []types.CodeElement `json:"elements"`
@@ -117,6 +330,10 @@ type RetrievalResult struct {
 	Confidence int                 `json:"confidence"`
 	StopReason string              `json:"stop_reason"`
 	Metadata   map[string]any      `json:"metadata,omitempty"`
+
+	// Trace records each round: tool calls, candidates, files, confidence
+	// and budget usage.
+	Trace []RoundTrace `json:"trace,omitempty"`
 }
 
 // NewIterativeAgent creates a new iterative retrieval agent.
@@ -140,11 +357,14 @@ func (ia *IterativeAgent) Retrieve(query string, pq *ProcessedQuery) (*Retrieval
 	ia.rounds = 0
 	ia.toolCallHistory = nil
 	ia.iterationHistory = nil
+	ia.searchMatches = make(map[string][]LineMatch)
+	ia.keepFiles = nil
+	ia.elementSources = make(map[string]string)
 
 	// ─── Round 1: Initial assessment (no code context yet) ───
 	round1Result, err := ia.executeRound1(query, pq)
 	if err != nil {
-		log.Printf("[agent] round 1 error: %v", err)
+		logger.Warn("round 1 failed", "err", err)
 		return &RetrievalResult{StopReason: "error"}, err
 	}
 
@@ -159,15 +379,32 @@ func (ia *IterativeAgent) Retrieve(query string, pq *ProcessedQuery) (*Retrieval
 	ia.initializeAdaptiveParams(queryComplexity)
 
 	// ─── Execute Round 1 ───
-	log.Printf("[agent] Executing Round 1 search")
+	logger.Debug("executing round 1 search")
 
 	// Step 1: Standard retrieval (BM25)
 	var standardElements []types.CodeElement
 	if res, toolErr := ia.toolExecutor.searchCode(query); toolErr == nil && res != nil {
 		standardElements = append(standardElements, res.Elements...)
-		log.Printf("[agent] Standard retrieval found %d elements", len(standardElements))
+		ia.tagSource(standardElements, "bm25")
+		logger.Debug("standard retrieval", "elements", len(standardElements))
 	} else if toolErr != nil {
-		log.Printf("[agent] Standard retrieval error: %v", toolErr)
+		logger.Warn("standard retrieval failed", "err", toolErr)
+	}
+
+	// A question about one file starts from that file's elements
+	if ia.config.FocusFile != "" {
+		focus := ia.toolExecutor.FindElementsForFile(ia.config.FocusFile)
+		ia.tagSource(focus, "focus_file")
+		standardElements = append(focus, standardElements...)
+		logger.Debug("seeded query with focus file", "path", ia.config.FocusFile, "elements", len(focus))
+	}
+
+	// Architecture questions are best grounded in the project's own docs
+	if pq.QueryType == "overview" && ia.config.OverviewDocs && ia.config.MaxOverviewDocs > 0 {
+		docs := ia.toolExecutor.projectDocs(ia.config.MaxOverviewDocs)
+		ia.tagSource(docs, "docs")
+		standardElements = append(docs, standardElements...)
+		logger.Debug("seeded overview query with documentation", "sections", len(docs))
 	}
 
 	// Step 2: Tool calls execution (Regex / Filesystem)
@@ -175,34 +412,13 @@ func (ia *IterativeAgent) Retrieve(query string, pq *ProcessedQuery) (*Retrieval
 	if len(round1Result.ToolCalls) > 0 {
 		for _, tc := range round1Result.ToolCalls {
 			toolName := tc.GetToolName()
-			params := tc.Parameters
 
 			if toolName == "search_codebase" || toolName == "search_code" {
-				searchTerm, _ := params["search_term"].(string)
-				if searchTerm == "" {
-					searchTerm = tc.GetArg()
-				}
-				filePattern, _ := params["file_pattern"].(string)
-				if filePattern == "" {
-					filePattern = "*"
-				}
-				useRegex, _ := params["use_regex"].(bool)
-
-				candidates := ia.toolExecutor.ExecuteSearchCodebase(searchTerm, filePattern, useRegex)
-				log.Printf("[agent] search_codebase(%q) returned %d files", searchTerm, len(candidates))
-
-				// Map directly to elements using the exact matched files
-				for _, c := range candidates {
-					elements := ia.toolExecutor.FindElementsForFile(c.FilePath)
-					toolElements = append(toolElements, elements...)
-				}
+				toolElements = append(toolElements, ia.searchCodebase(tc)...)
 			} else if toolName == "list_directory" || toolName == "list_files" {
-				dirPath, _ := params["path"].(string)
-				if dirPath == "" {
-					dirPath = tc.GetArg()
-				}
+				dirPath := tc.PathArgs().Path
 				candidates := ia.toolExecutor.ExecuteListDirectory(dirPath)
-				log.Printf("[agent] list_directory(%q) returned %d files", dirPath, len(candidates))
+				logger.Debug("list_directory", "path", dirPath, "files", len(candidates))
 
 				// Map directly to elements
 				for _, c := range candidates {
@@ -215,6 +431,7 @@ func (ia *IterativeAgent) Retrieve(query string, pq *ProcessedQuery) (*Retrieval
 
 					// Find elements (skips directories naturally as they aren't in elements)
 					elements := ia.toolExecutor.FindElementsForFile(c.FilePath)
+					ia.tagSource(elements, "file_selection")
 					toolElements = append(toolElements, elements...)
 				}
 			}
@@ -222,33 +439,34 @@ func (ia *IterativeAgent) Retrieve(query string, pq *ProcessedQuery) (*Retrieval
 	}
 
 	// Step 3: Merge and deduplicate
-	log.Printf("[agent] Merging %d standard and %d tool elements", len(standardElements), len(toolElements))
+	logger.Debug("merging round 1 elements", "standard", len(standardElements), "tool", len(toolElements))
 	var mergedElements []types.CodeElement
 	mergedElements = append(mergedElements, standardElements...)
 	mergedElements = append(mergedElements, toolElements...)
 
-	log.Printf("[agent] Calling removeDuplicatesWithContainment")
 	mergedElements = ia.removeDuplicatesWithContainment(mergedElements)
-	log.Printf("[agent] After deduplication: %d elements remain", len(mergedElements))
+	logger.Debug("deduplicated round 1 elements", "elements", len(mergedElements))
 
 	// Step 4: Graph expansion (replaces LLM Semantic Bridge)
-	log.Printf("[agent] Calling expandWithGraph")
 	ia.gatheredElements = ia.expandWithGraph(mergedElements, 2)
-	log.Printf("[agent] expandWithGraph returned %d elements", len(ia.gatheredElements))
+	logger.Debug("graph expansion", "elements", len(ia.gatheredElements))
+	ia.tagSource(ia.gatheredElements, "graph")
+	if ia.config.IncludeFileDependencies {
+		ia.gatheredElements = ia.includeFileDependencies(ia.gatheredElements)
+		ia.tagSource(ia.gatheredElements, "file_dependency")
+	}
 
 	// Record round 1 history
-	totalLines := ia.calculateTotalLines(ia.gatheredElements)
-	ia.iterationHistory = append(ia.iterationHistory, map[string]any{
-		"round":        1,
-		"confidence":   round1Result.Confidence,
-		"elements":     len(ia.gatheredElements),
-		"total_lines":  totalLines,
-		"budget_usage": float64(totalLines) / float64(ia.adaptiveLineBudget) * 100,
-	})
+	ia.traceRound(1, round1Result.Confidence, round1Result.ToolCalls, nil, len(standardElements)+len(toolElements))
 
 	ia.rounds = 1
 	lastConfidence := round1Result.Confidence
 	var stopReason string
+	// Round 1 always searches; a confident round 1 that asked for no tools
+	// marks a general-knowledge question that needs no further grounding
+	retrievalRounds := 1
+	generalKnowledge := len(round1Result.ToolCalls) == 0 && round1Result.Confidence >= ia.confidenceThreshold
+	prevIDs := elementIDSet(ia.gatheredElements)
 
 	// ─── Rounds 2..N: Assessment with context ───
 	for round := 2; round <= ia.maxIterations; round++ {
@@ -256,7 +474,7 @@ func (ia *IterativeAgent) Retrieve(query string, pq *ProcessedQuery) (*Retrieval
 
 		roundResult, err := ia.executeRoundN(query, pq, round)
 		if err != nil {
-			log.Printf("[agent] round %d error: %v", round, err)
+			logger.Warn("round failed", "round", round, "err", err)
 			stopReason = "error"
 			break
 		}
@@ -267,31 +485,27 @@ func (ia *IterativeAgent) Retrieve(query string, pq *ProcessedQuery) (*Retrieval
 		// Filter elements based on keep_files
 		if len(roundResult.KeepFiles) > 0 {
 			ia.gatheredElements = ia.filterElementsByKeepFiles(ia.gatheredElements, roundResult.KeepFiles)
+			ia.keepFiles = roundResult.KeepFiles
 		}
 
 		numBefore := len(ia.gatheredElements)
 		lastConfidence = roundResult.Confidence
 
 		// Log element filtering
-		log.Printf("[agent] Round %d element filtering: %d -> %d elements",
-			round, numBefore, len(ia.gatheredElements))
-		log.Printf("[agent] Round %d confidence: %d", round, lastConfidence)
+		logger.Debug("round assessed", "round", round, "confidence", lastConfidence,
+			"elements_before", numBefore, "elements_after", len(ia.gatheredElements))
 
 		// Calculate metrics
-		totalLines = ia.calculateTotalLines(ia.gatheredElements)
-		budgetUsage := float64(totalLines) / float64(ia.adaptiveLineBudget) * 100
-		ia.iterationHistory = append(ia.iterationHistory, map[string]any{
-			"round":        round,
-			"confidence":   lastConfidence,
-			"elements":     len(ia.gatheredElements),
-			"total_lines":  totalLines,
-			"budget_usage": budgetUsage,
-		})
+		ia.traceRound(round, lastConfidence, roundResult.ToolCalls, roundResult.KeepFiles, 0)
 
 		// Check stopping conditions
 		if lastConfidence >= ia.confidenceThreshold {
-			stopReason = "confidence_threshold_reached"
-			break
+			if retrievalRounds >= ia.config.MinRoundsBeforeStop || generalKnowledge || len(roundResult.ToolCalls) == 0 {
+				stopReason = "confidence_threshold_reached"
+				break
+			}
+			logger.Debug("confidence ignored before minimum rounds", "round", round, "confidence", lastConfidence,
+				"retrieval_rounds", retrievalRounds, "min_rounds", ia.config.MinRoundsBeforeStop)
 		}
 		if ia.totalTokensUsed >= ia.config.MaxTokenBudget {
 			stopReason = "budget_exhausted"
@@ -300,26 +514,56 @@ func (ia *IterativeAgent) Retrieve(query string, pq *ProcessedQuery) (*Retrieval
 
 		// Execute round N tool calls
 		if len(roundResult.ToolCalls) > 0 {
+			candidates := 0
 			for _, tc := range roundResult.ToolCalls {
 				toolName := tc.GetToolName()
+				if toolName == "search_codebase" || toolName == "search_code" {
+					elements := ia.searchCodebase(tc)
+					ia.gatheredElements = append(ia.gatheredElements, elements...)
+					candidates += len(elements)
+					continue
+				}
 				result, err := ia.toolExecutor.Execute(toolName, tc.GetArg())
 				if err != nil {
-					log.Printf("[agent] tool %s error: %v", toolName, err)
+					logger.Warn("tool failed", "tool", toolName, "err", err)
 					continue
 				}
+				ia.tagSource(result.Elements, toolName)
 				ia.gatheredElements = append(ia.gatheredElements, result.Elements...)
+				candidates += len(result.Elements)
+			}
+			if ia.config.IncludeFileDependencies {
+				ia.gatheredElements = ia.includeFileDependencies(ia.gatheredElements)
+				ia.tagSource(ia.gatheredElements, "file_dependency")
 			}
 			// Deduplicate after each round
 			ia.gatheredElements = ia.removeDuplicatesWithContainment(ia.gatheredElements)
+			if ia.config.GraphNeighbors {
+				candidates += ia.addGraphNeighbors()
+			}
+			ia.traceGathered(candidates)
+			retrievalRounds++
 		} else if lastConfidence < ia.confidenceThreshold {
 			stopReason = "no_more_actions"
 			break
 		}
+
+		// Stop once the model keeps asking for what it already has
+		ids := elementIDSet(ia.gatheredElements)
+		if change := idSetChange(prevIDs, ids); change <= ia.config.ConvergenceThreshold {
+			logger.Debug("round converged", "round", round, "change_pct", change*100)
+			stopReason = "converged"
+			break
+		}
+		prevIDs = ids
 	}
 
 	if stopReason == "" {
 		stopReason = "max_rounds"
 	}
+	if len(ia.iterationHistory) > 0 {
+		ia.iterationHistory[len(ia.iterationHistory)-1].StopReason = stopReason
+	}
 
 	// Final deduplication
 	elements := ia.removeDuplicatesWithContainment(ia.gatheredElements)
@@ -329,19 +573,72 @@ func (ia *IterativeAgent) Retrieve(query string, pq *ProcessedQuery) (*Retrieval
 		Rounds:     ia.rounds,
 		Confidence: lastConfidence,
 		StopReason: stopReason,
+		Trace:      ia.iterationHistory,
 		Metadata: map[string]any{
 			"query_complexity": queryComplexity,
 			"query_type":       pq.QueryType,
 			"tokens_used":      ia.totalTokensUsed,
+			"element_sources":  ia.elementSources,
 			"adaptive_params": map[string]any{
 				"max_iterations":       ia.maxIterations,
 				"confidence_threshold": ia.confidenceThreshold,
-				"line_budget":          ia.adaptiveLineBudget,
+				"budget_mode":          string(ia.budgetMode()),
+				"budget":               ia.adaptiveBudget,
 			},
 		},
 	}, nil
 }
 
+// searchCodebase runs a search_codebase call with all its arguments (search
+// term, file patterns, regex flag) and returns the elements of the matched
+// files, recording the matched lines to show with them.
+func (ia *IterativeAgent) searchCodebase(tc ToolCall) []types.CodeElement {
+	args := tc.SearchArgs()
+	candidates := ia.toolExecutor.ExecuteSearchCodebase(args.SearchTerm, args.FilePatterns, args.UseRegex)
+	logger.Debug("search_codebase", "term", args.SearchTerm, "patterns", args.FilePatterns, "files", len(candidates))
+
+	var found []types.CodeElement
+	for _, c := range candidates {
+		key := filepath.ToSlash(c.FilePath)
+		ia.searchMatches[key] = append(ia.searchMatches[key], c.Matches...)
+		elements := ia.toolExecutor.FindElementsForFile(c.FilePath)
+		ia.tagSource(elements, "search_codebase")
+		found = append(found, elements...)
+	}
+	return found
+}
+
+// elementIDSet returns the set of IDs of elements.
+func elementIDSet(elements []types.CodeElement) map[string]bool {
+	ids := make(map[string]bool, len(elements))
+	for _, e := range elements {
+		ids[e.ID] = true
+	}
+	return ids
+}
+
+// idSetChange returns the fraction of IDs in either set that are not in both
+// (0 when the sets are equal, 1 when they are disjoint).
+func idSetChange(prev, cur map[string]bool) float64 {
+	union := len(prev)
+	diff := 0
+	for id := range cur {
+		if !prev[id] {
+			union++
+			diff++
+		}
+	}
+	for id := range prev {
+		if !cur[id] {
+			diff++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return float64(diff) / float64(union)
+}
+
 // initializeAdaptiveParams sets dynamic thresholds matching Python's _initialize_adaptive_parameters.
 func (ia *IterativeAgent) initializeAdaptiveParams(queryComplexity int) {
 	// Adaptive max iterations
@@ -359,21 +656,27 @@ func (ia *IterativeAgent) initializeAdaptiveParams(queryComplexity int) {
 		ia.confidenceThreshold = ia.config.ConfidenceThreshold
 	}
 
-	// Adaptive line budget
-	maxLines := ia.config.MaxTotalLines
-	if maxLines == 0 {
-		maxLines = 12000
+	// Adaptive budget
+	maxBudget := ia.config.MaxTotalLines
+	if maxBudget == 0 {
+		maxBudget = 12000
+	}
+	if ia.budgetMode() == BudgetTokens {
+		maxBudget = ia.config.MaxTotalTokens
+		if maxBudget == 0 {
+			maxBudget = 100000
+		}
 	}
 	if queryComplexity <= 30 {
-		ia.adaptiveLineBudget = int(float64(maxLines) * 0.6)
+		ia.adaptiveBudget = int(float64(maxBudget) * 0.6)
 	} else if queryComplexity <= 60 {
-		ia.adaptiveLineBudget = int(float64(maxLines) * 0.8)
+		ia.adaptiveBudget = int(float64(maxBudget) * 0.8)
 	} else {
-		ia.adaptiveLineBudget = maxLines
+		ia.adaptiveBudget = maxBudget
 	}
 
-	log.Printf("[agent] Adaptive params: max_iterations=%d, confidence_threshold=%d, line_budget=%d, query_complexity=%d",
-		ia.maxIterations, ia.confidenceThreshold, ia.adaptiveLineBudget, queryComplexity)
+	logger.Debug("adaptive params", "max_iterations", ia.maxIterations, "confidence_threshold", ia.confidenceThreshold,
+		"budget", ia.adaptiveBudget, "budget_mode", ia.budgetMode(), "query_complexity", queryComplexity)
 }
 
 // ─── Round 1: Initial assessment (no code context) ─────────────────
@@ -392,13 +695,45 @@ func (ia *IterativeAgent) executeRound1(query string, pq *ProcessedQuery) (*Roun
 	return ia.parseRound1Response(response)
 }
 
+// languageHint describes the repository's primary language for the prompts,
+// or returns "" when it is unknown.
+func (ia *IterativeAgent) languageHint() string {
+	lang := ia.config.PrimaryLanguage
+	if lang == "" {
+		return ""
+	}
+	hint := fmt.Sprintf("\n**Primary Language**: %s", lang)
+	if ext := util.ExtensionForLanguage(lang); ext != "" {
+		hint += fmt.Sprintf(" (use file_pattern \"*%s\" to target source files)", ext)
+	}
+	return hint + "\n"
+}
+
+// historyHint lists the earlier turns of the conversation for the round 1
+// prompt, or returns "" when there are none.
+func (ia *IterativeAgent) historyHint() string {
+	if history := formatHistory(ia.config.History); history != "" {
+		return "\n" + history
+	}
+	return ""
+}
+
+// focusHint names the file the question is about for the prompts, or
+// returns "" when there is none.
+func (ia *IterativeAgent) focusHint() string {
+	if ia.config.FocusFile == "" {
+		return ""
+	}
+	return fmt.Sprintf("**Focus File**: %s (the question is about this file; its code is already gathered)\n", ia.config.FocusFile)
+}
+
 func (ia *IterativeAgent) buildRound1Prompt(query string, pq *ProcessedQuery) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf(`You are a code analysis agent performing initial query assessment. You have NOT seen any code files yet.
 
 **Current User Query**: %s
-
+%s
 **Repository Structure**:
 ./%s
 
@@ -417,7 +752,7 @@ IMPORTANT: At this stage, you have NOT seen any code files yet. Base your confid
 2. Whether the question asks about standard patterns vs custom implementation
 3. Your general understanding of the technology/framework mentioned
 
-`, query, ""))
+`, query, ia.languageHint()+ia.focusHint()+ia.historyHint(), ""))
 
 	// Output format
 	sb.WriteString(`**Output Format** (JSON only):
@@ -462,7 +797,8 @@ If confidence < 95:
 **Tool Call Guidelines**:
 - Use search_codebase for finding specific terms, classes, functions
   * search_term: literal text or regex pattern to find in file contents
-  * file_pattern: SINGLE glob pattern per tool call to filter files (only one pattern allowed)
+  * file_pattern: glob pattern to filter files, or a list of patterns matched as alternatives
+    (e.g. "*.go" or ["*.go", "*.py"]); "**" matches any number of directories
   * use_regex: true if search_term is regex, false for literal (default: false)
 
 - Use list_directory to explore directory structure
@@ -487,7 +823,7 @@ func (ia *IterativeAgent) parseRound1Response(response string) (*RoundResult, er
 
 	jsonStr := extractJSON(response)
 	if jsonStr == "" {
-		result.Confidence = 90
+		result.Confidence = ia.config.Round1FallbackConfidence
 		result.Reasoning = response
 		return result, nil
 	}
@@ -501,7 +837,7 @@ func (ia *IterativeAgent) parseRound1Response(response string) (*RoundResult, er
 	}
 
 	if err := json.Unmarshal([]byte(jsonStr), &parsed); err != nil {
-		result.Confidence = 90
+		result.Confidence = ia.config.Round1FallbackConfidence
 		result.Reasoning = response
 		return result, nil
 	}
@@ -519,17 +855,17 @@ func (ia *IterativeAgent) parseRound1Response(response string) (*RoundResult, er
 func (ia *IterativeAgent) executeRoundN(query string, pq *ProcessedQuery, round int) (*RoundResult, error) {
 	prompt := ia.buildRoundNPrompt(query, pq, round)
 
-	log.Printf("[agent] Making ChatCompletion call for Round %d", round)
+	logger.Debug("requesting round assessment", "round", round)
 	response, err := ia.client.ChatCompletion([]llm.ChatMessage{
 		{Role: "system", Content: "You are a precise code analysis agent. Respond in specified format only."},
 		{Role: "user", Content: prompt},
 	}, ia.config.Temperature, ia.config.MaxTokensAgent)
 	if err != nil {
-		log.Printf("[agent] ChatCompletion error: %v", err)
+		logger.Warn("round assessment failed", "round", round, "err", err)
 		return nil, fmt.Errorf("LLM call round %d: %w", round, err)
 	}
 
-	log.Printf("[agent] Done ChatCompletion. Parsing response.")
+	logger.Debug("parsing round assessment", "round", round)
 	return ia.parseRoundNResponse(response, round)
 }
 
@@ -537,29 +873,34 @@ func (ia *IterativeAgent) buildRoundNPrompt(query string, pq *ProcessedQuery, ro
 	var sb strings.Builder
 
 	// Calculate resource usage
-	totalLines := ia.calculateTotalLines(ia.gatheredElements)
-	remainingBudget := ia.adaptiveLineBudget - totalLines
+	unit := ia.budgetMode()
+	used := ia.budgetUsed(ia.gatheredElements)
+	remainingBudget := ia.adaptiveBudget - used
 	remainingIterations := ia.maxIterations - round
-	budgetUsagePct := float64(totalLines) / float64(ia.adaptiveLineBudget) * 100
+	budgetUsagePct := ia.budgetUsage(ia.gatheredElements)
+	lowBudget := 2000
+	if unit == BudgetTokens {
+		lowBudget = 16000
+	}
 
 	sb.WriteString(fmt.Sprintf(`You are a cost-aware code analysis agent in round %d of iterative retrieval.
 
 **Current User Query**: %s
-
+%s
 **Repository Structure**:
 Not available
 
-`, round, query))
+`, round, query, ia.languageHint()+ia.focusHint()))
 
 	// Resource status
 	sb.WriteString(fmt.Sprintf(`
 **Current Resource Usage**:
-- Current code lines: %d / %d (%.1f%% used)
-- Remaining budget: %d lines
+- Current code %s: %d / %d (%.1f%% used)
+- Remaining budget: %d %s
 - Current round: %d / %d
 - Remaining iterations: %d
 
-`, totalLines, ia.adaptiveLineBudget, budgetUsagePct, remainingBudget, round, ia.maxIterations, remainingIterations))
+`, unit, used, ia.adaptiveBudget, budgetUsagePct, remainingBudget, unit, round, ia.maxIterations, remainingIterations))
 
 	// Current elements
 	sb.WriteString(fmt.Sprintf("**Current Retrieved Elements**:\n%s\n", ia.formatElementsWithMetadata()))
@@ -597,7 +938,7 @@ Base your confidence on:
 2. **Confidence vs Cost Trade-off**:
    - If budget usage > 70%%: Be very selective, only keep essential files
    - If budget usage > 85%%: Only keep files critical for answering the query
-   - If remaining_budget < 2000 lines: Do NOT request more tool calls unless critical gaps exist
+   - If remaining_budget < %d %s: Do NOT request more tool calls unless critical gaps exist
 
 3. **Stopping Criteria** (when to set confidence >= %d):
    - You have enough information to answer the query reasonably well
@@ -611,7 +952,7 @@ Base your confidence on:
    - Do NOT repeat previous tool calls; use new terms/paths only
    - Consider if the information gap is worth the cost
 
-`, ia.confidenceThreshold, ia.confidenceThreshold))
+`, lowBudget, unit, ia.confidenceThreshold, ia.confidenceThreshold))
 
 	// Output format
 	sb.WriteString(fmt.Sprintf(`**Your Task**:
@@ -650,12 +991,23 @@ If continuing (confidence < %d and budget available):
 **Tool Call Guidelines**:
 - Use search_codebase for finding specific terms, classes, functions
   * search_term: literal text or regex pattern to find in file contents
-  * file_pattern: SINGLE glob pattern per tool call to filter files (only one pattern allowed)
+  * file_pattern: glob pattern to filter files, or a list of patterns matched as alternatives
+    (e.g. "*.go" or ["*.go", "*.py"]); "**" matches any number of directories
   * use_regex: true if search_term is regex, false for literal (default: false)
 
 - Use list_directory to explore directory structure
   * path: directory path to list
 
+- Use outline_file for an ordered overview of a file (imports, types, functions) without reading its code
+  * path: file path to outline
+
+- Use find_dependents to see which files import a given file (impact analysis)
+  * path: file path whose dependents to list
+
+- Use resolve_symbol to jump to the definition of a function, method, class or type
+  referenced in the code you have (more precise than search_codebase for this)
+  * symbol: identifier as written, e.g. "helper" or "Server.Start"
+
 - Do NOT use the model's native tool_calls format. Instead, include tool call instructions in your text response content in a parseable format
 
 **CRITICAL**:
@@ -673,7 +1025,7 @@ func (ia *IterativeAgent) parseRoundNResponse(response string, round int) (*Roun
 
 	jsonStr := extractJSON(response)
 	if jsonStr == "" {
-		result.Confidence = 95
+		result.Confidence = ia.config.RoundNFallbackConfidence
 		result.Reasoning = response
 		return result, nil
 	}
@@ -686,7 +1038,7 @@ func (ia *IterativeAgent) parseRoundNResponse(response string, round int) (*Roun
 	}
 
 	if err := json.Unmarshal([]byte(jsonStr), &parsed); err != nil {
-		result.Confidence = 95
+		result.Confidence = ia.config.RoundNFallbackConfidence
 		result.Reasoning = response
 		return result, nil
 	}
@@ -706,8 +1058,14 @@ func (ia *IterativeAgent) recordToolCalls(round int, calls []ToolCall) {
 		params := tc.Parameters
 		if params == nil {
 			params = map[string]any{}
-			if tc.GetArg() != "" {
-				params["search_term"] = tc.GetArg()
+			if arg := tc.GetArg(); arg != "" {
+				key := "search_term"
+				if isPathTool(tc.GetToolName()) {
+					key = "path"
+				} else if tc.GetToolName() == "resolve_symbol" {
+					key = "symbol"
+				}
+				params[key] = arg
 			}
 		}
 		ia.toolCallHistory = append(ia.toolCallHistory, toolCallRecord{
@@ -733,12 +1091,21 @@ func (ia *IterativeAgent) formatToolCallHistory(currentRound int) string {
 	return sb.String()
 }
 
-// formatElementsWithMetadata formats gathered elements for round N prompt.
+// formatElementsWithMetadata formats gathered elements for round N prompt,
+// listing at most MaxPromptElements of them with kept files first.
 func (ia *IterativeAgent) formatElementsWithMetadata() string {
+	limit := ia.config.MaxPromptElements
+	if limit <= 0 {
+		limit = DefaultAgentConfig().MaxPromptElements
+	}
+	elements := ia.prioritizeKeptElements(ia.gatheredElements)
+
 	var sb strings.Builder
-	for i, elem := range ia.gatheredElements {
-		if i >= 20 {
-			sb.WriteString(fmt.Sprintf("\n... and %d more elements\n", len(ia.gatheredElements)-20))
+	for i, elem := range elements {
+		if i >= limit {
+			elided := len(elements) - limit
+			logger.Debug("eliding gathered elements from prompt", "listed", limit, "total", len(elements), "elided", elided)
+			sb.WriteString(fmt.Sprintf("\n... and %d more elements\n", elided))
 			break
 		}
 
@@ -751,8 +1118,10 @@ func (ia *IterativeAgent) formatElementsWithMetadata() string {
 		sb.WriteString(fmt.Sprintf("   Repo: %s\n", repoName))
 		sb.WriteString(fmt.Sprintf("   Type: %s\n", elem.Type))
 
-		// Source info
-		source := "Retrieval"
+		source := ia.elementSources[elem.ID]
+		if source == "" {
+			source = "retrieval"
+		}
 		sb.WriteString(fmt.Sprintf("   Source: %s\n", source))
 
 		lines := elem.EndLine - elem.StartLine + 1
@@ -764,10 +1133,24 @@ func (ia *IterativeAgent) formatElementsWithMetadata() string {
 		if elem.Signature != "" {
 			sb.WriteString(fmt.Sprintf("   - def %s\n", elem.Signature))
 		}
+		for _, m := range ia.elementMatches(elem) {
+			sb.WriteString(fmt.Sprintf("   Match L%d: %s\n", m.Line, m.Excerpt))
+		}
 	}
 	return sb.String()
 }
 
+// elementMatches returns the search_codebase hits that fall inside elem.
+func (ia *IterativeAgent) elementMatches(elem types.CodeElement) []LineMatch {
+	var out []LineMatch
+	for _, m := range ia.searchMatches[filepath.ToSlash(elem.RelativePath)] {
+		if elem.Type == "file" || (m.Line >= elem.StartLine && m.Line <= elem.EndLine) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
 // calculateTotalLines calculates total lines across all elements.
 func (ia *IterativeAgent) calculateTotalLines(elements []types.CodeElement) int {
 	total := 0
@@ -781,59 +1164,132 @@ func (ia *IterativeAgent) calculateTotalLines(elements []types.CodeElement) int
 	return total
 }
 
+// calculateTotalTokens estimates the tokens across all elements at four
+// characters per token.
+func (ia *IterativeAgent) calculateTotalTokens(elements []types.CodeElement) int {
+	total := 0
+	for _, elem := range elements {
+		total += (len(elem.Code) + 3) / 4
+	}
+	return total
+}
+
+// budgetMode returns the configured budget mode, defaulting to lines.
+func (ia *IterativeAgent) budgetMode() BudgetMode {
+	if ia.config.BudgetMode == BudgetTokens {
+		return BudgetTokens
+	}
+	return BudgetLines
+}
+
+// budgetUsed measures elements in the unit of the budget mode.
+func (ia *IterativeAgent) budgetUsed(elements []types.CodeElement) int {
+	if ia.budgetMode() == BudgetTokens {
+		return ia.calculateTotalTokens(elements)
+	}
+	return ia.calculateTotalLines(elements)
+}
+
+// budgetUsage returns the percentage of the adaptive budget used by elements.
+func (ia *IterativeAgent) budgetUsage(elements []types.CodeElement) float64 {
+	return float64(ia.budgetUsed(elements)) / float64(ia.adaptiveBudget) * 100
+}
+
 // filterElementsByKeepFiles filters elements to only include those in the keep_files list.
 func (ia *IterativeAgent) filterElementsByKeepFiles(elements []types.CodeElement, keepFiles []string) []types.CodeElement {
 	if len(keepFiles) == 0 {
 		return elements
 	}
 
-	keepSet := make(map[string]bool)
-	for _, f := range keepFiles {
-		keepSet[f] = true
-		// Also add without leading repo prefix
-		parts := strings.SplitN(f, "/", 2)
-		if len(parts) > 1 {
-			keepSet[parts[1]] = true
-		}
+	if ia.config.FocusFile != "" {
+		keepFiles = append([]string{ia.config.FocusFile}, keepFiles...)
 	}
-
+	keepSet := keepFileSet(keepFiles)
 	var kept []types.CodeElement
 	for _, elem := range elements {
-		path := elem.RelativePath
-		repoPath := ""
-		if elem.RepoName != "" {
-			repoPath = elem.RepoName + "/" + path
+		if matchesKeepFiles(elem, keepSet, keepFiles) {
+			kept = append(kept, elem)
 		}
+	}
 
-		// Check various matching strategies
-		if keepSet[path] || keepSet[repoPath] {
-			kept = append(kept, elem)
-			continue
+	// If filtering removed everything, return originals (safety fallback)
+	if len(kept) == 0 && len(elements) > 0 {
+		return elements
+	}
+
+	return kept
+}
+
+// tagSource records source as the way elements were surfaced, keeping the
+// source of any element seen earlier.
+func (ia *IterativeAgent) tagSource(elements []types.CodeElement, source string) {
+	if ia.elementSources == nil {
+		ia.elementSources = make(map[string]string)
+	}
+	for _, elem := range elements {
+		if _, ok := ia.elementSources[elem.ID]; !ok {
+			ia.elementSources[elem.ID] = source
 		}
+	}
+}
 
-		// Check with element name suffix (path:ClassName or path:function_name)
-		pathWithName := path + ":" + elem.Name
-		repoPathWithName := repoPath + ":" + elem.Name
-		if keepSet[pathWithName] || keepSet[repoPathWithName] {
-			kept = append(kept, elem)
-			continue
+// prioritizeKeptElements returns elements with those matching the latest
+// keep_files first, preserving the relative order within each group.
+func (ia *IterativeAgent) prioritizeKeptElements(elements []types.CodeElement) []types.CodeElement {
+	if len(ia.keepFiles) == 0 {
+		return elements
+	}
+	keepSet := keepFileSet(ia.keepFiles)
+	ordered := make([]types.CodeElement, 0, len(elements))
+	var rest []types.CodeElement
+	for _, elem := range elements {
+		if matchesKeepFiles(elem, keepSet, ia.keepFiles) {
+			ordered = append(ordered, elem)
+		} else {
+			rest = append(rest, elem)
 		}
+	}
+	return append(ordered, rest...)
+}
 
-		// Check if any keep_file is a prefix match
-		for _, kf := range keepFiles {
-			if strings.HasSuffix(path, kf) || strings.HasSuffix(repoPath, kf) {
-				kept = append(kept, elem)
-				break
-			}
+// keepFileSet indexes keep_files entries, also without their leading repo prefix.
+func keepFileSet(keepFiles []string) map[string]bool {
+	keepSet := make(map[string]bool)
+	for _, f := range keepFiles {
+		keepSet[f] = true
+		parts := strings.SplitN(f, "/", 2)
+		if len(parts) > 1 {
+			keepSet[parts[1]] = true
 		}
 	}
+	return keepSet
+}
 
-	// If filtering removed everything, return originals (safety fallback)
-	if len(kept) == 0 && len(elements) > 0 {
-		return elements
+// matchesKeepFiles reports whether elem is named by a keep_files entry.
+func matchesKeepFiles(elem types.CodeElement, keepSet map[string]bool, keepFiles []string) bool {
+	path := elem.RelativePath
+	repoPath := ""
+	if elem.RepoName != "" {
+		repoPath = elem.RepoName + "/" + path
 	}
 
-	return kept
+	// Check various matching strategies
+	if keepSet[path] || keepSet[repoPath] {
+		return true
+	}
+
+	// Check with element name suffix (path:ClassName or path:function_name)
+	if keepSet[path+":"+elem.Name] || keepSet[repoPath+":"+elem.Name] {
+		return true
+	}
+
+	// Check if any keep_file is a prefix match
+	for _, kf := range keepFiles {
+		if strings.HasSuffix(path, kf) || strings.HasSuffix(repoPath, kf) {
+			return true
+		}
+	}
+	return false
 }
 
 func extractJSON(s string) string {
@@ -863,7 +1319,7 @@ func extractJSON(s string) string {
 
 // deduplicateElements was replaced by removeDuplicatesWithContainment to match Python's logic.
 func (ia *IterativeAgent) removeDuplicatesWithContainment(elements []types.CodeElement) []types.CodeElement {
-	log.Printf("[agent] removeDuplicatesWithContainment starting with %d elements", len(elements))
+	logger.Debug("removing duplicates", "elements", len(elements))
 	// First remove exact ID duplicates
 	seen := make(map[string]bool)
 	var unique []types.CodeElement
@@ -896,44 +1352,7 @@ func (ia *IterativeAgent) removeDuplicatesWithContainment(elements []types.CodeE
 			final = append(final, group[0])
 			continue
 		}
-
-		// Sort by priority (file > class > function, then line range size, then start line)
-		sort.Slice(group, func(i, j int) bool {
-			e1 := group[i]
-			e2 := group[j]
-
-			p1 := getTypePriority(e1.Type)
-			p2 := getTypePriority(e2.Type)
-			if p1 != p2 {
-				return p1 > p2 // Higher priority first
-			}
-
-			s1 := e1.EndLine - e1.StartLine
-			s2 := e2.EndLine - e2.StartLine
-			if s1 != s2 {
-				return s1 > s2 // Larger range first
-			}
-
-			return e1.StartLine < e2.StartLine
-		})
-
-		var kept []types.CodeElement
-		for _, elem := range group {
-			contained := false
-			for _, k := range kept {
-				// check if k contains elem
-				// Python: kept_start <= start and end <= kept_end and (kept_start < start or end < kept_end)
-				if k.StartLine <= elem.StartLine && elem.EndLine <= k.EndLine &&
-					(k.StartLine < elem.StartLine || elem.EndLine < k.EndLine) {
-					contained = true
-					break
-				}
-			}
-			if !contained {
-				kept = append(kept, elem)
-			}
-		}
-		final = append(final, kept...)
+		final = append(final, dropContained(group)...)
 	}
 
 	// Python's IterativeAgent seems to preserve original ordering (mostly), but we grouped them.
@@ -953,6 +1372,78 @@ func (ia *IterativeAgent) removeDuplicatesWithContainment(elements []types.CodeE
 	return orderedFinal
 }
 
+// dropContained removes the elements of one file that lie strictly inside
+// another element of the same or higher type priority (file > class >
+// function), e.g. a method inside its kept class. Python visits elements by
+// priority, then size, and drops those inside an already kept range; since a
+// range strictly containing another is larger, that is the same rule.
+//
+// Instead of comparing every pair, it sweeps the ranges by start line (longest
+// first), tracking the furthest end line reached by each priority so far, so
+// large files cost O(n log n) rather than O(n²) per round.
+func dropContained(group []types.CodeElement) []types.CodeElement {
+	const levels = 4 // getTypePriority values
+	sorted := make([]int, len(group))
+	for i := range sorted {
+		sorted[i] = i
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := group[sorted[i]], group[sorted[j]]
+		if a.StartLine != b.StartLine {
+			return a.StartLine < b.StartLine
+		}
+		return a.EndLine > b.EndLine
+	})
+
+	// maxEnd[p] is the furthest end line of a range of priority p starting
+	// before the current start line; runEnd[p] covers the current start line
+	// but only ranges ending after the element being checked.
+	var maxEnd, runEnd, pendingEnd [levels]int
+	for p := range maxEnd {
+		maxEnd[p], runEnd[p], pendingEnd[p] = -1, -1, -1
+	}
+	contained := make([]bool, len(group))
+	for i := 0; i < len(sorted); {
+		// Elements sharing a start line, longest first
+		j := i
+		for j < len(sorted) && group[sorted[j]].StartLine == group[sorted[i]].StartLine {
+			j++
+		}
+		for k := i; k < j; k++ {
+			elem := group[sorted[k]]
+			if k > i && elem.EndLine < group[sorted[k-1]].EndLine {
+				// Ranges already seen in this run now end strictly later
+				for p := range runEnd {
+					runEnd[p] = max(runEnd[p], pendingEnd[p])
+				}
+			}
+			prio := min(getTypePriority(elem.Type), levels-1)
+			for p := prio; p < levels; p++ {
+				// A range starting earlier needs only to reach as far; one
+				// starting on the same line has to end later
+				if maxEnd[p] >= elem.EndLine || runEnd[p] > elem.EndLine {
+					contained[sorted[k]] = true
+					break
+				}
+			}
+			pendingEnd[prio] = max(pendingEnd[prio], elem.EndLine)
+		}
+		for p := range maxEnd {
+			maxEnd[p] = max(maxEnd[p], pendingEnd[p])
+			runEnd[p], pendingEnd[p] = -1, -1
+		}
+		i = j
+	}
+
+	var kept []types.CodeElement
+	for i, elem := range group {
+		if !contained[i] {
+			kept = append(kept, elem)
+		}
+	}
+	return kept
+}
+
 func getTypePriority(t string) int {
 	switch t {
 	case "file":
@@ -968,7 +1459,7 @@ func getTypePriority(t string) int {
 // ─── Graph Expansion (matching Python's CodeGraphs inclusion) ───
 
 func (ia *IterativeAgent) expandWithGraph(elements []types.CodeElement, maxHops int) []types.CodeElement {
-	log.Printf("[agent] expandWithGraph starting with %d elements", len(elements))
+	logger.Debug("expanding with graph", "elements", len(elements))
 	if ia.graphs == nil || len(elements) == 0 {
 		return elements
 	}
@@ -983,11 +1474,9 @@ func (ia *IterativeAgent) expandWithGraph(elements []types.CodeElement, maxHops
 		limit = len(elements)
 	}
 
-	log.Printf("[agent] expandWithGraph loop. limit=%d", limit)
 	for i := 0; i < limit; i++ {
 		elem := elements[i]
 		relatedIDs := ia.graphs.GetRelatedElements(elem.ID, maxHops)
-		log.Printf("[agent] element %d (ID %s) has %d related elements", i, elem.ID, len(relatedIDs))
 		for _, relatedID := range relatedIDs {
 			if _, exists := expanded[relatedID]; !exists {
 				if relatedElem, ok := ia.toolExecutor.GetElement(relatedID); ok {
@@ -1002,7 +1491,94 @@ func (ia *IterativeAgent) expandWithGraph(elements []types.CodeElement, maxHops
 		result = append(result, elem)
 	}
 
-	log.Printf("[agent] expandWithGraph returning %d expanded elements", len(result))
+	logger.Debug("graph expansion done", "elements", len(result))
+	return result
+}
+
+// graphNeighborSeeds is how many of the most relevant gathered elements
+// addGraphNeighbors expands.
+const graphNeighborSeeds = 5
+
+// addGraphNeighbors appends the one-hop graph neighbors of the most relevant
+// gathered elements (keep_files first) that are not gathered yet, up to
+// MaxGraphNeighbors and while they fit in the adaptive budget. It returns
+// the number of elements added.
+func (ia *IterativeAgent) addGraphNeighbors() int {
+	if ia.graphs == nil || ia.config.MaxGraphNeighbors <= 0 {
+		return 0
+	}
+	present := make(map[string]bool, len(ia.gatheredElements))
+	for _, elem := range ia.gatheredElements {
+		present[elem.ID] = true
+	}
+	seeds := ia.prioritizeKeptElements(ia.gatheredElements)
+	if len(seeds) > graphNeighborSeeds {
+		seeds = seeds[:graphNeighborSeeds]
+	}
+
+	used := ia.budgetUsed(ia.gatheredElements)
+	var added []types.CodeElement
+	for _, seed := range seeds {
+		for _, id := range ia.graphs.GetRelatedElements(seed.ID, 1) {
+			if len(added) == ia.config.MaxGraphNeighbors {
+				break
+			}
+			if present[id] {
+				continue
+			}
+			neighbor, ok := ia.toolExecutor.GetElement(id)
+			if !ok || !ia.toolExecutor.Allowed(neighbor) {
+				continue
+			}
+			cost := ia.budgetUsed([]types.CodeElement{*neighbor})
+			if used+cost > ia.adaptiveBudget {
+				continue
+			}
+			present[id] = true
+			used += cost
+			added = append(added, *neighbor)
+			logger.Debug("added graph neighbor", "seed", seed.Name, "neighbor", neighbor.Name, "path", neighbor.RelativePath)
+		}
+	}
+	if len(added) > 0 {
+		ia.tagSource(added, "graph_neighbor")
+		ia.gatheredElements = append(ia.gatheredElements, added...)
+		logger.Debug("graph neighbors added", "count", len(added), "seeds", len(seeds))
+	}
+	return len(added)
+}
+
+// includeFileDependencies appends the resolved dependency targets of every
+// file-level element, so answers about module relationships see both ends of an import.
+func (ia *IterativeAgent) includeFileDependencies(elements []types.CodeElement) []types.CodeElement {
+	if ia.graphs == nil || ia.graphs.Dependency == nil {
+		return elements
+	}
+
+	present := make(map[string]bool, len(elements))
+	for _, elem := range elements {
+		present[elem.ID] = true
+	}
+
+	result := elements
+	for _, elem := range elements {
+		if elem.Type != "file" {
+			continue
+		}
+		for _, depID := range ia.graphs.Dependency.Successors(elem.ID) {
+			if present[depID] {
+				continue
+			}
+			if dep, ok := ia.toolExecutor.GetElement(depID); ok {
+				present[depID] = true
+				result = append(result, *dep)
+			}
+		}
+	}
+
+	if added := len(result) - len(elements); added > 0 {
+		logger.Debug("added file dependencies", "files", added)
+	}
 	return result
 }
 
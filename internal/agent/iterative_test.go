@@ -2,11 +2,15 @@ package agent
 
 import (
 	"encoding/json"
+	"fmt"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"strings"
 	"testing"
 
+	"github.com/duyhunghd6/fastcode-cli/internal/graph"
 	"github.com/duyhunghd6/fastcode-cli/internal/index"
 	"github.com/duyhunghd6/fastcode-cli/internal/llm"
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
@@ -114,8 +118,8 @@ func TestParseRound1ResponseBadJSON(t *testing.T) {
 	if err != nil {
 		t.Fatalf("parseRound1Response should not error on bad JSON: %v", err)
 	}
-	if result.Confidence != 90 {
-		t.Errorf("expected fallback confidence 90, got %d", result.Confidence)
+	if result.Confidence != cfg.Round1FallbackConfidence {
+		t.Errorf("expected fallback confidence %d, got %d", cfg.Round1FallbackConfidence, result.Confidence)
 	}
 }
 
@@ -144,6 +148,29 @@ func TestBuildRound1Prompt(t *testing.T) {
 	}
 }
 
+func TestBuildPromptsPrimaryLanguage(t *testing.T) {
+	client := llm.NewClientWith("key", "model", "http://localhost")
+	te := NewToolExecutor(index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75)), nil, nil)
+	cfg := DefaultAgentConfig()
+	agent := NewIterativeAgent(client, te, nil, cfg)
+	pq := ProcessQuery("where is the router?")
+
+	if strings.Contains(agent.buildRound1Prompt("where is the router?", pq), "Primary Language") {
+		t.Error("prompt should not mention a primary language when none is known")
+	}
+
+	cfg.PrimaryLanguage = "go"
+	agent = NewIterativeAgent(client, te, nil, cfg)
+	agent.initializeAdaptiveParams(50)
+	want := `**Primary Language**: go (use file_pattern "*.go" to target source files)`
+	if !strings.Contains(agent.buildRound1Prompt("where is the router?", pq), want) {
+		t.Errorf("round 1 prompt should contain %q", want)
+	}
+	if !strings.Contains(agent.buildRoundNPrompt("where is the router?", pq, 2), want) {
+		t.Errorf("round N prompt should contain %q", want)
+	}
+}
+
 func TestBuildRoundNPrompt(t *testing.T) {
 	client := llm.NewClientWith("key", "model", "http://localhost")
 	vs := index.NewVectorStore()
@@ -228,6 +255,48 @@ func TestRetrieveHighConfidence(t *testing.T) {
 	}
 }
 
+func TestRetrieveMalformedRoundNDoesNotStopConfident(t *testing.T) {
+	callCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		content := `{"confidence": 40, "query_complexity": 50, "reasoning": "need to search"}`
+		if callCount > 1 {
+			// Round 2+: truncated, unparseable output
+			content = `Sure! {"confidence": 99, "keep_files": ["main.go"`
+		}
+		resp := map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]string{"role": "assistant", "content": content}},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := llm.NewClientWith("test-key", "test-model", server.URL)
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	elements := []types.CodeElement{
+		{ID: "e1", Name: "main", Type: "function", Code: "func main() {}"},
+	}
+	_ = hr.IndexElements(elements, nil)
+	te := NewToolExecutor(hr, nil, elements)
+
+	cfg := DefaultAgentConfig()
+	agent := NewIterativeAgent(client, te, nil, cfg)
+
+	pq := ProcessQuery("where is main?")
+	result, err := agent.Retrieve("where is main?", pq)
+	if err != nil {
+		t.Fatalf("Retrieve error: %v", err)
+	}
+	if result.StopReason == "confidence_threshold_reached" {
+		t.Errorf("malformed round-N output should not reach the confidence threshold")
+	}
+	if result.Confidence != cfg.RoundNFallbackConfidence {
+		t.Errorf("confidence = %d, want fallback %d", result.Confidence, cfg.RoundNFallbackConfidence)
+	}
+}
+
 func TestRetrieveLLMError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
@@ -253,3 +322,151 @@ func TestRetrieveLLMError(t *testing.T) {
 	}
 	// Just verify no panic
 }
+
+func TestIncludeFileDependencies(t *testing.T) {
+	elements := []types.CodeElement{
+		{
+			ID: "f_main", Type: "file", Name: "main.go", RelativePath: "main.go",
+			Metadata: map[string]any{
+				"imports": []types.ImportInfo{{Module: "utils", Line: 3}},
+			},
+		},
+		{ID: "f_utils", Type: "file", Name: "utils.go", RelativePath: "utils.go", Metadata: map[string]any{}},
+		{ID: "f_other", Type: "file", Name: "other.go", RelativePath: "other.go", Metadata: map[string]any{}},
+	}
+	graphs := graph.NewCodeGraphs()
+	graphs.BuildGraphs(elements)
+
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	te := NewToolExecutor(hr, nil, elements)
+
+	cfg := DefaultAgentConfig()
+	cfg.IncludeFileDependencies = true
+	agent := NewIterativeAgent(nil, te, graphs, cfg)
+
+	got := agent.includeFileDependencies([]types.CodeElement{elements[0]})
+	if len(got) != 2 {
+		t.Fatalf("expected main.go plus its dependency, got %d elements", len(got))
+	}
+	if got[1].ID != "f_utils" {
+		t.Errorf("dependency = %s, want f_utils", got[1].ID)
+	}
+
+	// Already-present dependencies are not duplicated
+	got = agent.includeFileDependencies([]types.CodeElement{elements[0], elements[1]})
+	if len(got) != 2 {
+		t.Errorf("expected no duplicate dependency, got %d elements", len(got))
+	}
+}
+
+func TestAddGraphNeighbors(t *testing.T) {
+	elements := []types.CodeElement{
+		{
+			ID: "f_main", Type: "file", Name: "main.go", RelativePath: "main.go", Code: "package main",
+			Metadata: map[string]any{
+				"imports": []types.ImportInfo{{Module: "utils", Line: 3}},
+			},
+		},
+		{ID: "f_utils", Type: "file", Name: "utils.go", RelativePath: "utils.go", Code: "package utils", Metadata: map[string]any{}},
+		{ID: "f_other", Type: "file", Name: "other.go", RelativePath: "other.go", Code: "package other", Metadata: map[string]any{}},
+	}
+	graphs := graph.NewCodeGraphs()
+	graphs.BuildGraphs(elements)
+	te := NewToolExecutor(index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75)), nil, elements)
+
+	cfg := DefaultAgentConfig()
+	cfg.GraphNeighbors = true
+	agent := NewIterativeAgent(nil, te, graphs, cfg)
+	agent.adaptiveBudget = 100
+	agent.gatheredElements = []types.CodeElement{elements[0]}
+	if added := agent.addGraphNeighbors(); added != 1 {
+		t.Fatalf("added %d neighbors, want utils.go", added)
+	}
+	if got := agent.gatheredElements[1].ID; got != "f_utils" || agent.elementSources["f_utils"] != "graph_neighbor" {
+		t.Errorf("neighbor = %s (source %q), want f_utils from graph_neighbor", got, agent.elementSources["f_utils"])
+	}
+
+	// A neighbor that would overrun the budget is left out
+	agent.adaptiveBudget = 1
+	agent.gatheredElements = []types.CodeElement{elements[0]}
+	if added := agent.addGraphNeighbors(); added != 0 {
+		t.Errorf("added %d neighbors over budget", added)
+	}
+}
+
+// dropContainedPairwise is the original pairwise containment check, kept as
+// the reference for dropContained.
+func dropContainedPairwise(group []types.CodeElement) []types.CodeElement {
+	group = append([]types.CodeElement(nil), group...)
+	sort.SliceStable(group, func(i, j int) bool {
+		p1, p2 := getTypePriority(group[i].Type), getTypePriority(group[j].Type)
+		if p1 != p2 {
+			return p1 > p2
+		}
+		s1, s2 := group[i].EndLine-group[i].StartLine, group[j].EndLine-group[j].StartLine
+		if s1 != s2 {
+			return s1 > s2
+		}
+		return group[i].StartLine < group[j].StartLine
+	})
+	var kept []types.CodeElement
+	for _, elem := range group {
+		contained := false
+		for _, k := range kept {
+			if k.StartLine <= elem.StartLine && elem.EndLine <= k.EndLine &&
+				(k.StartLine < elem.StartLine || elem.EndLine < k.EndLine) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			kept = append(kept, elem)
+		}
+	}
+	return kept
+}
+
+// randomFileElements returns n elements of one file with random nested and
+// overlapping line ranges of every type.
+func randomFileElements(rng *rand.Rand, n, lines int) []types.CodeElement {
+	kinds := []string{"file", "class", "function", "documentation"}
+	elements := make([]types.CodeElement, n)
+	for i := range elements {
+		start := rng.Intn(lines) + 1
+		elements[i] = types.CodeElement{
+			ID:           fmt.Sprintf("e%d", i),
+			Type:         kinds[rng.Intn(len(kinds))],
+			RelativePath: "big.go",
+			StartLine:    start,
+			EndLine:      start + rng.Intn(lines/4+1),
+		}
+	}
+	return elements
+}
+
+func TestDropContainedMatchesPairwise(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 500; trial++ {
+		group := randomFileElements(rng, rng.Intn(30)+2, rng.Intn(40)+4)
+		ids := func(elements []types.CodeElement) string {
+			var out []string
+			for _, e := range elements {
+				out = append(out, e.ID)
+			}
+			sort.Strings(out)
+			return strings.Join(out, ",")
+		}
+		if got, want := ids(dropContained(group)), ids(dropContainedPairwise(group)); got != want {
+			t.Fatalf("trial %d: kept %s, want %s\ngroup: %+v", trial, got, want, group)
+		}
+	}
+}
+
+func BenchmarkRemoveDuplicatesWithContainment(b *testing.B) {
+	ia := &IterativeAgent{}
+	elements := randomFileElements(rand.New(rand.NewSource(1)), 2000, 20000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ia.removeDuplicatesWithContainment(elements)
+	}
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/llm"
+)
+
+// OverviewFile is one of the central files an overview is written from: its
+// place in the repository and the signatures of its classes and functions.
+type OverviewFile struct {
+	Path       string   `json:"path"`
+	Language   string   `json:"language"`
+	Score      float64  `json:"score"`    // PageRank over the dependency graph
+	Elements   int      `json:"elements"` // Classes and functions defined in the file
+	Summary    string   `json:"summary,omitempty"`
+	Signatures []string `json:"signatures,omitempty"`
+}
+
+// OverviewItem is one entry of an overview section.
+type OverviewItem struct {
+	Name        string `json:"name"`
+	Location    string `json:"location,omitempty"` // e.g. "cmd/app/main.go:12"
+	Description string `json:"description"`
+}
+
+// Overview is a structured architecture summary of a repository.
+type Overview struct {
+	Summary      string         `json:"summary"`
+	EntryPoints  []OverviewItem `json:"entry_points"`
+	KeyModules   []OverviewItem `json:"key_modules"`
+	NotableTypes []OverviewItem `json:"notable_types"`
+}
+
+// overviewMaxTokens is the generation limit of an overview.
+const overviewMaxTokens = 4000
+
+// GenerateOverview asks the model for an overview of the repository repoName
+// from the skimmed signatures of its central files, most central first. A
+// response that is not the requested JSON is kept whole as the Summary.
+// Secrets in the signatures and summaries are masked when RedactSecrets is set.
+func (ag *AnswerGenerator) GenerateOverview(repoName string, files []OverviewFile) (*Overview, error) {
+	prompt := buildOverviewPrompt(repoName, files)
+	if ag.RedactSecrets {
+		prompt = llm.RedactSecrets(prompt)
+	}
+	response, err := ag.client.ChatCompletion([]llm.ChatMessage{
+		{Role: "user", Content: prompt},
+	}, 0.2, overviewMaxTokens)
+	if err != nil {
+		return nil, fmt.Errorf("generate overview: %w", err)
+	}
+	return parseOverview(response), nil
+}
+
+func buildOverviewPrompt(repoName string, files []OverviewFile) string {
+	var sb strings.Builder
+	sb.WriteString("You are a senior engineer writing an architecture overview of the repository ")
+	sb.WriteString(fmt.Sprintf("`%s` for a new team member.\n", repoName))
+	sb.WriteString("Below are its most central files, ranked by how much of the code depends on them, ")
+	sb.WriteString("with the signatures of the classes and functions they define.\n\n")
+
+	for i, f := range files {
+		sb.WriteString(fmt.Sprintf("## %d. %s (%s, %d elements)\n", i+1, f.Path, f.Language, f.Elements))
+		if f.Summary != "" {
+			sb.WriteString(f.Summary + "\n")
+		}
+		for _, sig := range f.Signatures {
+			sb.WriteString("- " + sig + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(`**Instructions**: Describe the repository using only the files above. Respond with a JSON object and nothing else:
+{
+  "summary": "two or three sentences on what the repository does and how it is structured",
+  "entry_points": [{"name": "...", "location": "path:line", "description": "..."}],
+  "key_modules": [{"name": "path or package", "location": "path", "description": "its responsibility"}],
+  "notable_types": [{"name": "...", "location": "path:line", "description": "..."}]
+}
+List at most five entry points (main functions, CLI commands, servers, exported APIs), eight key modules and eight notable types.`)
+	return sb.String()
+}
+
+// parseOverview reads the model's JSON overview, falling back to the raw
+// response as the summary.
+func parseOverview(response string) *Overview {
+	var overview Overview
+	jsonStr := extractJSON(response)
+	if jsonStr == "" || json.Unmarshal([]byte(jsonStr), &overview) != nil {
+		return &Overview{Summary: strings.TrimSpace(response)}
+	}
+	return &overview
+}
@@ -1,7 +1,10 @@
 package agent
 
 import (
+	"container/list"
+	"slices"
 	"strings"
+	"sync"
 	"unicode"
 )
 
@@ -15,34 +18,148 @@ type ProcessedQuery struct {
 }
 
 // ProcessQuery analyzes a user query and extracts keywords, complexity, and type.
+// Results are memoized by the trimmed query, so repeated questions (common in
+// a REPL or the MCP server) skip the analysis. It is safe for concurrent use.
 func ProcessQuery(query string) *ProcessedQuery {
+	cleaned := strings.TrimSpace(query)
+	if cached, ok := processedQueries.get(cleaned); ok {
+		cached.Original = query
+		return cached
+	}
+
 	pq := &ProcessedQuery{
 		Original: query,
-		Cleaned:  strings.TrimSpace(query),
+		Cleaned:  cleaned,
 	}
 
 	pq.Keywords = extractKeywords(pq.Cleaned)
 	pq.Complexity = scoreComplexity(pq.Cleaned, pq.Keywords)
 	pq.QueryType = classifyQuery(pq.Cleaned)
 
+	processedQueries.put(cleaned, pq)
 	return pq
 }
 
-// extractKeywords pulls meaningful terms from the query.
-func extractKeywords(query string) []string {
-	// Stop words to filter out
-	stopWords := map[string]bool{
-		"the": true, "is": true, "at": true, "which": true, "on": true,
-		"a": true, "an": true, "and": true, "or": true, "but": true,
-		"in": true, "of": true, "to": true, "for": true, "with": true,
-		"how": true, "what": true, "where": true, "when": true, "why": true,
-		"does": true, "do": true, "this": true, "that": true, "it": true,
-		"from": true, "are": true, "was": true, "were": true, "be": true,
-		"has": true, "have": true, "had": true, "can": true, "could": true,
-		"would": true, "should": true, "will": true, "i": true, "me": true,
-		"my": true, "we": true, "our": true, "you": true, "your": true,
+// maxCachedQueries bounds the ProcessQuery cache; the least recently used
+// query is evicted first.
+const maxCachedQueries = 128
+
+var processedQueries = newQueryCache(maxCachedQueries)
+
+// queryCache is a bounded LRU cache of processed queries keyed by the
+// cleaned query text. It stores and hands out copies, so callers may modify
+// the ProcessedQuery they get.
+type queryCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	lru      *list.List // of *ProcessedQuery, most recently used first
+}
+
+func newQueryCache(capacity int) *queryCache {
+	return &queryCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (c *queryCache) get(key string) (*ProcessedQuery, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*ProcessedQuery).clone(), true
+}
+
+func (c *queryCache) put(key string, pq *ProcessedQuery) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = pq.clone()
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(pq.clone())
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Remove(c.lru.Back()).(*ProcessedQuery)
+		delete(c.entries, oldest.Cleaned)
 	}
+}
+
+// reset drops every cached query.
+func (c *queryCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+func (pq *ProcessedQuery) clone() *ProcessedQuery {
+	c := *pq
+	c.Keywords = slices.Clone(pq.Keywords)
+	return &c
+}
+
+// KeywordOptions configures how ProcessQuery extracts keywords.
+type KeywordOptions struct {
+	// StopWords are the lowercase words dropped from keywords.
+	StopWords []string
+
+	// Stem reduces keywords to a light stem, dropping plural and -ing/-ed
+	// endings, so "handlers" matches code mentioning "handler".
+	Stem bool
+}
+
+// DefaultStopWords returns the built-in English stop words.
+func DefaultStopWords() []string {
+	return []string{
+		"the", "is", "at", "which", "on",
+		"a", "an", "and", "or", "but",
+		"in", "of", "to", "for", "with",
+		"how", "what", "where", "when", "why",
+		"does", "do", "this", "that", "it",
+		"from", "are", "was", "were", "be",
+		"has", "have", "had", "can", "could",
+		"would", "should", "will", "i", "me",
+		"my", "we", "our", "you", "your",
+	}
+}
 
+// DefaultKeywordOptions returns the default extraction settings: the built-in
+// stop words and no stemming.
+func DefaultKeywordOptions() KeywordOptions {
+	return KeywordOptions{StopWords: DefaultStopWords()}
+}
+
+var (
+	keywordOptions = DefaultKeywordOptions()
+	stopWords      = stopWordSet(keywordOptions.StopWords)
+)
+
+// SetKeywordOptions replaces the keyword extraction settings used by
+// ProcessQuery. To extend the stop words rather than replace them, append to
+// DefaultStopWords(). It is not safe to call concurrently with ProcessQuery.
+func SetKeywordOptions(opts KeywordOptions) {
+	keywordOptions = opts
+	stopWords = stopWordSet(opts.StopWords)
+	// Cached keywords were extracted with the old settings
+	processedQueries.reset()
+}
+
+func stopWordSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[strings.ToLower(w)] = true
+	}
+	return set
+}
+
+// extractKeywords pulls meaningful terms from the query.
+func extractKeywords(query string) []string {
 	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
 		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.'
 	})
@@ -50,7 +167,13 @@ func extractKeywords(query string) []string {
 	var keywords []string
 	seen := make(map[string]bool)
 	for _, w := range words {
-		if len(w) < 2 || stopWords[w] || seen[w] {
+		if len(w) < 2 || stopWords[w] {
+			continue
+		}
+		if keywordOptions.Stem {
+			w = stem(w)
+		}
+		if stopWords[w] || seen[w] {
 			continue
 		}
 		seen[w] = true
@@ -59,6 +182,47 @@ func extractKeywords(query string) []string {
 	return keywords
 }
 
+// stem strips common English inflections: "queries" → "query",
+// "handlers" → "handler", "classes" → "class", "running" → "run",
+// "cached" → "cach". Stems only need to be substrings of the words they
+// stand for, since keywords are matched by substring.
+func stem(w string) string {
+	switch {
+	case len(w) > 4 && strings.HasSuffix(w, "ies"):
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "sses"):
+		return w[:len(w)-2]
+	case len(w) > 5 && strings.HasSuffix(w, "ing") && hasVowel(w[:len(w)-3]):
+		return undouble(w[:len(w)-3])
+	case len(w) > 4 && strings.HasSuffix(w, "ed") && hasVowel(w[:len(w)-2]):
+		return undouble(w[:len(w)-2])
+	case len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") &&
+		!strings.HasSuffix(w, "us") && !strings.HasSuffix(w, "is"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// hasVowel reports whether w contains a vowel, so that suffix stripping
+// leaves a plausible stem ("string" is not "str" + "ing").
+func hasVowel(w string) bool {
+	return strings.ContainsAny(w, "aeiouy")
+}
+
+// undouble drops a doubled final consonant left by removing a suffix
+// ("runn" → "run"), keeping doubles that are part of the word ("pass", "call").
+func undouble(w string) string {
+	n := len(w)
+	if n < 3 || w[n-1] != w[n-2] {
+		return w
+	}
+	switch w[n-1] {
+	case 'a', 'e', 'i', 'o', 'u', 'l', 's', 'z':
+		return w
+	}
+	return w[:n-1]
+}
+
 // scoreComplexity rates query complexity from 0-100.
 func scoreComplexity(query string, keywords []string) int {
 	score := 0
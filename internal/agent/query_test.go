@@ -1,6 +1,8 @@
 package agent
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 )
 
@@ -21,6 +23,48 @@ func TestProcessQuery(t *testing.T) {
 	}
 }
 
+func TestProcessQueryCache(t *testing.T) {
+	first := ProcessQuery("where is the cache evicted")
+	first.Keywords[0] = "changed"
+
+	second := ProcessQuery("  where is the cache evicted\n")
+	if second == first || second.Keywords[0] == "changed" {
+		t.Error("cached query should be an independent copy")
+	}
+	if second.Original != "  where is the cache evicted\n" || second.Cleaned != "where is the cache evicted" {
+		t.Errorf("cached query = %q / %q, want the caller's original", second.Original, second.Cleaned)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if pq := ProcessQuery(fmt.Sprintf("find handler %d", i%2)); len(pq.Keywords) == 0 {
+				t.Error("concurrent query lost its keywords")
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestQueryCacheEviction(t *testing.T) {
+	c := newQueryCache(2)
+	for _, q := range []string{"a", "b", "c"} {
+		c.put(q, &ProcessedQuery{Cleaned: q})
+	}
+	if _, ok := c.get("a"); ok {
+		t.Error("least recently used query should be evicted")
+	}
+	if _, ok := c.get("c"); !ok {
+		t.Error("newest query should be cached")
+	}
+	c.reset()
+	if _, ok := c.get("c"); ok {
+		t.Error("reset should drop cached queries")
+	}
+}
+
 func TestClassifyQuery(t *testing.T) {
 	tests := []struct {
 		query string
@@ -52,3 +96,42 @@ func TestScoreComplexity(t *testing.T) {
 		t.Errorf("complex (%d) should score higher than simple (%d)", complex, simple)
 	}
 }
+
+func TestExtractKeywordsOptions(t *testing.T) {
+	defer SetKeywordOptions(DefaultKeywordOptions())
+
+	if got := extractKeywords("the handlers"); fmt.Sprint(got) != "[handlers]" {
+		t.Errorf("default keywords = %v, want [handlers]", got)
+	}
+
+	SetKeywordOptions(KeywordOptions{StopWords: append(DefaultStopWords(), "handler"), Stem: true})
+	got := extractKeywords("Which handlers run queries? The handler is running classes, cached")
+	if want := "[run query class cach]"; fmt.Sprint(got) != want {
+		t.Errorf("stemmed keywords = %v, want %s", got, want)
+	}
+
+	// Replacing the stop words keeps "the" and drops "login"
+	SetKeywordOptions(KeywordOptions{StopWords: []string{"Login"}})
+	if got := extractKeywords("the login flow"); fmt.Sprint(got) != "[the flow]" {
+		t.Errorf("replaced stop word keywords = %v, want [the flow]", got)
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"handlers": "handler",
+		"queries":  "query",
+		"classes":  "class",
+		"running":  "run",
+		"calling":  "call",
+		"status":   "status",
+		"analysis": "analysis",
+		"pass":     "pass",
+		"string":   "string",
+	}
+	for in, want := range tests {
+		if got := stem(in); got != want {
+			t.Errorf("stem(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,17 +1,21 @@
 package agent
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"io/fs"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/duyhunghd6/fastcode-cli/internal/graph"
 	"github.com/duyhunghd6/fastcode-cli/internal/index"
 	"github.com/duyhunghd6/fastcode-cli/internal/llm"
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
+	"github.com/duyhunghd6/fastcode-cli/internal/util"
 )
 
 // Tool represents an agent action that can be invoked during retrieval.
@@ -30,11 +34,27 @@ type ToolResult struct {
 // FileCandidate represents a file found by search_codebase with match metadata.
 // This mirrors Python's search result dict from agent_tools.py.
 type FileCandidate struct {
-	FilePath   string `json:"file_path"`
-	MatchCount int    `json:"match_count"`
-	RepoName   string `json:"repo_name"`
+	FilePath   string      `json:"file_path"`
+	MatchCount int         `json:"match_count"`
+	RepoName   string      `json:"repo_name"`
+	Matches    []LineMatch `json:"matches,omitempty"` // first few matches, like grep -n
 }
 
+// LineMatch is a single search hit: the 1-based line number and the trimmed
+// text of that line.
+type LineMatch struct {
+	Line    int    `json:"line"`
+	Excerpt string `json:"excerpt"`
+}
+
+const (
+	maxMatchesPerFile = 5
+	maxExcerptLen     = 160
+	maxBrowseBytes    = 64 * 1024 // cap on files browse_file reads from disk
+	maxSymbolMatches  = 10        // cap on definitions resolve_symbol returns
+	maxContextLines   = 50        // cap on ContextLines
+)
+
 // AvailableTools returns the tools the agent can use (matching Python's tool schema).
 func AvailableTools() []Tool {
 	return []Tool{
@@ -42,6 +62,9 @@ func AvailableTools() []Tool {
 		{Name: "list_directory", Description: "Explore directory structure by listing contents of a path"},
 		{Name: "browse_file", Description: "Read the full content of a specific file"},
 		{Name: "skim_file", Description: "Read only signatures and docstrings from a file (token-efficient)"},
+		{Name: "outline_file", Description: "Ordered outline of a file: imports, types, then functions with signatures and one-line docs"},
+		{Name: "find_dependents", Description: "List files that import the given file, directly or transitively (impact analysis)"},
+		{Name: "resolve_symbol", Description: "Go to the definition of a function, method, class or type by its name"},
 	}
 }
 
@@ -49,21 +72,122 @@ func AvailableTools() []Tool {
 type ToolExecutor struct {
 	hybrid   *index.HybridRetriever
 	embedder *llm.Embedder
+	graphs   *graph.CodeGraphs
 	elements map[string]*types.CodeElement
-	repoRoot string // Absolute path to the repository root (for filesystem search)
-	repoName string // Name of the repository
+	ordered  []*types.CodeElement          // same elements sorted by path, then line, for deterministic iteration
+	files    map[string]*types.CodeElement // file elements by fileKey
+	repoRoot string                        // Absolute path to the repository root (for filesystem search)
+	repoName string                        // Name of the repository
+
+	// MaxFileElements caps how many elements FindElementsForFile returns (0 = no cap).
+	MaxFileElements int
+	// DependentsDepth is how many import hops find_dependents follows (default: 2).
+	DependentsDepth int
+	// SearchTopK is how many results the hybrid search tool returns (default: 5).
+	SearchTopK int
+	// Languages restricts searches and file lookups to elements in these
+	// languages (nil = all languages).
+	Languages []string
+	// ContextLines, if positive, widens the functions and classes that
+	// search_code and resolve_symbol return by this many lines of their
+	// file on each side (at most maxContextLines), so nearby imports and
+	// types come along; StartLine and EndLine cover the widened range.
+	ContextLines int
+	// Tests keeps or drops elements of test files (see index.IsTest) in
+	// searches and file lookups (default: TestsInclude).
+	Tests TestFilter
+	// RedactSecrets masks what looks like a secret (see llm.RedactSecrets)
+	// in the code and text tools return, as it is shown to the model
+	// (default: true).
+	RedactSecrets bool
+}
+
+// TestFilter selects elements by whether they come from test files.
+type TestFilter string
+
+const (
+	TestsInclude TestFilter = ""        // Test and non-test files alike
+	TestsExclude TestFilter = "exclude" // Only non-test files
+	TestsOnly    TestFilter = "only"    // Only test files
+)
+
+// TestAllowed reports whether an element of a test file (isTest) or of
+// another file passes the executor's test filter.
+func (te *ToolExecutor) TestAllowed(isTest bool) bool {
+	switch te.Tests {
+	case TestsExclude:
+		return !isTest
+	case TestsOnly:
+		return isTest
+	}
+	return true
+}
+
+// Allowed reports whether elem passes the executor's language and test
+// filters.
+func (te *ToolExecutor) Allowed(elem *types.CodeElement) bool {
+	return te.LanguageAllowed(elem.Language) && te.TestAllowed(index.IsTest(elem))
 }
 
-// NewToolExecutor creates a new tool executor.
+// LanguageAllowed reports whether lang passes the executor's language filter.
+func (te *ToolExecutor) LanguageAllowed(lang string) bool {
+	if len(te.Languages) == 0 {
+		return true
+	}
+	for _, l := range te.Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// elementFilter returns a search predicate for the language and test
+// filters, or nil when every element is allowed.
+func (te *ToolExecutor) elementFilter() func(*types.CodeElement) bool {
+	if len(te.Languages) == 0 && te.Tests == TestsInclude {
+		return nil
+	}
+	return te.Allowed
+}
+
+// NewToolExecutor creates a new tool executor. Of elements sharing an ID, the
+// last one is kept.
 func NewToolExecutor(hybrid *index.HybridRetriever, embedder *llm.Embedder, elements []types.CodeElement) *ToolExecutor {
 	elemMap := make(map[string]*types.CodeElement, len(elements))
+	files := make(map[string]*types.CodeElement)
 	for i := range elements {
 		elemMap[elements[i].ID] = &elements[i]
+		if elements[i].Type == "file" {
+			files[fileKey(&elements[i])] = &elements[i]
+		}
+	}
+	ordered := make([]*types.CodeElement, 0, len(elemMap))
+	for _, elem := range elemMap {
+		ordered = append(ordered, elem)
 	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if a.RelativePath != b.RelativePath {
+			return a.RelativePath < b.RelativePath
+		}
+		if a.StartLine != b.StartLine {
+			return a.StartLine < b.StartLine
+		}
+		if pa, pb := getTypePriority(a.Type), getTypePriority(b.Type); pa != pb {
+			return pa > pb
+		}
+		return a.ID < b.ID
+	})
 	return &ToolExecutor{
-		hybrid:   hybrid,
-		embedder: embedder,
-		elements: elemMap,
+		hybrid:          hybrid,
+		embedder:        embedder,
+		elements:        elemMap,
+		ordered:         ordered,
+		files:           files,
+		MaxFileElements: 50,
+		DependentsDepth: 2,
+		RedactSecrets:   true,
 	}
 }
 
@@ -73,6 +197,11 @@ func (te *ToolExecutor) SetRepoRoot(repoRoot, repoName string) {
 	te.repoName = repoName
 }
 
+// SetGraphs sets the code graphs used by graph-based tools such as find_dependents.
+func (te *ToolExecutor) SetGraphs(graphs *graph.CodeGraphs) {
+	te.graphs = graphs
+}
+
 // GetElement retrieves a specific CodeElement by ID.
 func (te *ToolExecutor) GetElement(id string) (*types.CodeElement, bool) {
 	if te.elements == nil {
@@ -84,6 +213,18 @@ func (te *ToolExecutor) GetElement(id string) (*types.CodeElement, bool) {
 
 // Execute runs a tool by name with the given argument.
 func (te *ToolExecutor) Execute(toolName, arg string) (*ToolResult, error) {
+	result, err := te.execute(toolName, arg)
+	if err != nil || !te.RedactSecrets {
+		return result, err
+	}
+	result.Text = llm.RedactSecrets(result.Text)
+	for i := range result.Elements {
+		result.Elements[i].Code = llm.RedactSecrets(result.Elements[i].Code)
+	}
+	return result, nil
+}
+
+func (te *ToolExecutor) execute(toolName, arg string) (*ToolResult, error) {
 	switch toolName {
 	case "search_codebase", "search_code":
 		return te.searchCode(arg)
@@ -93,6 +234,12 @@ func (te *ToolExecutor) Execute(toolName, arg string) (*ToolResult, error) {
 		return te.browseFile(arg)
 	case "skim_file":
 		return te.skimFile(arg)
+	case "outline_file":
+		return te.outlineFile(arg)
+	case "find_dependents":
+		return te.findDependents(arg)
+	case "resolve_symbol":
+		return te.resolveSymbol(arg)
 	case "search_graph":
 		// Stub: fall back to semantic search until graph index is implemented
 		return te.searchCode(arg)
@@ -101,9 +248,25 @@ func (te *ToolExecutor) Execute(toolName, arg string) (*ToolResult, error) {
 	}
 }
 
+// matchAnyPattern reports whether relPath matches any of the glob patterns.
+// An empty list or a "*" pattern matches every path.
+func matchAnyPattern(patterns []string, relPath string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if p == "*" || util.MatchGlob(p, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
 // ExecuteSearchCodebase performs real filesystem content search like Python's agent_tools.py.
 // ExecuteSearchCodebase runs a ripgrep search and returns matched files.
-func (te *ToolExecutor) ExecuteSearchCodebase(searchTerm, filePattern string, useRegex bool) []FileCandidate {
+// A file is searched when it matches any of filePatterns; an empty list or
+// "*" searches every file.
+func (te *ToolExecutor) ExecuteSearchCodebase(searchTerm string, filePatterns []string, useRegex bool) []FileCandidate {
 	if te.repoRoot == "" || searchTerm == "" {
 		return nil
 	}
@@ -114,7 +277,7 @@ func (te *ToolExecutor) ExecuteSearchCodebase(searchTerm, filePattern string, us
 	if useRegex {
 		compiled, err := regexp.Compile(flags + searchTerm)
 		if err != nil {
-			log.Printf("[tools] invalid regex: %v", err)
+			logger.Warn("invalid search regex", "err", err)
 			return nil
 		}
 		contentPattern = compiled
@@ -143,7 +306,7 @@ func (te *ToolExecutor) ExecuteSearchCodebase(searchTerm, filePattern string, us
 	var candidates []FileCandidate
 	maxResults := 30
 
-	log.Printf("[tools] Starting WalkDir for term=%q", searchTerm)
+	logger.Debug("searching repository files", "term", searchTerm)
 	_ = filepath.WalkDir(te.repoRoot, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil // skip errors
@@ -167,22 +330,12 @@ func (te *ToolExecutor) ExecuteSearchCodebase(searchTerm, filePattern string, us
 		relPath, _ := filepath.Rel(te.repoRoot, path)
 		relPath = filepath.ToSlash(relPath) // normalize to forward slashes
 
-		// File pattern matching (simple glob on filename or path)
-		if filePattern != "" && filePattern != "*" {
-			matched, _ := filepath.Match(filePattern, d.Name())
-			if !matched {
-				// Also try matching against full relative path
-				matched, _ = filepath.Match(filePattern, relPath)
-			}
-			if !matched {
-				// Try glob-style extension match (e.g., "**/*.ts")
-				parts := strings.Split(filePattern, "/")
-				lastPart := parts[len(parts)-1]
-				matched, _ = filepath.Match(lastPart, d.Name())
-			}
-			if !matched {
-				return nil
-			}
+		// File pattern matching ("*.ts", "src/**/*.go", "**/test_*.py")
+		if !matchAnyPattern(filePatterns, relPath) {
+			return nil
+		}
+		if !te.LanguageAllowed(util.GetLanguageFromPath(relPath)) || !te.TestAllowed(util.IsTestFile(relPath)) {
+			return nil
 		}
 
 		// Read file and search content
@@ -205,27 +358,68 @@ func (te *ToolExecutor) ExecuteSearchCodebase(searchTerm, filePattern string, us
 
 		// Find count for struct compatibility
 		matchCount := 1
+		var matches []LineMatch
 		if contentPattern != nil {
-			matchCount = len(contentPattern.FindAllIndex(data, -1))
+			locs := contentPattern.FindAllIndex(data, -1)
+			matchCount = len(locs)
+			matches = lineMatches(data, locs, maxMatchesPerFile)
+		}
+		if te.RedactSecrets {
+			for i := range matches {
+				matches[i].Excerpt = llm.RedactSecrets(matches[i].Excerpt)
+			}
 		}
 
 		candidates = append(candidates, FileCandidate{
 			FilePath:   relPath,
 			MatchCount: matchCount,
 			RepoName:   te.repoName,
+			Matches:    matches,
 		})
 
 		if len(candidates) >= maxResults {
-			log.Printf("[tools] max results reached for %q", searchTerm)
+			logger.Debug("search result limit reached", "term", searchTerm)
 			return filepath.SkipAll
 		}
 		return nil
 	})
 
-	log.Printf("[tools] Finished WalkDir for term=%q with %d candidates", searchTerm, len(candidates))
+	logger.Debug("searched repository files", "term", searchTerm, "candidates", len(candidates))
 	return candidates
 }
 
+// lineMatches converts match byte offsets into line numbers and excerpts,
+// keeping at most limit distinct lines. locs must be in ascending order, as
+// returned by FindAllIndex.
+func lineMatches(data []byte, locs [][]int, limit int) []LineMatch {
+	var matches []LineMatch
+	line, pos := 1, 0
+	for _, loc := range locs {
+		line += bytes.Count(data[pos:loc[0]], []byte("\n"))
+		pos = loc[0]
+		if n := len(matches); n > 0 && matches[n-1].Line == line {
+			continue // several hits on one line
+		}
+		if len(matches) >= limit {
+			break
+		}
+
+		start := bytes.LastIndexByte(data[:loc[0]], '\n') + 1
+		end := bytes.IndexByte(data[loc[0]:], '\n')
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += loc[0]
+		}
+		excerpt := strings.TrimSpace(string(data[start:end]))
+		if len(excerpt) > maxExcerptLen {
+			excerpt = excerpt[:maxExcerptLen] + "..."
+		}
+		matches = append(matches, LineMatch{Line: line, Excerpt: excerpt})
+	}
+	return matches
+}
+
 // ExecuteListDirectory performs real filesystem directory listing.
 // ExecuteListDirectory returns a list of files in the directory.
 func (te *ToolExecutor) ExecuteListDirectory(dirPath string) []FileCandidate {
@@ -267,17 +461,88 @@ func (te *ToolExecutor) ExecuteListDirectory(dirPath string) []FileCandidate {
 	return candidates
 }
 
-// FindElementsForFile retrieves all indexed elements for a given file path.
-// GetElementsForFiles fetches actual code elements from the given file paths.
+// FindElementsForFile retrieves the indexed elements for a given file path.
+// An exact RelativePath match wins; otherwise elements whose path ends with
+// filePath are used, and only then paths that filePath itself ends with (e.g.
+// a repo-prefixed path). This keeps a common basename like "index.ts" from
+// pulling in elements of unrelated files. Results are ordered by path and line
+// and capped at MaxFileElements.
 func (te *ToolExecutor) FindElementsForFile(filePath string) []types.CodeElement {
-	var result []types.CodeElement
-	for _, elem := range te.elements {
-		if elem.RelativePath == filePath ||
-			strings.HasSuffix(elem.RelativePath, filePath) ||
-			strings.HasSuffix(filePath, elem.RelativePath) {
-			result = append(result, *elem)
+	result := te.elementsForFile(filePath)
+	if te.MaxFileElements > 0 && len(result) > te.MaxFileElements {
+		result = result[:te.MaxFileElements]
+	}
+	return result
+}
+
+// elementsForFile is FindElementsForFile without the MaxFileElements cap.
+func (te *ToolExecutor) elementsForFile(filePath string) []types.CodeElement {
+	var exact, longer, shorter []types.CodeElement
+	for _, elem := range te.ordered {
+		if !te.Allowed(elem) {
+			continue
+		}
+		switch {
+		case elem.RelativePath == filePath:
+			exact = append(exact, *elem)
+		case strings.HasSuffix(elem.RelativePath, filePath):
+			longer = append(longer, *elem)
+		case strings.HasSuffix(filePath, elem.RelativePath):
+			shorter = append(shorter, *elem)
 		}
 	}
+
+	result := exact
+	if len(result) == 0 {
+		result = longer
+	}
+	if len(result) == 0 {
+		result = shorter
+	}
+	// te.ordered already sorts by path, then line, then type priority
+	return result
+}
+
+// ResolveFile returns the indexed path of the file filePath names, matched
+// like FindElementsForFile (exact path, then path suffix) and, failing that,
+// by the closest fuzzy match. It reports false when no file matches.
+func (te *ToolExecutor) ResolveFile(filePath string) (string, bool) {
+	filePath = strings.TrimPrefix(filepath.ToSlash(filePath), "./")
+	if filePath == "" {
+		return "", false
+	}
+	for _, elem := range te.elementsForFile(filePath) {
+		if elem.Type == "file" {
+			return elem.RelativePath, true
+		}
+	}
+	if matches := te.fuzzyFileMatches(filePath, 1); len(matches) > 0 {
+		return matches[0].RelativePath, true
+	}
+	return "", false
+}
+
+// projectDocs returns up to limit sections of the repository's README and
+// docs/ files, README sections first, each in document order.
+func (te *ToolExecutor) projectDocs(limit int) []types.CodeElement {
+	var readme, docs []types.CodeElement
+	for _, elem := range te.ordered {
+		if elem.Type != "documentation" && elem.Type != "section" {
+			continue
+		}
+		if elem.Language != "markdown" || !index.IsProjectDoc(elem.RelativePath) || !te.Allowed(elem) {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(filepath.Base(elem.RelativePath)), "readme") {
+			readme = append(readme, *elem)
+		} else {
+			docs = append(docs, *elem)
+		}
+	}
+	result := append(readme, docs...)
+	if len(result) > limit {
+		result = result[:limit]
+	}
 	return result
 }
 
@@ -291,7 +556,11 @@ func (te *ToolExecutor) searchCode(query string) (*ToolResult, error) {
 		}
 	}
 
-	results := te.hybrid.Search(query, queryVec, 5)
+	topK := te.SearchTopK
+	if topK <= 0 {
+		topK = 5
+	}
+	results := te.hybrid.SearchFiltered(query, queryVec, topK, te.elementFilter())
 	var elements []types.CodeElement
 	for _, r := range results {
 		if r.Element != nil {
@@ -301,28 +570,145 @@ func (te *ToolExecutor) searchCode(query string) (*ToolResult, error) {
 
 	return &ToolResult{
 		ToolName: "search_codebase",
-		Elements: elements,
+		Elements: te.WithContext(elements),
 	}, nil
 }
 
+// fileKey identifies the file an element is in, across the repositories of
+// a workspace.
+func fileKey(elem *types.CodeElement) string {
+	return elem.RepoName + "\x00" + elem.RelativePath
+}
+
+// WithContext returns elements with each function and class widened by
+// ContextLines lines of its file on each side, read from the file element's
+// Code. Elements are unchanged when ContextLines is not positive or the
+// file's (possibly truncated) code does not reach them.
+func (te *ToolExecutor) WithContext(elements []types.CodeElement) []types.CodeElement {
+	n := min(te.ContextLines, maxContextLines)
+	if n <= 0 {
+		return elements
+	}
+	out := make([]types.CodeElement, len(elements))
+	for i, elem := range elements {
+		out[i] = elem
+		if (elem.Type != "function" && elem.Type != "class") || elem.StartLine <= 0 {
+			continue
+		}
+		if file := te.files[fileKey(&elem)]; file != nil {
+			out[i] = addContextLines(elem, fileLines(file.Code), n)
+		}
+	}
+	return out
+}
+
+// addContextLines adds up to n of the file's lines (line i at lines[i-1])
+// before and after elem, widening its line range to match. No lines are
+// added after code that was truncated.
+func addContextLines(elem types.CodeElement, lines []string, n int) types.CodeElement {
+	if elem.StartLine-1 > len(lines) {
+		return elem
+	}
+	before := lines[max(elem.StartLine-1-n, 0) : elem.StartLine-1]
+	var after []string
+	if !strings.HasSuffix(elem.Code, index.TruncatedMarker) && elem.EndLine < len(lines) {
+		after = lines[elem.EndLine:min(elem.EndLine+n, len(lines))]
+	}
+	if len(before) == 0 && len(after) == 0 {
+		return elem
+	}
+	parts := append(append(append([]string{}, before...), elem.Code), after...)
+	elem.Code = strings.Join(parts, "\n")
+	elem.StartLine -= len(before)
+	elem.EndLine += len(after)
+	return elem
+}
+
+// fileLines splits a file element's code into lines, dropping the partial
+// last line of code the indexer truncated.
+func fileLines(code string) []string {
+	if kept, truncated := strings.CutSuffix(code, index.TruncatedMarker); truncated {
+		i := strings.LastIndex(kept, "\n")
+		if i < 0 {
+			return nil
+		}
+		code = kept[:i]
+	}
+	return strings.Split(code, "\n")
+}
+
 func (te *ToolExecutor) browseFile(filePath string) (*ToolResult, error) {
-	// Find the file element
-	for _, elem := range te.elements {
-		if elem.Type == "file" && (elem.RelativePath == filePath || strings.HasSuffix(elem.RelativePath, filePath)) {
+	// Find the file element, preferring an exact path match
+	for _, elem := range te.elementsForFile(filePath) {
+		if elem.Type == "file" {
 			return &ToolResult{
 				ToolName: "browse_file",
-				Elements: []types.CodeElement{*elem},
+				Elements: []types.CodeElement{elem},
 				Text:     elem.Code,
 			}, nil
 		}
 	}
+	// Files left out of the index (e.g. config files) are read from disk
+	if text, ok := te.readRepoFile(filePath); ok {
+		return &ToolResult{ToolName: "browse_file", Text: text}, nil
+	}
+	// Otherwise browse the closest indexed path, e.g. for a misspelled name,
+	// saying so, so the model does not take it for the file it asked for
+	if matches := te.fuzzyFileMatches(filePath, 1); len(matches) > 0 {
+		elem := matches[0]
+		logger.Debug("browse_file using closest match", "path", filePath, "match", elem.RelativePath)
+		return &ToolResult{
+			ToolName: "browse_file",
+			Elements: []types.CodeElement{elem},
+			Text:     fmt.Sprintf("%s not found; showing closest match %s:\n%s", filePath, elem.RelativePath, elem.Code),
+		}, nil
+	}
 	return &ToolResult{ToolName: "browse_file", Text: fmt.Sprintf("File not found: %s", filePath)}, nil
 }
 
+// readRepoFile reads a text file under the repository root, truncated to
+// maxBrowseBytes. Paths (or symlinks) leading outside the root, directories
+// and binary files are not read.
+func (te *ToolExecutor) readRepoFile(filePath string) (string, bool) {
+	if te.repoRoot == "" || filePath == "" {
+		return "", false
+	}
+	rel := filepath.Clean(filepath.FromSlash(filePath))
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	// Resolve symlinks so a link cannot lead outside the repository
+	root, err := filepath.EvalSymlinks(te.repoRoot)
+	if err != nil {
+		return "", false
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, rel))
+	if err != nil || !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return "", false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		return "", false
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, maxBrowseBytes+1))
+	if err != nil || bytes.IndexByte(data, 0) >= 0 {
+		return "", false
+	}
+	if len(data) > maxBrowseBytes {
+		return string(data[:maxBrowseBytes]) + fmt.Sprintf("\n... (truncated at %d bytes)", maxBrowseBytes), true
+	}
+	return string(data), true
+}
+
 func (te *ToolExecutor) skimFile(filePath string) (*ToolResult, error) {
 	// Find all elements from that file (functions, classes) — signatures only
 	var elements []types.CodeElement
-	for _, elem := range te.elements {
+	for _, elem := range te.ordered {
 		if (elem.Type == "function" || elem.Type == "class") &&
 			(elem.RelativePath == filePath || strings.HasSuffix(elem.RelativePath, filePath)) {
 			// Create a skim copy with signature only (no full code)
@@ -337,13 +723,231 @@ func (te *ToolExecutor) skimFile(filePath string) (*ToolResult, error) {
 	return &ToolResult{ToolName: "skim_file", Elements: elements}, nil
 }
 
+// outlineFile builds a compact, line-ordered outline of a file: its imports,
+// then its types, then its functions and methods, each with its signature and
+// the first line of its docstring. The outline replaces the file's code in the
+// returned element so it can be gathered as token-efficient context.
+func (te *ToolExecutor) outlineFile(filePath string) (*ToolResult, error) {
+	var file *types.CodeElement
+	var classes, funcs []types.CodeElement
+	for _, elem := range te.elementsForFile(filePath) {
+		switch elem.Type {
+		case "file":
+			if file == nil {
+				e := elem
+				file = &e
+			}
+		case "class":
+			classes = append(classes, elem)
+		case "function":
+			funcs = append(funcs, elem)
+		}
+	}
+	if file == nil && len(classes) == 0 && len(funcs) == 0 {
+		return &ToolResult{ToolName: "outline_file", Text: fmt.Sprintf("File not found: %s", filePath)}, nil
+	}
+
+	var sb strings.Builder
+	if file != nil {
+		sb.WriteString(file.RelativePath + "\n")
+		if imports, ok := file.Metadata["imports"].([]types.ImportInfo); ok && len(imports) > 0 {
+			sb.WriteString("imports:\n")
+			for _, imp := range imports {
+				sb.WriteString(fmt.Sprintf("  L%d %s\n", imp.Line, imp.Module))
+			}
+		}
+	}
+	writeSection := func(title string, elems []types.CodeElement) {
+		if len(elems) == 0 {
+			return
+		}
+		sb.WriteString(title + ":\n")
+		for _, elem := range elems {
+			sig := elem.Signature
+			if sig == "" {
+				sig = elem.Name
+			}
+			sb.WriteString(fmt.Sprintf("  L%d-%d %s", elem.StartLine, elem.EndLine, sig))
+			if doc := firstLine(elem.Docstring); doc != "" {
+				sb.WriteString(" — " + doc)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	// elementsForFile already orders by path, then line
+	writeSection("types", classes)
+	writeSection("functions", funcs)
+
+	outline := sb.String()
+	result := &ToolResult{ToolName: "outline_file", Text: outline}
+	if file != nil {
+		file.Code = outline
+		result.Elements = []types.CodeElement{*file}
+	}
+	return result, nil
+}
+
+// firstLine returns the first non-empty line of s, trimmed.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// findDependents walks the dependency graph backwards from filePath and returns
+// the files that import it, up to DependentsDepth hops away. The depth of each
+// dependent is listed in Text so the agent can judge the blast radius of a change.
+func (te *ToolExecutor) findDependents(filePath string) (*ToolResult, error) {
+	if te.graphs == nil {
+		return &ToolResult{ToolName: "find_dependents", Text: "Dependency graph not available"}, nil
+	}
+
+	var fileID string
+	for _, elem := range te.FindElementsForFile(filePath) {
+		if elem.Type == "file" {
+			fileID = elem.ID
+			break
+		}
+	}
+	if fileID == "" {
+		return &ToolResult{ToolName: "find_dependents", Text: fmt.Sprintf("File not found: %s", filePath)}, nil
+	}
+
+	maxDepth := te.DependentsDepth
+	if maxDepth <= 0 {
+		maxDepth = 1
+	}
+
+	var elements []types.CodeElement
+	var sb strings.Builder
+	visited := map[string]bool{fileID: true}
+	queue := []string{fileID}
+	for depth := 1; depth <= maxDepth && len(queue) > 0; depth++ {
+		var next []string
+		for _, id := range queue {
+			for _, dependent := range te.graphs.Dependency.Predecessors(id) {
+				if visited[dependent] {
+					continue
+				}
+				visited[dependent] = true
+				next = append(next, dependent)
+				if elem, ok := te.GetElement(dependent); ok {
+					elements = append(elements, *elem)
+					sb.WriteString(fmt.Sprintf("- [depth %d] %s\n", depth, elem.RelativePath))
+				}
+			}
+		}
+		queue = next
+	}
+
+	if len(elements) == 0 {
+		return &ToolResult{ToolName: "find_dependents", Text: fmt.Sprintf("No files depend on: %s", filePath)}, nil
+	}
+	return &ToolResult{ToolName: "find_dependents", Elements: elements, Text: sb.String()}, nil
+}
+
+// resolveSymbol returns the functions and classes named symbol, for "go to
+// definition" on an identifier seen in gathered code. A call such as
+// "helper()" or a qualified name such as "Server.Start" is reduced to its
+// last identifier; definitions inside the named class or receiver are listed
+// first, then classes before functions. Exact name matches win over
+// case-insensitive ones, and at most maxSymbolMatches are returned.
+func (te *ToolExecutor) resolveSymbol(symbol string) (*ToolResult, error) {
+	qualifier, name := splitSymbol(symbol)
+	if name == "" {
+		return &ToolResult{ToolName: "resolve_symbol", Text: "No symbol given"}, nil
+	}
+
+	var exact, folded []types.CodeElement
+	for _, elem := range te.ordered {
+		if (elem.Type != "function" && elem.Type != "class") || !te.Allowed(elem) {
+			continue
+		}
+		if elem.Name == name {
+			exact = append(exact, *elem)
+		} else if strings.EqualFold(elem.Name, name) {
+			folded = append(folded, *elem)
+		}
+	}
+	matches := exact
+	if len(matches) == 0 {
+		matches = folded
+	}
+	if len(matches) == 0 {
+		return &ToolResult{ToolName: "resolve_symbol", Text: fmt.Sprintf("Symbol not found: %s", symbol)}, nil
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		qi, qj := inScope(matches[i], qualifier), inScope(matches[j], qualifier)
+		if qi != qj {
+			return qi
+		}
+		return getTypePriority(matches[i].Type) > getTypePriority(matches[j].Type)
+	})
+	if len(matches) > maxSymbolMatches {
+		matches = matches[:maxSymbolMatches]
+	}
+
+	var sb strings.Builder
+	for _, elem := range matches {
+		sig := elem.Signature
+		if sig == "" {
+			sig = elem.Name
+		}
+		sb.WriteString(fmt.Sprintf("- %s:%d-%d %s %s\n", elem.RelativePath, elem.StartLine, elem.EndLine, elem.Type, sig))
+	}
+	return &ToolResult{ToolName: "resolve_symbol", Elements: te.WithContext(matches), Text: sb.String()}, nil
+}
+
+// splitSymbol reduces an identifier as written in code to the name it
+// defines and its qualifier, if any: "pkg.Server.Start()" gives "Server" and
+// "Start", "helper(x)" gives "" and "helper".
+func splitSymbol(symbol string) (qualifier, name string) {
+	symbol = strings.TrimSpace(symbol)
+	if i := strings.IndexAny(symbol, "(<["); i >= 0 {
+		symbol = symbol[:i]
+	}
+	parts := strings.FieldsFunc(symbol, func(r rune) bool {
+		return r == '.' || r == ':' || r == '#' || r == '>' || r == '-' || r == '*' || r == '&' || r == ' '
+	})
+	if len(parts) == 0 {
+		return "", ""
+	}
+	name = parts[len(parts)-1]
+	if len(parts) > 1 {
+		qualifier = parts[len(parts)-2]
+	}
+	return qualifier, name
+}
+
+// inScope reports whether elem is a method of the class or receiver type
+// named qualifier.
+func inScope(elem types.CodeElement, qualifier string) bool {
+	if qualifier == "" {
+		return false
+	}
+	for _, key := range []string{"class_name", "receiver"} {
+		if v, _ := elem.Metadata[key].(string); strings.TrimLeft(v, "*") == qualifier {
+			return true
+		}
+	}
+	return false
+}
+
 func (te *ToolExecutor) listFiles(pattern string) (*ToolResult, error) {
 	var files []types.CodeElement
 	pattern = strings.ToLower(pattern)
-	for _, elem := range te.elements {
+	for _, elem := range te.ordered {
 		if elem.Type == "file" && strings.Contains(strings.ToLower(elem.RelativePath), pattern) {
 			files = append(files, *elem)
 		}
 	}
+	// Fall back to the closest paths when nothing contains the pattern
+	if len(files) == 0 {
+		files = te.fuzzyFileMatches(pattern, maxFuzzyMatches)
+	}
 	return &ToolResult{ToolName: "list_directory", Elements: files}, nil
 }
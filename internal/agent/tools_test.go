@@ -1,8 +1,13 @@
 package agent
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/duyhunghd6/fastcode-cli/internal/graph"
 	"github.com/duyhunghd6/fastcode-cli/internal/index"
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
 )
@@ -86,6 +91,39 @@ func TestToolExecutorBrowseFile(t *testing.T) {
 	}
 }
 
+func TestToolExecutorRedactsSecrets(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "f1", Type: "file", RelativePath: "config/settings.py", Code: "API_KEY = \"abc123def\"\nDEBUG = True"},
+	}
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	te := NewToolExecutor(hr, nil, elements)
+
+	result, err := te.Execute("browse_file", "config/settings.py")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	want := "API_KEY = \"[REDACTED]\"\nDEBUG = True"
+	if result.Text != want || result.Elements[0].Code != want {
+		t.Errorf("browse_file = %q, code %q, want %q", result.Text, result.Elements[0].Code, want)
+	}
+	if elements[0].Code == want {
+		t.Error("redaction changed the indexed element")
+	}
+
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "deploy.sh"), []byte("export DB_PASSWORD=hunter2\n"), 0o644)
+	te.SetRepoRoot(root, "repo")
+	files := te.ExecuteSearchCodebase("DB_PASSWORD", nil, false)
+	if len(files) != 1 || files[0].Matches[0].Excerpt != "export DB_PASSWORD=[REDACTED]" {
+		t.Errorf("ExecuteSearchCodebase = %+v", files)
+	}
+
+	te.RedactSecrets = false
+	if result, _ := te.Execute("browse_file", "config/settings.py"); !strings.Contains(result.Text, "abc123def") {
+		t.Errorf("browse_file without redaction = %q", result.Text)
+	}
+}
+
 func TestToolExecutorBrowseFileNotFound(t *testing.T) {
 	elements := []types.CodeElement{
 		{ID: "f1", Type: "file", RelativePath: "internal/main.go"},
@@ -107,6 +145,109 @@ func TestToolExecutorBrowseFileNotFound(t *testing.T) {
 	}
 }
 
+func TestToolExecutorBrowseFileFromDisk(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "config.yaml"), []byte("port: 8080\n"), 0644)
+	os.WriteFile(filepath.Join(root, "big.txt"), bytes.Repeat([]byte("x"), maxBrowseBytes+10), 0644)
+	os.WriteFile(filepath.Join(root, "logo.png"), []byte{0x89, 'P', 'N', 'G', 0}, 0644)
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	os.WriteFile(outside, []byte("secret"), 0644)
+
+	te := NewToolExecutor(nil, nil, nil)
+	te.SetRepoRoot(root, "repo")
+
+	result, _ := te.Execute("browse_file", "config.yaml")
+	if result.Text != "port: 8080\n" {
+		t.Errorf("unindexed file text = %q, want its content", result.Text)
+	}
+	result, _ = te.Execute("browse_file", "big.txt")
+	if !strings.HasSuffix(result.Text, "(truncated at 65536 bytes)") {
+		t.Errorf("large file should be truncated, got %d bytes", len(result.Text))
+	}
+	for _, path := range []string{"logo.png", "../" + filepath.Base(filepath.Dir(outside)) + "/secret.txt", outside} {
+		if result, _ := te.Execute("browse_file", path); !strings.HasPrefix(result.Text, "File not found") {
+			t.Errorf("browse_file(%q) = %q, want not found", path, result.Text)
+		}
+	}
+}
+
+func TestFuzzyPathScore(t *testing.T) {
+	cases := []struct {
+		query, path string
+		ok          bool
+	}{
+		{"sever.go", "internal/server.go", true}, // typo
+		{"srvgo", "internal/server.go", true},    // subsequence of the path
+		{"server", "cmd/srv.go", true},           // abbreviated file name
+		{"client.go", "internal/server.go", false},
+		{"", "internal/server.go", false},
+	}
+	for _, c := range cases {
+		if _, ok := fuzzyPathScore(c.query, c.path); ok != c.ok {
+			t.Errorf("fuzzyPathScore(%q, %q) ok = %v, want %v", c.query, c.path, ok, c.ok)
+		}
+	}
+	typo, _ := fuzzyPathScore("server", "internal/server.go")
+	abbrev, _ := fuzzyPathScore("server", "cmd/srv.go")
+	if typo >= abbrev {
+		t.Errorf("near match score %d should rank before abbreviation score %d", typo, abbrev)
+	}
+}
+
+func TestToolExecutorFuzzyFiles(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "f1", Type: "file", RelativePath: "cmd/srv.go", Code: "package cmd"},
+		{ID: "f2", Type: "file", RelativePath: "internal/server.go", Code: "package internal"},
+		{ID: "f3", Type: "file", RelativePath: "internal/client.go", Code: "package client"},
+	}
+	te := NewToolExecutor(nil, nil, elements)
+
+	// A substring match keeps the exact fast path
+	result, _ := te.Execute("list_directory", "internal")
+	if len(result.Elements) != 2 {
+		t.Errorf("list_directory(internal) = %d files, want 2", len(result.Elements))
+	}
+	result, _ = te.Execute("list_directory", "sever.go")
+	if len(result.Elements) == 0 || result.Elements[0].RelativePath != "internal/server.go" {
+		t.Fatalf("list_directory(sever.go) = %+v, want internal/server.go first", result.Elements)
+	}
+
+	result, _ = te.Execute("browse_file", "internal/sever.go")
+	if want := "internal/sever.go not found; showing closest match internal/server.go:\npackage internal"; result.Text != want {
+		t.Errorf("browse_file of a misspelled path = %q, want the closest file, named", result.Text)
+	}
+	result, _ = te.Execute("browse_file", "client.go")
+	if result.Text != "package client" {
+		t.Errorf("browse_file(client.go) = %q, want the suffix match", result.Text)
+	}
+	result, _ = te.Execute("browse_file", "database.py")
+	if !strings.HasPrefix(result.Text, "File not found") {
+		t.Errorf("browse_file of an unrelated path = %q, want not found", result.Text)
+	}
+}
+
+func TestToolExecutorResolveFile(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "f1", Type: "file", RelativePath: "cmd/srv.go"},
+		{ID: "f2", Type: "file", RelativePath: "internal/server.go"},
+		{ID: "fn", Type: "function", Name: "Serve", RelativePath: "internal/server.go"},
+	}
+	te := NewToolExecutor(nil, nil, elements)
+	for query, want := range map[string]string{
+		"internal/server.go":   "internal/server.go",
+		"./internal/server.go": "internal/server.go",
+		"server.go":            "internal/server.go",
+		"internal/sever.go":    "internal/server.go",
+	} {
+		if got, ok := te.ResolveFile(query); !ok || got != want {
+			t.Errorf("ResolveFile(%q) = %q, %v; want %q", query, got, ok, want)
+		}
+	}
+	if got, ok := te.ResolveFile("database.py"); ok {
+		t.Errorf("ResolveFile(database.py) = %q, want no match", got)
+	}
+}
+
 func TestToolExecutorSkimFile(t *testing.T) {
 	elements := []types.CodeElement{
 		{ID: "f1", Type: "function", Name: "handleAuth", RelativePath: "auth.go", Code: "func handleAuth() {}"},
@@ -214,3 +355,261 @@ func TestProcessQueryEmpty(t *testing.T) {
 		t.Errorf("Original should be empty, got %q", pq.Original)
 	}
 }
+
+func TestFindElementsForFilePrefersExactMatch(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "root_file", Type: "file", RelativePath: "index.ts", StartLine: 1},
+		{ID: "root_fn", Type: "function", RelativePath: "index.ts", StartLine: 5},
+		{ID: "nested_file", Type: "file", RelativePath: "src/components/index.ts", StartLine: 1},
+		{ID: "nested_fn", Type: "function", RelativePath: "src/components/index.ts", StartLine: 3},
+	}
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	te := NewToolExecutor(hr, nil, elements)
+
+	got := te.FindElementsForFile("index.ts")
+	if len(got) != 2 {
+		t.Fatalf("expected only the 2 exact-match elements, got %d", len(got))
+	}
+	if got[0].ID != "root_file" || got[1].ID != "root_fn" {
+		t.Errorf("order = [%s %s], want [root_file root_fn]", got[0].ID, got[1].ID)
+	}
+
+	// Without an exact match, suffix matching still applies
+	got = te.FindElementsForFile("components/index.ts")
+	if len(got) != 2 {
+		t.Errorf("expected 2 suffix-match elements, got %d", len(got))
+	}
+
+	te.MaxFileElements = 1
+	if got := te.FindElementsForFile("index.ts"); len(got) != 1 {
+		t.Errorf("expected results capped at 1, got %d", len(got))
+	}
+}
+
+func TestToolExecutorFindDependents(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "f_parser", Type: "file", RelativePath: "internal/parser/parser.go", Metadata: map[string]any{}},
+		{
+			ID: "f_indexer", Type: "file", RelativePath: "internal/index/indexer.go",
+			Metadata: map[string]any{"imports": []types.ImportInfo{{Module: "internal/parser"}}},
+		},
+		{
+			ID: "f_engine", Type: "file", RelativePath: "internal/orchestrator/engine.go",
+			Metadata: map[string]any{"imports": []types.ImportInfo{{Module: "internal/index"}}},
+		},
+	}
+	graphs := graph.NewCodeGraphs()
+	graphs.BuildGraphs(elements)
+
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	te := NewToolExecutor(hr, nil, elements)
+	te.SetGraphs(graphs)
+
+	result, err := te.Execute("find_dependents", "internal/parser/parser.go")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if len(result.Elements) != 2 {
+		t.Fatalf("expected 2 transitive dependents, got %d", len(result.Elements))
+	}
+	if !strings.Contains(result.Text, "[depth 1] internal/index/indexer.go") ||
+		!strings.Contains(result.Text, "[depth 2] internal/orchestrator/engine.go") {
+		t.Errorf("depth not surfaced in text: %q", result.Text)
+	}
+
+	te.DependentsDepth = 1
+	result, _ = te.Execute("find_dependents", "internal/parser/parser.go")
+	if len(result.Elements) != 1 {
+		t.Errorf("expected 1 direct dependent at depth 1, got %d", len(result.Elements))
+	}
+}
+
+func TestToolExecutorOutlineFile(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "fn2", Type: "function", Name: "Stop", RelativePath: "server.go", StartLine: 30, EndLine: 35,
+			Signature: "Server.Stop() error"},
+		{ID: "file", Type: "file", Name: "server.go", RelativePath: "server.go", StartLine: 1, EndLine: 40,
+			Code: "package main ...", Metadata: map[string]any{
+				"imports": []types.ImportInfo{{Module: "fmt", Line: 3}, {Module: "net/http", Line: 4}},
+			}},
+		{ID: "fn1", Type: "function", Name: "Start", RelativePath: "server.go", StartLine: 12, EndLine: 20,
+			Signature: "Server.Start() error", Docstring: "Start listens on the port.\nIt blocks until Stop."},
+		{ID: "cls", Type: "class", Name: "Server", RelativePath: "server.go", StartLine: 6, EndLine: 10,
+			Signature: "struct Server"},
+		{ID: "other", Type: "function", Name: "helper", RelativePath: "util/server.go", StartLine: 1, EndLine: 2},
+	}
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	te := NewToolExecutor(hr, nil, elements)
+
+	result, err := te.Execute("outline_file", "server.go")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	want := `server.go
+imports:
+  L3 fmt
+  L4 net/http
+types:
+  L6-10 struct Server
+functions:
+  L12-20 Server.Start() error — Start listens on the port.
+  L30-35 Server.Stop() error
+`
+	if result.Text != want {
+		t.Errorf("outline =\n%s\nwant\n%s", result.Text, want)
+	}
+	if len(result.Elements) != 1 || result.Elements[0].Code != want {
+		t.Errorf("expected the file element carrying the outline, got %+v", result.Elements)
+	}
+
+	result, _ = te.Execute("outline_file", "missing.go")
+	if !strings.Contains(result.Text, "File not found") {
+		t.Errorf("expected not-found text, got %q", result.Text)
+	}
+}
+
+func TestToolExecutorResolveSymbol(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "call", Type: "function", Name: "main", RelativePath: "main.go", StartLine: 1, EndLine: 5,
+			Code: "func main() { helper(); NewServer().Start() }"},
+		{ID: "helper", Type: "function", Name: "helper", RelativePath: "util/helper.go", StartLine: 3, EndLine: 9,
+			Signature: "func helper() error"},
+		{ID: "doc", Type: "documentation", Name: "helper", RelativePath: "README.md", StartLine: 1, EndLine: 2},
+		{ID: "worker_start", Type: "function", Name: "Start", RelativePath: "a/worker.go", StartLine: 4, EndLine: 8,
+			Metadata: map[string]any{"receiver": "*Worker"}},
+		{ID: "server_start", Type: "function", Name: "Start", RelativePath: "server.go", StartLine: 12, EndLine: 20,
+			Metadata: map[string]any{"receiver": "*Server"}},
+		{ID: "server", Type: "class", Name: "Server", RelativePath: "server.go", StartLine: 6, EndLine: 10},
+	}
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	te := NewToolExecutor(hr, nil, elements)
+
+	result, err := te.Execute("resolve_symbol", "helper()")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if len(result.Elements) != 1 || result.Elements[0].ID != "helper" {
+		t.Errorf("helper() resolved to %+v, want only the function definition", result.Elements)
+	}
+	if !strings.Contains(result.Text, "util/helper.go:3-9 function func helper() error") {
+		t.Errorf("text = %q", result.Text)
+	}
+
+	result, _ = te.Execute("resolve_symbol", "s.Server.Start")
+	if len(result.Elements) != 2 || result.Elements[0].ID != "server_start" {
+		t.Errorf("Server.Start resolved to %+v, want the Server method first", result.Elements)
+	}
+
+	// Exact matches fail, so the case-insensitive one is used
+	result, _ = te.Execute("resolve_symbol", "server")
+	if len(result.Elements) != 1 || result.Elements[0].ID != "server" {
+		t.Errorf("server resolved to %+v", result.Elements)
+	}
+
+	te.Languages = []string{"python"}
+	result, _ = te.Execute("resolve_symbol", "helper")
+	if len(result.Elements) != 0 || !strings.Contains(result.Text, "Symbol not found") {
+		t.Errorf("expected the language filter to hide helper, got %+v", result)
+	}
+}
+
+func TestToolExecutorDeterministicOrder(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "b_file", Type: "file", RelativePath: "pkg/b.go", StartLine: 1},
+		{ID: "a_fn2", Type: "function", Name: "second", RelativePath: "pkg/a.go", StartLine: 20},
+		{ID: "a_file", Type: "file", RelativePath: "pkg/a.go", StartLine: 1},
+		{ID: "a_cls", Type: "class", Name: "Thing", RelativePath: "pkg/a.go", StartLine: 5},
+		{ID: "a_fn1", Type: "function", Name: "first", RelativePath: "pkg/a.go", StartLine: 10},
+		{ID: "c_file", Type: "file", RelativePath: "cmd/c.go", StartLine: 1},
+	}
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+
+	ids := func(elems []types.CodeElement) string {
+		var out []string
+		for _, e := range elems {
+			out = append(out, e.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	for run := 0; run < 5; run++ {
+		te := NewToolExecutor(hr, nil, elements)
+
+		list, _ := te.Execute("list_directory", ".go")
+		if got := ids(list.Elements); got != "c_file,a_file,b_file" {
+			t.Fatalf("list_directory order = %s", got)
+		}
+		skim, _ := te.Execute("skim_file", "pkg/a.go")
+		if got := ids(skim.Elements); got != "a_cls,a_fn1,a_fn2" {
+			t.Fatalf("skim_file order = %s", got)
+		}
+		if got := ids(te.FindElementsForFile("pkg/a.go")); got != "a_file,a_cls,a_fn1,a_fn2" {
+			t.Fatalf("FindElementsForFile order = %s", got)
+		}
+	}
+}
+
+func TestToolExecutorDuplicateIDsLastWins(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "a_fn", Type: "function", Name: "stale", RelativePath: "pkg/a.go", StartLine: 10},
+		{ID: "a_file", Type: "file", RelativePath: "pkg/a.go", StartLine: 1},
+		{ID: "a_fn", Type: "function", Name: "fresh", RelativePath: "pkg/a.go", StartLine: 30},
+	}
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	te := NewToolExecutor(hr, nil, elements)
+
+	found := te.FindElementsForFile("pkg/a.go")
+	if len(found) != 2 || found[1].Name != "fresh" || found[1].StartLine != 30 {
+		t.Fatalf("FindElementsForFile = %+v, want a_file then the last a_fn", found)
+	}
+	if elem := te.elements["a_fn"]; elem.Name != "fresh" {
+		t.Errorf("elements[a_fn] = %q, want the last duplicate", elem.Name)
+	}
+}
+
+func TestToolExecutorContextLines(t *testing.T) {
+	file := "package util\n\nimport \"fmt\"\n\nfunc helper() {\n\tfmt.Println()\n}\n\ntype Opts struct{}"
+	elements := []types.CodeElement{
+		{ID: "file", Type: "file", Name: "util.go", RelativePath: "util.go", StartLine: 1, EndLine: 9, Code: file},
+		{ID: "helper", Type: "function", Name: "helper", RelativePath: "util.go", StartLine: 5, EndLine: 7,
+			Code: "func helper() {\n\tfmt.Println()\n}"},
+		{ID: "other", Type: "function", Name: "helper", RelativePath: "other.go", StartLine: 1, EndLine: 1, Code: "func helper() {}"},
+	}
+	hr := index.NewHybridRetriever(index.NewVectorStore(), index.NewBM25(1.5, 0.75))
+	te := NewToolExecutor(hr, nil, elements)
+
+	byID := func(result *ToolResult, id string) types.CodeElement {
+		for _, e := range result.Elements {
+			if e.ID == id {
+				return e
+			}
+		}
+		t.Fatalf("%s not in %+v", id, result.Elements)
+		return types.CodeElement{}
+	}
+
+	result, _ := te.Execute("resolve_symbol", "helper")
+	if got := byID(result, "helper"); got.StartLine != 5 || got.EndLine != 7 {
+		t.Errorf("without ContextLines the range changed: %d-%d", got.StartLine, got.EndLine)
+	}
+
+	te.ContextLines = 2
+	result, _ = te.Execute("resolve_symbol", "helper")
+	if got := byID(result, "other"); got.StartLine != 1 || got.EndLine != 1 {
+		t.Errorf("element without an indexed file widened to %d-%d", got.StartLine, got.EndLine)
+	}
+	got := byID(result, "helper")
+	want := "import \"fmt\"\n\nfunc helper() {\n\tfmt.Println()\n}\n\ntype Opts struct{}"
+	if got.StartLine != 3 || got.EndLine != 9 || got.Code != want {
+		t.Errorf("widened to %d-%d:\n%s", got.StartLine, got.EndLine, got.Code)
+	}
+	if elements[1].StartLine != 5 {
+		t.Error("WithContext modified the indexed element")
+	}
+
+	// Code the indexer truncated only provides its complete lines
+	truncated := addContextLines(elements[1], fileLines("a\nb\nc\nd\npartial"+index.TruncatedMarker), 3)
+	if truncated.StartLine != 2 || truncated.EndLine != 7 {
+		t.Errorf("truncated file widened to %d-%d", truncated.StartLine, truncated.EndLine)
+	}
+}
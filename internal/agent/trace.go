@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"sort"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+// RoundTrace records what one retrieval round did, for diagnosing why the
+// agent gathered (or missed) code.
+type RoundTrace struct {
+	Round      int             `json:"round"`
+	Confidence int             `json:"confidence"`
+	ToolCalls  []TraceToolCall `json:"tool_calls,omitempty"`
+
+	// Candidates counts the elements the round's searches and tool calls
+	// returned, before deduplication and graph expansion.
+	Candidates int `json:"candidates"`
+
+	// KeepFiles are the files the model asked to keep (rounds 2+); Files
+	// are the files of the gathered elements after the round.
+	KeepFiles []string `json:"keep_files,omitempty"`
+	Files     []string `json:"files"`
+
+	Elements    int     `json:"elements"`
+	TotalLines  int     `json:"total_lines"`
+	BudgetUsage float64 `json:"budget_usage"` // percent of the adaptive budget
+
+	// StopReason is set on the round that ended retrieval.
+	StopReason string `json:"stop_reason,omitempty"`
+}
+
+// TraceToolCall is a tool call as issued by the model.
+type TraceToolCall struct {
+	Tool       string         `json:"tool"`
+	Parameters map[string]any `json:"parameters,omitempty"`
+}
+
+// traceRound starts the trace of round with the model's confidence and tool
+// calls, measuring the currently gathered elements.
+func (ia *IterativeAgent) traceRound(round, confidence int, calls []ToolCall, keepFiles []string, candidates int) {
+	tr := RoundTrace{Round: round, Confidence: confidence, KeepFiles: keepFiles}
+	for _, tc := range calls {
+		params := tc.Parameters
+		if params == nil && tc.GetArg() != "" {
+			params = map[string]any{"arg": tc.GetArg()}
+		}
+		tr.ToolCalls = append(tr.ToolCalls, TraceToolCall{Tool: tc.GetToolName(), Parameters: params})
+	}
+	ia.iterationHistory = append(ia.iterationHistory, tr)
+	ia.traceGathered(candidates)
+}
+
+// traceGathered adds candidates to the latest round's trace and re-measures
+// the gathered elements after its tool calls ran.
+func (ia *IterativeAgent) traceGathered(candidates int) {
+	tr := &ia.iterationHistory[len(ia.iterationHistory)-1]
+	tr.Candidates += candidates
+	tr.Files = elementFiles(ia.gatheredElements)
+	tr.Elements = len(ia.gatheredElements)
+	tr.TotalLines = ia.calculateTotalLines(ia.gatheredElements)
+	tr.BudgetUsage = ia.budgetUsage(ia.gatheredElements)
+}
+
+// elementFiles returns the sorted, distinct file paths of elements.
+func elementFiles(elements []types.CodeElement) []string {
+	seen := make(map[string]bool)
+	files := []string{}
+	for _, e := range elements {
+		if e.RelativePath != "" && !seen[e.RelativePath] {
+			seen[e.RelativePath] = true
+			files = append(files, e.RelativePath)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
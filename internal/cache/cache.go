@@ -2,9 +2,15 @@ package cache
 
 import (
 	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
 )
@@ -14,6 +20,8 @@ func init() {
 	gob.Register([]types.FunctionInfo{})
 	gob.Register([]types.ClassInfo{})
 	gob.Register(map[string]any{})
+	gob.Register(map[string]int{})
+	gob.Register(map[string]string{})
 }
 
 // IndexCache handles persisting and loading index data to/from disk.
@@ -28,12 +36,45 @@ func NewIndexCache(cacheDir string) *IndexCache {
 
 // CachedIndex represents the serializable index data.
 type CachedIndex struct {
-	RepoName string
-	Elements []types.CodeElement
-	Vectors  map[string][]float32 // elementID → embedding
+	RepoName  string
+	IndexedAt time.Time // zero for caches written before it was recorded
+	Elements  []types.CodeElement
+	Vectors   map[string][]float32 // elementID → embedding
+
+	// ChunkVectors holds the embeddings of chunks of large elements' code,
+	// keyed by element ID; nil for caches written before chunking.
+	ChunkVectors map[string][][]float32
+
+	// ParseErrorFiles lists the files indexed despite syntax errors.
+	ParseErrorFiles []string
+
+	// TruncatedFiles lists the files whose elements were capped by the
+	// per-file element limit.
+	TruncatedFiles []string
+
+	// EmbeddingModel and EmbeddingDimensions record how the vectors were
+	// embedded: the model and the requested vector size, or 0 for the
+	// model's native size. Both are zero for older caches.
+	EmbeddingModel      string
+	EmbeddingDimensions int
+
+	// RepoConfigHash identifies the repository's .fastcode.yaml settings
+	// the index was built under; it is empty when there were none.
+	RepoConfigHash string
 }
 
-// Save writes the index data to disk.
+// Entry summarizes one cached index for listing.
+type Entry struct {
+	RepoName  string    `json:"repo_name"`
+	Elements  int       `json:"elements"`
+	Vectors   int       `json:"vectors"`
+	IndexedAt time.Time `json:"indexed_at"`
+	SizeBytes int64     `json:"size_bytes"`
+	Error     string    `json:"error,omitempty"` // set when the cache file cannot be decoded
+}
+
+// Save writes the index data to disk, along with a small header that lets
+// List summarize the cache without decoding it.
 func (c *IndexCache) Save(repoName string, data *CachedIndex) error {
 	if err := os.MkdirAll(c.CacheDir, 0755); err != nil {
 		return fmt.Errorf("create cache dir: %w", err)
@@ -51,6 +92,18 @@ func (c *IndexCache) Save(repoName string, data *CachedIndex) error {
 		return fmt.Errorf("encode cache: %w", err)
 	}
 
+	header, err := json.Marshal(Entry{
+		RepoName:  data.RepoName,
+		Elements:  len(data.Elements),
+		Vectors:   len(data.Vectors),
+		IndexedAt: data.IndexedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("encode cache header: %w", err)
+	}
+	if err := os.WriteFile(c.headerPath(repoName), header, 0644); err != nil {
+		return fmt.Errorf("write cache header: %w", err)
+	}
 	return nil
 }
 
@@ -80,9 +133,88 @@ func (c *IndexCache) Exists(repoName string) bool {
 
 // Delete removes the cache file for a repo.
 func (c *IndexCache) Delete(repoName string) error {
-	return os.Remove(c.cachePath(repoName))
+	if err := os.Remove(c.cachePath(repoName)); err != nil {
+		return err
+	}
+	if err := os.Remove(c.headerPath(repoName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List summarizes every cached index in CacheDir, most recently indexed
+// first. Each summary comes from the header Save writes next to the cache;
+// caches without an up-to-date header are decoded in full. A missing cache
+// directory yields an empty list.
+func (c *IndexCache) List() ([]Entry, error) {
+	files, err := os.ReadDir(c.CacheDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cache dir: %w", err)
+	}
+
+	var entries []Entry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".gob") {
+			continue
+		}
+		repoName := strings.TrimSuffix(f.Name(), ".gob")
+		info, err := f.Info()
+		if err != nil {
+			entries = append(entries, Entry{RepoName: repoName, Error: err.Error()})
+			continue
+		}
+		entry, ok := c.readHeader(repoName, info.ModTime())
+		if !ok {
+			entry = Entry{RepoName: repoName}
+			if data, err := c.Load(repoName); err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.Elements = len(data.Elements)
+				entry.Vectors = len(data.Vectors)
+				entry.IndexedAt = data.IndexedAt
+			}
+		}
+		entry.RepoName = repoName
+		entry.SizeBytes = info.Size()
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].IndexedAt.Equal(entries[j].IndexedAt) {
+			return entries[i].IndexedAt.After(entries[j].IndexedAt)
+		}
+		return entries[i].RepoName < entries[j].RepoName
+	})
+	return entries, nil
+}
+
+// readHeader reads the header of repoName's cache, reporting false when it
+// is missing, unreadable or older than the cache file modified at cacheTime
+// (e.g. a cache written by a version that did not save headers).
+func (c *IndexCache) readHeader(repoName string, cacheTime time.Time) (Entry, bool) {
+	path := c.headerPath(repoName)
+	info, err := os.Stat(path)
+	if err != nil || info.ModTime().Before(cacheTime) {
+		return Entry{}, false
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, false
+	}
+	var entry Entry
+	if json.Unmarshal(raw, &entry) != nil {
+		return Entry{}, false
+	}
+	return entry, true
 }
 
 func (c *IndexCache) cachePath(repoName string) string {
 	return filepath.Join(c.CacheDir, repoName+".gob")
 }
+
+// headerPath is where Save writes the summary List reads.
+func (c *IndexCache) headerPath(repoName string) string {
+	return filepath.Join(c.CacheDir, repoName+".header.json")
+}
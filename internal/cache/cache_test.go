@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
 )
@@ -155,3 +156,80 @@ func TestCacheSaveEmptyData(t *testing.T) {
 		t.Errorf("RepoName = %q", loaded.RepoName)
 	}
 }
+
+func TestCacheList(t *testing.T) {
+	dir := t.TempDir()
+	c := NewIndexCache(dir)
+
+	older := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(24 * time.Hour)
+	c.Save("alpha", &CachedIndex{RepoName: "alpha", IndexedAt: older,
+		Elements: []types.CodeElement{{ID: "a1"}, {ID: "a2"}}})
+	c.Save("beta", &CachedIndex{RepoName: "beta", IndexedAt: newer,
+		Elements: []types.CodeElement{{ID: "b1"}}, Vectors: map[string][]float32{"b1": {0.1}}})
+	os.WriteFile(filepath.Join(dir, "broken.gob"), []byte("not gob"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644)
+
+	entries, err := c.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3: %+v", len(entries), entries)
+	}
+	// Newest first; undecodable caches (zero time) last
+	if entries[0].RepoName != "beta" || entries[1].RepoName != "alpha" || entries[2].RepoName != "broken" {
+		t.Errorf("order = %s, %s, %s", entries[0].RepoName, entries[1].RepoName, entries[2].RepoName)
+	}
+	if entries[0].Elements != 1 || entries[0].Vectors != 1 || !entries[0].IndexedAt.Equal(newer) {
+		t.Errorf("beta entry = %+v", entries[0])
+	}
+	if entries[1].Elements != 2 || entries[1].SizeBytes == 0 {
+		t.Errorf("alpha entry = %+v", entries[1])
+	}
+	if entries[2].Error == "" {
+		t.Error("broken cache should report an error")
+	}
+
+	// A missing cache dir is not an error
+	entries, err = NewIndexCache(filepath.Join(dir, "missing")).List()
+	if err != nil || len(entries) != 0 {
+		t.Errorf("missing dir: entries=%v err=%v", entries, err)
+	}
+}
+
+func TestCacheListReadsHeader(t *testing.T) {
+	dir := t.TempDir()
+	c := NewIndexCache(dir)
+	indexedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.Save("alpha", &CachedIndex{RepoName: "alpha", IndexedAt: indexedAt,
+		Elements: []types.CodeElement{{ID: "a1"}, {ID: "a2"}}})
+
+	// With a current header the cache itself is never decoded
+	cachePath := c.cachePath("alpha")
+	os.WriteFile(cachePath, []byte("not gob"), 0644)
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(cachePath, past, past)
+	entries, err := c.List()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List = %+v, %v", entries, err)
+	}
+	if e := entries[0]; e.Error != "" || e.Elements != 2 || !e.IndexedAt.Equal(indexedAt) || e.SizeBytes != int64(len("not gob")) {
+		t.Errorf("entry from header = %+v", e)
+	}
+
+	// A cache without a header (written by an older version) is decoded
+	c.Save("beta", &CachedIndex{RepoName: "beta", Elements: []types.CodeElement{{ID: "b1"}}})
+	os.Remove(c.headerPath("beta"))
+	entries, _ = c.List()
+	if len(entries) != 2 || entries[1].RepoName != "beta" || entries[1].Elements != 1 {
+		t.Errorf("entries without a header = %+v", entries)
+	}
+
+	if err := c.Delete("alpha"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(c.headerPath("alpha")); !os.IsNotExist(err) {
+		t.Errorf("Delete left the header behind: %v", err)
+	}
+}
@@ -4,17 +4,27 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"gopkg.in/yaml.v3"
 )
 
 // FastCodeConfig holds global configuration loaded from ~/.fastcode/config.yaml.
 type FastCodeConfig struct {
-	OpenAIAPIKey   string `yaml:"openai_api_key"`
-	Model          string `yaml:"model"`
-	BaseURL        string `yaml:"base_url"`
-	EmbeddingURL   string `yaml:"embedding_url"`   // Separate URL for embedding API
-	EmbeddingModel string `yaml:"embedding_model"` // Embedding model name
+	OpenAIAPIKey    string `yaml:"openai_api_key"`
+	Model           string `yaml:"model"`
+	BaseURL         string `yaml:"base_url"`
+	EmbeddingURL    string `yaml:"embedding_url"`        // Separate URL for embedding API
+	EmbeddingModel  string `yaml:"embedding_model"`      // Embedding model name
+	EmbeddingFormat string `yaml:"embedding_format"`     // Embedding response shape: "openai" or "embeddings"
+	EmbeddingDims   int    `yaml:"embedding_dimensions"` // Reduced embedding size; 0 keeps the model's
+	EmbeddingTokens int    `yaml:"embedding_max_tokens"` // Embedding input limit; 0 uses the model's known one
+	RedactSecrets   *bool  `yaml:"redact_secrets"`       // Mask secrets in code sent to the model (default true)
+	AnswerTokens    int    `yaml:"max_answer_tokens"`    // Answer generation limit; 0 follows the verbosity
+
+	StopWords      []string `yaml:"stop_words"`       // Replaces the built-in query stop words
+	ExtraStopWords []string `yaml:"extra_stop_words"` // Added to the query stop words
+	StemKeywords   bool     `yaml:"stem_keywords"`    // Stem query keywords ("handlers" → "handler")
 }
 
 // DefaultConfigPath returns the default config file path.
@@ -51,6 +61,19 @@ func LoadFrom(path string) (*FastCodeConfig, error) {
 	setIfEmpty("BASE_URL", cfg.BaseURL)
 	setIfEmpty("EMBEDDING_URL", cfg.EmbeddingURL)
 	setIfEmpty("EMBEDDING_MODEL", cfg.EmbeddingModel)
+	setIfEmpty("EMBEDDING_FORMAT", cfg.EmbeddingFormat)
+	if cfg.EmbeddingDims > 0 {
+		setIfEmpty("EMBEDDING_DIMENSIONS", strconv.Itoa(cfg.EmbeddingDims))
+	}
+	if cfg.EmbeddingTokens > 0 {
+		setIfEmpty("EMBEDDING_MAX_TOKENS", strconv.Itoa(cfg.EmbeddingTokens))
+	}
+	if cfg.AnswerTokens > 0 {
+		setIfEmpty("FASTCODE_MAX_ANSWER_TOKENS", strconv.Itoa(cfg.AnswerTokens))
+	}
+	if cfg.RedactSecrets != nil {
+		setIfEmpty("FASTCODE_REDACT_SECRETS", strconv.FormatBool(*cfg.RedactSecrets))
+	}
 
 	return cfg, nil
 }
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+// RepoConfigFile is the name of the per-repository settings file, read from
+// the repository root.
+const RepoConfigFile = ".fastcode.yaml"
+
+// RepoConfig holds the settings a repository keeps in its .fastcode.yaml.
+// They override the global config and the environment, which in turn
+// override the defaults. Unset fields leave the global value alone.
+type RepoConfig struct {
+	Include     []string `yaml:"include"`      // Globs of files to index, added to --include
+	Exclude     []string `yaml:"exclude"`      // Globs of files to skip, added to --exclude
+	ExcludeDirs []string `yaml:"exclude_dirs"` // Directory names skipped anywhere, added to the built-in ones
+	MaxFileSize int64    `yaml:"max_file_size"`
+
+	JSArrowFunctions *bool `yaml:"js_arrow_functions"`
+	MarkdownSections *bool `yaml:"markdown_sections"`
+
+	EmbeddingModel string `yaml:"embedding_model"`
+
+	// Languages maps file extensions to the language their files are
+	// indexed as, over the built-in mapping, e.g. {".mjs": javascript}.
+	Languages map[string]string `yaml:"languages"`
+
+	// Hash identifies the file's contents, so indexes built under other
+	// settings can be told apart; it is empty when there is no file.
+	Hash string `yaml:"-"`
+}
+
+// LoadRepoConfig reads the .fastcode.yaml at the root of the repository at
+// rootPath. A missing file yields an empty RepoConfig; unknown keys are an
+// error, so misspelled settings are not silently ignored.
+func LoadRepoConfig(rootPath string) (*RepoConfig, error) {
+	cfg := &RepoConfig{}
+	path := filepath.Join(rootPath, RepoConfigFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("read repository config %s: %w", path, err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse repository config %s: %w", path, err)
+	}
+	if cfg.MaxFileSize < 0 {
+		return nil, fmt.Errorf("repository config %s: max_file_size must not be negative", path)
+	}
+	if cfg.Languages, err = util.NormalizeLanguageOverrides(cfg.Languages); err != nil {
+		return nil, fmt.Errorf("repository config %s: languages: %w", path, err)
+	}
+	cfg.Hash = fmt.Sprintf("%x", sha256.Sum256(data))
+	return cfg, nil
+}
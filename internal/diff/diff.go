@@ -0,0 +1,106 @@
+package diff
+
+import (
+	"sort"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+// Change describes one class or function that differs between two indexes.
+type Change struct {
+	Key          string `json:"key"` // type:path:qualified name, stable across repository names
+	Type         string `json:"type"`
+	Name         string `json:"name"`
+	RelativePath string `json:"relative_path"`
+	OldID        string `json:"old_id,omitempty"`
+	NewID        string `json:"new_id,omitempty"`
+	OldSignature string `json:"old_signature,omitempty"`
+	NewSignature string `json:"new_signature,omitempty"`
+}
+
+// Result groups the changes between an old and a new index.
+type Result struct {
+	Added            []Change `json:"added"`
+	Removed          []Change `json:"removed"`
+	SignatureChanged []Change `json:"signature_changed"`
+	Modified         []Change `json:"modified"` // same signature, different body
+}
+
+// Empty reports whether the two indexes have no differing elements.
+func (r *Result) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.SignatureChanged) == 0 && len(r.Modified) == 0
+}
+
+// Elements compares the classes and functions of two indexes. Elements are
+// matched by type, relative path and qualified name rather than by ID, since
+// IDs include the repository name. File and documentation elements are
+// ignored: they change whenever anything in the file does.
+func Elements(oldElems, newElems []types.CodeElement) *Result {
+	oldByKey := keyed(oldElems)
+	newByKey := keyed(newElems)
+
+	result := &Result{}
+	for key, n := range newByKey {
+		o, ok := oldByKey[key]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, change(key, nil, n))
+		case o.Signature != n.Signature:
+			result.SignatureChanged = append(result.SignatureChanged, change(key, o, n))
+		case o.Code != n.Code:
+			result.Modified = append(result.Modified, change(key, o, n))
+		}
+	}
+	for key, o := range oldByKey {
+		if _, ok := newByKey[key]; !ok {
+			result.Removed = append(result.Removed, change(key, o, nil))
+		}
+	}
+
+	for _, changes := range [][]Change{result.Added, result.Removed, result.SignatureChanged, result.Modified} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	}
+	return result
+}
+
+// Key returns the repository-independent identity of a class or function
+// element, or "" for other element types.
+func Key(e *types.CodeElement) string {
+	switch e.Type {
+	case "class":
+		return "class:" + e.RelativePath + ":" + e.Name
+	case "function":
+		name := e.Name
+		if cls, _ := e.Metadata["class_name"].(string); cls != "" {
+			name = cls + "." + name
+		}
+		return "function:" + e.RelativePath + ":" + name
+	}
+	return ""
+}
+
+func keyed(elements []types.CodeElement) map[string]*types.CodeElement {
+	byKey := make(map[string]*types.CodeElement, len(elements))
+	for i := range elements {
+		if key := Key(&elements[i]); key != "" {
+			byKey[key] = &elements[i]
+		}
+	}
+	return byKey
+}
+
+func change(key string, o, n *types.CodeElement) Change {
+	c := Change{Key: key}
+	for _, e := range []*types.CodeElement{o, n} {
+		if e != nil {
+			c.Type, c.Name, c.RelativePath = e.Type, e.Name, e.RelativePath
+		}
+	}
+	if o != nil {
+		c.OldID, c.OldSignature = o.ID, o.Signature
+	}
+	if n != nil {
+		c.NewID, c.NewSignature = n.ID, n.Signature
+	}
+	return c
+}
@@ -0,0 +1,73 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+func fn(repo, path, class, name, sig, code string) types.CodeElement {
+	return types.CodeElement{
+		ID: repo + "_" + name, Type: "function", Name: name, RelativePath: path,
+		Signature: sig, Code: code, Metadata: map[string]any{"class_name": class},
+	}
+}
+
+func TestElements(t *testing.T) {
+	oldElems := []types.CodeElement{
+		{ID: "old_file", Type: "file", Name: "a.py", RelativePath: "a.py", Code: "old"},
+		fn("old", "a.py", "", "keep", "keep()", "return 1"),
+		fn("old", "a.py", "", "body", "body()", "return 1"),
+		fn("old", "a.py", "", "sig", "sig(a)", "return a"),
+		fn("old", "a.py", "", "gone", "gone()", "pass"),
+		fn("old", "a.py", "Server", "start", "Server.start(self)", "pass"),
+	}
+	newElems := []types.CodeElement{
+		{ID: "new_file", Type: "file", Name: "a.py", RelativePath: "a.py", Code: "new"},
+		fn("new", "a.py", "", "keep", "keep()", "return 1"),
+		fn("new", "a.py", "", "body", "body()", "return 2"),
+		fn("new", "a.py", "", "sig", "sig(a, b)", "return a + b"),
+		fn("new", "a.py", "", "fresh", "fresh()", "pass"),
+		// Same name, different class: a new method, not a rename of Server.start
+		fn("new", "a.py", "Client", "start", "Client.start(self)", "pass"),
+		{ID: "new_cls", Type: "class", Name: "Client", RelativePath: "a.py", Signature: "class Client"},
+	}
+
+	r := Elements(oldElems, newElems)
+	keys := func(changes []Change) []string {
+		var out []string
+		for _, c := range changes {
+			out = append(out, c.Key)
+		}
+		return out
+	}
+	check := func(name string, got []Change, want ...string) {
+		t.Helper()
+		k := keys(got)
+		if len(k) != len(want) {
+			t.Errorf("%s = %v, want %v", name, k, want)
+			return
+		}
+		for i := range want {
+			if k[i] != want[i] {
+				t.Errorf("%s = %v, want %v", name, k, want)
+				return
+			}
+		}
+	}
+	check("Added", r.Added, "class:a.py:Client", "function:a.py:Client.start", "function:a.py:fresh")
+	check("Removed", r.Removed, "function:a.py:Server.start", "function:a.py:gone")
+	check("SignatureChanged", r.SignatureChanged, "function:a.py:sig")
+	check("Modified", r.Modified, "function:a.py:body")
+
+	sig := r.SignatureChanged[0]
+	if sig.OldSignature != "sig(a)" || sig.NewSignature != "sig(a, b)" || sig.OldID != "old_sig" || sig.NewID != "new_sig" {
+		t.Errorf("signature change = %+v", sig)
+	}
+	if r.Empty() {
+		t.Error("Empty() = true for differing indexes")
+	}
+	if !Elements(oldElems, oldElems).Empty() {
+		t.Error("comparing an index with itself should be empty")
+	}
+}
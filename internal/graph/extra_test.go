@@ -152,6 +152,31 @@ func TestBuildCallGraphWithCalls(t *testing.T) {
 	}
 }
 
+func TestBuildCallGraphWeightsByCallCount(t *testing.T) {
+	cg := NewCodeGraphs()
+
+	elements := []types.CodeElement{
+		{
+			ID: "fn1", Type: "function", Name: "main",
+			Metadata: map[string]any{
+				"calls":       []string{"helper", "log"},
+				"call_counts": map[string]int{"helper": 4},
+			},
+		},
+		{ID: "fn2", Type: "function", Name: "helper", Metadata: map[string]any{}},
+		{ID: "fn3", Type: "function", Name: "log", Metadata: map[string]any{}},
+	}
+
+	cg.BuildGraphs(elements)
+
+	if got := cg.Call.EdgeWeight("fn1", "fn2"); got != 4 {
+		t.Errorf("EdgeWeight(main, helper) = %d, want 4", got)
+	}
+	if got := cg.Call.EdgeWeight("fn1", "fn3"); got != 1 {
+		t.Errorf("EdgeWeight(main, log) = %d, want 1 without a count", got)
+	}
+}
+
 func TestBuildCallGraphNoCalls(t *testing.T) {
 	cg := NewCodeGraphs()
 
@@ -265,3 +290,34 @@ func TestBuildDependencyGraphSkipsNonFileElements(t *testing.T) {
 		t.Error("non-file elements should be skipped in dependency graph")
 	}
 }
+
+// === buildDependencyGraph: C/C++ includes ===
+
+func TestBuildDependencyGraphCIncludes(t *testing.T) {
+	cg := NewCodeGraphs()
+
+	elements := []types.CodeElement{
+		{
+			ID: "main_c", Type: "file", Name: "src/main.c", RelativePath: "src/main.c", Language: "c",
+			Metadata: map[string]any{
+				"imports": []types.ImportInfo{
+					{Module: "stdio.h", Line: 1, IsSystem: true},
+					{Module: "util.h", Line: 2},
+				},
+			},
+		},
+		{ID: "src_util_h", Type: "file", Name: "src/util.h", RelativePath: "src/util.h", Language: "c", Metadata: map[string]any{}},
+		{ID: "lib_util_h", Type: "file", Name: "lib/util.h", RelativePath: "lib/util.h", Language: "c", Metadata: map[string]any{}},
+		{ID: "stdio_h", Type: "file", Name: "vendor/stdio.h", RelativePath: "vendor/stdio.h", Language: "c", Metadata: map[string]any{}},
+	}
+
+	cg.BuildGraphs(elements)
+
+	deps := cg.Dependency.Successors("main_c")
+	if len(deps) != 1 {
+		t.Fatalf("expected exactly 1 dependency (system include adds no edge), got %v", deps)
+	}
+	if deps[0] != "src_util_h" {
+		t.Errorf("local include resolved to %s, want src_util_h (sibling header)", deps[0])
+	}
+}
@@ -3,6 +3,7 @@ package graph
 import (
 	"crypto/sha256"
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
@@ -26,9 +27,10 @@ type Edge struct {
 
 // Graph holds a single graph's adjacency list.
 type Graph struct {
-	Type    GraphType           `json:"type"`
-	Forward map[string][]string // node → outgoing edges
-	Reverse map[string][]string // node → incoming edges
+	Type    GraphType                 `json:"type"`
+	Forward map[string][]string       // node → outgoing edges
+	Reverse map[string][]string       // node → incoming edges
+	Weights map[string]map[string]int // source → target → weight, for edges added via AddWeightedEdge
 }
 
 // NewGraph creates a new empty graph.
@@ -37,6 +39,7 @@ func NewGraph(t GraphType) *Graph {
 		Type:    t,
 		Forward: make(map[string][]string),
 		Reverse: make(map[string][]string),
+		Weights: make(map[string]map[string]int),
 	}
 }
 
@@ -55,6 +58,39 @@ func (g *Graph) AddEdge(source, target string) {
 	g.Reverse[target] = append(g.Reverse[target], source)
 }
 
+// AddWeightedEdge adds a directed edge from source to target with the given
+// weight. If the edge already exists its weight is increased instead.
+func (g *Graph) AddWeightedEdge(source, target string, weight int) {
+	if source == target {
+		return
+	}
+	existing := g.EdgeWeight(source, target)
+	if existing == 0 {
+		g.AddEdge(source, target)
+	}
+	if g.Weights == nil {
+		g.Weights = make(map[string]map[string]int)
+	}
+	if g.Weights[source] == nil {
+		g.Weights[source] = make(map[string]int)
+	}
+	g.Weights[source][target] = existing + weight
+}
+
+// EdgeWeight returns the weight of the edge from source to target.
+// Edges added via AddEdge have weight 1; missing edges have weight 0.
+func (g *Graph) EdgeWeight(source, target string) int {
+	if w, ok := g.Weights[source][target]; ok {
+		return w
+	}
+	for _, t := range g.Forward[source] {
+		if t == target {
+			return 1
+		}
+	}
+	return 0
+}
+
 // Successors returns all direct successors of a node.
 func (g *Graph) Successors(nodeID string) []string {
 	return g.Forward[nodeID]
@@ -94,7 +130,8 @@ type CodeGraphs struct {
 
 	// Lookup maps
 	elementByID map[string]*types.CodeElement
-	fileByPath  map[string]string // relativePath → elementID
+	fileByPath  map[string]string   // relativePath → elementID
+	referrers   map[string][]string // symbol name → IDs of elements calling or importing it
 }
 
 // NewCodeGraphs creates a new set of code relationship graphs.
@@ -105,6 +142,7 @@ func NewCodeGraphs() *CodeGraphs {
 		Call:        NewGraph(CallGraph),
 		elementByID: make(map[string]*types.CodeElement),
 		fileByPath:  make(map[string]string),
+		referrers:   make(map[string][]string),
 	}
 }
 
@@ -123,6 +161,7 @@ func (cg *CodeGraphs) BuildGraphs(elements []types.CodeElement) {
 	cg.buildDependencyGraph(elements)
 	cg.buildInheritanceGraph(elements)
 	cg.buildCallGraph(elements)
+	cg.buildReferrers(elements)
 }
 
 // GetRelatedElements returns all elements within maxHops of the given element.
@@ -163,6 +202,129 @@ func (cg *CodeGraphs) GetRelatedElements(elementID string, maxHops int) []string
 	return related
 }
 
+// ShortestPath returns the shortest chain of element IDs from fromID to toID,
+// following forward edges of the union of the given graphs (all graphs if none
+// are given). It returns nil when no path exists.
+func (cg *CodeGraphs) ShortestPath(fromID, toID string, graphs []GraphType) []string {
+	if fromID == toID {
+		return []string{fromID}
+	}
+	if len(graphs) == 0 {
+		graphs = []GraphType{DependencyGraph, InheritanceGraph, CallGraph}
+	}
+	var selected []*Graph
+	for _, t := range graphs {
+		if g := cg.Graph(t); g != nil {
+			selected = append(selected, g)
+		}
+	}
+
+	parent := map[string]string{fromID: ""}
+	queue := []string{fromID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, g := range selected {
+			for _, next := range g.Successors(id) {
+				if _, seen := parent[next]; seen {
+					continue
+				}
+				parent[next] = id
+				if next == toID {
+					// Walk back to the start and reverse
+					path := []string{toID}
+					for p := id; p != ""; p = parent[p] {
+						path = append(path, p)
+					}
+					for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+						path[i], path[j] = path[j], path[i]
+					}
+					return path
+				}
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// ImportanceScores ranks the nodes of the given graph with a few iterations of
+// PageRank, so nodes many others point to (directly or transitively) score
+// higher. Scores sum to 1; an empty or unknown graph yields an empty map.
+func (cg *CodeGraphs) ImportanceScores(t GraphType) map[string]float64 {
+	const (
+		damping    = 0.85
+		iterations = 20
+	)
+	scores := make(map[string]float64)
+	g := cg.Graph(t)
+	if g == nil {
+		return scores
+	}
+
+	for src, targets := range g.Forward {
+		scores[src] = 0
+		for _, dst := range targets {
+			scores[dst] = 0
+		}
+	}
+	n := float64(len(scores))
+	if n == 0 {
+		return scores
+	}
+	for id := range scores {
+		scores[id] = 1 / n
+	}
+
+	for iter := 0; iter < iterations; iter++ {
+		next := make(map[string]float64, len(scores))
+		// Rank held by nodes without outgoing edges is spread evenly
+		dangling := 0.0
+		for id, score := range scores {
+			if len(g.Forward[id]) == 0 {
+				dangling += score
+			}
+		}
+		base := (1-damping)/n + damping*dangling/n
+		for id := range scores {
+			next[id] = base
+		}
+		for src, targets := range g.Forward {
+			share := damping * scores[src] / float64(len(targets))
+			for _, dst := range targets {
+				next[dst] += share
+			}
+		}
+		scores = next
+	}
+	return scores
+}
+
+// Graph returns the graph of the given type, or nil if the type is unknown.
+func (cg *CodeGraphs) Graph(t GraphType) *Graph {
+	switch t {
+	case DependencyGraph:
+		return cg.Dependency
+	case InheritanceGraph:
+		return cg.Inheritance
+	case CallGraph:
+		return cg.Call
+	}
+	return nil
+}
+
+// Element returns the element with the given ID, or nil if it is not in the graphs.
+func (cg *CodeGraphs) Element(id string) *types.CodeElement {
+	return cg.elementByID[id]
+}
+
+// Referrers returns the IDs of the functions that call name and the files
+// whose imports name it, in element order. Unlike the call graph, callers are
+// listed even when name is not defined in the repository (library calls).
+func (cg *CodeGraphs) Referrers(name string) []string {
+	return cg.referrers[name]
+}
+
 // Stats returns statistics about all graphs.
 func (cg *CodeGraphs) Stats() map[string]any {
 	return map[string]any{
@@ -193,6 +355,10 @@ func (cg *CodeGraphs) buildDependencyGraph(elements []types.CodeElement) {
 		}
 
 		for _, imp := range importList {
+			// System headers (#include <...>) never live in the repo
+			if imp.IsSystem {
+				continue
+			}
 			// Try to resolve the import to a file in the repo
 			targetID := cg.resolveImport(imp, elem)
 			if targetID != "" {
@@ -255,27 +421,91 @@ func (cg *CodeGraphs) buildCallGraph(elements []types.CodeElement) {
 			continue
 		}
 
-		// Handle both []string (in-memory) and []interface{} (from JSON cache)
-		var callList []string
-		switch v := calls.(type) {
-		case []string:
-			callList = v
-		case []interface{}:
-			for _, item := range v {
-				if s, ok := item.(string); ok {
-					callList = append(callList, s)
+		counts := callCounts(elem.Metadata["call_counts"])
+		for _, callee := range callList(calls) {
+			if targetID, found := funcMap[callee]; found {
+				weight := counts[callee]
+				if weight < 1 {
+					weight = 1
 				}
+				cg.Call.AddWeightedEdge(elem.ID, targetID, weight)
 			}
-		default:
-			continue
 		}
+	}
+}
 
-		for _, callee := range callList {
-			if targetID, found := funcMap[callee]; found {
-				cg.Call.AddEdge(elem.ID, targetID)
+// callList reads callee names from element metadata, handling both []string
+// (in-memory) and []interface{} (from JSON cache).
+func callList(v any) []string {
+	switch calls := v.(type) {
+	case []string:
+		return calls
+	case []interface{}:
+		var list []string
+		for _, item := range calls {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// buildReferrers indexes each function under the names it calls and each file
+// under the modules and names it imports. Qualified names ("pkg.Func") are
+// also indexed under their last segment.
+func (cg *CodeGraphs) buildReferrers(elements []types.CodeElement) {
+	for i := range elements {
+		elem := &elements[i]
+		var names []string
+		switch elem.Type {
+		case "function":
+			names = callList(elem.Metadata["calls"])
+		case "file":
+			imports, _ := elem.Metadata["imports"].([]types.ImportInfo)
+			for _, imp := range imports {
+				names = append(names, imp.Module)
+				for _, n := range imp.Names {
+					if n != "*" {
+						names = append(names, n)
+					}
+				}
+			}
+		}
+
+		seen := make(map[string]bool)
+		for _, name := range names {
+			keys := []string{name}
+			if idx := strings.LastIndexAny(name, "./:"); idx >= 0 && idx < len(name)-1 {
+				keys = append(keys, name[idx+1:])
+			}
+			for _, key := range keys {
+				if key != "" && !seen[key] {
+					seen[key] = true
+					cg.referrers[key] = append(cg.referrers[key], elem.ID)
+				}
+			}
+		}
+	}
+}
+
+// callCounts reads per-callee call counts from element metadata, handling both
+// map[string]int (in-memory) and map[string]interface{} (from JSON cache).
+func callCounts(v any) map[string]int {
+	switch m := v.(type) {
+	case map[string]int:
+		return m
+	case map[string]interface{}:
+		counts := make(map[string]int, len(m))
+		for k, n := range m {
+			if f, ok := n.(float64); ok {
+				counts[k] = int(f)
 			}
 		}
+		return counts
 	}
+	return nil
 }
 
 // resolveImport tries to map an import to a file element ID.
@@ -285,6 +515,14 @@ func (cg *CodeGraphs) resolveImport(imp types.ImportInfo, source *types.CodeElem
 		return ""
 	}
 
+	// C/C++ local includes are relative to the including file's directory
+	if source != nil && (source.Language == "c" || source.Language == "cpp") {
+		rel := filepath.Join(filepath.Dir(source.RelativePath), filepath.FromSlash(module))
+		if id, ok := cg.fileByPath[rel]; ok {
+			return id
+		}
+	}
+
 	// Try direct path match (e.g., Go imports)
 	for path, id := range cg.fileByPath {
 		if strings.Contains(path, module) || strings.HasSuffix(path, module) {
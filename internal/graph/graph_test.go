@@ -1,6 +1,7 @@
 package graph
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
@@ -39,6 +40,26 @@ func TestGraphNoDuplicateEdges(t *testing.T) {
 	}
 }
 
+func TestGraphWeightedEdges(t *testing.T) {
+	g := NewGraph(CallGraph)
+	g.AddWeightedEdge("a", "b", 2)
+	g.AddWeightedEdge("a", "b", 3) // repeat increments
+	g.AddEdge("a", "c")
+
+	if got := g.EdgeCount(); got != 2 {
+		t.Errorf("EdgeCount() = %d, want 2", got)
+	}
+	if got := g.EdgeWeight("a", "b"); got != 5 {
+		t.Errorf("EdgeWeight(a, b) = %d, want 5", got)
+	}
+	if got := g.EdgeWeight("a", "c"); got != 1 {
+		t.Errorf("EdgeWeight(a, c) = %d, want 1 for unweighted edge", got)
+	}
+	if got := g.EdgeWeight("b", "a"); got != 0 {
+		t.Errorf("EdgeWeight(b, a) = %d, want 0 for missing edge", got)
+	}
+}
+
 func TestGraphNoSelfLoop(t *testing.T) {
 	g := NewGraph(InheritanceGraph)
 	g.AddEdge("a", "a")
@@ -139,6 +160,73 @@ func TestGetRelatedElementsReverse(t *testing.T) {
 	}
 }
 
+func TestShortestPath(t *testing.T) {
+	cg := NewCodeGraphs()
+	cg.Call.AddEdge("handler", "service")
+	cg.Call.AddEdge("service", "repo")
+	cg.Call.AddEdge("handler", "logger")
+	cg.Dependency.AddEdge("repo", "db")
+	cg.Call.AddEdge("handler", "shortcut")
+	cg.Dependency.AddEdge("shortcut", "db")
+
+	path := cg.ShortestPath("handler", "db", nil)
+	want := []string{"handler", "shortcut", "db"}
+	if len(path) != len(want) {
+		t.Fatalf("ShortestPath = %v, want %v", path, want)
+	}
+	for i := range want {
+		if path[i] != want[i] {
+			t.Fatalf("ShortestPath = %v, want %v", path, want)
+		}
+	}
+
+	// Restricting to the call graph leaves no route into the dependency edges
+	if path := cg.ShortestPath("handler", "db", []GraphType{CallGraph}); path != nil {
+		t.Errorf("expected no path over call graph only, got %v", path)
+	}
+	if path := cg.ShortestPath("handler", "repo", []GraphType{CallGraph}); len(path) != 3 {
+		t.Errorf("expected handler → service → repo, got %v", path)
+	}
+	// Edges are followed forward only
+	if path := cg.ShortestPath("db", "handler", nil); path != nil {
+		t.Errorf("expected nil for reverse direction, got %v", path)
+	}
+	if path := cg.ShortestPath("handler", "handler", nil); len(path) != 1 {
+		t.Errorf("expected single-element path to self, got %v", path)
+	}
+}
+
+func TestImportanceScores(t *testing.T) {
+	cg := NewCodeGraphs()
+	// Everything funnels into "core"
+	cg.Call.AddEdge("a", "core")
+	cg.Call.AddEdge("b", "core")
+	cg.Call.AddEdge("c", "core")
+	cg.Call.AddEdge("c", "a")
+	cg.Call.AddEdge("core", "util")
+
+	scores := cg.ImportanceScores(CallGraph)
+	if len(scores) != 5 {
+		t.Fatalf("expected 5 scored nodes, got %d", len(scores))
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if scores["core"] <= scores[id] {
+			t.Errorf("core (%f) should outrank %s (%f)", scores["core"], id, scores[id])
+		}
+	}
+	total := 0.0
+	for _, s := range scores {
+		total += s
+	}
+	if total < 0.99 || total > 1.01 {
+		t.Errorf("scores should sum to 1, got %f", total)
+	}
+
+	if got := cg.ImportanceScores(DependencyGraph); len(got) != 0 {
+		t.Errorf("expected empty scores for empty graph, got %v", got)
+	}
+}
+
 func TestGenerateElementID(t *testing.T) {
 	id1 := GenerateElementID("myrepo", "file", "internal/parser/parser.go")
 	id2 := GenerateElementID("myrepo", "file", "internal/parser/parser.go")
@@ -172,6 +260,36 @@ func TestBuildInheritanceGraph(t *testing.T) {
 	}
 }
 
+func TestReferrers(t *testing.T) {
+	cg := NewCodeGraphs()
+	elements := []types.CodeElement{
+		{ID: "fn_main", Type: "function", Name: "main", Metadata: map[string]any{
+			"calls": []string{"helper", "fmt.Println", "helper"},
+		}},
+		{ID: "fn_run", Type: "function", Name: "run", Metadata: map[string]any{
+			"calls": []any{"helper"},
+		}},
+		{ID: "fn_helper", Type: "function", Name: "helper"},
+		{ID: "file_app", Type: "file", Name: "app.py", Metadata: map[string]any{
+			"imports": []types.ImportInfo{{Module: "utils", Names: []string{"helper"}}, {Module: "os", Names: []string{"*"}}},
+		}},
+	}
+	cg.BuildGraphs(elements)
+
+	if got := cg.Referrers("helper"); fmt.Sprint(got) != "[fn_main fn_run file_app]" {
+		t.Errorf("Referrers(helper) = %v", got)
+	}
+	if got := cg.Referrers("Println"); fmt.Sprint(got) != "[fn_main]" {
+		t.Errorf("Referrers(Println) = %v, want the qualified call indexed by its last segment", got)
+	}
+	if got := cg.Referrers("utils"); fmt.Sprint(got) != "[file_app]" {
+		t.Errorf("Referrers(utils) = %v", got)
+	}
+	if got := cg.Referrers("*"); got != nil {
+		t.Errorf("Referrers(*) = %v, want nil", got)
+	}
+}
+
 func TestBuildCallGraph(t *testing.T) {
 	cg := NewCodeGraphs()
 	elements := []types.CodeElement{
@@ -17,6 +17,11 @@ type BM25 struct {
 	avgDL      float64
 	averageIdf float64
 	totalDocs  int
+
+	// fieldBoost weighs matches in a document's fields (see AddDocumentFields)
+	// against matches in its text; avgFieldDL is the average field length.
+	fieldBoost float64
+	avgFieldDL float64
 }
 
 type bm25Doc struct {
@@ -24,15 +29,31 @@ type bm25Doc struct {
 	Tokens []string
 	Length int
 	TF     map[string]float64
+
+	FieldLength int
+	FieldTF     map[string]float64
 }
 
-// NewBM25 creates a new BM25 index with standard parameters.
+// DefaultFieldBoost is the field boost of a new BM25 index: fields weigh no
+// more than the rest of the text.
+const DefaultFieldBoost = 1.0
+
+// Default BM25 parameters. K1 (> 0, typically 1.2-2.0) controls how quickly
+// repeated terms stop adding to a score; B (> 0, at most 1) how strongly long
+// elements are penalized.
+const (
+	DefaultBM25K1 = 1.5
+	DefaultBM25B  = 0.75
+)
+
+// NewBM25 creates a new BM25 index. A zero k1 or b selects DefaultBM25K1 or
+// DefaultBM25B.
 func NewBM25(k1, b float64) *BM25 {
 	if k1 == 0 {
-		k1 = 1.5
+		k1 = DefaultBM25K1
 	}
 	if b == 0 {
-		b = 0.75
+		b = DefaultBM25B
 	}
 	return &BM25{
 		k1:      k1,
@@ -40,28 +61,54 @@ func NewBM25(k1, b float64) *BM25 {
 		epsilon: 0.25, // Python's BM25Okapi default epsilon
 		df:      make(map[string]int),
 		idf:     make(map[string]float64),
+
+		fieldBoost: DefaultFieldBoost,
 	}
 }
 
+// SetFieldBoost sets how much a query term matching a document's fields
+// (typically its name and signature, which are also part of its text) weighs:
+// a field match scores boost times a text match. 1 or less adds nothing, which
+// ranks exactly like Python's rank_bm25.
+func (bm *BM25) SetFieldBoost(boost float64) {
+	bm.fieldBoost = boost
+}
+
 // AddDocument adds a document to the BM25 index.
 func (bm *BM25) AddDocument(id, text string) {
+	bm.AddDocumentFields(id, text, "")
+}
+
+// AddDocumentFields adds a document whose fields, such as an element's name
+// and signature, are scored separately from its text and weighted by the
+// field boost, so that "handleAuth" prefers the element named handleAuth over
+// ones that merely call it. Fields are usually also part of text.
+func (bm *BM25) AddDocumentFields(id, text, fields string) {
 	tokens := tokenize(text)
 	tf := make(map[string]float64)
 	for _, t := range tokens {
 		tf[t]++
 	}
+	fieldTokens := tokenize(fields)
+	fieldTF := make(map[string]float64)
+	for _, t := range fieldTokens {
+		fieldTF[t]++
+	}
 
 	doc := bm25Doc{
 		ID:     id,
 		Tokens: tokens,
 		Length: len(tokens),
 		TF:     tf,
+
+		FieldLength: len(fieldTokens),
+		FieldTF:     fieldTF,
 	}
 	bm.docs = append(bm.docs, doc)
 
 	// Update DF
 	seen := make(map[string]bool)
-	for _, t := range tokens {
+	for _, t := range append(tokens, fieldTokens...) {
 		if !seen[t] {
 			seen[t] = true
 			bm.df[t]++
@@ -69,12 +116,14 @@ func (bm *BM25) AddDocument(id, text string) {
 	}
 
 	bm.totalDocs++
-	// Recalculate avgDL
-	totalLen := 0
+	// Recalculate avgDL and the average field length
+	totalLen, totalFieldLen := 0, 0
 	for _, d := range bm.docs {
 		totalLen += d.Length
+		totalFieldLen += d.FieldLength
 	}
 	bm.avgDL = float64(totalLen) / float64(bm.totalDocs)
+	bm.avgFieldDL = float64(totalFieldLen) / float64(bm.totalDocs)
 
 	bm.calcIDF()
 }
@@ -129,16 +178,17 @@ func (bm *BM25) Search(query string, topK int) []BM25Result {
 		var score float64
 
 		for _, token := range queryTokens {
-			termFreq := doc.TF[token]
-			if termFreq == 0 {
-				continue
-			}
-
 			idf := bm.idf[token]
-			// Python's TF normalization implementation
-			tfNorm := (termFreq * (bm.k1 + 1)) / (termFreq + bm.k1*(1-bm.b+bm.b*float64(doc.Length)/bm.avgDL))
-
-			score += idf * tfNorm
+			if termFreq := doc.TF[token]; termFreq > 0 {
+				// Python's TF normalization implementation
+				tfNorm := (termFreq * (bm.k1 + 1)) / (termFreq + bm.k1*(1-bm.b+bm.b*float64(doc.Length)/bm.avgDL))
+				score += idf * tfNorm
+			}
+			if fieldFreq := doc.FieldTF[token]; fieldFreq > 0 && bm.fieldBoost > 1 {
+				// The text match already counted the field once
+				fieldNorm := (fieldFreq * (bm.k1 + 1)) / (fieldFreq + bm.k1*(1-bm.b+bm.b*float64(doc.FieldLength)/bm.avgFieldDL))
+				score += (bm.fieldBoost - 1) * idf * fieldNorm
+			}
 		}
 
 		if score > 0 {
@@ -175,38 +225,76 @@ func (bm *BM25) DocCount() int {
 	return bm.totalDocs
 }
 
-// tokenize splits text into lowercase tokens, handling camelCase and snake_case.
+// tokenize splits text into lowercase tokens. Identifiers are also split
+// into their camelCase and snake_case words, and a multi-word identifier is
+// kept whole as well, so "handleAuth" and "handle_auth" both yield handle,
+// auth and handleauth. Tokens of one character are dropped.
 func tokenize(text string) []string {
-	text = strings.ToLower(text)
-	// Split on non-alphanumeric characters
-	var raw []string
-	var current strings.Builder
-	for _, r := range text {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
-			current.WriteRune(r)
-		} else if r == '_' {
-			// underscore is a separator
-			if current.Len() > 0 {
-				raw = append(raw, current.String())
-				current.Reset()
-			}
-		} else {
-			if current.Len() > 0 {
-				raw = append(raw, current.String())
-				current.Reset()
-			}
-		}
-	}
-	if current.Len() > 0 {
-		raw = append(raw, current.String())
-	}
-
-	// Filter short tokens
 	var tokens []string
-	for _, tok := range raw {
+	add := func(tok string) {
 		if len(tok) > 1 {
 			tokens = append(tokens, tok)
 		}
 	}
+	for _, ident := range identifierRuns(text) {
+		words := splitIdentifier(ident)
+		for _, w := range words {
+			add(w)
+		}
+		if len(words) > 1 {
+			add(strings.Join(words, ""))
+		}
+	}
 	return tokens
 }
+
+// identifierRuns returns the maximal runs of ASCII letters, digits and
+// underscores in text.
+func identifierRuns(text string) []string {
+	var runs []string
+	start := -1
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && isIdentByte(text[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			runs = append(runs, text[start:i])
+			start = -1
+		}
+	}
+	return runs
+}
+
+func isIdentByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// splitIdentifier splits an identifier into lowercase words at underscores
+// and case changes: "parseHTTPRequest" → parse, http, request.
+func splitIdentifier(ident string) []string {
+	var words []string
+	for _, part := range strings.Split(ident, "_") {
+		start := 0
+		for i := 1; i < len(part); i++ {
+			prev, cur := part[i-1], part[i]
+			lowerToUpper := isLowerOrDigit(prev) && isUpper(cur)
+			// The last capital of an acronym starts the next word: "HTTPRequest"
+			acronymEnd := isUpper(prev) && isUpper(cur) && i+1 < len(part) && isLower(part[i+1])
+			if lowerToUpper || acronymEnd {
+				words = append(words, strings.ToLower(part[start:i]))
+				start = i
+			}
+		}
+		if start < len(part) {
+			words = append(words, strings.ToLower(part[start:]))
+		}
+	}
+	return words
+}
+
+func isUpper(c byte) bool        { return c >= 'A' && c <= 'Z' }
+func isLower(c byte) bool        { return c >= 'a' && c <= 'z' }
+func isLowerOrDigit(c byte) bool { return isLower(c) || c >= '0' && c <= '9' }
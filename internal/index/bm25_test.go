@@ -1,6 +1,7 @@
 package index
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -56,6 +57,13 @@ func TestBM25DefaultParams(t *testing.T) {
 	}
 }
 
+func TestBM25CustomParams(t *testing.T) {
+	bm := NewBM25(1.2, 0.3)
+	if bm.k1 != 1.2 || bm.b != 0.3 {
+		t.Errorf("k1, b = %f, %f; want 1.2, 0.3", bm.k1, bm.b)
+	}
+}
+
 func TestBM25SearchEmptyIndex(t *testing.T) {
 	bm := NewBM25(1.5, 0.75)
 	results := bm.Search("hello", 5)
@@ -91,17 +99,42 @@ func TestBM25SearchSingleDoc(t *testing.T) {
 }
 
 func TestTokenize(t *testing.T) {
-	tokens := tokenize("func ParseFile(path string) *Result")
-	expected := []string{"func", "parsefile", "path", "string", "result"}
-	if len(tokens) != len(expected) {
-		t.Errorf("tokenize: got %d tokens %v, want %d: %v", len(tokens), tokens, len(expected), expected)
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"func ParseFile(path string) *Result", []string{"func", "parse", "file", "parsefile", "path", "string", "result"}},
+		// Identifiers are split on case changes and underscores and also kept whole
+		{"handleAuth", []string{"handle", "auth", "handleauth"}},
+		{"handle_auth", []string{"handle", "auth", "handleauth"}},
+		{"build_graph_call", []string{"build", "graph", "call", "buildgraphcall"}},
+		{"parseHTTPRequest", []string{"parse", "http", "request", "parsehttprequest"}},
+		{"MAX_RETRIES sha256Sum", []string{"max", "retries", "maxretries", "sha256", "sum", "sha256sum"}},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.input); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("tokenize(%q) = %v, want %v", tt.input, got, tt.want)
+		}
 	}
+}
 
-	// Test underscore splitting
-	tokens2 := tokenize("build_graph_call")
-	expected2 := []string{"build", "graph", "call"}
-	if len(tokens2) != len(expected2) {
-		t.Errorf("tokenize snake_case: got %v, want %v", tokens2, expected2)
+func TestBM25MatchesSplitIdentifiers(t *testing.T) {
+	bm := NewBM25(1.5, 0.75)
+	bm.AddDocument("camel", "function handleAuth(req)")
+	bm.AddDocument("snake", "def handle_auth(req)")
+	bm.AddDocument("other", "function renderView(view)")
+	bm.AddDocument("other2", "def open_db(url)")
+	bm.AddDocument("other3", "func Close()")
+
+	for _, query := range []string{"handle auth", "handleAuth", "handle_auth"} {
+		results := bm.Search(query, 5)
+		ids := make(map[string]bool)
+		for _, r := range results {
+			ids[r.ID] = true
+		}
+		if len(results) != 2 || !ids["camel"] || !ids["snake"] {
+			t.Errorf("Search(%q) = %v, want the camelCase and snake_case elements", query, results)
+		}
 	}
 }
 
@@ -125,3 +158,28 @@ func TestTokenizeSpecialChars(t *testing.T) {
 		t.Errorf("expected tokens from special chars, got %v", tokens)
 	}
 }
+
+func TestBM25FieldBoostPrefersNamedElement(t *testing.T) {
+	build := func(boost float64) *BM25 {
+		bm := NewBM25(1.5, 0.75)
+		bm.SetFieldBoost(boost)
+		bm.AddDocumentFields("named", "handleAuth function handleAuth(req) validate(req.token) refresh(req.session)", "handleAuth handleAuth(req)")
+		bm.AddDocumentFields("caller", "login function login(req) handleAuth(req) handleAuth(req) handleAuth(req)", "login login(req)")
+		bm.AddDocumentFields("f1", "logout function logout()", "logout logout()")
+		bm.AddDocumentFields("f2", "render function render(view)", "render render(view)")
+		bm.AddDocumentFields("f3", "connect function connect(url)", "connect connect(url)")
+		return bm
+	}
+
+	// The caller mentions the term more often, so plain BM25 prefers it
+	if results := build(DefaultFieldBoost).Search("handleAuth", 2); len(results) != 2 || results[0].ID != "caller" {
+		t.Fatalf("unboosted results = %v, want caller first", results)
+	}
+	results := build(3).Search("handleAuth", 2)
+	if len(results) != 2 || results[0].ID != "named" {
+		t.Fatalf("boosted results = %v, want the element named handleAuth first", results)
+	}
+	if results[0].Score < 2*results[1].Score {
+		t.Errorf("named score %.3f should clearly beat caller score %.3f", results[0].Score, results[1].Score)
+	}
+}
@@ -0,0 +1,57 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+// Large elements are embedded as overlapping windows of their code in addition
+// to the element itself, so vector search can match content past the point
+// where the element's code is truncated.
+const (
+	chunkLines     = 80 // lines per window
+	chunkOverlap   = 10 // lines shared by consecutive windows
+	maxChunksPerEl = 16 // windows beyond this are dropped
+)
+
+// chunkCode splits code into windows of size lines, each starting overlap
+// lines before the previous one ended, and returns at most maxChunks of them
+// together with the 1-based line offset of each window.
+func chunkCode(code string, size, overlap, maxChunks int) (chunks []string, offsets []int) {
+	lines := strings.Split(code, "\n")
+	step := size - overlap
+	if step < 1 {
+		step = 1
+	}
+	for start := 0; start < len(lines) && len(chunks) < maxChunks; start += step {
+		end := min(start+size, len(lines))
+		chunks = append(chunks, strings.Join(lines[start:end], "\n"))
+		offsets = append(offsets, start+1)
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks, offsets
+}
+
+// addChunks records embedding texts for the windows of an element's full code
+// when the code stored on the element was truncated.
+func (idx *Indexer) addChunks(elem *types.CodeElement, fullCode string) {
+	if elem.Code == fullCode {
+		return
+	}
+	chunks, offsets := chunkCode(fullCode, chunkLines, chunkOverlap, maxChunksPerEl)
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		start := elem.StartLine + offsets[i] - 1
+		end := start + strings.Count(chunk, "\n")
+		texts[i] = fmt.Sprintf("Type: %s\nName: %s\nFile: %s (lines %d-%d)\nCode:\n%s",
+			elem.Type, elem.Name, elem.RelativePath, start, end, chunk)
+	}
+	if idx.Chunks == nil {
+		idx.Chunks = make(map[string][]string)
+	}
+	idx.Chunks[elem.ID] = texts
+}
@@ -0,0 +1,111 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/llm"
+	"github.com/duyhunghd6/fastcode-cli/internal/loader"
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+func numberedLines(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line%d", i+1)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestChunkCode(t *testing.T) {
+	chunks, offsets := chunkCode(numberedLines(25), 10, 2, 5)
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+	if want := []int{1, 9, 17}; fmt.Sprint(offsets) != fmt.Sprint(want) {
+		t.Errorf("offsets = %v, want %v", offsets, want)
+	}
+	// Consecutive windows share the overlap lines
+	if !strings.HasPrefix(chunks[1], "line9\nline10\n") {
+		t.Errorf("second chunk does not overlap the first: %q", chunks[1])
+	}
+	if !strings.HasSuffix(chunks[2], "line25") {
+		t.Errorf("last chunk does not reach the end: %q", chunks[2])
+	}
+
+	if chunks, _ := chunkCode(numberedLines(1000), 10, 2, 4); len(chunks) != 4 {
+		t.Errorf("got %d chunks, want the cap of 4", len(chunks))
+	}
+	if chunks, _ := chunkCode("short", 10, 2, 4); len(chunks) != 1 || chunks[0] != "short" {
+		t.Errorf("short code chunks = %q", chunks)
+	}
+}
+
+func TestIndexerChunksLargeFiles(t *testing.T) {
+	idx := NewIndexer("repo")
+	small := loader.FileInfo{Path: "/repo/small.go", RelativePath: "small.go", Language: "go"}
+	large := loader.FileInfo{Path: "/repo/large.go", RelativePath: "large.go", Language: "go"}
+	content := numberedLines(800) // well past the 4000-character file code limit
+
+	idx.indexFile(small, "package small\n", &types.FileParseResult{TotalLines: 1})
+	idx.indexFile(large, content, &types.FileParseResult{TotalLines: 800})
+
+	if len(idx.Chunks) != 1 {
+		t.Fatalf("chunks recorded for %d elements, want only the large file", len(idx.Chunks))
+	}
+	var texts []string
+	for _, v := range idx.Chunks {
+		texts = v
+	}
+	if len(texts) != 12 {
+		t.Fatalf("got %d chunks, want 12 windows over 800 lines", len(texts))
+	}
+	last := texts[len(texts)-1]
+	if !strings.Contains(last, "File: large.go (lines 771-800)") || !strings.Contains(last, "line800") {
+		t.Errorf("last chunk does not cover the end of the file:\n%s", last[:80])
+	}
+}
+
+func TestHybridIndexElementsWithChunks(t *testing.T) {
+	// Embed "deep" texts along one axis and everything else along another
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		data := make([]map[string]any, len(req.Input))
+		for i, text := range req.Input {
+			vec := []float64{0, 1}
+			if strings.Contains(text, "deep") {
+				vec = []float64{1, 0}
+			}
+			data[i] = map[string]any{"index": i, "embedding": vec}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer server.Close()
+	embedder := llm.NewEmbedder(llm.NewClientWith("key", "model", server.URL), "model", 32)
+
+	hr := NewHybridRetriever(NewVectorStore(), NewBM25(1.5, 0.75))
+	elements := []types.CodeElement{
+		{ID: "big", Name: "big.go", Type: "file", Code: "package big"},
+		{ID: "other", Name: "other.go", Type: "file", Code: "package other"},
+	}
+	chunks := map[string][]string{"big": {"head of big.go", "deep content of big.go"}}
+	if err := hr.IndexElementsWithChunks(context.Background(), elements, chunks, embedder); err != nil {
+		t.Fatalf("IndexElementsWithChunks: %v", err)
+	}
+
+	if n := len(hr.vectorStore.Chunks("big")); n != 2 {
+		t.Fatalf("stored %d chunk vectors, want 2", n)
+	}
+	results := hr.vectorStore.Search([]float32{1, 0}, 2)
+	if len(results) != 1 || results[0].ID != "big" || results[0].Score < 0.99 {
+		t.Errorf("results = %+v, want big matched on its deep chunk", results)
+	}
+}
@@ -0,0 +1,67 @@
+package index
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+// dedupTypes are the element types collapsed by Indexer.Dedup; file-level
+// and documentation elements are always kept.
+var dedupTypes = map[string]bool{"function": true, "class": true}
+
+// dedupElements collapses function and class elements whose code is the same
+// once whitespace is normalized into the first of them, which records where
+// the copies were in Metadata["duplicate_locations"]. Elements whose code was
+// truncated (those with Chunks) are never collapsed, since their full code
+// was not compared. It returns the number of elements removed.
+func (idx *Indexer) dedupElements() int {
+	first := make(map[string]int) // code hash -> index in kept
+	kept := idx.Elements[:0]
+	removed := 0
+	for _, elem := range idx.Elements {
+		key, ok := idx.dedupKey(elem)
+		if !ok {
+			kept = append(kept, elem)
+			continue
+		}
+		i, dup := first[key]
+		if !dup {
+			first[key] = len(kept)
+			kept = append(kept, elem)
+			continue
+		}
+		rep := &kept[i]
+		if rep.Metadata == nil {
+			rep.Metadata = make(map[string]any)
+		}
+		locations, _ := rep.Metadata["duplicate_locations"].([]string)
+		rep.Metadata["duplicate_locations"] = append(locations, elementLocation(elem))
+		removed++
+	}
+	idx.Elements = kept
+	return removed
+}
+
+// dedupKey hashes an element's type and whitespace-normalized code. It
+// reports false for elements that are never collapsed.
+func (idx *Indexer) dedupKey(elem types.CodeElement) (string, bool) {
+	if !dedupTypes[elem.Type] || strings.TrimSpace(elem.Code) == "" {
+		return "", false
+	}
+	if _, truncated := idx.Chunks[elem.ID]; truncated {
+		return "", false
+	}
+	h := sha256.New()
+	h.Write([]byte(elem.Type))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(strings.Fields(elem.Code), " ")))
+	return fmt.Sprintf("%x", h.Sum(nil)), true
+}
+
+// elementLocation formats where an element is, e.g. "pkg/a.go:10-24".
+func elementLocation(elem types.CodeElement) string {
+	return fmt.Sprintf("%s:%d-%d", elem.RelativePath, elem.StartLine, elem.EndLine)
+}
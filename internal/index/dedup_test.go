@@ -0,0 +1,41 @@
+package index
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+func TestDedupElements(t *testing.T) {
+	helper := func(id, path string, code string) types.CodeElement {
+		return types.CodeElement{ID: id, Type: "function", Name: "clamp", RelativePath: path,
+			StartLine: 3, EndLine: 5, Code: code, Metadata: map[string]any{}}
+	}
+	idx := NewIndexer("repo")
+	idx.Elements = []types.CodeElement{
+		{ID: "fa", Type: "file", RelativePath: "a.go", Code: "package gen"},
+		helper("a", "a.go", "func clamp(x int) int {\n\treturn x\n}"),
+		{ID: "fb", Type: "file", RelativePath: "b.go", Code: "package gen"},
+		helper("b", "b.go", "func clamp(x int) int {\n    return x\n}"),
+		helper("c", "c.go", "func clamp(x int) int {\n\treturn -x\n}"),
+		helper("d", "d.go", "func clamp(x int) int {\n\treturn x\n}"),
+	}
+	idx.Chunks = map[string][]string{"d": {"window"}}
+
+	if removed := idx.dedupElements(); removed != 1 {
+		t.Errorf("removed %d elements, want 1", removed)
+	}
+	var ids []string
+	for _, e := range idx.Elements {
+		ids = append(ids, e.ID)
+	}
+	// File elements stay, a different body stays, and a truncated element
+	// was never compared
+	if fmt.Sprint(ids) != "[fa a fb c d]" {
+		t.Errorf("elements = %v", ids)
+	}
+	if got := idx.Elements[1].Metadata["duplicate_locations"]; fmt.Sprint(got) != "[b.go:3-5]" {
+		t.Errorf("duplicate_locations = %v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package index
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -18,6 +19,11 @@ type HybridRetriever struct {
 	// Weights for combining scores
 	SemanticWeight float64
 	KeywordWeight  float64
+
+	// EmbedMaxLines, if positive, leaves elements spanning more lines
+	// unembedded (see EmbedSkipped): they are indexed for keyword search
+	// only, to save embedding cost on large files and classes.
+	EmbedMaxLines int
 }
 
 // HybridResult holds a combined search result.
@@ -58,6 +64,9 @@ func buildBM25Text(elem *types.CodeElement) string {
 	if elem.Signature != "" {
 		parts = append(parts, elem.Signature)
 	}
+	if decorators := elementDecorators(elem); len(decorators) > 0 {
+		parts = append(parts, strings.Join(decorators, " "))
+	}
 	if elem.Summary != "" {
 		parts = append(parts, elem.Summary)
 	}
@@ -71,7 +80,23 @@ func buildBM25Text(elem *types.CodeElement) string {
 	return strings.Join(parts, " ")
 }
 
-func buildEmbeddingText(elem *types.CodeElement) string {
+// elementDecorators returns the decorators or annotations the parser recorded
+// for a class or function element, which often name its role (e.g. Injectable).
+func elementDecorators(elem *types.CodeElement) []string {
+	decorators, _ := elem.Metadata["decorators"].([]string)
+	return decorators
+}
+
+// buildBM25Fields returns the boosted BM25 fields of an element: its name
+// and signature.
+func buildBM25Fields(elem *types.CodeElement) string {
+	return elem.Name + " " + elem.Signature
+}
+
+// buildEmbeddingText describes an element for embedding in about maxTokens
+// tokens at most: its type, name, signature, documentation and summary,
+// then as much of its code as fits, cut at a line or word boundary.
+func buildEmbeddingText(elem *types.CodeElement, maxTokens int) string {
 	var parts []string
 	if elem.Type != "" {
 		parts = append(parts, fmt.Sprintf("Type: %s", elem.Type))
@@ -85,22 +110,41 @@ func buildEmbeddingText(elem *types.CodeElement) string {
 	if elem.Docstring != "" {
 		parts = append(parts, fmt.Sprintf("Documentation: %s", elem.Docstring))
 	}
+	if decorators := elementDecorators(elem); len(decorators) > 0 {
+		parts = append(parts, fmt.Sprintf("Decorators: %s", strings.Join(decorators, " ")))
+	}
 	if elem.Summary != "" {
 		parts = append(parts, elem.Summary)
 	}
 	if elem.Code != "" {
-		code := elem.Code
-		if len(code) > 10000 {
-			code = code[:10000] + "..."
-		}
-		parts = append(parts, fmt.Sprintf("Code:\n%s", code))
+		parts = append(parts, fmt.Sprintf("Code:\n%s", elem.Code))
 	}
-	return strings.Join(parts, "\n")
+	return llm.TruncateToTokens(strings.Join(parts, "\n"), maxTokens)
+}
+
+// EmbedSkipped reports whether an element spans more than maxLines lines, if
+// maxLines is positive, and so is indexed without a vector.
+func EmbedSkipped(elem *types.CodeElement, maxLines int) bool {
+	return maxLines > 0 && elem.EndLine-elem.StartLine+1 > maxLines
 }
 
 // IndexElements indexes code elements into both BM25 and vector stores.
 // embedder may be nil if embeddings are not available.
 func (hr *HybridRetriever) IndexElements(elements []types.CodeElement, embedder *llm.Embedder) error {
+	return hr.IndexElementsContext(context.Background(), elements, embedder)
+}
+
+// IndexElementsContext is like IndexElements but stops embedding between
+// batches once ctx is cancelled. BM25 indexing always completes.
+func (hr *HybridRetriever) IndexElementsContext(ctx context.Context, elements []types.CodeElement, embedder *llm.Embedder) error {
+	return hr.IndexElementsWithChunks(ctx, elements, nil, embedder)
+}
+
+// IndexElementsWithChunks is like IndexElementsContext but also embeds chunks,
+// element ID → texts of windows over the element's full code (see
+// Indexer.Chunks), in the same pass. Chunk vectors are stored under their
+// element's ID so vector search matches an element on its best chunk.
+func (hr *HybridRetriever) IndexElementsWithChunks(ctx context.Context, elements []types.CodeElement, chunks map[string][]string, embedder *llm.Embedder) error {
 	// Store element references
 	for i := range elements {
 		elem := &elements[i]
@@ -108,27 +152,54 @@ func (hr *HybridRetriever) IndexElements(elements []types.CodeElement, embedder
 
 		// Add to BM25
 		searchText := buildBM25Text(elem)
-		hr.bm25.AddDocument(elem.ID, searchText)
+		hr.bm25.AddDocumentFields(elem.ID, searchText, buildBM25Fields(elem))
 	}
 
 	// Generate and store embeddings if embedder is available
 	if embedder != nil {
-		texts := make([]string, len(elements))
+		maxTokens := embedder.TokenLimit()
+		var embedded []*types.CodeElement
 		for i := range elements {
-			elem := &elements[i]
-			texts[i] = buildEmbeddingText(elem)
+			if !EmbedSkipped(&elements[i], hr.EmbedMaxLines) {
+				embedded = append(embedded, &elements[i])
+			}
+		}
+		texts := make([]string, len(embedded))
+		for i, elem := range embedded {
+			texts[i] = buildEmbeddingText(elem, maxTokens)
+		}
+		// Chunk texts follow the element texts, in element order
+		var chunkIDs []string
+		for _, elem := range embedded {
+			for _, text := range chunks[elem.ID] {
+				texts = append(texts, llm.TruncateToTokens(text, maxTokens))
+				chunkIDs = append(chunkIDs, elem.ID)
+			}
 		}
 
-		embeddings, err := embedder.EmbedTexts(texts)
+		embeddings, err := embedder.EmbedTextsContext(ctx, texts)
 		if err != nil {
 			// Non-fatal: continue without vector search
 			return err
 		}
 
+		skipped := 0
 		for i, emb := range embeddings {
-			if emb != nil {
-				hr.vectorStore.Add(elements[i].ID, emb)
+			if emb == nil {
+				continue
+			}
+			var err error
+			if i < len(embedded) {
+				err = hr.vectorStore.Add(embedded[i].ID, emb)
+			} else {
+				err = hr.vectorStore.AddChunk(chunkIDs[i-len(embedded)], emb)
 			}
+			if err != nil {
+				skipped++
+			}
+		}
+		if skipped > 0 {
+			logger.Warn("skipped embeddings of an inconsistent dimension", "count", skipped, "dimension", hr.vectorStore.Dimension())
 		}
 	}
 
@@ -137,10 +208,67 @@ func (hr *HybridRetriever) IndexElements(elements []types.CodeElement, embedder
 
 // Search performs hybrid search combining semantic and keyword results.
 func (hr *HybridRetriever) Search(query string, queryVec []float32, topK int) []HybridResult {
+	return hr.SearchFiltered(query, queryVec, topK, nil)
+}
+
+// SearchFiltered is like Search but only returns elements for which keep
+// returns true. A nil keep accepts every element.
+func (hr *HybridRetriever) SearchFiltered(query string, queryVec []float32, topK int, keep func(*types.CodeElement) bool) []HybridResult {
+	// Filtering may discard most top candidates, so consider every element
+	ranked := hr.rank(query, queryVec, topK, keep != nil, keep)
+	if topK > len(ranked) {
+		topK = len(ranked)
+	}
+
+	results := make([]HybridResult, topK)
+	for i := 0; i < topK; i++ {
+		elem := hr.elements[ranked[i].id]
+		source := "hybrid"
+		results[i] = HybridResult{
+			Element: elem,
+			Score:   ranked[i].score,
+			Source:  source,
+		}
+		logger.Debug("hybrid result", "rank", i, "id", elem.ID, "type", elem.Type, "score", ranked[i].score)
+	}
+	return results
+}
+
+// SearchPage returns limit results starting at offset of the full ranking of
+// elements matching query (and passing keep, if non-nil), along with the
+// number of ranked elements. Every element is scored, so pages of the same
+// query never overlap or skip results; equal scores are ordered by element ID.
+func (hr *HybridRetriever) SearchPage(query string, queryVec []float32, offset, limit int, keep func(*types.CodeElement) bool) ([]HybridResult, int) {
+	ranked := hr.rank(query, queryVec, 0, true, keep)
+	offset = min(max(offset, 0), len(ranked))
+	end := min(offset+max(limit, 0), len(ranked))
+
+	results := make([]HybridResult, 0, end-offset)
+	for _, r := range ranked[offset:end] {
+		results = append(results, HybridResult{Element: hr.elements[r.id], Score: r.score, Source: "hybrid"})
+	}
+	return results, len(ranked)
+}
+
+// rankedID is an element ID with its combined search score.
+type rankedID struct {
+	id    string
+	score float64
+}
+
+// rank scores elements against query by weighted BM25 and vector similarity
+// and returns them best first, equal scores ordered by ID. Unless all is set
+// only the top keyword and vector candidates for topK results are scored.
+func (hr *HybridRetriever) rank(query string, queryVec []float32, topK int, all bool, keep func(*types.CodeElement) bool) []rankedID {
 	scores := make(map[string]float64)
 
+	bm25Limit := 50
+	if all {
+		bm25Limit = len(hr.elements)
+	}
+
 	// BM25 keyword search
-	bm25Results := hr.bm25.Search(query, 50)
+	bm25Results := hr.bm25.Search(query, bm25Limit)
 	maxBM25 := 0.0
 	for _, r := range bm25Results {
 		if r.Score > maxBM25 {
@@ -161,6 +289,9 @@ func (hr *HybridRetriever) Search(query string, queryVec []float32, topK int) []
 		if topK*2 > 20 {
 			vecLimit = topK * 2
 		}
+		if all {
+			vecLimit = hr.vectorStore.Count()
+		}
 		vecResults := hr.vectorStore.Search(queryVec, vecLimit)
 		for _, r := range vecResults {
 			scores[r.ID] += r.Score * hr.SemanticWeight
@@ -170,6 +301,10 @@ func (hr *HybridRetriever) Search(query string, queryVec []float32, topK int) []
 	// Apply _rerank type weights
 	for id, s := range scores {
 		elem, ok := hr.elements[id]
+		if keep != nil && (!ok || !keep(elem)) {
+			delete(scores, id)
+			continue
+		}
 		if ok {
 			weight := 1.0
 			switch elem.Type {
@@ -186,38 +321,26 @@ func (hr *HybridRetriever) Search(query string, queryVec []float32, topK int) []
 		}
 	}
 
-	// Sort by combined score
-	type scored struct {
-		id    string
-		score float64
-	}
-	var sorted_ []scored
+	// Sort by combined score; IDs break ties so pages are stable
+	ranked := make([]rankedID, 0, len(scores))
 	for id, s := range scores {
-		sorted_ = append(sorted_, scored{id: id, score: s})
+		ranked = append(ranked, rankedID{id: id, score: s})
 	}
-	sort.Slice(sorted_, func(i, j int) bool {
-		return sorted_[i].score > sorted_[j].score
-	})
-
-	if topK > len(sorted_) {
-		topK = len(sorted_)
-	}
-
-	results := make([]HybridResult, topK)
-	for i := 0; i < topK; i++ {
-		elem := hr.elements[sorted_[i].id]
-		source := "hybrid"
-		results[i] = HybridResult{
-			Element: elem,
-			Score:   sorted_[i].score,
-			Source:  source,
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
 		}
-		fmt.Printf("DEBUG HYBRID TOP %d: ID=%s Type=%s Score=%f\n", i, elem.ID, elem.Type, sorted_[i].score)
-	}
-	return results
+		return ranked[i].id < ranked[j].id
+	})
+	return ranked
 }
 
 // ElementCount returns the total number of indexed elements.
 func (hr *HybridRetriever) ElementCount() int {
 	return len(hr.elements)
 }
+
+// VectorCount returns the number of elements with an embedding vector.
+func (hr *HybridRetriever) VectorCount() int {
+	return hr.vectorStore.Count()
+}
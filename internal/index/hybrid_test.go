@@ -1,9 +1,12 @@
 package index
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/llm"
@@ -35,6 +38,91 @@ func TestHybridRetrieverBM25Only(t *testing.T) {
 	}
 }
 
+func TestHybridSearchDecorators(t *testing.T) {
+	hr := NewHybridRetriever(NewVectorStore(), NewBM25(1.5, 0.75))
+	elements := []types.CodeElement{
+		{ID: "svc", Name: "UserStore", Type: "class", Code: "class UserStore {}",
+			Metadata: map[string]any{"decorators": []string{"Injectable"}}},
+		{ID: "cmp", Name: "UserView", Type: "class", Code: "class UserView {}",
+			Metadata: map[string]any{"decorators": []string{"Component"}}},
+		{ID: "db", Name: "openDB", Type: "function", Code: "function openDB() {}"},
+		{ID: "log", Name: "logLine", Type: "function", Code: "function logLine() {}"},
+	}
+	if err := hr.IndexElements(elements, nil); err != nil {
+		t.Fatalf("IndexElements: %v", err)
+	}
+
+	results := hr.Search("injectable", nil, 5)
+	if len(results) != 1 || results[0].Element.ID != "svc" {
+		t.Errorf("results = %v, want only the injectable class", results)
+	}
+	if text := buildEmbeddingText(&elements[1], llm.DefaultMaxTokens); !strings.Contains(text, "Decorators: Component") {
+		t.Errorf("embedding text missing decorators:\n%s", text)
+	}
+}
+
+func TestHybridSearchFiltered(t *testing.T) {
+	hr := NewHybridRetriever(NewVectorStore(), NewBM25(1.5, 0.75))
+	var elements []types.CodeElement
+	// Strong Python matches crowd out the single Go match without a filter;
+	// unrelated elements keep the query terms rare enough to score
+	for i := 0; i < 8; i++ {
+		elements = append(elements, types.CodeElement{ID: fmt.Sprintf("py%d", i), Type: "function",
+			Language: "python", Name: "parse_handler", Code: "def parse_handler(): parse handler parse"})
+	}
+	for i := 0; i < 20; i++ {
+		elements = append(elements, types.CodeElement{ID: fmt.Sprintf("misc%d", i), Type: "function",
+			Language: "python", Name: fmt.Sprintf("close_conn_%d", i), Code: "def close_conn(): pass"})
+	}
+	elements = append(elements, types.CodeElement{ID: "go1", Type: "function", Language: "go",
+		Name: "ParseHandler", Code: "func ParseHandler() { /* parse */ }"})
+	if err := hr.IndexElements(elements, nil); err != nil {
+		t.Fatalf("IndexElements: %v", err)
+	}
+
+	goOnly := func(e *types.CodeElement) bool { return e.Language == "go" }
+	results := hr.SearchFiltered("parse handler", nil, 5, goOnly)
+	if len(results) != 1 || results[0].Element.ID != "go1" {
+		t.Fatalf("filtered results = %v, want only go1", results)
+	}
+	if n := len(hr.SearchFiltered("parse handler", nil, 5, nil)); n != 5 {
+		t.Errorf("unfiltered search returned %d results, want 5", n)
+	}
+}
+
+func TestHybridSearchPage(t *testing.T) {
+	hr := NewHybridRetriever(NewVectorStore(), NewBM25(1.5, 0.75))
+	var elements []types.CodeElement
+	for i := 0; i < 7; i++ {
+		// Identical code gives equal scores, so pages rely on the ID tie-break
+		elements = append(elements, types.CodeElement{ID: fmt.Sprintf("e%d", 6-i), Type: "function", Code: "func handler() { route request }"})
+	}
+	for i := 0; i < 10; i++ {
+		elements = append(elements, types.CodeElement{ID: fmt.Sprintf("other%d", i), Type: "function", Code: "func unrelated() {}"})
+	}
+	hr.IndexElements(elements, nil)
+
+	var ids []string
+	for offset := 0; ; offset += 3 {
+		page, total := hr.SearchPage("route request", nil, offset, 3, nil)
+		if total != 7 {
+			t.Fatalf("total = %d, want 7", total)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, r := range page {
+			ids = append(ids, r.Element.ID)
+		}
+	}
+	if strings.Join(ids, ",") != "e0,e1,e2,e3,e4,e5,e6" {
+		t.Errorf("paged IDs = %v, want every match once in ID order", ids)
+	}
+	if page, _ := hr.SearchPage("route request", nil, 20, 3, nil); len(page) != 0 {
+		t.Errorf("page past the end = %d results", len(page))
+	}
+}
+
 func TestHybridRetrieverElementCount(t *testing.T) {
 	vs := NewVectorStore()
 	bm := NewBM25(1.5, 0.75)
@@ -144,6 +232,47 @@ func TestHybridIndexElementsWithEmbedder(t *testing.T) {
 	}
 }
 
+func TestHybridEmbedMaxLines(t *testing.T) {
+	var inputs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		inputs = append(inputs, req.Input...)
+
+		data := make([]map[string]any, len(req.Input))
+		for i := range req.Input {
+			data[i] = map[string]any{"index": i, "embedding": []float64{0.1, 0.5, 0.3}}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer server.Close()
+	embedder := llm.NewEmbedder(llm.NewClientWith("key", "model", server.URL), "model", 32)
+
+	vs := NewVectorStore()
+	hr := NewHybridRetriever(vs, NewBM25(1.5, 0.75))
+	hr.EmbedMaxLines = 100
+	elements := []types.CodeElement{
+		{ID: "small", Name: "parseHeader", Type: "function", StartLine: 1, EndLine: 100, Code: "func parseHeader() {}"},
+		{ID: "tiny", Name: "parseBody", Type: "function", StartLine: 5, EndLine: 5, Code: "func parseBody() {}"},
+		{ID: "large", Name: "generatedTables", Type: "function", StartLine: 1, EndLine: 101, Code: "func generatedTables() {}"},
+	}
+	chunks := map[string][]string{"large": {"window one", "window two"}}
+	if err := hr.IndexElementsWithChunks(context.Background(), elements, chunks, embedder); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inputs) != 2 || vs.Get("small") == nil || vs.Get("large") != nil || vs.Chunks("large") != nil {
+		t.Errorf("embedded %d texts, small %v, large %v", len(inputs), vs.Get("small") != nil, vs.Get("large") != nil)
+	}
+	// The large element is still found by keyword
+	results := hr.Search("generatedTables", nil, 5)
+	if len(results) == 0 || results[0].Element.ID != "large" {
+		t.Errorf("Search = %v, want the unembedded element first", results)
+	}
+}
+
 func TestHybridIndexElementsEmbedderError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
@@ -3,26 +3,74 @@ package index
 import (
 	"crypto/sha256"
 	"fmt"
-	"log"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/loader"
+	"github.com/duyhunghd6/fastcode-cli/internal/logging"
 	"github.com/duyhunghd6/fastcode-cli/internal/parser"
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
+	"github.com/duyhunghd6/fastcode-cli/internal/util"
 )
 
+var logger = logging.New("indexer")
+
+// DefaultMaxElementsPerFile is the per-file element limit of a new Indexer.
+const DefaultMaxElementsPerFile = 500
+
 // Indexer indexes a code repository at multiple levels (file, class, function, documentation).
 type Indexer struct {
 	parser   *parser.Parser
 	repoName string
 	Elements []types.CodeElement
+
+	// Chunks maps the ID of each element whose code was truncated to the
+	// embedding texts of overlapping windows over its full code. Pass it to
+	// HybridRetriever.IndexElementsWithChunks so search can match deep content.
+	Chunks map[string][]string
+
+	// ParseErrorFiles lists the relative paths of files that had syntax
+	// errors and were only partially indexed.
+	ParseErrorFiles []string
+
+	// MarkdownSections indexes each "#" and "##" section of a Markdown file
+	// as a "section" element named after its heading (deeper headings stay
+	// part of their parent section). It adds elements, so it is off by
+	// default. README and docs/ sections are then indexed only this way.
+	MarkdownSections bool
+
+	// MaxElementsPerFile caps the elements indexed below the file level of
+	// one file, so generated or vendored files with thousands of functions
+	// cannot crowd out the rest of the repository. A file over the limit
+	// keeps its file element and its most complex (then largest) elements,
+	// and is listed in TruncatedFiles. Zero or less means no limit.
+	MaxElementsPerFile int
+	TruncatedFiles     []string
+
+	// Dedup collapses function and class elements with identical code
+	// (ignoring whitespace), e.g. helpers copied across generated files,
+	// into one element listing the other copies in
+	// Metadata["duplicate_locations"]. It changes element counts, so it is
+	// off by default. Duplicates counts the elements it removed.
+	Dedup      bool
+	Duplicates int
+
+	// Progress, if set, is called after each file with the number of files
+	// processed so far, the total file count and the running element count.
+	Progress func(filesDone, totalFiles, elements int)
 }
 
-// NewIndexer creates a new multi-level code indexer.
-func NewIndexer(repoName string) *Indexer {
+// NewIndexer creates a new multi-level code indexer. opts configure the
+// underlying parser.
+func NewIndexer(repoName string, opts ...parser.Option) *Indexer {
 	return &Indexer{
-		parser:   parser.New(),
+		parser:   parser.New(opts...),
 		repoName: repoName,
+
+		MaxElementsPerFile: DefaultMaxElementsPerFile,
 	}
 }
 
@@ -30,35 +78,62 @@ func NewIndexer(repoName string) *Indexer {
 func (idx *Indexer) IndexRepository(repo *loader.Repository) ([]types.CodeElement, error) {
 	idx.repoName = repo.Name
 	idx.Elements = nil
+	idx.Chunks = nil
+	idx.ParseErrorFiles = nil
+	idx.TruncatedFiles = nil
+	idx.Duplicates = 0
 
-	for _, fi := range repo.Files {
-		content, err := loader.ReadFileContent(fi.Path)
-		if err != nil {
-			log.Printf("[indexer] skip %s: %v", fi.RelativePath, err)
-			continue
+	for i, fi := range repo.Files {
+		idx.indexRepoFile(fi)
+		if idx.Progress != nil {
+			idx.Progress(i+1, len(repo.Files), len(idx.Elements))
 		}
+	}
 
-		// Skip empty files (matches Python's `if not c: continue`)
-		if content == "" {
-			continue
-		}
+	if idx.Dedup {
+		idx.Duplicates = idx.dedupElements()
+		logger.Info("collapsed duplicate elements", "removed", idx.Duplicates)
+	}
 
-		parseResult := idx.parser.ParseFile(fi.Path, content)
-		if parseResult == nil {
-			continue
-		}
+	logger.Info("indexed repository", "repo", repo.Name, "elements", len(idx.Elements), "files", len(repo.Files))
+	if len(idx.ParseErrorFiles) > 0 {
+		logger.Info("files with syntax errors were partially indexed", "files", len(idx.ParseErrorFiles))
+	}
+	if len(idx.TruncatedFiles) > 0 {
+		logger.Info("files over the element limit were truncated", "files", len(idx.TruncatedFiles), "limit", idx.MaxElementsPerFile)
+	}
+	return idx.Elements, nil
+}
 
-		idx.indexFile(fi, content, parseResult)
+// indexRepoFile reads and parses one repository file, skipping unreadable,
+// empty and unparseable files.
+func (idx *Indexer) indexRepoFile(fi loader.FileInfo) {
+	content, err := loader.ReadFileContent(fi.Path)
+	if err != nil {
+		logger.Warn("skipping unreadable file", "path", fi.RelativePath, "err", err)
+		return
 	}
 
-	log.Printf("[indexer] indexed %d elements from %s (%d files)",
-		len(idx.Elements), repo.Name, len(repo.Files))
-	return idx.Elements, nil
+	// Skip empty files (matches Python's `if not c: continue`)
+	if content == "" {
+		return
+	}
+
+	parseResult := idx.parser.ParseFile(fi.Path, content)
+	if parseResult == nil {
+		return
+	}
+	if parseResult.HasErrors {
+		idx.ParseErrorFiles = append(idx.ParseErrorFiles, fi.RelativePath)
+	}
+
+	idx.indexFile(fi, content, parseResult)
 }
 
 func (idx *Indexer) indexFile(fi loader.FileInfo, content string, pr *types.FileParseResult) {
 	// File-level element
 	idx.addFileElement(fi, content, pr)
+	fileElem := len(idx.Elements) - 1
 
 	// Track methods already emitted via cls.Methods to avoid double-counting
 	// (JS/TS parsers put class methods in both cls.Methods and pr.Functions;
@@ -92,6 +167,95 @@ func (idx *Indexer) indexFile(fi loader.FileInfo, content string, pr *types.File
 	if pr.ModuleDocstring != "" {
 		idx.addDocElement(fi, pr)
 	}
+
+	// Markdown sections become searchable elements; README and docs/
+	// sections are always kept to ground overview questions
+	if idx.MarkdownSections {
+		for _, sec := range topSections(pr.Sections, 2) {
+			idx.addSectionElement(fi, content, sec)
+		}
+	} else if IsProjectDoc(fi.RelativePath) {
+		for _, sec := range pr.Sections {
+			idx.addDocSectionElement(fi, content, sec)
+		}
+	}
+
+	idx.limitFileElements(fileElem)
+
+	isTest := util.IsTestFile(fi.RelativePath)
+	for i := fileElem; i < len(idx.Elements); i++ {
+		idx.Elements[i].Metadata["is_test"] = isTest
+	}
+}
+
+// limitFileElements drops the elements of a file beyond MaxElementsPerFile,
+// given the index of its file element, keeping the most complex and then
+// largest ones in their original order.
+func (idx *Indexer) limitFileElements(fileElem int) {
+	elems := idx.Elements[fileElem+1:]
+	if idx.MaxElementsPerFile <= 0 || len(elems) <= idx.MaxElementsPerFile {
+		return
+	}
+
+	order := make([]int, len(elems))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ea, eb := elems[order[a]], elems[order[b]]
+		if ca, cb := elementComplexity(ea), elementComplexity(eb); ca != cb {
+			return ca > cb
+		}
+		return ea.EndLine-ea.StartLine > eb.EndLine-eb.StartLine
+	})
+	keep := make(map[int]bool, idx.MaxElementsPerFile)
+	for _, i := range order[:idx.MaxElementsPerFile] {
+		keep[i] = true
+	}
+
+	kept := idx.Elements[:fileElem+1]
+	for i, elem := range elems {
+		if keep[i] {
+			kept = append(kept, elem)
+		} else {
+			delete(idx.Chunks, elem.ID)
+		}
+	}
+	idx.Elements = kept
+
+	file := &idx.Elements[fileElem]
+	dropped := len(elems) - idx.MaxElementsPerFile
+	file.Metadata["dropped_elements"] = dropped
+	idx.TruncatedFiles = append(idx.TruncatedFiles, file.RelativePath)
+	logger.Info("truncated file elements", "path", file.RelativePath, "elements", len(elems), "dropped", dropped)
+}
+
+// elementComplexity returns the cyclomatic complexity recorded for a
+// function element, or 0.
+func elementComplexity(elem types.CodeElement) int {
+	c, _ := elem.Metadata["complexity"].(int)
+	return c
+}
+
+// IsExported reports whether an element is part of the public API, as
+// recorded by the parsers in Metadata["exported"]. Only functions and classes
+// can be unexported; those indexed before visibility was recorded count as
+// exported.
+func IsExported(elem *types.CodeElement) bool {
+	if elem.Type != "function" && elem.Type != "class" {
+		return true
+	}
+	exported, ok := elem.Metadata["exported"].(bool)
+	return exported || !ok
+}
+
+// IsTest reports whether an element comes from a test file, as recorded in
+// Metadata["is_test"], classifying elements indexed before that by their path.
+func IsTest(elem *types.CodeElement) bool {
+	if isTest, ok := elem.Metadata["is_test"].(bool); ok {
+		return isTest
+	}
+	return util.IsTestFile(elem.RelativePath)
 }
 
 func (idx *Indexer) addFileElement(fi loader.FileInfo, content string, pr *types.FileParseResult) {
@@ -117,12 +281,16 @@ func (idx *Indexer) addFileElement(fi loader.FileInfo, content string, pr *types
 			"imports":       pr.Imports,
 		},
 	}
+	if pr.Package != "" {
+		elem.Metadata["package"] = pr.Package
+	}
+	idx.addChunks(&elem, content)
 	idx.Elements = append(idx.Elements, elem)
 }
 
 func (idx *Indexer) addClassElement(fi loader.FileInfo, content string, pr *types.FileParseResult, cls types.ClassInfo) {
 	code := extractCodeBlock(content, cls.StartLine, cls.EndLine)
-	sig := fmt.Sprintf("%s %s", cls.Kind, cls.Name)
+	sig := fmt.Sprintf("%s %s%s", cls.Kind, cls.Name, cls.TypeParams)
 	if len(cls.Bases) > 0 {
 		sig += " extends " + strings.Join(cls.Bases, ", ")
 	}
@@ -145,6 +313,7 @@ func (idx *Indexer) addClassElement(fi loader.FileInfo, content string, pr *type
 			"bases":       cls.Bases,
 			"num_methods": len(cls.Methods),
 			"decorators":  cls.Decorators,
+			"exported":    cls.IsExported,
 		},
 	}
 	idx.Elements = append(idx.Elements, elem)
@@ -152,13 +321,18 @@ func (idx *Indexer) addClassElement(fi loader.FileInfo, content string, pr *type
 
 func (idx *Indexer) addFunctionElement(fi loader.FileInfo, content string, pr *types.FileParseResult, fn types.FunctionInfo) {
 	code := extractCodeBlock(content, fn.StartLine, fn.EndLine)
-	sig := fn.Name + "(" + strings.Join(fn.Parameters, ", ") + ")"
-	if fn.ReturnType != "" {
+	sig := fn.Name + fn.TypeParams + "(" + strings.Join(fn.Parameters, ", ") + ")"
+	if fn.ReturnType != "" && fi.Language == "python" {
+		sig += " -> " + fn.ReturnType
+	} else if fn.ReturnType != "" {
 		sig += " " + fn.ReturnType
 	}
 	if fn.ClassName != "" {
 		sig = fn.ClassName + "." + sig
 	}
+	if fn.IsAsync {
+		sig = "async " + sig // so keyword and semantic search find async functions
+	}
 
 	elem := types.CodeElement{
 		ID:           idx.genID("function", fi.RelativePath, fn.ClassName, fn.Name),
@@ -174,14 +348,22 @@ func (idx *Indexer) addFunctionElement(fi loader.FileInfo, content string, pr *t
 		Docstring:    fn.Docstring,
 		RepoName:     idx.repoName,
 		Metadata: map[string]any{
-			"class_name": fn.ClassName,
-			"is_method":  fn.IsMethod,
-			"is_async":   fn.IsAsync,
-			"receiver":   fn.Receiver,
-			"complexity": fn.Complexity,
-			"calls":      fn.Calls,
+			"class_name":  fn.ClassName,
+			"is_method":   fn.IsMethod,
+			"is_async":    fn.IsAsync,
+			"receiver":    fn.Receiver,
+			"complexity":  fn.Complexity,
+			"calls":       fn.Calls,
+			"decorators":  fn.Decorators,
+			"exported":    fn.IsExported,
+			"param_types": fn.ParamTypes,
+			"return_type": fn.ReturnType,
 		},
 	}
+	if len(fn.CallCounts) > 0 {
+		elem.Metadata["call_counts"] = fn.CallCounts
+	}
+	idx.addChunks(&elem, code)
 	idx.Elements = append(idx.Elements, elem)
 }
 
@@ -198,10 +380,96 @@ func (idx *Indexer) addDocElement(fi loader.FileInfo, pr *types.FileParseResult)
 		Code:         pr.ModuleDocstring,
 		Docstring:    pr.ModuleDocstring,
 		RepoName:     idx.repoName,
+		Metadata:     map[string]any{},
 	}
 	idx.Elements = append(idx.Elements, elem)
 }
 
+// addDocSectionElement records a section of a documentation file as a
+// documentation element named "<file>: <heading>".
+func (idx *Indexer) addDocSectionElement(fi loader.FileInfo, content string, sec types.DocSection) {
+	code := extractCodeBlock(content, sec.StartLine, sec.EndLine)
+	if strings.TrimSpace(code) == "" {
+		return
+	}
+	name := fi.RelativePath
+	if sec.Title != "" {
+		name += ": " + sec.Title
+	}
+	elem := types.CodeElement{
+		ID:           idx.genID("docsection", fi.RelativePath, strconv.Itoa(sec.StartLine)),
+		Type:         "documentation",
+		Name:         name,
+		FilePath:     fi.Path,
+		RelativePath: fi.RelativePath,
+		Language:     fi.Language,
+		StartLine:    sec.StartLine,
+		EndLine:      sec.EndLine,
+		Code:         truncate(code, 3000),
+		Docstring:    sec.Title,
+		RepoName:     idx.repoName,
+		Metadata: map[string]any{
+			"section": sec.Title,
+			"level":   sec.Level,
+		},
+	}
+	idx.addChunks(&elem, code)
+	idx.Elements = append(idx.Elements, elem)
+}
+
+// addSectionElement records a titled Markdown section as a "section" element
+// named after its heading.
+func (idx *Indexer) addSectionElement(fi loader.FileInfo, content string, sec types.DocSection) {
+	code := extractCodeBlock(content, sec.StartLine, sec.EndLine)
+	elem := types.CodeElement{
+		ID:           idx.genID("section", fi.RelativePath, strconv.Itoa(sec.StartLine)),
+		Type:         "section",
+		Name:         sec.Title,
+		FilePath:     fi.Path,
+		RelativePath: fi.RelativePath,
+		Language:     fi.Language,
+		StartLine:    sec.StartLine,
+		EndLine:      sec.EndLine,
+		Code:         truncate(code, 3000),
+		Signature:    strings.Repeat("#", sec.Level) + " " + sec.Title,
+		RepoName:     idx.repoName,
+		Metadata: map[string]any{
+			"level": sec.Level,
+		},
+	}
+	idx.addChunks(&elem, code)
+	idx.Elements = append(idx.Elements, elem)
+}
+
+// topSections returns the titled sections with a heading level of at most
+// maxLevel, each extended over the deeper sections that follow it. Text
+// before the first such heading is dropped.
+func topSections(sections []types.DocSection, maxLevel int) []types.DocSection {
+	var top []types.DocSection
+	for _, sec := range sections {
+		switch {
+		case sec.Title != "" && sec.Level <= maxLevel:
+			top = append(top, sec)
+		case len(top) > 0:
+			top[len(top)-1].EndLine = sec.EndLine
+		}
+	}
+	return top
+}
+
+// IsProjectDoc reports whether relPath is a README at the repository root or
+// a file under a top-level docs/ (or doc/) directory.
+func IsProjectDoc(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	dir, base := path.Split(relPath)
+	if dir == "" {
+		return strings.HasPrefix(strings.ToLower(base), "readme")
+	}
+	top, _, _ := strings.Cut(relPath, "/")
+	top = strings.ToLower(top)
+	return top == "docs" || top == "doc"
+}
+
 func (idx *Indexer) generateFileSummary(pr *types.FileParseResult) string {
 	var parts []string
 	parts = append(parts, fmt.Sprintf("Language: %s, Lines: %d", pr.Language, pr.TotalLines))
@@ -250,9 +518,13 @@ func extractCodeBlock(content string, startLine, endLine int) string {
 	return strings.Join(lines[startLine-1:endLine], "\n")
 }
 
+// TruncatedMarker ends the code of elements whose code was cut at the
+// indexer's length limit.
+const TruncatedMarker = "\n... (truncated)"
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
 	}
-	return s[:maxLen] + "\n... (truncated)"
+	return s[:maxLen] + TruncatedMarker
 }
@@ -255,3 +255,241 @@ func TestGenID(t *testing.T) {
 		t.Error("different inputs should produce different IDs")
 	}
 }
+
+func TestIndexProjectDocSections(t *testing.T) {
+	idx := NewIndexer("repo")
+	files := map[string]string{
+		"README.md":       "# Demo\nA demo tool.\n## Architecture\nThree layers.",
+		"docs/design.md":  "# Design\nDetails.",
+		"pkg/api/note.md": "# Note\nInternal.",
+	}
+	for rel, content := range files {
+		fi := loader.FileInfo{Path: "/repo/" + rel, RelativePath: rel, Language: "markdown"}
+		idx.indexFile(fi, content, idx.parser.ParseFile(fi.Path, content))
+	}
+
+	sections := make(map[string]types.CodeElement)
+	for _, e := range idx.Elements {
+		if e.Type == "documentation" {
+			sections[e.Name] = e
+		}
+	}
+	if len(sections) != 3 {
+		t.Fatalf("documentation elements = %v, want README and docs/ sections only", sections)
+	}
+	arch, ok := sections["README.md: Architecture"]
+	if !ok || arch.StartLine != 3 || arch.EndLine != 4 || arch.Code != "## Architecture\nThree layers." {
+		t.Errorf("Architecture section = %+v", arch)
+	}
+	if _, ok := sections["docs/design.md: Design"]; !ok {
+		t.Error("docs/design.md should be split into sections")
+	}
+}
+
+func TestIsProjectDoc(t *testing.T) {
+	for path, want := range map[string]bool{
+		"README.md":           true,
+		"readme.rst":          true,
+		"docs/guide/intro.md": true,
+		"Doc/api.md":          true,
+		"pkg/README.md":       false,
+		"CHANGELOG.md":        false,
+	} {
+		if got := IsProjectDoc(path); got != want {
+			t.Errorf("IsProjectDoc(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestPythonFunctionSignature(t *testing.T) {
+	idx := NewIndexer("repo")
+	fi := loader.FileInfo{Path: "/repo/tree.py", RelativePath: "tree.py", Language: "python"}
+	content := "def find(tree: 'Tree') -> Optional[Node]:\n    pass\n"
+	pr := &types.FileParseResult{FilePath: fi.Path, Language: "python", TotalLines: 2,
+		Functions: []types.FunctionInfo{{Name: "find", StartLine: 1, EndLine: 2, Parameters: []string{"tree: 'Tree'"},
+			ParamTypes: map[string]string{"tree": "Tree"}, ReturnType: "Optional[Node]"}}}
+	idx.indexFile(fi, content, pr)
+
+	for _, e := range idx.Elements {
+		if e.Type != "function" {
+			continue
+		}
+		if e.Signature != "find(tree: 'Tree') -> Optional[Node]" {
+			t.Errorf("signature = %q", e.Signature)
+		}
+		if e.Metadata["return_type"] != "Optional[Node]" || e.Metadata["param_types"].(map[string]string)["tree"] != "Tree" {
+			t.Errorf("metadata = %v", e.Metadata)
+		}
+		return
+	}
+	t.Fatal("no function element")
+}
+
+func TestAsyncFunctionSignature(t *testing.T) {
+	idx := NewIndexer("repo")
+	fi := loader.FileInfo{Path: "/repo/client.py", RelativePath: "client.py", Language: "python"}
+	content := "class Client:\n    async def get(self, path):\n        pass\n"
+	pr := &types.FileParseResult{FilePath: fi.Path, Language: "python", TotalLines: 3,
+		Classes: []types.ClassInfo{{Name: "Client", StartLine: 1, EndLine: 3, Methods: []types.FunctionInfo{
+			{Name: "get", StartLine: 2, EndLine: 3, Parameters: []string{"self", "path"}, ClassName: "Client", IsMethod: true, IsAsync: true}}}}}
+	idx.indexFile(fi, content, pr)
+
+	for _, e := range idx.Elements {
+		if e.Type != "function" {
+			continue
+		}
+		if e.Signature != "async Client.get(self, path)" || e.Metadata["is_async"] != true {
+			t.Errorf("signature = %q, is_async = %v; want an async signature", e.Signature, e.Metadata["is_async"])
+		}
+		return
+	}
+	t.Fatal("no function element")
+}
+
+func TestFunctionCallCounts(t *testing.T) {
+	idx := NewIndexer("repo")
+	fi := loader.FileInfo{Path: "/repo/app.py", RelativePath: "app.py", Language: "python"}
+	content := "def run():\n    step()\n    step()\n\ndef step():\n    pass\n"
+	pr := &types.FileParseResult{FilePath: fi.Path, Language: "python", TotalLines: 6,
+		Functions: []types.FunctionInfo{
+			{Name: "run", StartLine: 1, EndLine: 3, Calls: []string{"step"}, CallCounts: map[string]int{"step": 2}},
+			{Name: "step", StartLine: 5, EndLine: 6},
+		}}
+	idx.indexFile(fi, content, pr)
+
+	for _, e := range idx.Elements {
+		counts, ok := e.Metadata["call_counts"]
+		switch e.Name {
+		case "run":
+			if !ok || counts.(map[string]int)["step"] != 2 {
+				t.Errorf("run call_counts = %v, want step: 2", counts)
+			}
+		case "step":
+			if ok {
+				t.Errorf("step has call_counts %v, want none without calls", counts)
+			}
+		}
+	}
+}
+
+func TestIsExported(t *testing.T) {
+	cases := []struct {
+		elem types.CodeElement
+		want bool
+	}{
+		{types.CodeElement{Type: "function", Metadata: map[string]any{"exported": false}}, false},
+		{types.CodeElement{Type: "class", Metadata: map[string]any{"exported": true}}, true},
+		{types.CodeElement{Type: "function"}, true}, // indexed before visibility was recorded
+		{types.CodeElement{Type: "file", Metadata: map[string]any{"exported": false}}, true},
+	}
+	for i, c := range cases {
+		if got := IsExported(&c.elem); got != c.want {
+			t.Errorf("case %d: IsExported = %v, want %v", i, got, c.want)
+		}
+	}
+}
+
+func TestIsTest(t *testing.T) {
+	idx := NewIndexer("repo")
+	pr := &types.FileParseResult{Functions: []types.FunctionInfo{{Name: "TestRun", StartLine: 1, EndLine: 3}}}
+	for _, rel := range []string{"run.go", "run_test.go"} {
+		idx.indexFile(loader.FileInfo{Path: "/repo/" + rel, RelativePath: rel, Language: "go"}, "func TestRun() {\n}\n", pr)
+	}
+	for _, e := range idx.Elements {
+		if want := e.RelativePath == "run_test.go"; IsTest(&e) != want || e.Metadata["is_test"] != want {
+			t.Errorf("%s %s: IsTest = %v, metadata %v, want %v", e.Type, e.RelativePath, IsTest(&e), e.Metadata["is_test"], want)
+		}
+	}
+
+	// Elements indexed before tests were recorded are classified by path
+	if !IsTest(&types.CodeElement{RelativePath: "src/__tests__/app.js"}) {
+		t.Error("IsTest without metadata should fall back to the path")
+	}
+}
+
+func TestIsTestModuleDocstring(t *testing.T) {
+	idx := NewIndexer("repo")
+	content := "\"\"\"Tests for the app.\"\"\"\n\ndef test_run():\n    pass\n"
+	pr := &types.FileParseResult{Language: "python", TotalLines: 4, ModuleDocstring: "Tests for the app.",
+		Functions: []types.FunctionInfo{{Name: "test_run", StartLine: 3, EndLine: 4}}}
+	idx.indexFile(loader.FileInfo{Path: "/repo/tests/test_app.py", RelativePath: "tests/test_app.py", Language: "python"}, content, pr)
+
+	var doc bool
+	for _, e := range idx.Elements {
+		doc = doc || e.Type == "documentation"
+		if e.Metadata["is_test"] != true {
+			t.Errorf("%s element: is_test = %v, want true", e.Type, e.Metadata["is_test"])
+		}
+	}
+	if !doc {
+		t.Error("expected a documentation element for the module docstring")
+	}
+}
+
+func TestIndexMarkdownSections(t *testing.T) {
+	content := "Intro\n# Guide\nOverview.\n## Configure the cache\nSet cache_dir.\n### Defaults\n~/.fastcode\n## Deploy\nRun it."
+	idx := NewIndexer("repo")
+	idx.MarkdownSections = true
+	for _, rel := range []string{"README.md", "guide/setup.md"} {
+		fi := loader.FileInfo{Path: "/repo/" + rel, RelativePath: rel, Language: "markdown"}
+		idx.indexFile(fi, content, idx.parser.ParseFile(fi.Path, content))
+	}
+
+	var sections []types.CodeElement
+	for _, e := range idx.Elements {
+		switch e.Type {
+		case "section":
+			if e.RelativePath == "guide/setup.md" {
+				sections = append(sections, e)
+			}
+		case "documentation":
+			t.Errorf("README should only be indexed as sections, got %q", e.Name)
+		}
+	}
+	if len(sections) != 3 {
+		t.Fatalf("got %d sections, want Guide, Configure the cache and Deploy", len(sections))
+	}
+	configure := sections[1]
+	if configure.Name != "Configure the cache" || configure.Signature != "## Configure the cache" ||
+		configure.StartLine != 4 || configure.EndLine != 7 || !strings.Contains(configure.Code, "~/.fastcode") {
+		t.Errorf("section keeps its ### subsection: %+v", configure)
+	}
+}
+
+func TestIndexFileElementLimit(t *testing.T) {
+	idx := NewIndexer("repo")
+	idx.MaxElementsPerFile = 2
+	idx.Chunks = map[string][]string{}
+	pr := &types.FileParseResult{
+		TotalLines: 40,
+		Functions: []types.FunctionInfo{
+			{Name: "small", StartLine: 1, EndLine: 2, Complexity: 1},
+			{Name: "branchy", StartLine: 3, EndLine: 5, Complexity: 9},
+			{Name: "long", StartLine: 6, EndLine: 30, Complexity: 1},
+			{Name: "tiny", StartLine: 31, EndLine: 31, Complexity: 1},
+		},
+	}
+	fi := loader.FileInfo{Path: "/repo/gen.go", RelativePath: "gen.go", Language: "go"}
+	idx.indexFile(fi, strings.Repeat("x\n", 40), pr)
+
+	var names []string
+	for _, e := range idx.Elements {
+		names = append(names, e.Name)
+	}
+	if strings.Join(names, ",") != "gen.go,branchy,long" {
+		t.Errorf("elements = %v, want the file, then the most complex and the largest in file order", names)
+	}
+	if len(idx.TruncatedFiles) != 1 || idx.TruncatedFiles[0] != "gen.go" {
+		t.Errorf("TruncatedFiles = %v", idx.TruncatedFiles)
+	}
+	if got := idx.Elements[0].Metadata["dropped_elements"]; got != 2 {
+		t.Errorf("dropped_elements = %v, want 2", got)
+	}
+
+	idx.MaxElementsPerFile = 0
+	idx.Elements, idx.TruncatedFiles = nil, nil
+	idx.indexFile(fi, strings.Repeat("x\n", 40), pr)
+	if len(idx.Elements) != 5 || idx.TruncatedFiles != nil {
+		t.Errorf("without a limit got %d elements, truncated %v", len(idx.Elements), idx.TruncatedFiles)
+	}
+}
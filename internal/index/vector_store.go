@@ -1,13 +1,20 @@
 package index
 
 import (
+	"container/heap"
+	"errors"
+	"fmt"
 	"math"
-	"sort"
 )
 
+// ErrDimensionMismatch is returned when a vector's dimension differs from
+// the vectors already in a store.
+var ErrDimensionMismatch = errors.New("vector dimension mismatch")
+
 // VectorStore is an in-memory vector store for embedding-based similarity search.
 type VectorStore struct {
-	vectors map[string][]float32 // elementID → embedding vector
+	vectors map[string][]float32   // elementID → embedding vector
+	chunks  map[string][][]float32 // elementID → embeddings of chunks of its code
 	dim     int
 }
 
@@ -15,15 +22,49 @@ type VectorStore struct {
 func NewVectorStore() *VectorStore {
 	return &VectorStore{
 		vectors: make(map[string][]float32),
+		chunks:  make(map[string][][]float32),
 	}
 }
 
-// Add stores an embedding vector for the given element ID.
-func (vs *VectorStore) Add(id string, vector []float32) {
+// Add stores an embedding vector for the given element ID. The first vector
+// fixes the store's dimension; vectors of another dimension are rejected
+// with ErrDimensionMismatch, as cosine scores between them are meaningless.
+func (vs *VectorStore) Add(id string, vector []float32) error {
+	if err := vs.checkDimension(vector); err != nil {
+		return fmt.Errorf("vector for %s: %w", id, err)
+	}
 	vs.vectors[id] = vector
-	if vs.dim == 0 && len(vector) > 0 {
+	return nil
+}
+
+// AddChunk stores the embedding of one chunk of an element's code. Search
+// scores an element by its best-matching vector, element or chunk. Like Add
+// it rejects vectors of another dimension.
+func (vs *VectorStore) AddChunk(id string, vector []float32) error {
+	if err := vs.checkDimension(vector); err != nil {
+		return fmt.Errorf("chunk vector for %s: %w", id, err)
+	}
+	vs.chunks[id] = append(vs.chunks[id], vector)
+	return nil
+}
+
+// checkDimension verifies that vector matches the store's dimension, setting
+// the dimension from the first vector added.
+func (vs *VectorStore) checkDimension(vector []float32) error {
+	switch {
+	case len(vector) == 0:
+		return fmt.Errorf("%w: empty vector", ErrDimensionMismatch)
+	case vs.dim == 0:
 		vs.dim = len(vector)
+	case len(vector) != vs.dim:
+		return fmt.Errorf("%w: got %d, store has %d", ErrDimensionMismatch, len(vector), vs.dim)
 	}
+	return nil
+}
+
+// Chunks returns the chunk embeddings stored for an ID, or nil.
+func (vs *VectorStore) Chunks(id string) [][]float32 {
+	return vs.chunks[id]
 }
 
 // VectorResult holds a similarity search result.
@@ -32,43 +73,84 @@ type VectorResult struct {
 	Score float64
 }
 
-// Search finds the top-k most similar vectors to the query vector.
+// Search finds the top-k most similar vectors to the query vector. A query
+// vector whose dimension differs from the store's (e.g. from another
+// embedding model) matches nothing.
 func (vs *VectorStore) Search(queryVec []float32, topK int) []VectorResult {
-	if len(vs.vectors) == 0 || len(queryVec) == 0 {
+	if (len(vs.vectors) == 0 && len(vs.chunks) == 0) || len(queryVec) == 0 {
+		return nil
+	}
+	if len(queryVec) != vs.dim {
+		logger.Warn("skipping vector search: query dimension differs from the index",
+			"query_dimension", len(queryVec), "index_dimension", vs.dim)
 		return nil
 	}
 
-	type scored struct {
-		id    string
-		score float64
+	if topK <= 0 {
+		return []VectorResult{}
 	}
-	var results []scored
 
-	for id, vec := range vs.vectors {
-		sim := cosineSimilarity(queryVec, vec)
-		if sim > 0 {
-			results = append(results, scored{id: id, score: sim})
+	// Keep the best topK in a min-heap rather than sorting every score
+	top := make(resultHeap, 0, topK)
+	score := func(id string) {
+		sim := 0.0
+		if vec, ok := vs.vectors[id]; ok {
+			sim = cosineSimilarity(queryVec, vec)
+		}
+		for _, vec := range vs.chunks[id] {
+			sim = max(sim, cosineSimilarity(queryVec, vec))
+		}
+		if sim <= 0 {
+			return
+		}
+		r := VectorResult{ID: id, Score: sim}
+		if len(top) < topK {
+			heap.Push(&top, r)
+		} else if better(r, top[0]) {
+			top[0] = r
+			heap.Fix(&top, 0)
 		}
 	}
-
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].score > results[j].score
-	})
-
-	if topK > len(results) {
-		topK = len(results)
+	for id := range vs.vectors {
+		score(id)
 	}
-
-	out := make([]VectorResult, topK)
-	for i := 0; i < topK; i++ {
-		out[i] = VectorResult{
-			ID:    results[i].id,
-			Score: results[i].score,
+	for id := range vs.chunks {
+		if _, ok := vs.vectors[id]; !ok {
+			score(id)
 		}
 	}
+
+	// Popping the min-heap yields the results worst first
+	out := make([]VectorResult, len(top))
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(&top).(VectorResult)
+	}
 	return out
 }
 
+// better orders search results: higher score first, ties broken by ID so
+// results do not depend on map iteration order.
+func better(a, b VectorResult) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.ID < b.ID
+}
+
+// resultHeap is a min-heap of results, the worst at the root.
+type resultHeap []VectorResult
+
+func (h resultHeap) Len() int           { return len(h) }
+func (h resultHeap) Less(i, j int) bool { return better(h[j], h[i]) }
+func (h resultHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x any)        { *h = append(*h, x.(VectorResult)) }
+func (h *resultHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
 // Count returns the number of stored vectors.
 func (vs *VectorStore) Count() int {
 	return len(vs.vectors)
@@ -1,7 +1,11 @@
 package index
 
 import (
+	"errors"
+	"fmt"
 	"math"
+	"math/rand"
+	"sort"
 	"testing"
 )
 
@@ -108,6 +112,33 @@ func TestVectorStoreDimensionEmpty(t *testing.T) {
 	}
 }
 
+func TestVectorStoreDimensionMismatch(t *testing.T) {
+	vs := NewVectorStore()
+	if err := vs.Add("a", []float32{1, 0}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := vs.Add("b", []float32{1, 0, 0}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Add with 3 dimensions: err = %v, want ErrDimensionMismatch", err)
+	}
+	if err := vs.AddChunk("a", []float32{1}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("AddChunk with 1 dimension: err = %v, want ErrDimensionMismatch", err)
+	}
+	if err := vs.Add("c", nil); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Add empty vector: err = %v, want ErrDimensionMismatch", err)
+	}
+	if vs.Count() != 1 || vs.Get("b") != nil || vs.Chunks("a") != nil {
+		t.Errorf("rejected vectors were stored: count = %d", vs.Count())
+	}
+
+	// A query from another model must not be scored against the index
+	if got := vs.Search([]float32{1, 0, 0}, 5); len(got) != 0 {
+		t.Errorf("Search with 3 dimensions = %v, want no results", got)
+	}
+	if got := vs.Search([]float32{1, 0}, 5); len(got) != 1 {
+		t.Errorf("Search with 2 dimensions = %v, want a", got)
+	}
+}
+
 func TestVectorStoreGet(t *testing.T) {
 	vs := NewVectorStore()
 	vs.Add("a", []float32{1, 2, 3})
@@ -138,3 +169,62 @@ func TestNewVectorStore(t *testing.T) {
 		t.Error("new store should be empty")
 	}
 }
+
+// searchFullSort is Search implemented by sorting every score, the baseline
+// the heap selection must match.
+func searchFullSort(vs *VectorStore, queryVec []float32, topK int) []VectorResult {
+	var results []VectorResult
+	for id, vec := range vs.vectors {
+		if sim := cosineSimilarity(queryVec, vec); sim > 0 {
+			results = append(results, VectorResult{ID: id, Score: sim})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return better(results[i], results[j]) })
+	return results[:min(topK, len(results))]
+}
+
+func randomVectorStore(n, dim int) *VectorStore {
+	rng := rand.New(rand.NewSource(1))
+	vs := NewVectorStore()
+	for i := 0; i < n; i++ {
+		vec := make([]float32, dim)
+		for j := range vec {
+			// Few distinct values so that scores tie
+			vec[j] = float32(rng.Intn(3) - 1)
+		}
+		vs.Add(fmt.Sprintf("e%d", i), vec)
+	}
+	return vs
+}
+
+func TestVectorStoreSearchMatchesFullSort(t *testing.T) {
+	vs := randomVectorStore(500, 4)
+	query := []float32{1, 1, 0, -1}
+	for _, k := range []int{1, 5, 50, 1000} {
+		got, want := vs.Search(query, k), searchFullSort(vs, query, k)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("k=%d: Search = %v\nwant %v", k, got, want)
+		}
+	}
+	if got := vs.Search(query, 0); len(got) != 0 {
+		t.Errorf("k=0: got %d results", len(got))
+	}
+}
+
+func BenchmarkVectorStoreSearch(b *testing.B) {
+	vs := randomVectorStore(100000, 16)
+	query := make([]float32, 16)
+	for i := range query {
+		query[i] = float32(i%3) - 1
+	}
+	b.Run("heap", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vs.Search(query, 10)
+		}
+	})
+	b.Run("full-sort", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			searchFullSort(vs, query, 10)
+		}
+	})
+}
@@ -21,18 +21,31 @@ type Client struct {
 	APIKey           string
 	Model            string
 	BaseURL          string
-	EmbeddingBaseURL string // Separate base URL for embeddings (optional)
+	EmbeddingBaseURL string // Base URL for embeddings only, e.g. a local embedding server; defaults to BaseURL
+	EmbeddingFormat  string // Embedding response shape: EmbeddingFormatOpenAI (default) or EmbeddingFormatList
 	HTTP             *http.Client
 }
 
-// NewClient creates a new LLM client from environment variables.
+// Supported embedding response shapes.
+const (
+	// EmbeddingFormatOpenAI is {"data": [{"index": 0, "embedding": [...]}]}.
+	// Entries without "index" are assigned to inputs in order.
+	EmbeddingFormatOpenAI = "openai"
+	// EmbeddingFormatList is {"embeddings": [[...], [...]]}, in input order.
+	EmbeddingFormatList = "embeddings"
+)
+
+// NewClient creates a new LLM client from environment variables. Embeddings
+// are requested from EMBEDDING_BASE_URL (or the older EMBEDDING_URL) when
+// set, and from BASE_URL otherwise.
 func NewClient() *Client {
 	baseURL := getEnvOr("BASE_URL", "https://api.openai.com/v1")
 	return &Client{
 		APIKey:           os.Getenv("OPENAI_API_KEY"),
 		Model:            getEnvOr("MODEL", "gpt-4o"),
 		BaseURL:          baseURL,
-		EmbeddingBaseURL: getEnvOr("EMBEDDING_URL", baseURL),
+		EmbeddingBaseURL: getEnvOr("EMBEDDING_BASE_URL", getEnvOr("EMBEDDING_URL", baseURL)),
+		EmbeddingFormat:  getEnvOr("EMBEDDING_FORMAT", EmbeddingFormatOpenAI),
 		HTTP: &http.Client{
 			Timeout: 120 * time.Second,
 		},
@@ -46,6 +59,7 @@ func NewClientWith(apiKey, model, baseURL string) *Client {
 		Model:            model,
 		BaseURL:          baseURL,
 		EmbeddingBaseURL: baseURL,
+		EmbeddingFormat:  EmbeddingFormatOpenAI,
 		HTTP:             &http.Client{Timeout: 120 * time.Second},
 	}
 }
@@ -141,29 +155,39 @@ func (c *Client) ChatCompletion(messages []ChatMessage, temperature float64, max
 // --- Embeddings ---
 
 type embeddingRequest struct {
-	Model string   `json:"model"`
-	Input []string `json:"input"`
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
 }
 
 type embeddingResponse struct {
 	Data []struct {
 		Embedding []float32 `json:"embedding"`
-		Index     int       `json:"index"`
+		Index     *int      `json:"index"` // optional; some gateways omit it
 	} `json:"data"`
-	Error *struct {
+	Embeddings [][]float32 `json:"embeddings"` // EmbeddingFormatList
+	Error      *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
 }
 
 // Embed generates embedding vectors for the given texts.
 func (c *Client) Embed(texts []string, model string) ([][]float32, error) {
+	return c.EmbedDimensions(texts, model, 0)
+}
+
+// EmbedDimensions is like Embed but asks the model for vectors of the given
+// size, for models that support shortening them (OpenAI text-embedding-3).
+// Zero requests the model's native size.
+func (c *Client) EmbedDimensions(texts []string, model string, dimensions int) ([][]float32, error) {
 	if model == "" {
 		model = "text-embedding-3-small"
 	}
 
 	req := embeddingRequest{
-		Model: model,
-		Input: texts,
+		Model:      model,
+		Input:      texts,
+		Dimensions: dimensions,
 	}
 
 	var url string
@@ -186,12 +210,27 @@ func (c *Client) Embed(texts []string, model string) ([][]float32, error) {
 		return nil, fmt.Errorf("API error: %s", resp.Error.Message)
 	}
 
-	// Sort by index to maintain order
 	result := make([][]float32, len(texts))
-	for _, d := range resp.Data {
-		if d.Index < len(result) {
-			result[d.Index] = d.Embedding
+	switch c.EmbeddingFormat {
+	case EmbeddingFormatList:
+		for i, emb := range resp.Embeddings {
+			if i < len(result) {
+				result[i] = emb
+			}
+		}
+	case "", EmbeddingFormatOpenAI:
+		// Place by index to maintain order, falling back to position when absent
+		for i, d := range resp.Data {
+			idx := i
+			if d.Index != nil {
+				idx = *d.Index
+			}
+			if idx >= 0 && idx < len(result) {
+				result[idx] = d.Embedding
+			}
 		}
+	default:
+		return nil, fmt.Errorf("unknown embedding format %q", c.EmbeddingFormat)
 	}
 
 	return result, nil
@@ -194,6 +194,55 @@ func TestEmbed(t *testing.T) {
 	}
 }
 
+func TestEmbedWithoutIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := map[string]any{
+			"data": []map[string]any{
+				{"embedding": []float64{1, 0}},
+				{"embedding": []float64{0, 1}},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClientWith("test-key", "test-model", server.URL)
+	embeddings, err := client.Embed([]string{"first", "second"}, "test-embed")
+	if err != nil {
+		t.Fatalf("Embed error: %v", err)
+	}
+	if len(embeddings) != 2 || embeddings[0] == nil || embeddings[1] == nil {
+		t.Fatalf("expected 2 embeddings assigned by position, got %v", embeddings)
+	}
+	if embeddings[0][0] != 1 || embeddings[1][1] != 1 {
+		t.Errorf("embeddings not associated with inputs by position: %v", embeddings)
+	}
+}
+
+func TestEmbedListFormat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"embeddings": [][]float64{{0.1, 0.2}, {0.3, 0.4}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClientWith("test-key", "test-model", server.URL)
+	client.EmbeddingFormat = EmbeddingFormatList
+	embeddings, err := client.Embed([]string{"a", "b"}, "test-embed")
+	if err != nil {
+		t.Fatalf("Embed error: %v", err)
+	}
+	if len(embeddings) != 2 || len(embeddings[1]) != 2 || embeddings[1][0] != float32(0.3) {
+		t.Errorf("unexpected embeddings: %v", embeddings)
+	}
+
+	client.EmbeddingFormat = "bogus"
+	if _, err := client.Embed([]string{"a"}, "test-embed"); err == nil {
+		t.Error("expected error for unknown embedding format")
+	}
+}
+
 func TestEmbedDefaultModel(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var req embeddingRequest
@@ -268,7 +317,7 @@ func TestPostNoAPIKey(t *testing.T) {
 }
 
 func TestBuildSearchText(t *testing.T) {
-	text := BuildSearchText("parseFile", "Parses a file", "parseFile(path string)", "")
+	text := BuildSearchText("parseFile", "Parses a file", "parseFile(path string)", "", 0)
 	if text == "" {
 		t.Error("expected non-empty search text")
 	}
@@ -278,17 +327,20 @@ func TestBuildSearchText(t *testing.T) {
 }
 
 func TestBuildSearchTextAllEmpty(t *testing.T) {
-	text := BuildSearchText("", "", "", "")
+	text := BuildSearchText("", "", "", "", 0)
 	if text != "" {
 		t.Errorf("expected empty search text for all empty, got %q", text)
 	}
 }
 
 func TestBuildSearchTextCodeTruncation(t *testing.T) {
-	longCode := strings.Repeat("x", 3000)
-	text := BuildSearchText("name", "", "", longCode)
-	if len(text) > 2100 {
-		t.Errorf("text too long: %d, code should be truncated at 2000", len(text))
+	longCode := strings.Repeat("total += values[i]\n", 500)
+	text := BuildSearchText("name", "", "", longCode, 100)
+	if n := EstimateTokens(text); n > 100 || n < 90 {
+		t.Errorf("text is %d tokens, want about 100", n)
+	}
+	if !strings.HasPrefix(text, "name\n") || !strings.HasSuffix(text, "total += values[i]") {
+		t.Errorf("text should keep the name and end on a whole line: %q", text[len(text)-30:])
 	}
 }
 
@@ -306,3 +358,23 @@ func TestGetEnvOr(t *testing.T) {
 		t.Errorf("getEnvOr(set) = %q, want present", got)
 	}
 }
+
+func TestNewClientEmbeddingBaseURL(t *testing.T) {
+	t.Setenv("BASE_URL", "https://chat.example/v1")
+	t.Setenv("EMBEDDING_URL", "")
+	t.Setenv("EMBEDDING_BASE_URL", "")
+	if got := NewClient().EmbeddingBaseURL; got != "https://chat.example/v1" {
+		t.Errorf("EmbeddingBaseURL = %q, want BASE_URL", got)
+	}
+
+	t.Setenv("EMBEDDING_URL", "http://legacy:8080")
+	if got := NewClient().EmbeddingBaseURL; got != "http://legacy:8080" {
+		t.Errorf("EmbeddingBaseURL = %q, want EMBEDDING_URL", got)
+	}
+
+	t.Setenv("EMBEDDING_BASE_URL", "http://localhost:8080/v1")
+	client := NewClient()
+	if client.EmbeddingBaseURL != "http://localhost:8080/v1" || client.BaseURL != "https://chat.example/v1" {
+		t.Errorf("EmbeddingBaseURL = %q, BaseURL = %q; want embeddings local and chat hosted", client.EmbeddingBaseURL, client.BaseURL)
+	}
+}
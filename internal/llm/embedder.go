@@ -1,16 +1,39 @@
 package llm
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"math"
 	"strings"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/logging"
 )
 
+// ProgressFunc reports how many of total items have been processed so far.
+type ProgressFunc func(done, total int)
+
+var logger = logging.New("embedder")
+
 // Embedder generates embedding vectors for code elements via an LLM API.
 type Embedder struct {
 	client    *Client
 	model     string
 	batchSize int
+
+	// Progress, if set, is called after each embedded batch.
+	Progress ProgressFunc
+
+	// Dimensions, if positive, reduces embeddings to this many dimensions.
+	// It is sent as the request's "dimensions" parameter unless Truncate is
+	// set, for models that do not support it; either way, longer vectors
+	// returned are truncated and renormalized.
+	Dimensions int
+	Truncate   bool
+
+	// MaxTokens, if positive, is the model's input limit in tokens, to
+	// which embedding texts are truncated; 0 uses the limit known for the
+	// model (see ModelMaxTokens).
+	MaxTokens int
 }
 
 // NewEmbedder creates a new embedder using the given client.
@@ -28,8 +51,27 @@ func NewEmbedder(client *Client, embeddingModel string, batchSize int) *Embedder
 	}
 }
 
+// Model returns the name of the embedding model.
+func (e *Embedder) Model() string {
+	return e.model
+}
+
+// TokenLimit returns the input limit of the embedding model in tokens.
+func (e *Embedder) TokenLimit() int {
+	if e.MaxTokens > 0 {
+		return e.MaxTokens
+	}
+	return ModelMaxTokens(e.model)
+}
+
 // EmbedTexts generates embeddings for a list of texts, batching as needed.
 func (e *Embedder) EmbedTexts(texts []string) ([][]float32, error) {
+	return e.EmbedTextsContext(context.Background(), texts)
+}
+
+// EmbedTextsContext is like EmbedTexts but stops between batches once ctx is
+// cancelled, returning the context's error.
+func (e *Embedder) EmbedTextsContext(ctx context.Context, texts []string) ([][]float32, error) {
 	if len(texts) == 0 {
 		return nil, nil
 	}
@@ -37,29 +79,62 @@ func (e *Embedder) EmbedTexts(texts []string) ([][]float32, error) {
 	allEmbeddings := make([][]float32, len(texts))
 
 	for start := 0; start < len(texts); start += e.batchSize {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("embedding cancelled after %d/%d texts: %w", start, len(texts), err)
+		}
 		end := start + e.batchSize
 		if end > len(texts) {
 			end = len(texts)
 		}
 		batch := texts[start:end]
 
-		embeddings, err := e.client.Embed(batch, e.model)
+		dimensions := e.Dimensions
+		if e.Truncate {
+			dimensions = 0
+		}
+		embeddings, err := e.client.EmbedDimensions(batch, e.model, dimensions)
 		if err != nil {
 			return nil, fmt.Errorf("embed batch [%d:%d]: %w", start, end, err)
 		}
 
 		for i, emb := range embeddings {
-			allEmbeddings[start+i] = emb
+			allEmbeddings[start+i] = truncateEmbedding(emb, e.Dimensions)
 		}
 
-		if end < len(texts) {
-			log.Printf("[embedder] embedded %d/%d texts", end, len(texts))
+		if e.Progress != nil {
+			e.Progress(end, len(texts))
+		} else if end < len(texts) {
+			logger.Debug("embedded texts", "done", end, "total", len(texts))
 		}
 	}
 
 	return allEmbeddings, nil
 }
 
+// truncateEmbedding keeps the first dimensions values of vec and rescales
+// them to unit length, as for Matryoshka-trained models whose leading
+// dimensions carry most of the meaning. Vectors already within dimensions,
+// or a non-positive dimensions, are returned unchanged.
+func truncateEmbedding(vec []float32, dimensions int) []float32 {
+	if dimensions <= 0 || len(vec) <= dimensions {
+		return vec
+	}
+	out := make([]float32, dimensions)
+	copy(out, vec)
+	var norm float64
+	for _, v := range out {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return out
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range out {
+		out[i] *= scale
+	}
+	return out
+}
+
 // EmbedText generates an embedding for a single text.
 func (e *Embedder) EmbedText(text string) ([]float32, error) {
 	results, err := e.EmbedTexts([]string{text})
@@ -72,31 +147,19 @@ func (e *Embedder) EmbedText(text string) ([]float32, error) {
 	return results[0], nil
 }
 
-// BuildSearchText creates a searchable text representation for a code element.
-func BuildSearchText(name, docstring, signature, code string) string {
-	var parts []string
-	if name != "" {
-		parts = append(parts, name)
-	}
-	if docstring != "" {
-		parts = append(parts, docstring)
+// BuildSearchText creates a searchable text representation for a code
+// element of about maxTokens tokens at most (DefaultMaxTokens if not
+// positive). The name, docstring and signature come first, so it is the code
+// that is cut, at a line or word boundary (see TruncateToTokens).
+func BuildSearchText(name, docstring, signature, code string, maxTokens int) string {
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
 	}
-	if signature != "" {
-		parts = append(parts, signature)
-	}
-	// Truncate code aggressively to avoid exceeding strict 128-token limits (e.g., TEI)
-	if code != "" {
-		if len(code) > 200 {
-			code = code[:200]
+	var parts []string
+	for _, part := range []string{name, docstring, signature, code} {
+		if part != "" {
+			parts = append(parts, part)
 		}
-		parts = append(parts, code)
-	}
-
-	result := strings.Join(parts, "\n")
-
-	// Hard cap characters assuming ~1-2 chars per token for extremely dense code chunks (128 max tokens)
-	if len(result) > 180 {
-		return result[:180]
 	}
-	return result
+	return TruncateToTokens(strings.Join(parts, "\n"), maxTokens)
 }
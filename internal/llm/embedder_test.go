@@ -1,7 +1,10 @@
 package llm
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -106,6 +109,64 @@ func TestEmbedTextsBatching(t *testing.T) {
 	}
 }
 
+func TestEmbedTextsProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		data := make([]map[string]any, len(req.Input))
+		for i := range req.Input {
+			data[i] = map[string]any{"index": i, "embedding": []float64{1}}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer server.Close()
+
+	client := NewClientWith("key", "model", server.URL)
+	e := NewEmbedder(client, "model", 2)
+	var calls [][2]int
+	e.Progress = func(done, total int) {
+		calls = append(calls, [2]int{done, total})
+	}
+
+	if _, err := e.EmbedTexts([]string{"a", "b", "c", "d", "e"}); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := [][2]int{{2, 5}, {4, 5}, {5, 5}}
+	if len(calls) != len(want) {
+		t.Fatalf("progress calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("progress call %d = %v, want %v", i, calls[i], want[i])
+		}
+	}
+}
+
+func TestEmbedTextsContextCancel(t *testing.T) {
+	batchCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		batchCount++
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{{"index": 0, "embedding": []float64{1}}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClientWith("key", "model", server.URL)
+	e := NewEmbedder(client, "model", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	// Cancel once the first batch is done
+	e.Progress = func(done, total int) { cancel() }
+
+	_, err := e.EmbedTextsContext(ctx, []string{"a", "b", "c"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if batchCount != 1 {
+		t.Errorf("expected 1 batch before cancellation, got %d", batchCount)
+	}
+}
+
 func TestEmbedTextsError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
@@ -178,3 +239,51 @@ func TestEmbedTextNilResult(t *testing.T) {
 		t.Error("expected error for nil embedding result")
 	}
 }
+
+func TestEmbedTextsDimensions(t *testing.T) {
+	var requested []any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		requested = append(requested, req["dimensions"])
+		// A model ignoring "dimensions" returns its full-size vector
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{{"index": 0, "embedding": []float64{3, 4, 12}}},
+		})
+	}))
+	defer server.Close()
+
+	e := NewEmbedder(NewClientWith("key", "model", server.URL), "text-embedding-3-small", 32)
+	e.Dimensions = 2
+	vec, err := e.EmbedText("hello")
+	if err != nil {
+		t.Fatalf("EmbedText: %v", err)
+	}
+	if len(vec) != 2 || math.Abs(float64(vec[0])-0.6) > 1e-6 || math.Abs(float64(vec[1])-0.8) > 1e-6 {
+		t.Errorf("vector = %v, want [0.6 0.8]", vec)
+	}
+
+	e.Truncate = true
+	if _, err := e.EmbedText("hello"); err != nil {
+		t.Fatalf("EmbedText (truncate): %v", err)
+	}
+	if len(requested) != 2 || requested[0] != float64(2) || requested[1] != nil {
+		t.Errorf("requested dimensions = %v, want [2 <nil>]", requested)
+	}
+}
+
+func TestTruncateEmbedding(t *testing.T) {
+	vec := []float32{1, 2, 3}
+	if got := truncateEmbedding(vec, 0); len(got) != 3 {
+		t.Errorf("dimensions 0 changed the vector: %v", got)
+	}
+	if got := truncateEmbedding(vec, 5); len(got) != 3 || got[2] != 3 {
+		t.Errorf("short vector changed: %v", got)
+	}
+	if got := truncateEmbedding([]float32{0, 0, 1}, 2); len(got) != 2 || got[0] != 0 || got[1] != 0 {
+		t.Errorf("zero prefix = %v, want [0 0]", got)
+	}
+	if vec[0] != 1 {
+		t.Error("truncateEmbedding modified its input")
+	}
+}
@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RedactedMarker replaces secret values masked by RedactSecrets.
+const RedactedMarker = "[REDACTED]"
+
+// secretPatterns match credentials by their well-known shapes.
+var secretPatterns = []*regexp.Regexp{
+	// PEM private keys, whole blocks
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),                                         // AWS access key IDs
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),                               // GitHub tokens
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{40,}\b`),                             // GitHub fine-grained tokens
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),                                      // OpenAI and Anthropic API keys
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),                               // Slack tokens
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),                                    // Google API keys
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`), // JWTs
+	regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{16,}=*)`),                    // Authorization headers
+	regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://[^\s:/@]+:([^\s@/]+)@`),              // Passwords in URLs
+}
+
+// secretAssignment matches a value assigned to a secret-sounding name, as in
+// "API_KEY=...", `password: "..."` or `"token": "..."`. Group 1 is the name
+// and group 2 the value.
+var secretAssignment = regexp.MustCompile(`(?i)\b([\w.-]*(?:passw(?:or)?d|pwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credentials?|auth[_-]?key)[\w.-]*)["']?[ \t]*(?::=|[:=])[ \t]*("[^"\n]*"|'[^'\n]*'|` + "`[^`\\n]*`" + `|[^\s"'` + "`" + `,;)\]}]+)`)
+
+// highEntropyWord matches candidates for random-looking tokens.
+var highEntropyWord = regexp.MustCompile(`[A-Za-z0-9+/_=-]{24,}`)
+
+// RedactSecrets masks what looks like a secret in text with RedactedMarker,
+// so code sent to a model does not leak credentials:
+//   - well-known credential shapes (private keys, AWS, GitHub, OpenAI,
+//     Slack and Google keys, JWTs, bearer tokens, passwords in URLs);
+//   - values assigned to names such as password, secret, token or api_key,
+//     when quoted, or unquoted in .env style (an upper-case name, or a value
+//     with a digit or symbol, so `token = request.token` is left alone);
+//   - long strings of letters and digits with the entropy of random keys.
+//
+// Placeholders such as "", "changeme" or "${API_KEY}" are kept.
+func RedactSecrets(text string) string {
+	for _, re := range secretPatterns {
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			// Patterns with a group mask only the group, e.g. a URL's password
+			if sub := re.FindStringSubmatchIndex(match); len(sub) > 2 && sub[2] >= 0 {
+				return match[:sub[2]] + RedactedMarker + match[sub[3]:]
+			}
+			return RedactedMarker
+		})
+	}
+
+	text = secretAssignment.ReplaceAllStringFunc(text, func(match string) string {
+		sub := secretAssignment.FindStringSubmatchIndex(match)
+		name, value := match[sub[2]:sub[3]], match[sub[4]:sub[5]]
+		if !secretValue(name, value) {
+			return match
+		}
+		if quote := value[0]; quote == '"' || quote == '\'' || quote == '`' {
+			return match[:sub[4]] + string(quote) + RedactedMarker + string(quote)
+		}
+		return match[:sub[4]] + RedactedMarker
+	})
+
+	return highEntropyWord.ReplaceAllStringFunc(text, func(word string) string {
+		if looksRandom(word) {
+			return RedactedMarker
+		}
+		return word
+	})
+}
+
+// secretValue reports whether value, assigned to the secret-sounding name,
+// holds a secret rather than a placeholder, a type or an expression.
+func secretValue(name, value string) bool {
+	quoted := value[0] == '"' || value[0] == '\'' || value[0] == '`'
+	if quoted {
+		value = value[1 : len(value)-1]
+	}
+	if value == "" || value == RedactedMarker || strings.HasPrefix(value, "$") || strings.HasPrefix(value, "<") {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "changeme", "xxx", "none", "null", "nil", "true", "false", "undefined", "required", "optional":
+		return false
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return false // e.g. max_tokens = 4000
+	}
+	if quoted {
+		return len(value) >= 4
+	}
+	if strings.ContainsAny(value, "([") {
+		return false // a call or an index, e.g. os.environ["TOKEN"]
+	}
+	if strings.ToUpper(name) == name {
+		return true // .env and shell style: SECRET_KEY=...
+	}
+	// An unquoted value of a lower-case name may be code (a type, a variable)
+	return strings.ContainsFunc(value, func(r rune) bool {
+		return unicode.IsDigit(r) || (!unicode.IsLetter(r) && r != '_' && r != '.')
+	})
+}
+
+// looksRandom reports whether word, at least 24 characters long, mixes
+// letters and digits with the per-character entropy of a random key, so
+// identifiers, hex hashes of low entropy and paths are kept.
+func looksRandom(word string) bool {
+	var lower, upper, digits bool
+	counts := make(map[rune]int)
+	for _, r := range word {
+		counts[r]++
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digits = true
+		}
+	}
+	if !digits || !(lower && upper) {
+		return false
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(len(word))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy > 4.0
+}
@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultMaxTokens is the input limit assumed for embedding models not in
+// modelMaxTokens, the common limit of BERT-style local models.
+const DefaultMaxTokens = 512
+
+// modelMaxTokens holds the input limits, in tokens, of known embedding models.
+var modelMaxTokens = map[string]int{
+	"text-embedding-3-small": 8191,
+	"text-embedding-3-large": 8191,
+	"text-embedding-ada-002": 8191,
+}
+
+// ModelMaxTokens returns the input limit of an embedding model, or
+// DefaultMaxTokens if the model is unknown.
+func ModelMaxTokens(model string) int {
+	if n, ok := modelMaxTokens[model]; ok {
+		return n
+	}
+	return DefaultMaxTokens
+}
+
+// EstimateTokens approximates how many tokens a BPE tokenizer splits text
+// into: one per four letters or digits of a word and one per punctuation
+// character, with whitespace free. Code, dense with symbols, comes out well
+// above the usual four characters per token.
+func EstimateTokens(text string) int {
+	tokens, _ := scanTokens(text, -1)
+	return tokens
+}
+
+// TruncateToTokens cuts text to about maxTokens estimated tokens (see
+// EstimateTokens). The cut falls at the end of a line when that keeps at
+// least half the text, and otherwise between words, so identifiers are never
+// split unless a single word is over the limit. A non-positive maxTokens
+// leaves text unchanged.
+func TruncateToTokens(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return text
+	}
+	if _, cut := scanTokens(text, maxTokens); cut >= 0 {
+		return strings.TrimRightFunc(text[:cut], unicode.IsSpace)
+	}
+	return text
+}
+
+// scanTokens counts the estimated tokens of text. Once the count exceeds
+// limit (if non-negative) it stops and also returns the byte offset to cut
+// text at; the offset is -1 when text fits.
+func scanTokens(text string, limit int) (tokens, cut int) {
+	run := 0 // letters and digits in the current word
+	lastLine, lastSpace := -1, -1
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			run = 0
+			lastSpace = i
+			if r == '\n' {
+				lastLine = i
+			}
+			continue
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			if run%4 == 0 {
+				tokens++
+			}
+			run++
+		default:
+			tokens++
+			run = 0
+		}
+		if limit >= 0 && tokens > limit {
+			switch {
+			case lastLine > i/2:
+				return tokens, lastLine
+			case lastSpace >= 0:
+				return tokens, lastSpace
+			}
+			return tokens, i
+		}
+	}
+	return tokens, -1
+}
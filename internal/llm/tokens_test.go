@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	cases := map[string]int{
+		"":                     0,
+		"hello world":          4, // "hell" "o" "worl" "d"
+		"a.b(c)":               6,
+		"snake_case_name":      4,
+		"  \n\t":               0,
+		"x := make([]int, 10)": 11,
+	}
+	for text, want := range cases {
+		if got := EstimateTokens(text); got != want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestTruncateToTokens(t *testing.T) {
+	if got := TruncateToTokens("short text", 100); got != "short text" {
+		t.Errorf("text within the limit changed: %q", got)
+	}
+	if got := TruncateToTokens("anything at all", 0); got != "anything at all" {
+		t.Errorf("non-positive limit changed the text: %q", got)
+	}
+
+	// Cut at the end of a line when that keeps most of the text
+	lines := "func a() {}\nfunc b() {}\nfunc c() {}\n"
+	if got := TruncateToTokens(lines, 14); got != "func a() {}\nfunc b() {}" {
+		t.Errorf("line cut = %q", got)
+	}
+	// Otherwise between words, never inside an identifier
+	if got := TruncateToTokens("alpha beta gamma delta", 4); got != "alpha beta" {
+		t.Errorf("word cut = %q", got)
+	}
+	// A single word over the limit has to be split
+	if got := TruncateToTokens(strings.Repeat("x", 40), 2); got != "xxxxxxxx" {
+		t.Errorf("long word cut = %q", got)
+	}
+}
+
+func TestEmbedderTokenLimit(t *testing.T) {
+	if got := NewEmbedder(nil, "text-embedding-3-small", 0).TokenLimit(); got != 8191 {
+		t.Errorf("OpenAI model limit = %d, want 8191", got)
+	}
+	e := NewEmbedder(nil, "bge-small-en", 0)
+	if got := e.TokenLimit(); got != DefaultMaxTokens {
+		t.Errorf("unknown model limit = %d, want %d", got, DefaultMaxTokens)
+	}
+	e.MaxTokens = 128
+	if got := e.TokenLimit(); got != 128 {
+		t.Errorf("configured limit = %d, want 128", got)
+	}
+}
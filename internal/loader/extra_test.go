@@ -58,6 +58,79 @@ func TestLoadRepositoryExcludeFilePatterns(t *testing.T) {
 	}
 }
 
+func TestLoadRepositoryIncludeExcludeGlobs(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"main.go",
+		"internal/agent/agent.go",
+		"internal/agent/testdata/fixture.go",
+		"internal/util/util.go",
+		"cmd/tool/tool.go",
+	}
+	for _, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte("package x\n"), 0644)
+	}
+
+	load := func(include, exclude []string) map[string]bool {
+		cfg := DefaultConfig()
+		cfg.Include = include
+		cfg.Exclude = exclude
+		repo, err := LoadRepository(dir, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]bool{}
+		for _, f := range repo.Files {
+			got[filepath.ToSlash(f.RelativePath)] = true
+		}
+		return got
+	}
+
+	got := load([]string{"internal/**"}, nil)
+	if len(got) != 3 || got["main.go"] || got["cmd/tool/tool.go"] {
+		t.Errorf("include internal/**: %v", got)
+	}
+
+	got = load(nil, []string{"**/testdata/**"})
+	if len(got) != 4 || got["internal/agent/testdata/fixture.go"] {
+		t.Errorf("exclude **/testdata/**: %v", got)
+	}
+
+	// Exclude wins over include
+	got = load([]string{"internal/**"}, []string{"internal/util/**"})
+	if len(got) != 2 || got["internal/util/util.go"] {
+		t.Errorf("include internal/** exclude internal/util/**: %v", got)
+	}
+}
+
+func TestPrimaryLanguage(t *testing.T) {
+	repo := &Repository{Files: []FileInfo{
+		{RelativePath: "README.md", Language: "markdown"},
+		{RelativePath: "docs/a.md", Language: "markdown"},
+		{RelativePath: "docs/b.md", Language: "markdown"},
+		{RelativePath: "main.go", Language: "go"},
+		{RelativePath: "util.go", Language: "go"},
+		{RelativePath: "tools/gen.py", Language: "python"},
+	}}
+	stats := repo.LanguageStats()
+	if stats["markdown"] != 3 || stats["go"] != 2 || stats["python"] != 1 {
+		t.Errorf("LanguageStats = %v", stats)
+	}
+	// Documentation does not count as the primary language
+	if got := PrimaryLanguage(stats); got != "go" {
+		t.Errorf("PrimaryLanguage = %q, want go", got)
+	}
+	// Ties resolve alphabetically; no source files means no primary language
+	if got := PrimaryLanguage(map[string]int{"rust": 2, "java": 2}); got != "java" {
+		t.Errorf("PrimaryLanguage tie = %q, want java", got)
+	}
+	if got := PrimaryLanguage(map[string]int{"markdown": 4}); got != "" {
+		t.Errorf("PrimaryLanguage docs only = %q, want empty", got)
+	}
+}
+
 // TestLoadRepositoryExcludeDirs tests directory exclusion
 func TestLoadRepositoryExcludeDirs(t *testing.T) {
 	dir, _ := os.MkdirTemp("", "loader-exdir-*")
@@ -157,6 +230,27 @@ func TestLoadRepositoryNotADir(t *testing.T) {
 	}
 }
 
+// TestLoadRepositoryName tests overriding the repository name
+func TestLoadRepositoryName(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Name = "myservice"
+	repo, err := LoadRepository(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Name != "myservice" {
+		t.Errorf("Name = %q, want myservice", repo.Name)
+	}
+
+	for _, name := range []string{"a/b", `a\b`, ".."} {
+		cfg.Name = name
+		if _, err := LoadRepository(dir, cfg); err == nil {
+			t.Errorf("name %q: expected error", name)
+		}
+	}
+}
+
 // TestMatchGitignoreNegation tests gitignore negation pattern
 func TestMatchGitignoreNegation(t *testing.T) {
 	// Negation pattern (starts with !) should not match
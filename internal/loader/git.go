@@ -0,0 +1,53 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ChangedFiles lists the files of the git repository at rootPath that differ
+// between ref and the working tree, as slash-separated paths relative to
+// rootPath (which may be a subdirectory of the repository). Deleted files are
+// included; LoadRepository simply does not find them. It fails if git is not
+// installed, rootPath is not in a git repository or ref does not name a
+// commit.
+func ChangedFiles(rootPath, ref string) ([]string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid git ref %q", ref)
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git not found: %w", err)
+	}
+	if _, err := runGit(rootPath, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("unknown git ref %q", ref)
+	}
+	// -z keeps unusual file names unquoted
+	out, err := runGit(rootPath, "diff", "--name-only", "-z", "--relative", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, name := range strings.Split(out, "\x00") {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// runGit runs git in dir and returns its standard output.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
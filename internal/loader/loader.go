@@ -7,16 +7,18 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/util"
 )
 
 // FileInfo represents a loaded file from the repository.
 type FileInfo struct {
-	Path         string `json:"path"`
-	RelativePath string `json:"relative_path"`
-	Language     string `json:"language"`
-	Size         int64  `json:"size"`
+	Path         string    `json:"path"`
+	RelativePath string    `json:"relative_path"`
+	Language     string    `json:"language"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"mod_time"`
 }
 
 // Config holds loader configuration.
@@ -24,6 +26,27 @@ type Config struct {
 	MaxFileSize  int64    // Maximum file size in bytes (default: 1MB)
 	ExcludeDirs  []string // Directories to exclude
 	ExcludeFiles []string // File patterns to exclude
+
+	// Include and Exclude are globs over repository-relative paths, where
+	// "**" matches any number of directories (see util.MatchGlob). When
+	// Include is non-empty only matching files are loaded; Exclude wins over
+	// Include.
+	Include []string
+	Exclude []string
+
+	// Files, if non-nil, restricts loading to these repository-relative,
+	// slash-separated paths (e.g. from ChangedFiles); the other filters
+	// still apply. An empty non-nil list loads no files.
+	Files []string
+
+	// Languages maps lower-case file extensions (".mjs") to the language
+	// their files are indexed as, over the built-in mapping (see
+	// util.LanguageForPath).
+	Languages map[string]string
+
+	// Name overrides the repository name, which otherwise is the base name
+	// of its directory. It must be usable as a file name.
+	Name string
 }
 
 // DefaultConfig returns the default loader configuration.
@@ -47,6 +70,33 @@ type Repository struct {
 	Files    []FileInfo
 }
 
+// LanguageStats counts the repository's files per language.
+func (r *Repository) LanguageStats() map[string]int {
+	stats := make(map[string]int)
+	for _, f := range r.Files {
+		if f.Language != "" {
+			stats[f.Language]++
+		}
+	}
+	return stats
+}
+
+// PrimaryLanguage returns the source language with the most files, ignoring
+// documentation and configuration files; ties go to the alphabetically first
+// language. Returns "" when the repository has no source files.
+func PrimaryLanguage(stats map[string]int) string {
+	primary := ""
+	for lang, n := range stats {
+		if util.IsContextLanguage(lang) {
+			continue
+		}
+		if primary == "" || n > stats[primary] || (n == stats[primary] && lang < primary) {
+			primary = lang
+		}
+	}
+	return primary
+}
+
 // LoadRepository walks a repository directory and returns all supported source files.
 func LoadRepository(rootPath string, cfg Config) (*Repository, error) {
 	absRoot, err := filepath.Abs(rootPath)
@@ -66,10 +116,24 @@ func LoadRepository(rootPath string, cfg Config) (*Repository, error) {
 		RootPath: absRoot,
 		Name:     filepath.Base(absRoot),
 	}
+	if cfg.Name != "" {
+		if cfg.Name == "." || cfg.Name == ".." || strings.ContainsAny(cfg.Name, `/\`) {
+			return nil, fmt.Errorf("invalid repository name %q", cfg.Name)
+		}
+		repo.Name = cfg.Name
+	}
 
 	// Load .gitignore patterns
 	gitignorePatterns := loadGitignore(absRoot)
 
+	var onlyFiles map[string]bool
+	if cfg.Files != nil {
+		onlyFiles = make(map[string]bool, len(cfg.Files))
+		for _, f := range cfg.Files {
+			onlyFiles[filepath.ToSlash(filepath.Clean(f))] = true
+		}
+	}
+
 	excludeDirSet := make(map[string]bool, len(cfg.ExcludeDirs))
 	for _, d := range cfg.ExcludeDirs {
 		excludeDirSet[d] = true
@@ -91,12 +155,17 @@ func LoadRepository(rootPath string, cfg Config) (*Repository, error) {
 
 		relPath, _ := filepath.Rel(absRoot, path)
 
+		slashPath := filepath.ToSlash(relPath)
+
 		// Skip excluded directories
 		if d.IsDir() {
 			dirName := d.Name()
 			if excludeDirSet[dirName] {
 				return filepath.SkipDir
 			}
+			if path != absRoot && matchAnyGlob(cfg.Exclude, slashPath) {
+				return filepath.SkipDir
+			}
 			// Check gitignore for directories — only SkipDir if there are
 			// NO negation patterns (negation patterns require entering the
 			// directory to check individual files)
@@ -111,7 +180,20 @@ func LoadRepository(rootPath string, cfg Config) (*Repository, error) {
 		}
 
 		// Check file support
-		if !util.IsSupportedFile(path) {
+		language := util.LanguageForPath(path, cfg.Languages)
+		if language == "" {
+			return nil
+		}
+
+		if onlyFiles != nil && !onlyFiles[slashPath] {
+			return nil
+		}
+
+		// Check include/exclude globs
+		if matchAnyGlob(cfg.Exclude, slashPath) {
+			return nil
+		}
+		if len(cfg.Include) > 0 && !matchAnyGlob(cfg.Include, slashPath) {
 			return nil
 		}
 
@@ -140,8 +222,9 @@ func LoadRepository(rootPath string, cfg Config) (*Repository, error) {
 		repo.Files = append(repo.Files, FileInfo{
 			Path:         path,
 			RelativePath: relPath,
-			Language:     util.GetLanguageFromPath(path),
+			Language:     language,
 			Size:         fi.Size(),
+			ModTime:      fi.ModTime(),
 		})
 		return nil
 	})
@@ -161,6 +244,16 @@ func ReadFileContent(path string) (string, error) {
 	return string(data), nil
 }
 
+// matchAnyGlob reports whether relPath matches any of the globs.
+func matchAnyGlob(globs []string, relPath string) bool {
+	for _, g := range globs {
+		if util.MatchGlob(g, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
 // loadGitignore reads .gitignore patterns from the repository root.
 func loadGitignore(rootPath string) []string {
 	f, err := os.Open(filepath.Join(rootPath, ".gitignore"))
@@ -2,7 +2,9 @@ package loader
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -285,3 +287,78 @@ func TestLoadRepositoryDotDir(t *testing.T) {
 		t.Error(".hidden/secret.go should be loaded (dot dirs are no longer blanket-excluded)")
 	}
 }
+
+// gitRepo creates a git repository in a temporary directory with one commit
+// of files, skipping the test when git is not installed.
+func gitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir := gitRepo(t, map[string]string{"a.go": "package a\n", "pkg/b.go": "package b\n", "c.go": "package c\n"})
+	os.WriteFile(filepath.Join(dir, "pkg", "b.go"), []byte("package b // changed\n"), 0644)
+	os.Remove(filepath.Join(dir, "c.go"))
+
+	files, err := ChangedFiles(dir, "HEAD")
+	if err != nil {
+		t.Fatalf("ChangedFiles: %v", err)
+	}
+	if strings.Join(files, ",") != "c.go,pkg/b.go" {
+		t.Errorf("changed files = %v, want c.go and pkg/b.go", files)
+	}
+
+	// Paths are relative to a subdirectory root
+	if files, err := ChangedFiles(filepath.Join(dir, "pkg"), "HEAD"); err != nil || strings.Join(files, ",") != "b.go" {
+		t.Errorf("ChangedFiles(pkg) = %v, %v; want b.go", files, err)
+	}
+
+	for _, ref := range []string{"no-such-branch", "--output=x", ""} {
+		if _, err := ChangedFiles(dir, ref); err == nil {
+			t.Errorf("ChangedFiles(%q) should fail", ref)
+		}
+	}
+	if _, err := ChangedFiles(t.TempDir(), "HEAD"); err == nil {
+		t.Error("ChangedFiles outside a git repository should fail")
+	}
+}
+
+func TestLoadRepositoryFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "pkg"), 0755)
+	for _, name := range []string{"a.go", "pkg/b.go", "pkg/c.go"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("package x\n"), 0644)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Files = []string{"pkg/b.go", "gone.go"}
+	repo, err := LoadRepository(dir, cfg)
+	if err != nil {
+		t.Fatalf("LoadRepository: %v", err)
+	}
+	if len(repo.Files) != 1 || filepath.ToSlash(repo.Files[0].RelativePath) != "pkg/b.go" {
+		t.Errorf("files = %+v, want only pkg/b.go", repo.Files)
+	}
+
+	cfg.Files = []string{}
+	if repo, _ := LoadRepository(dir, cfg); len(repo.Files) != 0 {
+		t.Errorf("an empty file list loaded %d files", len(repo.Files))
+	}
+}
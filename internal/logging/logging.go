@@ -0,0 +1,104 @@
+// Package logging provides the leveled, structured logger shared by the
+// fastcode packages. Logs go to stderr as key=value lines and only records
+// at or above the configured level (default: warn) are written, so normal
+// runs stay quiet and stdout is left to command output.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// EnvLevel is the environment variable selecting the log level.
+const EnvLevel = "FASTCODE_LOG_LEVEL"
+
+// DefaultLevel is the level used when none is configured.
+const DefaultLevel = slog.LevelWarn
+
+var (
+	level slog.LevelVar
+	base  atomic.Pointer[slog.Logger]
+)
+
+func init() {
+	lvl, err := LevelFromEnv()
+	if err != nil {
+		lvl = DefaultLevel
+	}
+	level.Set(lvl)
+	SetOutput(os.Stderr)
+}
+
+// ParseLevel converts a level name ("debug", "info", "warn" or "error") to a
+// slog.Level; "" selects DefaultLevel.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "":
+		return DefaultLevel, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", name)
+}
+
+// LevelFromEnv returns the level named by FASTCODE_LOG_LEVEL, or
+// DefaultLevel when it is unset.
+func LevelFromEnv() (slog.Level, error) {
+	lvl, err := ParseLevel(os.Getenv(EnvLevel))
+	if err != nil {
+		return DefaultLevel, fmt.Errorf("%s: %w", EnvLevel, err)
+	}
+	return lvl, nil
+}
+
+// SetLevel sets the minimum level of records that are written.
+func SetLevel(lvl slog.Level) {
+	level.Set(lvl)
+}
+
+// SetOutput sends log records to w.
+func SetOutput(w io.Writer) {
+	base.Store(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: &level})))
+}
+
+// Logger writes records tagged with the component that produced them.
+type Logger struct {
+	component string
+}
+
+// New returns a logger for component, e.g. "agent" or "engine".
+func New(component string) *Logger {
+	return &Logger{component: component}
+}
+
+// Debug logs step-by-step detail, e.g. each retrieval round.
+func (l *Logger) Debug(msg string, args ...any) { l.log(slog.LevelDebug, msg, args) }
+
+// Info logs progress worth seeing on a verbose run.
+func (l *Logger) Info(msg string, args ...any) { l.log(slog.LevelInfo, msg, args) }
+
+// Warn logs a problem that was worked around, e.g. a failed cache load.
+func (l *Logger) Warn(msg string, args ...any) { l.log(slog.LevelWarn, msg, args) }
+
+// Error logs a failure.
+func (l *Logger) Error(msg string, args ...any) { l.log(slog.LevelError, msg, args) }
+
+func (l *Logger) log(lvl slog.Level, msg string, args []any) {
+	logger := base.Load()
+	ctx := context.Background()
+	if !logger.Enabled(ctx, lvl) {
+		return
+	}
+	logger.Log(ctx, lvl, msg, append([]any{"component", l.component}, args...)...)
+}
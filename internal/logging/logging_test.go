@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]slog.Level{
+		"":      DefaultLevel,
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	for name, want := range cases {
+		got, err := ParseLevel(name)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestLevelFromEnv(t *testing.T) {
+	t.Setenv(EnvLevel, "debug")
+	if lvl, err := LevelFromEnv(); err != nil || lvl != slog.LevelDebug {
+		t.Errorf("LevelFromEnv = %v, %v; want debug", lvl, err)
+	}
+	t.Setenv(EnvLevel, "chatty")
+	if lvl, err := LevelFromEnv(); err == nil || lvl != DefaultLevel {
+		t.Errorf("LevelFromEnv = %v, %v; want an error and the default level", lvl, err)
+	}
+}
+
+func TestLoggerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+	defer SetLevel(level.Level())
+
+	logger := New("engine")
+	SetLevel(slog.LevelWarn)
+	logger.Info("loaded from cache", "elements", 3)
+	logger.Warn("cache save failed", "err", "disk full")
+	out := buf.String()
+	if strings.Contains(out, "loaded from cache") {
+		t.Errorf("info record written at warn level: %s", out)
+	}
+	if !strings.Contains(out, "level=WARN") || !strings.Contains(out, "component=engine") ||
+		!strings.Contains(out, `msg="cache save failed"`) || !strings.Contains(out, `err="disk full"`) {
+		t.Errorf("warn record = %q", out)
+	}
+
+	buf.Reset()
+	SetLevel(slog.LevelDebug)
+	logger.Debug("round", "n", 2)
+	if !strings.Contains(buf.String(), "level=DEBUG") || !strings.Contains(buf.String(), "n=2") {
+		t.Errorf("debug record = %q", buf.String())
+	}
+}
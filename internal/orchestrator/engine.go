@@ -1,17 +1,31 @@
 package orchestrator
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/agent"
 	"github.com/duyhunghd6/fastcode-cli/internal/cache"
+	"github.com/duyhunghd6/fastcode-cli/internal/config"
+	"github.com/duyhunghd6/fastcode-cli/internal/diff"
 	"github.com/duyhunghd6/fastcode-cli/internal/graph"
 	"github.com/duyhunghd6/fastcode-cli/internal/index"
 	"github.com/duyhunghd6/fastcode-cli/internal/llm"
 	"github.com/duyhunghd6/fastcode-cli/internal/loader"
+	"github.com/duyhunghd6/fastcode-cli/internal/logging"
+	"github.com/duyhunghd6/fastcode-cli/internal/parser"
+	"github.com/duyhunghd6/fastcode-cli/internal/session"
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
 )
 
@@ -20,20 +34,144 @@ type Engine struct {
 	client   *llm.Client
 	embedder *llm.Embedder
 	cache    *cache.IndexCache
+	cacheDir string
+	config   Config
+
+	// mu guards the index state below: indexing holds the write lock while
+	// rebuilding it and lookups and queries hold the read lock, so an Engine
+	// can serve concurrent requests.
+	mu       sync.RWMutex
 	graphs   *graph.CodeGraphs
 	hybrid   *index.HybridRetriever
 	elements []types.CodeElement
 	repoName string
-	repoPath string // Absolute path to the repo root
-	cacheDir string
+	repoPath string    // Absolute path to the repo root
+	language string    // Primary source language of the repo
+	indexed  time.Time // When the loaded index was built (zero if unknown)
 }
 
+var logger = logging.New("engine")
+
 // Config holds engine configuration.
 type Config struct {
 	CacheDir       string
 	EmbeddingModel string
 	BatchSize      int
 	NoEmbeddings   bool // If true, skip embedding generation (BM25 only)
+
+	// ExplicitEmbeddingModel marks EmbeddingModel as given on the command
+	// line, so the embedding_model of a repository's .fastcode.yaml does not
+	// replace it.
+	ExplicitEmbeddingModel bool
+
+	// EmbeddingDimensions, if positive, reduces embeddings to this many
+	// dimensions to shrink the cache and speed up vector search. It is
+	// passed to the model as "dimensions" (OpenAI text-embedding-3) unless
+	// TruncateEmbeddings is set, in which case vectors are truncated and
+	// renormalized client-side. Caches embedded at another size are rebuilt.
+	EmbeddingDimensions int
+	TruncateEmbeddings  bool
+
+	// EmbeddingMaxTokens, if positive, is the embedding model's input
+	// limit in tokens, to which element texts are truncated before
+	// embedding; 0 uses the limit known for the model (see
+	// llm.ModelMaxTokens).
+	EmbeddingMaxTokens int
+
+	// NoRedactSecrets sends code to the model as is, without masking what
+	// looks like a secret (see llm.RedactSecrets) in the code and search
+	// excerpts the agent reads and the answer context. Redaction is on
+	// unless FASTCODE_REDACT_SECRETS is false.
+	NoRedactSecrets bool
+
+	// MaxAnswerTokens, if positive, caps the tokens generated for answers
+	// whose QueryOptions set no cap of their own (default:
+	// FASTCODE_MAX_ANSWER_TOKENS, else the verbosity level's limit).
+	MaxAnswerTokens int
+
+	// EmbedMaxElementLines, if positive, embeds only elements of at most
+	// this many lines; larger ones (whole files, big classes) are indexed
+	// for keyword search alone, to save embedding cost. Like
+	// MaxElementsPerFile it bypasses the cache.
+	EmbedMaxElementLines int
+
+	// EmbeddingBaseURL, if set, overrides the endpoint embeddings are
+	// requested from (EMBEDDING_BASE_URL, else BASE_URL), so a local
+	// embedding server can be paired with a hosted chat model.
+	EmbeddingBaseURL string
+
+	// BM25K1 and BM25B tune keyword search (see index.NewBM25); zero
+	// selects index.DefaultBM25K1 (1.5) and index.DefaultBM25B (0.75).
+	BM25K1 float64
+	BM25B  float64
+
+	// BM25FieldBoost weighs keyword matches on element names and signatures
+	// (see index.BM25.SetFieldBoost); zero selects index.DefaultFieldBoost
+	// (1, no boost).
+	BM25FieldBoost float64
+
+	// IncludeFileDependencies makes the agent add the resolved imports of
+	// retrieved files to the answer context.
+	IncludeFileDependencies bool
+
+	// GraphNeighbors makes the agent add the direct graph neighbors of the
+	// most relevant gathered elements after each round's tool calls.
+	GraphNeighbors bool
+
+	// Progress, if set, receives IndexProgress events while a repository is
+	// indexed from scratch.
+	Progress func(IndexProgress)
+
+	// SessionDir is where conversation sessions are stored.
+	SessionDir string
+
+	// Include and Exclude restrict which files are indexed, as repository-
+	// relative globs (e.g. "internal/**", "testdata/**"). Setting either
+	// bypasses the cache so the filters always take effect.
+	Include []string
+	Exclude []string
+
+	// JSArrowFunctions indexes JS/TS functions assigned to variables and
+	// object keys (React hooks, exported handlers) as function elements.
+	// Like Include/Exclude it bypasses the cache. It is on by default for
+	// repositories whose primary language is JavaScript or TypeScript,
+	// unless their .fastcode.yaml sets js_arrow_functions.
+	JSArrowFunctions bool
+
+	// MarkdownSections indexes the "#" and "##" sections of Markdown files
+	// as searchable "section" elements. Like JSArrowFunctions it bypasses
+	// the cache.
+	MarkdownSections bool
+
+	// MaxElementsPerFile caps the elements indexed from a single file (0
+	// means index.DefaultMaxElementsPerFile, negative means no limit).
+	// Setting it bypasses the cache.
+	MaxElementsPerFile int
+
+	// DedupElements collapses function and class elements with identical
+	// code into one that lists the other copies (see index.Indexer.Dedup).
+	// Like MarkdownSections it bypasses the cache.
+	DedupElements bool
+
+	// Since, if set, indexes only the files changed since this git ref
+	// (e.g. "origin/main") plus the files of their direct graph neighbors in
+	// the cached index, for fast CI runs focused on a diff. All files are
+	// indexed if git cannot list the changes. It bypasses the cache, and the
+	// partial index is not cached.
+	Since string
+
+	// RepoName overrides the repository name derived from its directory,
+	// which names the cache file and is recorded on every element. Useful
+	// for temporary or CI checkouts whose directory name is meaningless.
+	RepoName string
+
+	// repo holds the settings of the .fastcode.yaml of the repository this
+	// configuration was resolved for (see Engine.repoConfig), nil otherwise.
+	repo *config.RepoConfig
+
+	// jsArrowDefault records that JSArrowFunctions was turned on for the
+	// repository's primary language (see withLanguageDefaults).
+	jsArrowDefault bool
 }
 
 // DefaultConfig returns the default engine configuration.
@@ -43,20 +181,32 @@ func DefaultConfig() Config {
 	if embeddingModel == "" {
 		embeddingModel = "text-embedding-3-small"
 	}
+	dimensions, _ := strconv.Atoi(os.Getenv("EMBEDDING_DIMENSIONS"))
+	maxTokens, _ := strconv.Atoi(os.Getenv("EMBEDDING_MAX_TOKENS"))
+	answerTokens, _ := strconv.Atoi(os.Getenv("FASTCODE_MAX_ANSWER_TOKENS"))
+	redact, err := strconv.ParseBool(os.Getenv("FASTCODE_REDACT_SECRETS"))
 	return Config{
-		CacheDir:       filepath.Join(home, ".fastcode", "cache"),
-		EmbeddingModel: embeddingModel,
-		BatchSize:      32,
-		NoEmbeddings:   false,
+		CacheDir:            filepath.Join(home, ".fastcode", "cache"),
+		SessionDir:          filepath.Join(home, ".fastcode", "sessions"),
+		EmbeddingModel:      embeddingModel,
+		EmbeddingDimensions: max(dimensions, 0),
+		EmbeddingMaxTokens:  max(maxTokens, 0),
+		BatchSize:           32,
+		NoEmbeddings:        false,
+		NoRedactSecrets:     err == nil && !redact,
+		MaxAnswerTokens:     max(answerTokens, 0),
 	}
 }
 
 // NewEngine creates a new FastCode engine.
 func NewEngine(cfg Config) *Engine {
 	client := llm.NewClient()
+	if cfg.EmbeddingBaseURL != "" {
+		client.EmbeddingBaseURL = cfg.EmbeddingBaseURL
+	}
 	var embedder *llm.Embedder
 	if !cfg.NoEmbeddings && client.APIKey != "" {
-		embedder = llm.NewEmbedder(client, cfg.EmbeddingModel, cfg.BatchSize)
+		embedder = newEmbedder(client, cfg)
 	}
 
 	return &Engine{
@@ -64,9 +214,92 @@ func NewEngine(cfg Config) *Engine {
 		embedder: embedder,
 		cache:    cache.NewIndexCache(cfg.CacheDir),
 		cacheDir: cfg.CacheDir,
+		config:   cfg,
+	}
+}
+
+// IndexPhase names a stage of indexing.
+type IndexPhase string
+
+const (
+	PhaseLoaded    IndexPhase = "loaded"    // Done = Total = files found
+	PhaseParsing   IndexPhase = "parsing"   // Done of Total files parsed
+	PhaseGraphs    IndexPhase = "graphs"    // code graphs built
+	PhaseEmbedding IndexPhase = "embedding" // Done of Total batches embedded
+)
+
+// IndexProgress is a progress event reported through Config.Progress.
+type IndexProgress struct {
+	Phase    IndexPhase `json:"phase"`
+	Done     int        `json:"done"`
+	Total    int        `json:"total"`
+	Elements int        `json:"elements"` // elements parsed so far
+}
+
+// newEmbedder creates an embedder for the configured model and dimensions.
+func newEmbedder(client *llm.Client, cfg Config) *llm.Embedder {
+	embedder := llm.NewEmbedder(client, cfg.EmbeddingModel, cfg.BatchSize)
+	embedder.Dimensions = cfg.EmbeddingDimensions
+	embedder.Truncate = cfg.TruncateEmbeddings
+	embedder.MaxTokens = cfg.EmbeddingMaxTokens
+	if cfg.Progress != nil {
+		embedder.Progress = embeddingProgress(cfg.Progress, cfg.BatchSize)
+	}
+	return embedder
+}
+
+// useEmbeddingModel switches the embedder, if any, to the embedding model of
+// cfg, which a repository's .fastcode.yaml may select, so the repository's
+// vectors and query vectors come from the same model.
+func (e *Engine) useEmbeddingModel(cfg Config) {
+	if e.embedder == nil || e.embedder.Model() == cfg.EmbeddingModel {
+		return
+	}
+	logger.Info("using repository embedding model", "model", cfg.EmbeddingModel)
+	e.embedder = newEmbedder(e.client, cfg)
+}
+
+// embeddingProgress adapts an IndexProgress callback to the embedder's
+// per-text progress, reporting whole batches.
+func embeddingProgress(report func(IndexProgress), batchSize int) llm.ProgressFunc {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	return func(done, total int) {
+		report(IndexProgress{
+			Phase:    PhaseEmbedding,
+			Done:     (done + batchSize - 1) / batchSize,
+			Total:    (total + batchSize - 1) / batchSize,
+			Elements: total,
+		})
+	}
+}
+
+// progress reports p if a progress callback is configured.
+func (e *Engine) progress(p IndexProgress) {
+	if e.config.Progress != nil {
+		e.config.Progress(p)
 	}
 }
 
+// loaderConfig returns the loader configuration with the file filters and
+// repository name of c, and the repository's directory exclusions, file
+// size limit and extension languages.
+func (c Config) loaderConfig() loader.Config {
+	cfg := loader.DefaultConfig()
+	cfg.Include = c.Include
+	cfg.Exclude = c.Exclude
+	cfg.Name = c.RepoName
+	if c.repo != nil {
+		cfg.ExcludeDirs = append(cfg.ExcludeDirs, c.repo.ExcludeDirs...)
+		cfg.Languages = c.repo.Languages
+		if c.repo.MaxFileSize > 0 {
+			cfg.MaxFileSize = c.repo.MaxFileSize
+		}
+	}
+	return cfg
+}
+
 // IndexResult holds the result of an indexing operation.
 type IndexResult struct {
 	RepoName      string         `json:"repo_name"`
@@ -74,40 +307,99 @@ type IndexResult struct {
 	TotalElements int            `json:"total_elements"`
 	GraphStats    map[string]any `json:"graph_stats"`
 	Cached        bool           `json:"cached"`
+
+	// Languages counts files per language; PrimaryLanguage is the source
+	// language with the most files. It selects language defaults for
+	// indexing (see Config.JSArrowFunctions) and is a hint in the agent's
+	// prompts.
+	Languages       map[string]int `json:"languages"`
+	PrimaryLanguage string         `json:"primary_language,omitempty"`
+
+	// ParseErrors counts the files with syntax errors, listed in
+	// ParseErrorFiles; they are indexed only as far as they could be parsed.
+	ParseErrors     int      `json:"parse_errors"`
+	ParseErrorFiles []string `json:"parse_error_files,omitempty"`
+
+	// TruncatedFiles lists the files with more elements than the per-file
+	// limit (see Config.MaxElementsPerFile); only their most complex
+	// elements are indexed.
+	TruncatedFiles []string `json:"truncated_files,omitempty"`
+
+	// DuplicateElements counts the elements collapsed into an identical
+	// copy when Config.DedupElements is set (not reported for workspaces).
+	DuplicateElements int `json:"duplicate_elements,omitempty"`
+
+	// Embedded counts the elements with a vector; EmbedSkipped those left
+	// to keyword search for spanning more than Config.EmbedMaxElementLines.
+	Embedded     int `json:"embedded"`
+	EmbedSkipped int `json:"embed_skipped,omitempty"`
+
+	// Repos names the repositories of a workspace (see IndexWorkspace),
+	// whose ParseErrorFiles and TruncatedFiles are prefixed with the
+	// repository name.
+	Repos []string `json:"repos,omitempty"`
 }
 
 // Index parses, indexes, and optionally embeds a repository.
 func (e *Engine) Index(repoPath string, forceReindex bool) (*IndexResult, error) {
+	return e.IndexContext(context.Background(), repoPath, forceReindex)
+}
+
+// IndexContext is like Index but can be cancelled between embedding batches,
+// in which case nothing is cached and the context's error is returned.
+func (e *Engine) IndexContext(ctx context.Context, repoPath string, forceReindex bool) (*IndexResult, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	// Load repository
-	loaderCfg := loader.DefaultConfig()
-	repo, err := loader.LoadRepository(repoPath, loaderCfg)
+	repo, cfg, err := e.loadRepository(repoPath)
 	if err != nil {
 		return nil, fmt.Errorf("load repository: %w", err)
 	}
+	e.useEmbeddingModel(cfg)
 	e.repoName = repo.Name
 	e.repoPath, _ = filepath.Abs(repoPath)
-	log.Printf("[engine] loaded %d files from %s", len(repo.Files), repo.Name)
+	languages := repo.LanguageStats()
+	e.language = loader.PrimaryLanguage(languages)
+	logger.Info("loaded repository", "repo", repo.Name, "files", len(repo.Files), "primary_language", e.language)
 
 	// Check cache
-	if !forceReindex && e.cache.Exists(repo.Name) {
-		cached, err := e.cache.Load(repo.Name)
+	if !forceReindex && e.cacheUsable() && e.cache.Exists(repo.Name) {
+		cached, err := e.loadCache(repo.Name, cfg)
 		if err == nil {
-			log.Printf("[engine] loaded %d elements from cache", len(cached.Elements))
+			logger.Info("loaded index from cache", "repo", repo.Name, "elements", len(cached.Elements))
 			e.elements = cached.Elements
+			e.indexed = cached.IndexedAt
 			e.rebuildFromCache(cached)
+			embedded, skipped := e.embeddingCounts(cached, cfg)
 			return &IndexResult{
 				RepoName:      repo.Name,
 				TotalFiles:    len(repo.Files),
 				TotalElements: len(e.elements),
 				GraphStats:    e.graphs.Stats(),
 				Cached:        true,
+
+				Languages:       languages,
+				PrimaryLanguage: e.language,
+
+				ParseErrors:     len(cached.ParseErrorFiles),
+				ParseErrorFiles: cached.ParseErrorFiles,
+				TruncatedFiles:  cached.TruncatedFiles,
+
+				Embedded:     embedded,
+				EmbedSkipped: skipped,
 			}, nil
 		}
-		log.Printf("[engine] cache load failed, re-indexing: %v", err)
+		logger.Warn("cache load failed, re-indexing", "repo", repo.Name, "err", err)
 	}
 
+	e.progress(IndexProgress{Phase: PhaseLoaded, Done: len(repo.Files), Total: len(repo.Files)})
+
 	// Parse and index
-	indexer := index.NewIndexer(repo.Name)
+	indexer := cfg.newIndexer(repo.Name)
+	indexer.Progress = func(done, total, elements int) {
+		e.progress(IndexProgress{Phase: PhaseParsing, Done: done, Total: total, Elements: elements})
+	}
 	elements, err := indexer.IndexRepository(repo)
 	if err != nil {
 		return nil, fmt.Errorf("index repository: %w", err)
@@ -117,75 +409,489 @@ func (e *Engine) Index(repoPath string, forceReindex bool) (*IndexResult, error)
 	// Build graphs
 	e.graphs = graph.NewCodeGraphs()
 	e.graphs.BuildGraphs(elements)
+	e.progress(IndexProgress{Phase: PhaseGraphs, Done: 1, Total: 1, Elements: len(elements)})
 
 	// Build hybrid search index
 	vs := index.NewVectorStore()
-	bm := index.NewBM25(1.5, 0.75)
+	bm := e.newBM25()
 	e.hybrid = index.NewHybridRetriever(vs, bm)
+	e.hybrid.EmbedMaxLines = cfg.EmbedMaxElementLines
 
-	err = e.hybrid.IndexElements(elements, e.embedder)
+	err = e.hybrid.IndexElementsWithChunks(ctx, elements, indexer.Chunks, e.embedder)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("indexing cancelled: %w", ctx.Err())
+	}
 	if err != nil {
-		log.Printf("[engine] embedding failed (BM25 only): %v", err)
+		logger.Warn("embedding failed, using BM25 only", "err", err)
 	}
 
 	// Cache results
+	saved := e.saveIndex(repo.Name, cfg, indexer, vs)
+	e.indexed = saved.IndexedAt
+	embedded, skipped := e.embeddingCounts(saved, cfg)
+
+	return &IndexResult{
+		RepoName:      repo.Name,
+		TotalFiles:    len(repo.Files),
+		TotalElements: len(elements),
+		GraphStats:    e.graphs.Stats(),
+		Cached:        false,
+
+		Languages:       languages,
+		PrimaryLanguage: e.language,
+
+		ParseErrors:     len(indexer.ParseErrorFiles),
+		ParseErrorFiles: indexer.ParseErrorFiles,
+		TruncatedFiles:  indexer.TruncatedFiles,
+
+		DuplicateElements: indexer.Duplicates,
+
+		Embedded:     embedded,
+		EmbedSkipped: skipped,
+	}, nil
+}
+
+// embeddingCounts returns how many elements of an index have a vector and,
+// when embedding, how many were left unembedded under
+// cfg.EmbedMaxElementLines.
+func (e *Engine) embeddingCounts(data *cache.CachedIndex, cfg Config) (embedded, skipped int) {
+	for i := range data.Elements {
+		if _, ok := data.Vectors[data.Elements[i].ID]; ok {
+			embedded++
+		} else if e.embedder != nil && index.EmbedSkipped(&data.Elements[i], cfg.EmbedMaxElementLines) {
+			skipped++
+		}
+	}
+	return embedded, skipped
+}
+
+// IsIndexed reports whether the repository at repoPath has a cached index
+// under the name indexing it would use, so querying it would not index it
+// first.
+func (e *Engine) IsIndexed(repoPath string) bool {
+	return e.cache.Exists(e.repoNameFor(repoPath))
+}
+
+// repoNameFor returns the name the repository at repoPath is indexed and
+// cached under: Config.RepoName, or else its directory name.
+func (e *Engine) repoNameFor(repoPath string) string {
+	if e.config.RepoName != "" {
+		return e.config.RepoName
+	}
+	abs, err := filepath.Abs(repoPath)
+	if err != nil {
+		abs = repoPath
+	}
+	return filepath.Base(abs)
+}
+
+// cacheUsable reports whether cached indexes can be loaded: a filtered or
+// differently parsed index may differ from the cached one. Settings from a
+// repository's .fastcode.yaml do not count, since loadCache checks that the
+// cache was built under the same ones.
+func (e *Engine) cacheUsable() bool {
+	return len(e.config.Include) == 0 && len(e.config.Exclude) == 0 && !e.config.JSArrowFunctions && !e.config.MarkdownSections &&
+		e.config.MaxElementsPerFile == 0 && e.config.EmbedMaxElementLines == 0 && !e.config.DedupElements && e.config.Since == ""
+}
+
+// loadCache loads the cached index of repoName, rejecting one built under
+// other .fastcode.yaml settings than cfg's, or whose vectors were embedded by
+// another model or at another size than cfg selects, since they could not be
+// compared with query vectors.
+func (e *Engine) loadCache(repoName string, cfg Config) (*cache.CachedIndex, error) {
+	cached, err := e.cache.Load(repoName)
+	if err != nil {
+		return nil, err
+	}
+	if hash := cfg.repoConfigHash(); cached.RepoConfigHash != hash {
+		return nil, fmt.Errorf("repository settings in %s changed since the index was built", config.RepoConfigFile)
+	}
+	if e.embedder == nil || len(cached.Vectors) == 0 {
+		return cached, nil
+	}
+	if cached.EmbeddingModel != "" && cached.EmbeddingModel != cfg.EmbeddingModel {
+		return nil, fmt.Errorf("cached embeddings are from %s, configured %s", cached.EmbeddingModel, cfg.EmbeddingModel)
+	}
+	if cached.EmbeddingDimensions != cfg.EmbeddingDimensions {
+		return nil, fmt.Errorf("cached embeddings were requested with %d dimensions, configured %d (0 is the model's size)",
+			cached.EmbeddingDimensions, cfg.EmbeddingDimensions)
+	}
+	return cached, nil
+}
+
+// saveIndex caches the index an indexer just built of a repository under
+// cfg, with the vectors stored for its elements, and returns the cached data.
+// Indexes built while cacheUsable is false are returned but not cached.
+func (e *Engine) saveIndex(repoName string, cfg Config, indexer *index.Indexer, vs *index.VectorStore) *cache.CachedIndex {
+	elements := indexer.Elements
 	cachedData := &cache.CachedIndex{
-		RepoName: repo.Name,
-		Elements: elements,
-		Vectors:  make(map[string][]float32),
+		RepoName:  repoName,
+		IndexedAt: time.Now(),
+		Elements:  elements,
+		Vectors:   make(map[string][]float32),
+
+		ChunkVectors:    make(map[string][][]float32),
+		ParseErrorFiles: indexer.ParseErrorFiles,
+		TruncatedFiles:  indexer.TruncatedFiles,
+
+		EmbeddingModel:      cfg.EmbeddingModel,
+		EmbeddingDimensions: cfg.EmbeddingDimensions,
+		RepoConfigHash:      cfg.repoConfigHash(),
 	}
 	// Store vectors if available
 	for _, elem := range elements {
 		if vec := vs.Get(elem.ID); vec != nil {
 			cachedData.Vectors[elem.ID] = vec
 		}
+		if chunks := vs.Chunks(elem.ID); chunks != nil {
+			cachedData.ChunkVectors[elem.ID] = chunks
+		}
 	}
-	if err := e.cache.Save(repo.Name, cachedData); err != nil {
-		log.Printf("[engine] cache save failed: %v", err)
+	// An index of changed files alone, or one filtered or parsed by
+	// command-line settings, must not replace the full one
+	if !e.cacheUsable() {
+		return cachedData
 	}
-
-	return &IndexResult{
-		RepoName:      repo.Name,
-		TotalFiles:    len(repo.Files),
-		TotalElements: len(elements),
-		GraphStats:    e.graphs.Stats(),
-		Cached:        false,
-	}, nil
+	if err := e.cache.Save(repoName, cachedData); err != nil {
+		logger.Warn("cache save failed", "repo", repoName, "err", err)
+	}
+	return cachedData
 }
 
 // QueryResult holds the result of a query operation.
 type QueryResult struct {
-	Answer     string `json:"answer"`
-	Confidence int    `json:"confidence"`
-	Rounds     int    `json:"rounds"`
-	StopReason string `json:"stop_reason"`
-	Elements   int    `json:"elements_used"`
+	Answer     string   `json:"answer"`
+	Confidence int      `json:"confidence"`
+	Rounds     int      `json:"rounds"`
+	StopReason string   `json:"stop_reason"`
+	Elements   int      `json:"elements_used"`
+	ElementIDs []string `json:"element_ids,omitempty"`
+
+	// Mode is QueryModeAgent when the iterative LLM agent answered and
+	// QueryModeDirect when no API key is configured and the answer is a plain
+	// hybrid-search listing. Warning explains a degraded mode to the caller.
+	Mode    string `json:"mode"`
+	Warning string `json:"warning,omitempty"`
+
+	// Metadata explains the agent's behaviour: query_complexity, query_type,
+	// tokens_used and adaptive_params. Empty for direct (non-LLM) queries.
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	// Trace lists the agent's retrieval rounds when QueryOptions.Trace is
+	// set. Empty for direct queries.
+	Trace []agent.RoundTrace `json:"trace,omitempty"`
+
+	// Code holds the elements the answer was based on, with their code,
+	// in ElementIDs order when QueryOptions.IncludeCode is set.
+	Code []RetrievedCode `json:"code,omitempty"`
+}
+
+// RetrievedCode is an element an answer was based on, with its code.
+type RetrievedCode struct {
+	ID        string `json:"id"`
+	RepoName  string `json:"repo_name,omitempty"`
+	Path      string `json:"path"`
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Language  string `json:"language"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Code      string `json:"code"`
 }
 
-// Query performs a full query pipeline: search → agent → answer.
+// retrievedCode returns the elements with their code for QueryResult.Code.
+func retrievedCode(elements []types.CodeElement) []RetrievedCode {
+	code := make([]RetrievedCode, len(elements))
+	for i, elem := range elements {
+		code[i] = RetrievedCode{
+			ID:        elem.ID,
+			RepoName:  elem.RepoName,
+			Path:      elem.RelativePath,
+			Type:      elem.Type,
+			Name:      elem.Name,
+			Language:  elem.Language,
+			StartLine: elem.StartLine,
+			EndLine:   elem.EndLine,
+			Code:      elem.Code,
+		}
+	}
+	return code
+}
+
+// Query modes reported in QueryResult.Mode.
+const (
+	QueryModeAgent  = "agent"
+	QueryModeDirect = "direct"
+)
+
+// directSearchWarning tells callers why a direct-search answer is thin.
+const directSearchWarning = "no LLM API key configured (set OPENAI_API_KEY): " +
+	"answered with direct hybrid search only, without the retrieval agent or answer generation"
+
+// QueryOptions tunes a single query. The zero value of each field means
+// "use the default", so callers only set the knobs they care about.
+type QueryOptions struct {
+	// TopK is how many search results a direct query lists and the agent's
+	// baseline search returns (default: 10).
+	TopK int
+
+	// Languages restricts retrieval to elements in these languages, as named
+	// in the index (e.g. "go", "typescript"); empty means all.
+	Languages []string
+
+	// File scopes the question to one indexed file, resolved like the
+	// agent's browse_file tool (exact path, path suffix, then closest fuzzy
+	// match). The agent starts from that file's elements and keeps them;
+	// a direct search only searches that file.
+	File string
+
+	// PublicOnly drops unexported functions and classes (see
+	// index.IsExported) from the results and the answer context, for
+	// questions about a repository's public API.
+	PublicOnly bool
+
+	// Tests keeps or drops elements of test files (see index.IsTest):
+	// agent.TestsExclude leaves tests out, agent.TestsOnly searches only
+	// tests, and the default keeps both.
+	Tests agent.TestFilter
+
+	// ContextLines, if positive, widens the functions and classes found by
+	// searches by this many lines of their file on each side (capped by the
+	// agent), so nearby imports and types come along. A direct search then
+	// lists their code.
+	ContextLines int
+
+	// MaxRounds caps the agent's retrieval rounds (default: the agent's own).
+	MaxRounds int
+
+	// MinRoundsBeforeStop is the number of retrieval rounds the agent runs
+	// before a confident assessment may stop it (default: the agent's own;
+	// see agent.AgentConfig).
+	MinRoundsBeforeStop int
+
+	// BudgetMode is the unit the agent budgets gathered code in: lines
+	// (default) or estimated tokens.
+	BudgetMode agent.BudgetMode
+
+	// Format is the layout of a generated answer (default: markdown). Direct
+	// search answers are always a plain listing.
+	Format agent.AnswerFormat
+
+	// Verbosity is the requested answer length (default: normal), which also
+	// sets the generation limit unless MaxAnswerTokens is given.
+	Verbosity agent.Verbosity
+
+	// MaxAnswerTokens caps the tokens generated for the answer (default:
+	// Config.MaxAnswerTokens, else the verbosity level's limit).
+	MaxAnswerTokens int
+
+	// CheckStaleness compares the index time against the repository's file
+	// modification times and warns when files are newer. It walks the
+	// repository, so it is off by default.
+	CheckStaleness bool
+
+	// SessionID, if set, continues the named session: its earlier turns are
+	// shown to the model, so follow-up questions can refer to them, and the
+	// exchange is appended to it, creating it on first use.
+	SessionID string
+
+	// Trace fills QueryResult.Trace with the agent's per-round decisions.
+	Trace bool
+
+	// IncludeCode fills QueryResult.Code with the code of the elements the
+	// answer was based on, for programs that show it alongside the answer.
+	// It makes results much larger, so it is off by default.
+	IncludeCode bool
+
+	// GraphNeighbors turns on Config.GraphNeighbors for this query: the
+	// agent adds the direct graph neighbors of the most relevant gathered
+	// elements after each round's tool calls.
+	GraphNeighbors bool
+}
+
+// DefaultQueryOptions returns the options Query uses.
+func DefaultQueryOptions() QueryOptions {
+	return QueryOptions{TopK: 10, Format: agent.AnswerFormatMarkdown, Verbosity: agent.VerbosityNormal}
+}
+
+// Query performs a full query pipeline (search → agent → answer) with
+// DefaultQueryOptions.
 func (e *Engine) Query(question string) (*QueryResult, error) {
+	return e.QueryWithOptions(question, DefaultQueryOptions())
+}
+
+// QueryWithOptions performs a full query pipeline tuned by opts.
+func (e *Engine) QueryWithOptions(question string, opts QueryOptions) (*QueryResult, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
 	if e.hybrid == nil || len(e.elements) == 0 {
 		return nil, fmt.Errorf("no repository indexed — run 'fastcode index <path>' first")
 	}
 
+	if opts.File != "" {
+		file, ok := e.resolveFile(opts.File, opts.Languages)
+		if !ok {
+			return nil, fmt.Errorf("file %q is not in the index", opts.File)
+		}
+		logger.Debug("scoped query to file", "file", opts.File, "resolved", file)
+		opts.File = file
+	}
+
 	// Process query
 	pq := agent.ProcessQuery(question)
-	log.Printf("[engine] query type=%s complexity=%d keywords=%v", pq.QueryType, pq.Complexity, pq.Keywords)
+	logger.Debug("processed query", "type", pq.QueryType, "complexity", pq.Complexity, "keywords", pq.Keywords)
 
 	// If we have an API key, use the iterative agent
+	var result *QueryResult
+	var err error
 	if e.client.APIKey != "" {
-		return e.queryWithAgent(question, pq)
+		result, err = e.queryWithAgent(question, pq, opts)
+	} else {
+		// Fallback: direct search without LLM
+		result, err = e.queryDirect(question, pq, opts)
+		if err == nil {
+			result.addWarning(directSearchWarning)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.CheckStaleness {
+		if warning := e.stalenessWarning(); warning != "" {
+			logger.Info("stale index", "warning", warning)
+			result.addWarning(warning)
+		}
 	}
 
-	// Fallback: direct search without LLM
-	return e.queryDirect(question, pq)
+	if opts.SessionID != "" {
+		if err := e.recordTurn(opts.SessionID, question, result); err != nil {
+			return result, fmt.Errorf("save session: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// addWarning appends a warning, separating multiple warnings with "; ".
+func (r *QueryResult) addWarning(warning string) {
+	if r.Warning != "" {
+		r.Warning += "; "
+	}
+	r.Warning += warning
+}
+
+// stalenessWarning reports when repository files were modified after the
+// loaded index was built, or returns "" if the index is current or its age
+// is unknown.
+func (e *Engine) stalenessWarning() string {
+	if e.indexed.IsZero() || e.repoPath == "" {
+		return ""
+	}
+	cfg, err := e.repoConfig(e.repoPath)
+	if err != nil {
+		logger.Warn("staleness check failed", "err", err)
+		return ""
+	}
+	repo, err := loader.LoadRepository(e.repoPath, cfg.loaderConfig())
+	if err != nil {
+		logger.Warn("staleness check failed", "err", err)
+		return ""
+	}
+	changed := 0
+	for _, f := range repo.Files {
+		if f.ModTime.After(e.indexed) {
+			changed++
+		}
+	}
+	if changed == 0 {
+		return ""
+	}
+	return fmt.Sprintf("stale index: %d file(s) changed since it was built at %s (run 'fastcode index --force %s' to refresh)",
+		changed, e.indexed.Format("2006-01-02 15:04"), e.repoPath)
+}
+
+// QuerySession runs Query as a follow-up of the earlier turns of the named
+// session and appends the exchange to it, creating it on first use, so the
+// conversation can be resumed and reviewed later.
+func (e *Engine) QuerySession(sessionID, question string) (*QueryResult, error) {
+	opts := DefaultQueryOptions()
+	opts.SessionID = sessionID
+	return e.QueryWithOptions(question, opts)
 }
 
-func (e *Engine) queryWithAgent(question string, pq *agent.ProcessedQuery) (*QueryResult, error) {
+// sessionHistory returns the turns recorded in the named session, so a
+// resumed conversation continues where it left off. A session not created
+// yet, or no session, has no history.
+func (e *Engine) sessionHistory(sessionID string) ([]agent.ConversationTurn, error) {
+	if sessionID == "" {
+		return nil, nil
+	}
+	sess, err := session.NewStore(e.config.SessionDir).Load(sessionID)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load session: %w", err)
+	}
+	history := make([]agent.ConversationTurn, len(sess.Turns))
+	for i, turn := range sess.Turns {
+		history[i] = agent.ConversationTurn{Question: turn.Question, Answer: turn.Answer}
+	}
+	return history, nil
+}
+
+// recordTurn appends a question and its result to the named session.
+func (e *Engine) recordTurn(sessionID, question string, result *QueryResult) error {
+	store := session.NewStore(e.config.SessionDir)
+	turn := session.Turn{
+		Question:   question,
+		Answer:     result.Answer,
+		ElementIDs: result.ElementIDs,
+		Confidence: result.Confidence,
+		Time:       time.Now(),
+	}
+	_, err := store.Append(sessionID, e.repoName, turn)
+	return err
+}
+
+func elementIDs(elements []types.CodeElement) []string {
+	ids := make([]string, len(elements))
+	for i := range elements {
+		ids[i] = elements[i].ID
+	}
+	return ids
+}
+
+func (e *Engine) queryWithAgent(question string, pq *agent.ProcessedQuery, opts QueryOptions) (*QueryResult, error) {
 	// Set up agent
 	toolExec := agent.NewToolExecutor(e.hybrid, e.embedder, e.elements)
 	toolExec.SetRepoRoot(e.repoPath, e.repoName)
+	toolExec.SetGraphs(e.graphs)
+	toolExec.SearchTopK = opts.topK()
+	toolExec.Languages = opts.Languages
+	toolExec.Tests = opts.Tests
+	toolExec.RedactSecrets = !e.config.NoRedactSecrets
+	toolExec.ContextLines = opts.ContextLines
 	agentCfg := agent.DefaultAgentConfig()
+	if opts.MaxRounds > 0 {
+		agentCfg.MaxRounds = opts.MaxRounds
+	}
+	if opts.MinRoundsBeforeStop > 0 {
+		agentCfg.MinRoundsBeforeStop = opts.MinRoundsBeforeStop
+	}
+	if opts.BudgetMode != "" {
+		agentCfg.BudgetMode = opts.BudgetMode
+	}
+	agentCfg.IncludeFileDependencies = e.config.IncludeFileDependencies
+	agentCfg.GraphNeighbors = e.config.GraphNeighbors || opts.GraphNeighbors
+	agentCfg.PrimaryLanguage = e.language
+	agentCfg.FocusFile = opts.File
+	history, err := e.sessionHistory(opts.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	agentCfg.History = history
 	iterAgent := agent.NewIterativeAgent(e.client, toolExec, e.graphs, agentCfg)
 
 	// Run retrieval
@@ -193,24 +899,111 @@ func (e *Engine) queryWithAgent(question string, pq *agent.ProcessedQuery) (*Que
 	if err != nil {
 		return nil, fmt.Errorf("agent retrieval: %w", err)
 	}
+	// Graph expansion can pull in other languages; enforce the filters on the result
+	retrieval.Elements = opts.filterElements(retrieval.Elements)
 
 	// Generate answer
 	gen := agent.NewAnswerGenerator(e.client)
+	gen.RedactSecrets = !e.config.NoRedactSecrets
+	gen.Format = opts.Format
+	gen.Verbosity = opts.Verbosity
+	gen.MaxAnswerTokens = opts.MaxAnswerTokens
+	if gen.MaxAnswerTokens <= 0 {
+		gen.MaxAnswerTokens = e.config.MaxAnswerTokens
+	}
+	gen.History = history
 	answer, err := gen.GenerateAnswer(question, pq, retrieval.Elements)
 	if err != nil {
 		return nil, fmt.Errorf("answer generation: %w", err)
 	}
 
-	return &QueryResult{
+	result := &QueryResult{
 		Answer:     answer,
 		Confidence: retrieval.Confidence,
 		Rounds:     retrieval.Rounds,
 		StopReason: retrieval.StopReason,
 		Elements:   len(retrieval.Elements),
-	}, nil
+		ElementIDs: elementIDs(retrieval.Elements),
+		Mode:       QueryModeAgent,
+		Metadata:   retrieval.Metadata,
+	}
+	if opts.Trace {
+		result.Trace = retrieval.Trace
+	}
+	if opts.IncludeCode {
+		result.Code = retrievedCode(retrieval.Elements)
+	}
+	return result, nil
 }
 
-func (e *Engine) queryDirect(question string, pq *agent.ProcessedQuery) (*QueryResult, error) {
+// elementFilter returns a search predicate for Languages, PublicOnly and
+// Tests, or nil when every element passes.
+func (o QueryOptions) elementFilter() func(*types.CodeElement) bool {
+	if len(o.Languages) == 0 && !o.PublicOnly && o.Tests == agent.TestsInclude {
+		return nil
+	}
+	return func(elem *types.CodeElement) bool {
+		if len(o.Languages) > 0 && !slices.Contains(o.Languages, elem.Language) {
+			return false
+		}
+		if o.PublicOnly && !index.IsExported(elem) {
+			return false
+		}
+		switch o.Tests {
+		case agent.TestsExclude:
+			return !index.IsTest(elem)
+		case agent.TestsOnly:
+			return index.IsTest(elem)
+		}
+		return true
+	}
+}
+
+// searchFilter returns the predicate a direct search applies: Languages,
+// PublicOnly, Tests and, if set, File. It returns nil when every element passes.
+func (o QueryOptions) searchFilter() func(*types.CodeElement) bool {
+	keep := o.elementFilter()
+	if o.File == "" {
+		return keep
+	}
+	return func(elem *types.CodeElement) bool {
+		return elem.RelativePath == o.File && (keep == nil || keep(elem))
+	}
+}
+
+// resolveFile returns the indexed path of the file filePath names, among
+// files in languages (all if empty).
+func (e *Engine) resolveFile(filePath string, languages []string) (string, bool) {
+	toolExec := agent.NewToolExecutor(e.hybrid, nil, e.elements)
+	toolExec.Languages = languages
+	return toolExec.ResolveFile(filePath)
+}
+
+// filterElements drops elements outside Languages, those Tests leaves out
+// and, with PublicOnly, unexported ones.
+func (o QueryOptions) filterElements(elements []types.CodeElement) []types.CodeElement {
+	keep := o.elementFilter()
+	if keep == nil {
+		return elements
+	}
+	var out []types.CodeElement
+	for i := range elements {
+		if keep(&elements[i]) {
+			out = append(out, elements[i])
+		}
+	}
+	return out
+}
+
+// topK returns the requested result count, falling back to 10.
+func (o QueryOptions) topK() int {
+	if o.TopK <= 0 {
+		return 10
+	}
+	return o.TopK
+}
+
+func (e *Engine) queryDirect(question string, pq *agent.ProcessedQuery, opts QueryOptions) (*QueryResult, error) {
 	// Direct hybrid search without LLM agent
 	var queryVec []float32
 	if e.embedder != nil {
@@ -220,23 +1013,446 @@ func (e *Engine) queryDirect(question string, pq *agent.ProcessedQuery) (*QueryR
 		}
 	}
 
-	results := e.hybrid.Search(question, queryVec, 10)
-	var sb fmt.Stringer = &simpleAnswer{}
-	answer := &simpleAnswer{}
+	results := e.hybrid.SearchFiltered(question, queryVec, opts.topK(), opts.searchFilter())
+	var found []types.CodeElement
 	for _, r := range results {
 		if r.Element != nil {
-			answer.addResult(r.Element)
+			found = append(found, *r.Element)
 		}
 	}
-	_ = sb // suppress unused
+	if opts.ContextLines > 0 {
+		toolExec := agent.NewToolExecutor(e.hybrid, nil, e.elements)
+		toolExec.ContextLines = opts.ContextLines
+		found = toolExec.WithContext(found)
+	}
+	answer := &simpleAnswer{showCode: opts.ContextLines > 0}
+	var ids []string
+	for i := range found {
+		answer.addResult(&found[i])
+		ids = append(ids, found[i].ID)
+	}
 
-	return &QueryResult{
+	result := &QueryResult{
 		Answer:     answer.String(),
-		Confidence: 50,
+		Confidence: directConfidence(pq, results),
 		Rounds:     1,
 		StopReason: "direct_search",
 		Elements:   len(results),
-	}, nil
+		ElementIDs: ids,
+		Mode:       QueryModeDirect,
+	}
+	if opts.IncludeCode {
+		result.Code = retrievedCode(found)
+	}
+	return result, nil
+}
+
+// Embedding states reported in EngineStatus.Embeddings.
+const (
+	EmbeddingsDisabled = "disabled" // no embedder configured (e.g. --no-embeddings)
+	EmbeddingsPending  = "pending"  // nothing indexed yet
+	EmbeddingsReady    = "ready"    // the index has vectors
+	EmbeddingsMissing  = "missing"  // indexed without vectors, e.g. embedding failed; BM25 only
+)
+
+// EngineStatus describes whether an engine is ready to answer queries.
+type EngineStatus struct {
+	Indexed  bool   `json:"indexed"`
+	RepoName string `json:"repo_name,omitempty"`
+	Elements int    `json:"elements"`
+
+	// LLMConfigured reports an API key for the answering model; without
+	// one queries fall back to direct search.
+	LLMConfigured bool   `json:"llm_configured"`
+	Model         string `json:"model,omitempty"`
+
+	// Embeddings is one of the Embeddings* states; Vectors counts the
+	// elements with a vector.
+	Embeddings string `json:"embeddings"`
+	Vectors    int    `json:"vectors"`
+}
+
+// Status reports the loaded index and the configured models.
+func (e *Engine) Status() EngineStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	st := EngineStatus{
+		Indexed:       e.hybrid != nil && len(e.elements) > 0,
+		Elements:      len(e.elements),
+		LLMConfigured: e.client.APIKey != "",
+	}
+	if st.Indexed {
+		st.RepoName = e.repoName
+	}
+	if st.LLMConfigured {
+		st.Model = e.client.Model
+	}
+	switch {
+	case e.embedder == nil:
+		st.Embeddings = EmbeddingsDisabled
+	case !st.Indexed:
+		st.Embeddings = EmbeddingsPending
+	default:
+		st.Vectors = e.hybrid.VectorCount()
+		st.Embeddings = EmbeddingsReady
+		if st.Vectors == 0 {
+			st.Embeddings = EmbeddingsMissing
+		}
+	}
+	return st
+}
+
+// SearchResult is one page of hybrid search results, best first.
+type SearchResult struct {
+	Query   string      `json:"query"`
+	Results []SearchHit `json:"results"`
+	Offset  int         `json:"offset"`
+	Total   int         `json:"total"` // ranked elements across all pages
+
+	// NextOffset is the offset of the next page, or 0 after the last one.
+	NextOffset int `json:"next_offset,omitempty"`
+}
+
+// SearchHit is an element found by Search.
+type SearchHit struct {
+	ID           string  `json:"id"`
+	Type         string  `json:"type"`
+	Name         string  `json:"name"`
+	RelativePath string  `json:"relative_path"`
+	StartLine    int     `json:"start_line"`
+	EndLine      int     `json:"end_line"`
+	Signature    string  `json:"signature,omitempty"`
+	Score        float64 `json:"score"`
+}
+
+// Search ranks the indexed elements against query by hybrid search, without
+// the agent, and returns limit of them starting at offset. The ranking is
+// stable, so a client can page through it by passing NextOffset.
+func (e *Engine) Search(query string, offset, limit int) (*SearchResult, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.hybrid == nil || len(e.elements) == 0 {
+		return nil, fmt.Errorf("no repository indexed — run 'fastcode index <path>' first")
+	}
+	if offset < 0 || limit < 1 {
+		return nil, fmt.Errorf("invalid page: offset %d, limit %d", offset, limit)
+	}
+	var queryVec []float32
+	if e.embedder != nil {
+		if vec, err := e.embedder.EmbedText(query); err == nil {
+			queryVec = vec
+		}
+	}
+
+	hits, total := e.hybrid.SearchPage(query, queryVec, offset, limit, nil)
+	result := &SearchResult{Query: query, Results: []SearchHit{}, Offset: offset, Total: total}
+	for _, h := range hits {
+		if h.Element == nil {
+			continue
+		}
+		result.Results = append(result.Results, SearchHit{
+			ID:           h.Element.ID,
+			Type:         h.Element.Type,
+			Name:         h.Element.Name,
+			RelativePath: h.Element.RelativePath,
+			StartLine:    h.Element.StartLine,
+			EndLine:      h.Element.EndLine,
+			Signature:    h.Element.Signature,
+			Score:        h.Score,
+		})
+	}
+	if next := offset + len(hits); next < total {
+		result.NextOffset = next
+	}
+	return result, nil
+}
+
+// Files returns the file-level elements of the indexed repository, whose
+// Code holds the (possibly truncated) file content.
+func (e *Engine) Files() []types.CodeElement {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var files []types.CodeElement
+	for _, elem := range e.elements {
+		if elem.Type == "file" {
+			files = append(files, elem)
+		}
+	}
+	return files
+}
+
+// FindPath returns the shortest chain of elements leading from one element to
+// another over the selected graphs (all graphs if none are given). Elements can
+// be given by ID, name or file path. It returns nil without error when the
+// elements exist but are not connected.
+func (e *Engine) FindPath(from, to string, graphs []graph.GraphType) ([]types.CodeElement, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.graphs == nil || len(e.elements) == 0 {
+		return nil, fmt.Errorf("no repository indexed — run 'fastcode index <path>' first")
+	}
+	fromElem := e.resolveElement(from)
+	if fromElem == nil {
+		return nil, fmt.Errorf("element not found: %s", from)
+	}
+	toElem := e.resolveElement(to)
+	if toElem == nil {
+		return nil, fmt.Errorf("element not found: %s", to)
+	}
+
+	ids := e.graphs.ShortestPath(fromElem.ID, toElem.ID, graphs)
+	if ids == nil {
+		return nil, nil
+	}
+	path := make([]types.CodeElement, 0, len(ids))
+	for _, id := range ids {
+		if elem := e.graphs.Element(id); elem != nil {
+			path = append(path, *elem)
+		}
+	}
+	return path, nil
+}
+
+// FindSymbol returns the elements whose name contains symbol, ignoring case,
+// or equals it exactly when exact is set, without consulting the LLM. A
+// non-empty elemType ("function", "class", "file") restricts the element type,
+// and publicOnly skips unexported functions and classes. Exact name matches
+// come first, then functions before classes before files.
+func (e *Engine) FindSymbol(symbol, elemType string, exact, publicOnly bool) ([]types.CodeElement, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if len(e.elements) == 0 {
+		return nil, fmt.Errorf("no repository indexed — run 'fastcode index <path>' first")
+	}
+	lower := strings.ToLower(symbol)
+	var found []types.CodeElement
+	for _, elem := range e.elements {
+		if (elemType != "" && elem.Type != elemType) || (publicOnly && !index.IsExported(&elem)) {
+			continue
+		}
+		if elem.Name == symbol || (!exact && strings.Contains(strings.ToLower(elem.Name), lower)) {
+			found = append(found, elem)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if (a.Name == symbol) != (b.Name == symbol) {
+			return a.Name == symbol
+		}
+		if namePriority(a.Type) != namePriority(b.Type) {
+			return namePriority(a.Type) < namePriority(b.Type)
+		}
+		if a.RelativePath != b.RelativePath {
+			return a.RelativePath < b.RelativePath
+		}
+		return a.StartLine < b.StartLine
+	})
+	return found, nil
+}
+
+// FindReferences returns the functions that call symbol and the files whose
+// imports name it.
+func (e *Engine) FindReferences(symbol string) ([]types.CodeElement, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.graphs == nil || len(e.elements) == 0 {
+		return nil, fmt.Errorf("no repository indexed — run 'fastcode index <path>' first")
+	}
+	var refs []types.CodeElement
+	for _, id := range e.graphs.Referrers(symbol) {
+		if elem := e.graphs.Element(id); elem != nil {
+			refs = append(refs, *elem)
+		}
+	}
+	return refs, nil
+}
+
+// ScoredElement pairs a code element with a ranking score.
+type ScoredElement struct {
+	Element types.CodeElement `json:"element"`
+	Score   float64           `json:"score"`
+}
+
+// ImportantElements returns the topN elements with the highest PageRank
+// importance over the given graph, most important first.
+func (e *Engine) ImportantElements(t graph.GraphType, topN int) ([]ScoredElement, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.graphs == nil || len(e.elements) == 0 {
+		return nil, fmt.Errorf("no repository indexed — run 'fastcode index <path>' first")
+	}
+	if e.graphs.Graph(t) == nil {
+		return nil, fmt.Errorf("unknown graph %q", t)
+	}
+
+	var ranked []ScoredElement
+	for id, score := range e.graphs.ImportanceScores(t) {
+		if elem := e.graphs.Element(id); elem != nil {
+			ranked = append(ranked, ScoredElement{Element: *elem, Score: score})
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Element.ID < ranked[j].Element.ID
+	})
+	if topN > 0 && len(ranked) > topN {
+		ranked = ranked[:topN]
+	}
+	return ranked, nil
+}
+
+// Diff parses two checkouts of a repository (e.g. two revisions) and reports
+// the classes and functions added, removed or changed between them. Neither
+// checkout is cached or loaded into the engine.
+func (e *Engine) Diff(oldPath, newPath string) (*diff.Result, error) {
+	oldElems, err := e.parseRepository(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newElems, err := e.parseRepository(newPath)
+	if err != nil {
+		return nil, err
+	}
+	return diff.Elements(oldElems, newElems), nil
+}
+
+// parserOptions returns the parser options selected by the configuration.
+func (c Config) parserOptions() []parser.Option {
+	opts := []parser.Option{parser.WithJSArrowFunctions(c.JSArrowFunctions)}
+	if c.repo != nil {
+		opts = append(opts, parser.WithLanguages(c.repo.Languages))
+	}
+	return opts
+}
+
+// newBM25 creates a keyword index with the configured parameters.
+func (e *Engine) newBM25() *index.BM25 {
+	bm := index.NewBM25(e.config.BM25K1, e.config.BM25B)
+	if e.config.BM25FieldBoost != 0 {
+		bm.SetFieldBoost(e.config.BM25FieldBoost)
+	}
+	return bm
+}
+
+// newIndexer creates an indexer configured by the configuration.
+func (c Config) newIndexer(repoName string) *index.Indexer {
+	indexer := index.NewIndexer(repoName, c.parserOptions()...)
+	indexer.MarkdownSections = c.MarkdownSections
+	indexer.Dedup = c.DedupElements
+	if c.MaxElementsPerFile != 0 {
+		indexer.MaxElementsPerFile = max(c.MaxElementsPerFile, 0)
+	}
+	return indexer
+}
+
+// parseRepository loads and indexes a repository without embeddings or caching.
+func (e *Engine) parseRepository(repoPath string) ([]types.CodeElement, error) {
+	cfg, err := e.repoConfig(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := loader.LoadRepository(repoPath, cfg.loaderConfig())
+	if err != nil {
+		return nil, fmt.Errorf("load repository: %w", err)
+	}
+	cfg = cfg.withLanguageDefaults(loader.PrimaryLanguage(repo.LanguageStats()))
+	elements, err := cfg.newIndexer(repo.Name).IndexRepository(repo)
+	if err != nil {
+		return nil, fmt.Errorf("index repository %s: %w", repoPath, err)
+	}
+	return elements, nil
+}
+
+// resolveElement finds an element by ID, then by name (preferring functions,
+// then classes), then by file path.
+func (e *Engine) resolveElement(ref string) *types.CodeElement {
+	var byName, byPath *types.CodeElement
+	for i := range e.elements {
+		elem := &e.elements[i]
+		switch {
+		case elem.ID == ref:
+			return elem
+		case elem.Name == ref:
+			if byName == nil || namePriority(elem.Type) < namePriority(byName.Type) {
+				byName = elem
+			}
+		case elem.Type == "file" && elem.RelativePath == ref:
+			byPath = elem
+		}
+	}
+	if byName != nil {
+		return byName
+	}
+	return byPath
+}
+
+func namePriority(elemType string) int {
+	switch elemType {
+	case "function":
+		return 0
+	case "class":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// directConfidence estimates answer confidence (0-100) without an LLM from how
+// many query keywords appear in the top results and how clearly the top result
+// stands out from the runner-up.
+func directConfidence(pq *agent.ProcessedQuery, results []index.HybridResult) int {
+	if len(results) == 0 || results[0].Element == nil {
+		return 0
+	}
+
+	// Keyword coverage over the top few results
+	const topN = 5
+	var sb strings.Builder
+	for i, r := range results {
+		if i >= topN {
+			break
+		}
+		if r.Element == nil {
+			continue
+		}
+		sb.WriteString(strings.ToLower(strings.Join([]string{
+			r.Element.Name, r.Element.RelativePath, r.Element.Signature, r.Element.Docstring, r.Element.Code,
+		}, " ")))
+		sb.WriteString(" ")
+	}
+	text := sb.String()
+	coverage := 0.0
+	if len(pq.Keywords) > 0 {
+		found := 0
+		for _, kw := range pq.Keywords {
+			if strings.Contains(text, kw) {
+				found++
+			}
+		}
+		coverage = float64(found) / float64(len(pq.Keywords))
+	}
+
+	// Relative gap between the best and second-best scores
+	gap := 1.0
+	if len(results) > 1 && results[0].Score > 0 {
+		gap = (results[0].Score - results[1].Score) / results[0].Score
+	}
+	gap = math.Max(0, math.Min(1, gap))
+
+	confidence := int(math.Round(100 * (0.7*coverage + 0.3*gap)))
+	if confidence > 100 {
+		confidence = 100
+	}
+	return confidence
 }
 
 func (e *Engine) rebuildFromCache(cached *cache.CachedIndex) {
@@ -244,31 +1460,67 @@ func (e *Engine) rebuildFromCache(cached *cache.CachedIndex) {
 	e.graphs.BuildGraphs(cached.Elements)
 
 	vs := index.NewVectorStore()
+	skipped := 0
 	for id, vec := range cached.Vectors {
-		vs.Add(id, vec)
+		if vs.Add(id, vec) != nil {
+			skipped++
+		}
 	}
-	bm := index.NewBM25(1.5, 0.75)
+	for id, chunks := range cached.ChunkVectors {
+		for _, vec := range chunks {
+			if vs.AddChunk(id, vec) != nil {
+				skipped++
+			}
+		}
+	}
+	if skipped > 0 {
+		logger.Warn("skipped cached vectors of an inconsistent dimension", "repo", cached.RepoName,
+			"count", skipped, "dimension", vs.Dimension())
+	}
+	bm := e.newBM25()
 	e.hybrid = index.NewHybridRetriever(vs, bm)
 	_ = e.hybrid.IndexElements(cached.Elements, nil)
 }
 
-// simpleAnswer builds a text answer from search results without LLM.
+// simpleAnswer builds a text answer from search results without LLM,
+// grouping elements by file in the order files first appear in the results.
 type simpleAnswer struct {
-	lines []string
+	files    []string
+	byFile   map[string][]*types.CodeElement
+	showCode bool // list each element's code under its signature
 }
 
 func (sa *simpleAnswer) addResult(elem *types.CodeElement) {
-	sa.lines = append(sa.lines, fmt.Sprintf("[%s] %s (%s:L%d-%d)\n  %s",
-		elem.Type, elem.Name, elem.RelativePath, elem.StartLine, elem.EndLine, elem.Signature))
+	if sa.byFile == nil {
+		sa.byFile = make(map[string][]*types.CodeElement)
+	}
+	if _, seen := sa.byFile[elem.RelativePath]; !seen {
+		sa.files = append(sa.files, elem.RelativePath)
+	}
+	sa.byFile[elem.RelativePath] = append(sa.byFile[elem.RelativePath], elem)
 }
 
 func (sa *simpleAnswer) String() string {
-	if len(sa.lines) == 0 {
+	if len(sa.files) == 0 {
 		return "No matching code elements found."
 	}
-	result := "Found matching code elements:\n\n"
-	for _, l := range sa.lines {
-		result += l + "\n\n"
+	var sb strings.Builder
+	sb.WriteString("Found matching code elements:\n")
+	for _, file := range sa.files {
+		elems := sa.byFile[file]
+		sort.SliceStable(elems, func(i, j int) bool { return elems[i].StartLine < elems[j].StartLine })
+		fmt.Fprintf(&sb, "\n%s\n", file)
+		for _, elem := range elems {
+			fmt.Fprintf(&sb, "  L%d-%d [%s] %s\n", elem.StartLine, elem.EndLine, elem.Type, elem.Name)
+			if elem.Signature != "" {
+				fmt.Fprintf(&sb, "      %s\n", elem.Signature)
+			}
+			if sa.showCode && elem.Code != "" {
+				for _, line := range strings.Split(elem.Code, "\n") {
+					fmt.Fprintf(&sb, "      | %s\n", line)
+				}
+			}
+		}
 	}
-	return result
+	return sb.String()
 }
@@ -1,10 +1,16 @@
 package orchestrator
 
 import (
+	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/duyhunghd6/fastcode-cli/internal/cache"
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
 )
 
@@ -21,6 +27,17 @@ func TestDefaultConfig(t *testing.T) {
 	}
 }
 
+func TestDefaultConfigMaxAnswerTokens(t *testing.T) {
+	t.Setenv("FASTCODE_MAX_ANSWER_TOKENS", "900")
+	if got := DefaultConfig().MaxAnswerTokens; got != 900 {
+		t.Errorf("MaxAnswerTokens = %d, want 900 from the environment", got)
+	}
+	t.Setenv("FASTCODE_MAX_ANSWER_TOKENS", "-3")
+	if got := DefaultConfig().MaxAnswerTokens; got != 0 {
+		t.Errorf("MaxAnswerTokens = %d, want 0 for a negative value", got)
+	}
+}
+
 func TestEngineInit(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "fastcode-engine-test-*")
 	if err != nil {
@@ -119,6 +136,88 @@ func helper() string {
 	}
 }
 
+func TestEngineIndexProgress(t *testing.T) {
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "a.go"), []byte("package main\nfunc A() {}\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "b.go"), []byte("package main\nfunc B() {}\n"), 0644)
+
+	var events []IndexProgress
+	cfg := DefaultConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.NoEmbeddings = true
+	cfg.Progress = func(p IndexProgress) { events = append(events, p) }
+	engine := NewEngine(cfg)
+	if _, err := engine.Index(repoDir, true); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	var phases []IndexPhase
+	for _, ev := range events {
+		if len(phases) == 0 || phases[len(phases)-1] != ev.Phase {
+			phases = append(phases, ev.Phase)
+		}
+	}
+	want := []IndexPhase{PhaseLoaded, PhaseParsing, PhaseGraphs}
+	if len(phases) != len(want) {
+		t.Fatalf("phases = %v, want %v", phases, want)
+	}
+	for i := range want {
+		if phases[i] != want[i] {
+			t.Fatalf("phases = %v, want %v", phases, want)
+		}
+	}
+	if ev := events[0]; ev.Total != 2 {
+		t.Errorf("loaded event = %+v, want 2 files", ev)
+	}
+	if ev := events[len(events)-2]; ev.Done != 2 || ev.Total != 2 {
+		t.Errorf("last parsing event = %+v, want 2/2", ev)
+	}
+
+	if engine.language != "go" {
+		t.Errorf("engine language = %q, want go", engine.language)
+	}
+
+	// A cached load reports nothing
+	events = nil
+	if _, err := engine.Index(repoDir, false); err != nil {
+		t.Fatalf("cached Index: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("cached load reported %d events", len(events))
+	}
+}
+
+func TestEngineIndexLanguages(t *testing.T) {
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "a.py"), []byte("def a(): pass\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "b.py"), []byte("def b(): pass\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "README.md"), []byte("# demo\n"), 0644)
+
+	cfg := DefaultConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.NoEmbeddings = true
+	engine := NewEngine(cfg)
+	for _, force := range []bool{true, false} {
+		result, err := engine.Index(repoDir, force)
+		if err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+		if result.PrimaryLanguage != "python" || result.Languages["python"] != 2 || result.Languages["markdown"] != 1 {
+			t.Errorf("cached=%v: PrimaryLanguage = %q, Languages = %v", result.Cached, result.PrimaryLanguage, result.Languages)
+		}
+	}
+}
+
+func TestEmbeddingProgressBatches(t *testing.T) {
+	var got []IndexProgress
+	report := embeddingProgress(func(p IndexProgress) { got = append(got, p) }, 32)
+	report(32, 70)
+	report(70, 70)
+	if got[0].Done != 1 || got[0].Total != 3 || got[1].Done != 3 || got[1].Phase != PhaseEmbedding {
+		t.Errorf("embedding progress = %+v", got)
+	}
+}
+
 func TestEngineIndexCached(t *testing.T) {
 	repoDir, err := os.MkdirTemp("", "fastcode-repo-*")
 	if err != nil {
@@ -238,6 +337,97 @@ func main() {
 	if result.StopReason != "direct_search" {
 		t.Errorf("StopReason = %q, want direct_search", result.StopReason)
 	}
+	if result.Mode != QueryModeDirect || !strings.Contains(result.Warning, "OPENAI_API_KEY") {
+		t.Errorf("Mode = %q, Warning = %q; want direct mode with an API key warning", result.Mode, result.Warning)
+	}
+}
+
+func TestEngineConcurrentIndexAndQuery(t *testing.T) {
+	repoDir := t.TempDir()
+	for _, name := range []string{"main.go", "config.go", "server.go"} {
+		content := "package main\n\n// " + name + " handles config loading\nfunc f() {}\n"
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	origKey := os.Getenv("OPENAI_API_KEY")
+	os.Unsetenv("OPENAI_API_KEY")
+	defer os.Setenv("OPENAI_API_KEY", origKey)
+
+	engine := NewEngine(Config{CacheDir: t.TempDir(), BatchSize: 32, NoEmbeddings: true})
+	if _, err := engine.Index(repoDir, true); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	// Reindexing while querying must not race (run with -race)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if _, err := engine.Index(repoDir, true); err != nil {
+				t.Errorf("Index: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := engine.Query("config loading"); err != nil {
+				t.Errorf("Query: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := engine.FindSymbol("main", "", false, false); err != nil {
+				t.Errorf("FindSymbol: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestEngineQueryStaleness(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	repoDir := t.TempDir()
+	mainPath := filepath.Join(repoDir, "main.go")
+	os.WriteFile(mainPath, []byte("package main\n\n// LoadConfig reads configuration\nfunc LoadConfig() {}\n"), 0644)
+
+	cfg := Config{CacheDir: t.TempDir(), BatchSize: 32, NoEmbeddings: true}
+	opts := QueryOptions{CheckStaleness: true}
+	engine := NewEngine(cfg)
+	if _, err := engine.Index(repoDir, true); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	result, err := engine.QueryWithOptions("how is config loaded?", opts)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if strings.Contains(result.Warning, "stale index") {
+		t.Errorf("fresh index reported stale: %q", result.Warning)
+	}
+
+	// Modify a file after indexing; the cached index is now stale
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(mainPath, future, future)
+	reloaded := NewEngine(cfg)
+	if _, err := reloaded.Index(repoDir, false); err != nil {
+		t.Fatalf("Index from cache: %v", err)
+	}
+	result, err = reloaded.QueryWithOptions("how is config loaded?", opts)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if !strings.Contains(result.Warning, "stale index: 1 file(s) changed") {
+		t.Errorf("expected stale index warning, got %q", result.Warning)
+	}
+
+	// Without CheckStaleness no walk happens and no warning is added
+	unchecked := NewEngine(cfg)
+	unchecked.Index(repoDir, false)
+	if result, _ := unchecked.Query("how is config loaded?"); result != nil && strings.Contains(result.Warning, "stale") {
+		t.Errorf("staleness warning without CheckStaleness: %q", result.Warning)
+	}
 }
 
 func TestSimpleAnswerEmpty(t *testing.T) {
@@ -276,3 +466,341 @@ func TestEngineIndexInvalidPath(t *testing.T) {
 		t.Error("expected error for nonexistent path")
 	}
 }
+
+func TestEngineIndexWorkspace(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"alpha", "beta"} {
+		dir := filepath.Join(root, name)
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "README.md"), []byte("# "+name+"\n\nAbout "+name+".\n"), 0644)
+		os.WriteFile(filepath.Join(dir, name+".go"), []byte("package "+name+"\nfunc Run() {}\n"), 0644)
+	}
+	repos := []string{filepath.Join(root, "alpha"), filepath.Join(root, "beta")}
+
+	cfg := DefaultConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.NoEmbeddings = true
+	engine := NewEngine(cfg)
+	result, err := engine.IndexWorkspace(context.Background(), repos, true)
+	if err != nil {
+		t.Fatalf("IndexWorkspace: %v", err)
+	}
+	if result.RepoName != "alpha+beta" || len(result.Repos) != 2 || result.TotalFiles != 4 || result.Cached {
+		t.Errorf("result = %+v", result)
+	}
+	byRepo := make(map[string]int)
+	for _, elem := range engine.elements {
+		byRepo[elem.RepoName]++
+	}
+	if byRepo["alpha"] == 0 || byRepo["beta"] == 0 || len(byRepo) != 2 {
+		t.Errorf("elements per repo = %v", byRepo)
+	}
+	for _, name := range []string{"alpha", "beta"} {
+		if !engine.cache.Exists(name) {
+			t.Errorf("repository %s was not cached separately", name)
+		}
+	}
+
+	// A second workspace loads both repositories from the cache
+	again, err := NewEngine(cfg).IndexWorkspace(context.Background(), repos, false)
+	if err != nil {
+		t.Fatalf("IndexWorkspace (cached): %v", err)
+	}
+	if !again.Cached || again.TotalElements != result.TotalElements {
+		t.Errorf("cached result = %+v, want %d elements from cache", again, result.TotalElements)
+	}
+}
+
+func TestEngineIndexWorkspaceDuplicateNames(t *testing.T) {
+	root := t.TempDir()
+	repos := []string{filepath.Join(root, "a", "svc"), filepath.Join(root, "b", "svc")}
+	for _, dir := range repos {
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "README.md"), []byte("# svc\n"), 0644)
+	}
+
+	cfg := DefaultConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.NoEmbeddings = true
+	_, err := NewEngine(cfg).IndexWorkspace(context.Background(), repos, true)
+	if err == nil || !strings.Contains(err.Error(), `both named "svc"`) {
+		t.Errorf("err = %v, want duplicate name error", err)
+	}
+}
+
+func TestEngineIndexRepoName(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), "checkout")
+	os.MkdirAll(repoDir, 0755)
+	os.WriteFile(filepath.Join(repoDir, "README.md"), []byte("# demo\n"), 0644)
+
+	cfg := DefaultConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.NoEmbeddings = true
+	cfg.RepoName = "myservice"
+	engine := NewEngine(cfg)
+	result, err := engine.Index(repoDir, true)
+	if err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if result.RepoName != "myservice" || !engine.cache.Exists("myservice") || engine.cache.Exists("checkout") {
+		t.Errorf("RepoName = %q; cached as myservice: %v", result.RepoName, engine.cache.Exists("myservice"))
+	}
+	for _, elem := range engine.elements {
+		if elem.RepoName != "myservice" {
+			t.Errorf("element %s has RepoName %q", elem.Name, elem.RepoName)
+		}
+	}
+
+	if _, err := engine.IndexWorkspace(context.Background(), []string{repoDir, t.TempDir()}, false); err == nil {
+		t.Error("expected error naming a workspace of two repositories")
+	}
+}
+
+func TestEnginePlan(t *testing.T) {
+	repoDir := t.TempDir()
+	os.MkdirAll(filepath.Join(repoDir, "testdata"), 0755)
+	os.WriteFile(filepath.Join(repoDir, "b.py"), []byte("def b(): pass\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "a.py"), []byte("def a(): pass\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "README.md"), []byte("# demo\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "testdata", "x.py"), []byte("x = 1\n"), 0644)
+
+	cfg := DefaultConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.NoEmbeddings = true
+	cfg.Exclude = []string{"testdata/**"}
+	engine := NewEngine(cfg)
+	plan, err := engine.Plan(repoDir)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	var paths []string
+	for _, f := range plan.Files {
+		paths = append(paths, f.RelativePath)
+	}
+	if strings.Join(paths, ",") != "README.md,a.py,b.py" {
+		t.Errorf("planned files = %v, want README.md, a.py, b.py", paths)
+	}
+	if plan.Languages["python"] != 2 || plan.Languages["markdown"] != 1 || plan.TotalSize != 14+14+7 {
+		t.Errorf("languages = %v, total size = %d", plan.Languages, plan.TotalSize)
+	}
+	if len(engine.elements) != 0 || engine.cache.Exists(plan.RepoName) {
+		t.Error("Plan parsed or cached the repository")
+	}
+}
+
+func TestEnginePlanSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repoDir := t.TempDir()
+	for _, name := range []string{"main.go", "utils.go", "other.go"} {
+		os.WriteFile(filepath.Join(repoDir, name), []byte("package main\n"), 0644)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repoDir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main // changed\n"), 0644)
+
+	cfg := DefaultConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.NoEmbeddings = true
+	cfg.Since = "HEAD"
+	engine := NewEngine(cfg)
+	planned := func() string {
+		t.Helper()
+		plan, err := engine.Plan(repoDir)
+		if err != nil {
+			t.Fatalf("Plan: %v", err)
+		}
+		var paths []string
+		for _, f := range plan.Files {
+			paths = append(paths, f.RelativePath)
+		}
+		return strings.Join(paths, ",")
+	}
+	if got := planned(); got != "main.go" {
+		t.Errorf("planned files = %s, want the changed main.go", got)
+	}
+
+	// A cached full index adds the files of graph neighbors
+	engine.cache.Save(filepath.Base(repoDir), &cache.CachedIndex{Elements: []types.CodeElement{
+		{ID: "f_main", Type: "file", Name: "main.go", RelativePath: "main.go",
+			Metadata: map[string]any{"imports": []types.ImportInfo{{Module: "utils", Line: 3}}}},
+		{ID: "f_utils", Type: "file", Name: "utils.go", RelativePath: "utils.go", Metadata: map[string]any{}},
+		{ID: "f_other", Type: "file", Name: "other.go", RelativePath: "other.go", Metadata: map[string]any{}},
+	}})
+	if got := planned(); got != "main.go,utils.go" {
+		t.Errorf("planned files = %s, want main.go and its dependency utils.go", got)
+	}
+
+	// An unknown ref falls back to every file
+	engine.config.Since = "no-such-branch"
+	if got := planned(); got != "main.go,other.go,utils.go" {
+		t.Errorf("planned files = %s, want all files", got)
+	}
+}
+
+func TestRepoConfig(t *testing.T) {
+	repoDir := t.TempDir()
+	for _, dir := range []string{"gen", "vendor"} {
+		os.MkdirAll(filepath.Join(repoDir, dir), 0755)
+	}
+	os.WriteFile(filepath.Join(repoDir, "a.py"), []byte("def a(): pass\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "b.py"), []byte("def b(): pass\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "gen", "x.py"), []byte("x = 1\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "vendor", "v.py"), []byte("v = 1\n"), 0644)
+	manifest := filepath.Join(repoDir, ".fastcode.yaml")
+	os.WriteFile(manifest, []byte("exclude: [\"gen/**\"]\nexclude_dirs: [vendor]\nmarkdown_sections: true\n"), 0644)
+
+	cfg := DefaultConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.NoEmbeddings = true
+	cfg.Exclude = []string{"b.py"}
+	engine := NewEngine(cfg)
+
+	plan, err := engine.Plan(repoDir)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	var paths []string
+	for _, f := range plan.Files {
+		if f.RelativePath != ".fastcode.yaml" {
+			paths = append(paths, f.RelativePath)
+		}
+	}
+	if strings.Join(paths, ",") != "a.py" {
+		t.Errorf("planned files = %v, want a.py alone", paths)
+	}
+	repoCfg, err := engine.repoConfig(repoDir)
+	if err != nil {
+		t.Fatalf("repoConfig: %v", err)
+	}
+	if !repoCfg.MarkdownSections || engine.config.MarkdownSections {
+		t.Error("expected markdown_sections to apply to the repository only")
+	}
+
+	// An index filtered on the command line is not cached
+	if _, err := engine.Index(repoDir, false); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if engine.cache.Exists(engine.repoName) {
+		t.Error("an index built with command-line filters should not be cached")
+	}
+
+	// The cache is used while the settings stay the same
+	engine.config.Exclude = nil
+	if _, err := engine.Index(repoDir, false); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if result, err := engine.Index(repoDir, false); err != nil || !result.Cached {
+		t.Errorf("second Index: cached = %v, err = %v; want the cache", result != nil && result.Cached, err)
+	}
+	os.WriteFile(manifest, []byte("exclude: [\"gen/**\"]\n"), 0644)
+	if result, err := engine.Index(repoDir, false); err != nil || result.Cached {
+		t.Errorf("Index after a settings change: cached = %v, err = %v; want a re-index", result != nil && result.Cached, err)
+	}
+
+	os.WriteFile(manifest, []byte("exlude: [\"gen/**\"]\n"), 0644)
+	if _, err := engine.Plan(repoDir); err == nil || !strings.Contains(err.Error(), "exlude") {
+		t.Errorf("Plan with a misspelled setting: err = %v, want an unknown field error", err)
+	}
+}
+
+func TestRepoConfigEmbeddingModel(t *testing.T) {
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, ".fastcode.yaml"), []byte("embedding_model: repo-model\n"), 0644)
+
+	engine := NewEngine(Config{EmbeddingModel: "global-model"})
+	cfg, err := engine.repoConfig(repoDir)
+	if err != nil {
+		t.Fatalf("repoConfig: %v", err)
+	}
+	if cfg.EmbeddingModel != "repo-model" {
+		t.Errorf("EmbeddingModel = %q, want the file's repo-model over the global one", cfg.EmbeddingModel)
+	}
+
+	engine = NewEngine(Config{EmbeddingModel: "flag-model", ExplicitEmbeddingModel: true})
+	if cfg, err = engine.repoConfig(repoDir); err != nil {
+		t.Fatalf("repoConfig: %v", err)
+	}
+	if cfg.EmbeddingModel != "flag-model" {
+		t.Errorf("EmbeddingModel = %q, want the command-line flag-model", cfg.EmbeddingModel)
+	}
+}
+
+func TestLanguageDefaults(t *testing.T) {
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "hooks.js"), []byte("const useCart = () => load();\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "util.py"), []byte("def load(): pass\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "app.js"), []byte("function main() { useCart(); }\n"), 0644)
+
+	hasElement := func(engine *Engine, name string) bool {
+		for _, elem := range engine.elements {
+			if elem.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	cfg := DefaultConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.NoEmbeddings = true
+	engine := NewEngine(cfg)
+	result, err := engine.Index(repoDir, false)
+	if err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if result.PrimaryLanguage != "javascript" || !hasElement(engine, "useCart") {
+		t.Errorf("primary language %q: expected the arrow function useCart to be indexed by default", result.PrimaryLanguage)
+	}
+	if !engine.cache.Exists(engine.repoName) {
+		t.Error("an index built with language defaults should be cached")
+	}
+
+	// .fastcode.yaml overrides the language default, and re-indexes
+	os.WriteFile(filepath.Join(repoDir, ".fastcode.yaml"), []byte("js_arrow_functions: false\n"), 0644)
+	if result, err = engine.Index(repoDir, false); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if result.Cached || hasElement(engine, "useCart") {
+		t.Errorf("cached = %v: expected js_arrow_functions: false to re-index without arrow functions", result.Cached)
+	}
+}
+
+func TestRepoConfigLanguages(t *testing.T) {
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "app.mjs"), []byte("export function start() {}\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "page.tpl"), []byte("<h1>{{ title }}</h1>\n"), 0644)
+	manifest := filepath.Join(repoDir, ".fastcode.yaml")
+	os.WriteFile(manifest, []byte("languages: {mjs: js, .tpl: html}\n"), 0644)
+
+	cfg := DefaultConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.NoEmbeddings = true
+	engine := NewEngine(cfg)
+	if _, err := engine.Index(repoDir, false); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	languages := make(map[string]string)
+	for _, elem := range engine.elements {
+		if elem.Type == "file" {
+			languages[elem.RelativePath] = elem.Language
+		}
+	}
+	if languages["app.mjs"] != "javascript" || languages["page.tpl"] != "html" {
+		t.Errorf("file languages = %v, want app.mjs as javascript and page.tpl as html", languages)
+	}
+
+	os.WriteFile(manifest, []byte("languages: {.cbl: cobol}\n"), 0644)
+	if _, err := engine.Plan(repoDir); err == nil || !strings.Contains(err.Error(), "cobol") {
+		t.Errorf("Plan with an unsupported language: err = %v, want an error naming it", err)
+	}
+}
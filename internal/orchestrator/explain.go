@@ -0,0 +1,115 @@
+package orchestrator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/agent"
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+// maxExplainSignatures caps the signatures skimmed from an explained file.
+const maxExplainSignatures = 60
+
+// FileSummary is an explanation of one file of the loaded repository.
+type FileSummary struct {
+	RepoName    string                 `json:"repo_name"`
+	File        agent.ExplainedFile    `json:"file"`
+	Explanation *agent.FileExplanation `json:"explanation,omitempty"`
+	Warning     string                 `json:"warning,omitempty"`
+}
+
+// Explain resolves filePath to an indexed file like the agent's browse_file
+// tool (exact path, path suffix, then closest fuzzy match) and asks the
+// model to explain it from its signatures, docstrings, imports and
+// dependency graph edges. Without an API key only the skimmed file is
+// returned.
+func (e *Engine) Explain(filePath string) (*FileSummary, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.graphs == nil || len(e.elements) == 0 {
+		return nil, fmt.Errorf("no repository indexed — run 'fastcode index <path>' first")
+	}
+	relPath, ok := e.resolveFile(filePath, nil)
+	if !ok {
+		return nil, fmt.Errorf("file not found in the index: %s", filePath)
+	}
+
+	summary := &FileSummary{RepoName: e.repoName, File: e.explainedFile(relPath)}
+	if e.client.APIKey == "" {
+		summary.Warning = "no LLM API key configured: listing the file's definitions without an explanation"
+		return summary, nil
+	}
+
+	gen := agent.NewAnswerGenerator(e.client)
+	gen.RedactSecrets = !e.config.NoRedactSecrets
+	explanation, err := gen.ExplainFile(e.repoName, summary.File)
+	if err != nil {
+		return nil, err
+	}
+	summary.Explanation = explanation
+	return summary, nil
+}
+
+// explainedFile gathers the signatures, docstrings, imports and dependency
+// edges of the indexed file at relPath.
+func (e *Engine) explainedFile(relPath string) agent.ExplainedFile {
+	file := agent.ExplainedFile{Path: relPath}
+	filePaths := make(map[string]string) // file element ID -> path
+	var fileID string
+	for i := range e.elements {
+		elem := &e.elements[i]
+		if elem.Type == "file" {
+			filePaths[elem.ID] = elem.RelativePath
+		}
+		if elem.RelativePath != relPath {
+			continue
+		}
+		switch elem.Type {
+		case "file":
+			fileID = elem.ID
+			file.Language = elem.Language
+			file.Lines = elem.EndLine
+			file.Summary = elem.Summary
+			imports, _ := elem.Metadata["imports"].([]types.ImportInfo)
+			for _, imp := range imports {
+				file.Imports = append(file.Imports, imp.Module)
+			}
+		case "class", "function":
+			if len(file.Signatures) < maxExplainSignatures {
+				file.Signatures = append(file.Signatures, explainSignature(elem))
+			}
+		}
+	}
+
+	if fileID != "" {
+		file.DependsOn = graphPaths(e.graphs.Dependency.Successors(fileID), filePaths)
+		file.UsedBy = graphPaths(e.graphs.Dependency.Predecessors(fileID), filePaths)
+	}
+	return file
+}
+
+// explainSignature is elementSignature followed by the first line of the
+// element's docstring, if any.
+func explainSignature(elem *types.CodeElement) string {
+	sig := elementSignature(elem)
+	doc, _, _ := strings.Cut(strings.TrimSpace(elem.Docstring), "\n")
+	if doc != "" {
+		sig += " — " + doc
+	}
+	return sig
+}
+
+// graphPaths returns the sorted paths of the file elements among ids.
+func graphPaths(ids []string, filePaths map[string]string) []string {
+	var paths []string
+	for _, id := range ids {
+		if p, ok := filePaths[id]; ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -2,15 +2,23 @@ package orchestrator
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/duyhunghd6/fastcode-cli/internal/agent"
 	"github.com/duyhunghd6/fastcode-cli/internal/cache"
 	"github.com/duyhunghd6/fastcode-cli/internal/graph"
 	"github.com/duyhunghd6/fastcode-cli/internal/index"
+	"github.com/duyhunghd6/fastcode-cli/internal/llm"
+	"github.com/duyhunghd6/fastcode-cli/internal/session"
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
 )
 
@@ -107,7 +115,442 @@ func TestRebuildFromCacheEmptyVectors(t *testing.T) {
 	}
 }
 
+// TestFindPath tests resolving element names and walking the shortest path
+func TestFindPath(t *testing.T) {
+	engine := &Engine{}
+	if _, err := engine.FindPath("a", "b", nil); err == nil {
+		t.Error("expected error when no repository is indexed")
+	}
+
+	cached := &cache.CachedIndex{
+		RepoName: "test-repo",
+		Elements: []types.CodeElement{
+			{ID: "f1", Name: "handle", Type: "function", RelativePath: "api.go", StartLine: 3,
+				Metadata: map[string]any{"calls": []string{"save"}}},
+			{ID: "f2", Name: "save", Type: "function", RelativePath: "store.go", StartLine: 10,
+				Metadata: map[string]any{"calls": []string{"exec"}}},
+			{ID: "f3", Name: "exec", Type: "function", RelativePath: "db.go", StartLine: 7},
+			{ID: "f4", Name: "unrelated", Type: "function", RelativePath: "misc.go", StartLine: 1},
+		},
+	}
+	engine.elements = cached.Elements
+	engine.rebuildFromCache(cached)
+
+	path, err := engine.FindPath("handle", "exec", nil)
+	if err != nil {
+		t.Fatalf("FindPath: %v", err)
+	}
+	if len(path) != 3 || path[0].Name != "handle" || path[1].Name != "save" || path[2].Name != "exec" {
+		t.Errorf("unexpected path: %+v", path)
+	}
+
+	path, err = engine.FindPath("handle", "unrelated", nil)
+	if err != nil || path != nil {
+		t.Errorf("expected nil path without error, got %v, %v", path, err)
+	}
+
+	if _, err := engine.FindPath("handle", "missing", nil); err == nil {
+		t.Error("expected error for unknown element")
+	}
+}
+
+// TestImportantElements tests ranking elements by call-graph importance
+func TestFindSymbol(t *testing.T) {
+	engine := &Engine{}
+	if _, err := engine.FindSymbol("a", "", false, false); err == nil {
+		t.Error("expected error when no repository is indexed")
+	}
+
+	engine.elements = []types.CodeElement{
+		{ID: "c1", Name: "LoadConfigError", Type: "class", RelativePath: "errors.go", StartLine: 4},
+		{ID: "f1", Name: "reloadConfig", Type: "function", RelativePath: "watch.go", StartLine: 12,
+			Metadata: map[string]any{"exported": false}},
+		{ID: "f2", Name: "LoadConfig", Type: "function", RelativePath: "config.go", StartLine: 8},
+		{ID: "f3", Name: "Save", Type: "function", RelativePath: "config.go", StartLine: 30},
+	}
+	names := func(elems []types.CodeElement) string {
+		var out []string
+		for _, e := range elems {
+			out = append(out, e.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	found, _ := engine.FindSymbol("LoadConfig", "", false, false)
+	if got := names(found); got != "LoadConfig,reloadConfig,LoadConfigError" {
+		t.Errorf("substring matches = %s", got)
+	}
+	found, _ = engine.FindSymbol("LoadConfig", "", true, false)
+	if got := names(found); got != "LoadConfig" {
+		t.Errorf("exact matches = %s", got)
+	}
+	found, _ = engine.FindSymbol("config", "class", false, false)
+	if got := names(found); got != "LoadConfigError" {
+		t.Errorf("class matches = %s", got)
+	}
+	found, _ = engine.FindSymbol("LoadConfig", "", false, true)
+	if got := names(found); got != "LoadConfig,LoadConfigError" {
+		t.Errorf("public matches = %s", got)
+	}
+}
+
+func TestFindReferences(t *testing.T) {
+	engine := &Engine{}
+	if _, err := engine.FindReferences("save"); err == nil {
+		t.Error("expected error when no repository is indexed")
+	}
+
+	cached := &cache.CachedIndex{
+		RepoName: "test-repo",
+		Elements: []types.CodeElement{
+			{ID: "f1", Name: "handle", Type: "function", RelativePath: "api.go", StartLine: 3,
+				Metadata: map[string]any{"calls": []string{"save"}}},
+			{ID: "f2", Name: "save", Type: "function", RelativePath: "store.go", StartLine: 10},
+		},
+	}
+	engine.elements = cached.Elements
+	engine.rebuildFromCache(cached)
+
+	refs, err := engine.FindReferences("save")
+	if err != nil {
+		t.Fatalf("FindReferences: %v", err)
+	}
+	if len(refs) != 1 || refs[0].Name != "handle" || refs[0].StartLine != 3 {
+		t.Errorf("refs = %+v, want handle at api.go:3", refs)
+	}
+}
+
+func TestImportantElements(t *testing.T) {
+	engine := &Engine{}
+	cached := &cache.CachedIndex{
+		RepoName: "test-repo",
+		Elements: []types.CodeElement{
+			{ID: "f1", Name: "a", Type: "function", Metadata: map[string]any{"calls": []string{"core"}}},
+			{ID: "f2", Name: "b", Type: "function", Metadata: map[string]any{"calls": []string{"core"}}},
+			{ID: "f3", Name: "core", Type: "function"},
+		},
+	}
+	engine.elements = cached.Elements
+	engine.rebuildFromCache(cached)
+
+	ranked, err := engine.ImportantElements(graph.CallGraph, 2)
+	if err != nil {
+		t.Fatalf("ImportantElements: %v", err)
+	}
+	if len(ranked) != 2 || ranked[0].Element.Name != "core" {
+		t.Errorf("expected core ranked first with 2 results, got %+v", ranked)
+	}
+	if _, err := engine.ImportantElements("bogus", 5); err == nil {
+		t.Error("expected error for unknown graph")
+	}
+}
+
+// TestQueryDirectConfidenceFromCoverage tests that strong keyword matches give higher confidence
+func TestQueryDirectConfidenceFromCoverage(t *testing.T) {
+	engine := &Engine{}
+	cached := &cache.CachedIndex{
+		RepoName: "test-repo",
+		Elements: []types.CodeElement{
+			{ID: "f1", Name: "loadConfig", Type: "function", RelativePath: "config.go",
+				Docstring: "loadConfig reads the yaml settings file", Code: "func loadConfig(path string) {}"},
+			{ID: "f2", Name: "startServer", Type: "function", RelativePath: "server.go",
+				Docstring: "startServer listens for http requests", Code: "func startServer() {}"},
+			{ID: "f3", Name: "closeDB", Type: "function", RelativePath: "db.go",
+				Docstring: "closeDB releases the connection pool", Code: "func closeDB() {}"},
+		},
+	}
+	engine.elements = cached.Elements
+	engine.rebuildFromCache(cached)
+
+	strongQ := "loadConfig yaml settings"
+	strong, err := engine.queryDirect(strongQ, agent.ProcessQuery(strongQ), DefaultQueryOptions())
+	if err != nil {
+		t.Fatalf("queryDirect strong: %v", err)
+	}
+	weakQ := "yaml migration rollback scheduler"
+	weak, err := engine.queryDirect(weakQ, agent.ProcessQuery(weakQ), DefaultQueryOptions())
+	if err != nil {
+		t.Fatalf("queryDirect weak: %v", err)
+	}
+
+	if strong.Confidence <= weak.Confidence {
+		t.Errorf("strong match confidence %d should exceed weak match confidence %d", strong.Confidence, weak.Confidence)
+	}
+	if strong.Confidence < 0 || strong.Confidence > 100 || weak.Confidence < 0 {
+		t.Errorf("confidence out of range: strong=%d weak=%d", strong.Confidence, weak.Confidence)
+	}
+}
+
+// TestQuerySessionPersistsHistory tests that session queries are saved and reloadable
+func TestQuerySessionPersistsHistory(t *testing.T) {
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\nfunc main() {}\n"), 0644)
+
+	origKey := os.Getenv("OPENAI_API_KEY")
+	os.Unsetenv("OPENAI_API_KEY")
+	defer os.Setenv("OPENAI_API_KEY", origKey)
+
+	cfg := Config{CacheDir: t.TempDir(), SessionDir: t.TempDir(), BatchSize: 32, NoEmbeddings: true}
+	engine := NewEngine(cfg)
+	if _, err := engine.Index(repoDir, true); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	first, err := engine.QuerySession("s1", "what does main do")
+	if err != nil {
+		t.Fatalf("QuerySession: %v", err)
+	}
+	if _, err := engine.QuerySession("s1", "where is main"); err != nil {
+		t.Fatalf("QuerySession: %v", err)
+	}
+
+	sess, err := session.NewStore(cfg.SessionDir).Load("s1")
+	if err != nil {
+		t.Fatalf("reload session: %v", err)
+	}
+	if len(sess.Turns) != 2 {
+		t.Fatalf("expected 2 turns, got %d", len(sess.Turns))
+	}
+	if sess.Turns[0].Question != "what does main do" || sess.Turns[0].Answer != first.Answer {
+		t.Errorf("first turn not preserved: %+v", sess.Turns[0])
+	}
+	if len(sess.Turns[0].ElementIDs) != len(first.ElementIDs) {
+		t.Errorf("element IDs = %v, want %v", sess.Turns[0].ElementIDs, first.ElementIDs)
+	}
+	if sess.RepoName != filepath.Base(repoDir) {
+		t.Errorf("RepoName = %q", sess.RepoName)
+	}
+}
+
+func TestQuerySessionResumesHistory(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []llm.ChatMessage `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+		json.NewEncoder(w).Encode(map[string]any{"choices": []map[string]any{
+			{"message": map[string]any{"role": "assistant", "content": `{"confidence": 97, "reasoning": "main starts the program"}`}},
+		}})
+	}))
+	defer server.Close()
+
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\nfunc main() {}\n"), 0644)
+	engine := NewEngine(Config{CacheDir: t.TempDir(), SessionDir: t.TempDir(), BatchSize: 32, NoEmbeddings: true})
+	if _, err := engine.Index(repoDir, true); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	engine.client = llm.NewClientWith("test-key", "test-model", server.URL)
+
+	if _, err := engine.QuerySession("s1", "what does main do"); err != nil {
+		t.Fatalf("first QuerySession: %v", err)
+	}
+	for _, prompt := range prompts {
+		if strings.Contains(prompt, "Conversation History") {
+			t.Fatalf("a new session should have no history, got prompt:\n%s", prompt)
+		}
+	}
+
+	prompts = nil
+	if _, err := engine.QuerySession("s1", "where is it called"); err != nil {
+		t.Fatalf("second QuerySession: %v", err)
+	}
+	if len(prompts) < 2 {
+		t.Fatalf("expected round 1 and answer prompts, got %d", len(prompts))
+	}
+	// The round 1 prompt and the answer prompt carry the earlier turn
+	for _, prompt := range []string{prompts[0], prompts[len(prompts)-1]} {
+		if !strings.Contains(prompt, "Q1: what does main do") || !strings.Contains(prompt, "main starts the program") {
+			t.Errorf("prompt lacks the earlier turn:\n%s", prompt)
+		}
+	}
+}
+
 // TestQueryDirectWithEmbedderSuccess tests queryDirect where embedder successfully embeds
+func TestQueryDirectTopK(t *testing.T) {
+	var elements []types.CodeElement
+	for i := 0; i < 15; i++ {
+		elements = append(elements, types.CodeElement{ID: fmt.Sprintf("f%d", i), Name: fmt.Sprintf("handler%d", i),
+			Type: "function", RelativePath: fmt.Sprintf("h%d.go", i), Code: "func handler() { parse request }"})
+	}
+	cached := &cache.CachedIndex{RepoName: "test-repo", Elements: elements}
+
+	for _, tc := range []struct{ topK, want int }{{0, 10}, {3, 3}, {12, 12}} {
+		engine := &Engine{}
+		engine.elements = cached.Elements
+		engine.rebuildFromCache(cached)
+		res, err := engine.queryDirect("parse request", agent.ProcessQuery("parse request"), QueryOptions{TopK: tc.topK})
+		if err != nil {
+			t.Fatalf("queryDirect: %v", err)
+		}
+		if res.Elements != tc.want {
+			t.Errorf("TopK %d: got %d elements, want %d", tc.topK, res.Elements, tc.want)
+		}
+	}
+}
+
+func TestQueryDirectLanguages(t *testing.T) {
+	cached := &cache.CachedIndex{RepoName: "test-repo", Elements: []types.CodeElement{
+		{ID: "g", Name: "Route", Type: "function", Language: "go", RelativePath: "route.go", Code: "func Route() { register route }"},
+		{ID: "t", Name: "route", Type: "function", Language: "typescript", RelativePath: "route.ts", Code: "function route() { register route }"},
+		{ID: "d1", Name: "closeDB", Type: "function", Language: "go", RelativePath: "db.go", Code: "func closeDB() {}"},
+		{ID: "d2", Name: "openDB", Type: "function", Language: "go", RelativePath: "db.go", Code: "func openDB() {}"},
+		{ID: "d3", Name: "migrate", Type: "function", Language: "go", RelativePath: "db.go", Code: "func migrate() {}"},
+	}}
+	engine := &Engine{}
+	engine.elements = cached.Elements
+	engine.rebuildFromCache(cached)
+
+	opts := QueryOptions{Languages: []string{"typescript"}}
+	res, err := engine.queryDirect("register route", agent.ProcessQuery("register route"), opts)
+	if err != nil {
+		t.Fatalf("queryDirect: %v", err)
+	}
+	if len(res.ElementIDs) != 1 || res.ElementIDs[0] != "t" {
+		t.Errorf("ElementIDs = %v, want [t]", res.ElementIDs)
+	}
+	if got := opts.filterElements(cached.Elements[:2]); len(got) != 1 || got[0].ID != "t" {
+		t.Errorf("filterElements = %v", got)
+	}
+}
+
+func TestQueryDirectIncludeCode(t *testing.T) {
+	cached := &cache.CachedIndex{RepoName: "test-repo", Elements: []types.CodeElement{
+		{ID: "g", Name: "Route", Type: "function", Language: "go", RelativePath: "route.go", StartLine: 3, EndLine: 5, Code: "func Route() { register route }"},
+		{ID: "d1", Name: "closeDB", Type: "function", Language: "go", RelativePath: "db.go", Code: "func closeDB() {}"},
+		{ID: "d2", Name: "openDB", Type: "function", Language: "go", RelativePath: "db.go", Code: "func openDB() {}"},
+	}}
+	engine := &Engine{}
+	engine.elements = cached.Elements
+	engine.rebuildFromCache(cached)
+
+	opts := QueryOptions{TopK: 1}
+	res, err := engine.queryDirect("register route", agent.ProcessQuery("register route"), opts)
+	if err != nil {
+		t.Fatalf("queryDirect: %v", err)
+	}
+	if res.Code != nil {
+		t.Errorf("Code = %v without IncludeCode", res.Code)
+	}
+
+	opts.IncludeCode = true
+	if res, err = engine.queryDirect("register route", agent.ProcessQuery("register route"), opts); err != nil {
+		t.Fatalf("queryDirect: %v", err)
+	}
+	want := []RetrievedCode{{ID: "g", Path: "route.go", Type: "function", Name: "Route", Language: "go", StartLine: 3, EndLine: 5, Code: "func Route() { register route }"}}
+	if !reflect.DeepEqual(res.Code, want) {
+		t.Errorf("Code = %+v, want %+v", res.Code, want)
+	}
+}
+
+func TestQueryOptionsTests(t *testing.T) {
+	elements := []types.CodeElement{
+		{ID: "r", Type: "function", RelativePath: "route.go"},
+		{ID: "rt", Type: "function", RelativePath: "route_test.go"},
+		{ID: "m", Type: "function", RelativePath: "legacy.go", Metadata: map[string]any{"is_test": true}},
+	}
+	for _, tt := range []struct {
+		tests agent.TestFilter
+		want  string
+	}{
+		{agent.TestsInclude, "[r rt m]"},
+		{agent.TestsExclude, "[r]"},
+		{agent.TestsOnly, "[rt m]"},
+	} {
+		if got := fmt.Sprint(elementIDs(QueryOptions{Tests: tt.tests}.filterElements(elements))); got != tt.want {
+			t.Errorf("Tests=%q: filterElements = %s, want %s", tt.tests, got, tt.want)
+		}
+	}
+}
+
+func TestQueryFile(t *testing.T) {
+	cached := &cache.CachedIndex{RepoName: "test-repo", Elements: []types.CodeElement{
+		{ID: "rf", Name: "route.go", Type: "file", Language: "go", RelativePath: "api/route.go", Code: "package api"},
+		{ID: "r", Name: "Route", Type: "function", Language: "go", RelativePath: "api/route.go", Code: "func Route() { mount route table }"},
+		{ID: "hf", Name: "handlers.go", Type: "file", Language: "go", RelativePath: "api/handlers.go", Code: "package api"},
+		{ID: "h", Name: "register", Type: "function", Language: "go", RelativePath: "api/handlers.go", Code: "func register() { mount route handler }"},
+	}}
+	engine := NewEngine(Config{NoEmbeddings: true})
+	engine.client.APIKey = ""
+	engine.elements = cached.Elements
+	engine.rebuildFromCache(cached)
+
+	res, err := engine.QueryWithOptions("route table handler", QueryOptions{File: "rout.go"})
+	if err != nil {
+		t.Fatalf("QueryWithOptions: %v", err)
+	}
+	if len(res.ElementIDs) != 1 || res.ElementIDs[0] != "r" {
+		t.Errorf("ElementIDs = %v, want only the element of api/route.go", res.ElementIDs)
+	}
+	if _, err := engine.QueryWithOptions("register route", QueryOptions{File: "billing.py"}); err == nil ||
+		!strings.Contains(err.Error(), "not in the index") {
+		t.Errorf("unknown file: err = %v", err)
+	}
+}
+
+func TestEngineStatus(t *testing.T) {
+	engine := NewEngine(Config{NoEmbeddings: true})
+	engine.client.APIKey = ""
+	if st := engine.Status(); st.Indexed || st.Elements != 0 || st.LLMConfigured || st.Embeddings != EmbeddingsDisabled {
+		t.Errorf("empty engine status = %+v", st)
+	}
+
+	cached := &cache.CachedIndex{RepoName: "test-repo", Elements: []types.CodeElement{
+		{ID: "a", Name: "A", Type: "function", RelativePath: "a.go", RepoName: "test-repo", Code: "func A() {}"},
+	}, Vectors: map[string][]float32{"a": {1, 0}}}
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("MODEL", "gpt-test")
+	engine = NewEngine(Config{EmbeddingModel: "m", BatchSize: 8})
+	if st := engine.Status(); st.Embeddings != EmbeddingsPending {
+		t.Errorf("embeddings before indexing = %q, want pending", st.Embeddings)
+	}
+	engine.elements = cached.Elements
+	engine.repoName = cached.RepoName
+	engine.rebuildFromCache(cached)
+	st := engine.Status()
+	if !st.Indexed || st.RepoName != "test-repo" || st.Elements != 1 || !st.LLMConfigured || st.Model != "gpt-test" ||
+		st.Embeddings != EmbeddingsReady || st.Vectors != 1 {
+		t.Errorf("indexed engine status = %+v", st)
+	}
+}
+
+func TestEngineSearch(t *testing.T) {
+	engine := &Engine{}
+	if _, err := engine.Search("route", 0, 5); err == nil {
+		t.Error("Search without an index should fail")
+	}
+
+	cached := &cache.CachedIndex{RepoName: "test-repo", Elements: []types.CodeElement{
+		{ID: "a", Name: "Route", Type: "function", RelativePath: "a.go", StartLine: 3, EndLine: 9, Code: "func Route() { route }"},
+		{ID: "b", Name: "route", Type: "function", RelativePath: "b.go", Code: "func route() { route }"},
+		{ID: "c", Name: "open", Type: "function", RelativePath: "c.go", Code: "func open() {}"},
+		{ID: "d", Name: "close", Type: "function", RelativePath: "c.go", Code: "func close() {}"},
+		{ID: "e", Name: "read", Type: "function", RelativePath: "c.go", Code: "func read() {}"},
+	}}
+	engine.elements = cached.Elements
+	engine.rebuildFromCache(cached)
+
+	first, err := engine.Search("route", 0, 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if first.Total != 2 || len(first.Results) != 1 || first.NextOffset != 1 {
+		t.Fatalf("first page = %+v", first)
+	}
+	if hit := first.Results[0]; hit.RelativePath != "a.go" || hit.StartLine != 3 || hit.EndLine != 9 {
+		t.Errorf("first hit = %+v", hit)
+	}
+	second, _ := engine.Search("route", first.NextOffset, 1)
+	if len(second.Results) != 1 || second.Results[0].ID != "b" || second.NextOffset != 0 {
+		t.Errorf("second page = %+v", second)
+	}
+	if _, err := engine.Search("route", -1, 1); err == nil {
+		t.Error("a negative offset should fail")
+	}
+}
+
 func TestQueryDirectWithEmbedderSuccess(t *testing.T) {
 	// Mock embedding server
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -201,6 +644,56 @@ func TestQueryWithAgentRetrievalError(t *testing.T) {
 	}
 }
 
+// TestQueryWithAgentMetadata tests that retrieval metadata reaches the query result
+func TestQueryWithAgentMetadata(t *testing.T) {
+	mockLLM := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]string{
+					"role":    "assistant",
+					"content": `{"confidence": 95, "reasoning": "done", "tool_calls": []}`,
+				}},
+			},
+		})
+	}))
+	defer mockLLM.Close()
+
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\nfunc main() {}\n"), 0644)
+
+	origKey := os.Getenv("OPENAI_API_KEY")
+	origBase := os.Getenv("BASE_URL")
+	os.Setenv("OPENAI_API_KEY", "test-key")
+	os.Setenv("BASE_URL", mockLLM.URL)
+	defer func() {
+		os.Setenv("OPENAI_API_KEY", origKey)
+		os.Setenv("BASE_URL", origBase)
+	}()
+
+	engine := NewEngine(Config{CacheDir: t.TempDir(), BatchSize: 32, NoEmbeddings: true})
+	if _, err := engine.Index(repoDir, true); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	result, err := engine.Query("how does main work")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	for _, key := range []string{"query_complexity", "query_type", "tokens_used", "adaptive_params"} {
+		if _, ok := result.Metadata[key]; !ok {
+			t.Errorf("metadata missing %q: %v", key, result.Metadata)
+		}
+	}
+
+	data, _ := json.Marshal(result)
+	if !strings.Contains(string(data), `"metadata"`) {
+		t.Errorf("JSON output should include metadata: %s", data)
+	}
+	if result.Mode != QueryModeAgent || result.Warning != "" {
+		t.Errorf("Mode = %q, Warning = %q; want agent mode without warning", result.Mode, result.Warning)
+	}
+}
+
 // TestQueryWithAgentAnswerError tests queryWithAgent when answer generation fails
 func TestQueryWithAgentAnswerError(t *testing.T) {
 	callCount := 0
@@ -393,13 +886,37 @@ func TestSimpleAnswerMultipleResults(t *testing.T) {
 		Type: "class", Name: "Bar", RelativePath: "b.go",
 		StartLine: 10, EndLine: 30, Signature: "type Bar struct",
 	})
+	sa.addResult(&types.CodeElement{
+		Type: "function", Name: "early", RelativePath: "b.go",
+		StartLine: 2, EndLine: 4,
+	})
 
 	result := sa.String()
-	if result == "" {
-		t.Error("expected non-empty result")
+	want := `Found matching code elements:
+
+a.go
+  L1-5 [function] foo
+      func foo()
+
+b.go
+  L2-4 [function] early
+  L10-30 [class] Bar
+      type Bar struct
+`
+	if result != want {
+		t.Errorf("String() =\n%s\nwant\n%s", result, want)
 	}
-	if len(sa.lines) != 2 {
-		t.Errorf("expected 2 lines, got %d", len(sa.lines))
+}
+
+func TestSimpleAnswerShowCode(t *testing.T) {
+	sa := &simpleAnswer{showCode: true}
+	sa.addResult(&types.CodeElement{
+		Type: "function", Name: "foo", RelativePath: "a.go",
+		StartLine: 1, EndLine: 3, Code: "import \"os\"\n\nfunc foo() {}",
+	})
+	want := "Found matching code elements:\n\na.go\n  L1-3 [function] foo\n      | import \"os\"\n      | \n      | func foo() {}\n"
+	if got := sa.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
 	}
 }
 
@@ -432,3 +949,205 @@ func TestVectorStoreInRebuild(t *testing.T) {
 		t.Error("expected nil for nonexistent")
 	}
 }
+
+func TestEngineEmbeddingDimensionsCache(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var data []map[string]any
+		for i := range req.Input {
+			data = append(data, map[string]any{"index": i, "embedding": []float64{3, 4, 12}})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer mockServer.Close()
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("BASE_URL", mockServer.URL)
+	t.Setenv("EMBEDDING_URL", mockServer.URL)
+
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\nfunc main() {}\n"), 0644)
+	cfg := Config{CacheDir: t.TempDir(), BatchSize: 32, EmbeddingModel: "text-embedding-3-small", EmbeddingDimensions: 2, TruncateEmbeddings: true}
+
+	engine := NewEngine(cfg)
+	if _, err := engine.Index(repoDir, true); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	cached, err := engine.cache.Load(engine.repoName)
+	if err != nil {
+		t.Fatalf("cache load: %v", err)
+	}
+	if cached.EmbeddingDimensions != 2 || len(cached.Vectors) == 0 {
+		t.Fatalf("cached dimensions = %d with %d vectors", cached.EmbeddingDimensions, len(cached.Vectors))
+	}
+	for id, vec := range cached.Vectors {
+		if len(vec) != 2 {
+			t.Errorf("vector %s has %d dimensions, want 2", id, len(vec))
+		}
+	}
+
+	if result, err := NewEngine(cfg).Index(repoDir, false); err != nil || !result.Cached {
+		t.Errorf("same dimensions: cached = %v, err = %v; want the cache reused", result != nil && result.Cached, err)
+	}
+	cfg.EmbeddingDimensions = 0
+	if result, err := NewEngine(cfg).Index(repoDir, false); err != nil || result.Cached {
+		t.Errorf("native dimensions: cached = %v, err = %v; want a re-index", result != nil && result.Cached, err)
+	}
+
+	// Vectors from another model are not comparable either
+	cfg.EmbeddingModel = "other-model"
+	if result, err := NewEngine(cfg).Index(repoDir, false); err != nil || result.Cached {
+		t.Errorf("other model: cached = %v, err = %v; want a re-index", result != nil && result.Cached, err)
+	}
+	if result, err := NewEngine(cfg).Index(repoDir, false); err != nil || !result.Cached {
+		t.Errorf("same model: cached = %v, err = %v; want the cache reused", result != nil && result.Cached, err)
+	}
+}
+
+func TestEngineEmbeddingBaseURL(t *testing.T) {
+	var hits atomic.Int32
+	embedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{{"index": 0, "embedding": []float64{0.1, 0.2}}},
+		})
+	}))
+	defer embedServer.Close()
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("BASE_URL", "http://127.0.0.1:1") // the chat endpoint is never used for embeddings
+	t.Setenv("EMBEDDING_BASE_URL", "")
+	t.Setenv("EMBEDDING_URL", "")
+
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\nfunc main() {}\n"), 0644)
+	engine := NewEngine(Config{CacheDir: t.TempDir(), BatchSize: 32, EmbeddingBaseURL: embedServer.URL})
+	if engine.client.BaseURL != "http://127.0.0.1:1" || engine.client.EmbeddingBaseURL != embedServer.URL {
+		t.Fatalf("BaseURL = %q, EmbeddingBaseURL = %q", engine.client.BaseURL, engine.client.EmbeddingBaseURL)
+	}
+	if _, err := engine.Index(repoDir, true); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if hits.Load() == 0 {
+		t.Error("embeddings were not requested from EmbeddingBaseURL")
+	}
+}
+
+func TestSummarizeWithoutLLM(t *testing.T) {
+	engine := &Engine{client: llm.NewClientWith("", "", "")}
+	if _, err := engine.Summarize(5); err == nil {
+		t.Error("expected error when no repository is indexed")
+	}
+
+	engine.repoName = "app"
+	engine.elements = []types.CodeElement{
+		{ID: "f1", Type: "file", RelativePath: "util.go", Language: "go", EndLine: 40},
+		{ID: "f2", Type: "file", RelativePath: "server.go", Language: "go", EndLine: 20},
+		{ID: "f3", Type: "file", RelativePath: "README.md", Language: "markdown", EndLine: 90},
+		{ID: "c1", Type: "class", Name: "Server", RelativePath: "server.go", Signature: "type Server struct", StartLine: 3},
+		{ID: "m1", Type: "function", Name: "Start", RelativePath: "server.go", StartLine: 9},
+	}
+	engine.graphs = graph.NewCodeGraphs()
+	engine.graphs.BuildGraphs(engine.elements)
+
+	summary, err := engine.Summarize(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Overview != nil || summary.Warning == "" {
+		t.Errorf("expected a warning and no overview without an API key: %+v", summary)
+	}
+	if len(summary.Files) != 2 || summary.Files[0].Path != "server.go" || summary.Files[1].Path != "util.go" {
+		t.Fatalf("central files = %+v", summary.Files)
+	}
+	want := []string{"type Server struct (line 3)", "function Start (line 9)"}
+	if !slices.Equal(summary.Files[0].Signatures, want) {
+		t.Errorf("signatures = %q, want %q", summary.Files[0].Signatures, want)
+	}
+}
+
+func TestExplainWithoutLLM(t *testing.T) {
+	engine := &Engine{client: llm.NewClientWith("", "", "")}
+	if _, err := engine.Explain("server.py"); err == nil {
+		t.Error("expected error when no repository is indexed")
+	}
+
+	engine.repoName = "app"
+	engine.elements = []types.CodeElement{
+		{ID: "f1", Type: "file", RelativePath: "app/server.py", Language: "python", EndLine: 40,
+			Metadata: map[string]any{"imports": []types.ImportInfo{{Module: "app.util", Line: 1}}}},
+		{ID: "f2", Type: "file", RelativePath: "app/util.py", Language: "python", EndLine: 20},
+		{ID: "c1", Type: "class", Name: "Server", RelativePath: "app/server.py", Signature: "class Server", StartLine: 3,
+			Docstring: "Serves requests.\n\nMore detail."},
+		{ID: "m1", Type: "function", Name: "start", RelativePath: "app/server.py", StartLine: 9},
+	}
+	engine.rebuildFromCache(&cache.CachedIndex{RepoName: "app", Elements: engine.elements})
+
+	if _, err := engine.Explain("missing/nothing_like_it.rs"); err == nil {
+		t.Error("expected error for a file that is not indexed")
+	}
+	summary, err := engine.Explain("server.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Explanation != nil || summary.Warning == "" {
+		t.Errorf("expected a warning and no explanation without an API key: %+v", summary)
+	}
+	f := summary.File
+	if f.Path != "app/server.py" || f.Lines != 40 || !slices.Equal(f.Imports, []string{"app.util"}) {
+		t.Errorf("file = %+v", f)
+	}
+	want := []string{"class Server (line 3) — Serves requests.", "function start (line 9)"}
+	if !slices.Equal(f.Signatures, want) {
+		t.Errorf("signatures = %q, want %q", f.Signatures, want)
+	}
+	if !slices.Equal(f.DependsOn, []string{"app/util.py"}) {
+		t.Errorf("DependsOn = %v, want [app/util.py]", f.DependsOn)
+	}
+}
+
+func TestEmbeddingCounts(t *testing.T) {
+	data := &cache.CachedIndex{
+		Elements: []types.CodeElement{
+			{ID: "f", Type: "file", StartLine: 1, EndLine: 900},
+			{ID: "a", Type: "function", StartLine: 3, EndLine: 20},
+			{ID: "b", Type: "function", StartLine: 30, EndLine: 40},
+		},
+		Vectors: map[string][]float32{"a": {1, 0}, "b": {0, 1}},
+	}
+	cfg := Config{EmbedMaxElementLines: 200}
+
+	engine := &Engine{embedder: &llm.Embedder{}}
+	if embedded, skipped := engine.embeddingCounts(data, cfg); embedded != 2 || skipped != 1 {
+		t.Errorf("embeddingCounts = %d, %d, want 2, 1", embedded, skipped)
+	}
+	// Nothing is skipped for size when nothing is embedded
+	engine.embedder = nil
+	if _, skipped := engine.embeddingCounts(data, cfg); skipped != 0 {
+		t.Errorf("skipped = %d without an embedder, want 0", skipped)
+	}
+}
+
+func TestIsIndexed(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), "app")
+	os.MkdirAll(repoDir, 0o755)
+	os.WriteFile(filepath.Join(repoDir, "main.py"), []byte("def main():\n    pass\n"), 0o644)
+
+	engine := NewEngine(Config{CacheDir: t.TempDir(), NoEmbeddings: true})
+	if engine.IsIndexed(repoDir) {
+		t.Fatal("IsIndexed before indexing")
+	}
+	if _, err := engine.Index(repoDir, false); err != nil {
+		t.Fatal(err)
+	}
+	if !engine.IsIndexed(repoDir) || engine.IsIndexed(t.TempDir()) {
+		t.Error("IsIndexed should only report the indexed repository")
+	}
+
+	// A repository name override names the cache
+	engine.config.RepoName = "renamed"
+	if engine.IsIndexed(repoDir) {
+		t.Error("IsIndexed found a cache under the directory name despite RepoName")
+	}
+}
@@ -0,0 +1,42 @@
+package orchestrator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/loader"
+)
+
+// IndexPlan lists the files indexing a repository would parse, without
+// parsing or embedding them.
+type IndexPlan struct {
+	RepoName  string            `json:"repo_name"`
+	RootPath  string            `json:"root_path"`
+	Files     []loader.FileInfo `json:"files"`
+	Languages map[string]int    `json:"languages"`
+	TotalSize int64             `json:"total_size"`
+}
+
+// Plan walks the repository at repoPath with the engine's file filters (and
+// those of its .fastcode.yaml), Since and repository name, returning the
+// files that Index would load sorted by path.
+func (e *Engine) Plan(repoPath string) (*IndexPlan, error) {
+	repo, _, err := e.loadRepository(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("load repository: %w", err)
+	}
+	plan := &IndexPlan{
+		RepoName:  repo.Name,
+		RootPath:  repo.RootPath,
+		Files:     repo.Files,
+		Languages: repo.LanguageStats(),
+	}
+	if plan.Files == nil {
+		plan.Files = []loader.FileInfo{}
+	}
+	sort.Slice(plan.Files, func(i, j int) bool { return plan.Files[i].RelativePath < plan.Files[j].RelativePath })
+	for _, f := range plan.Files {
+		plan.TotalSize += f.Size
+	}
+	return plan, nil
+}
@@ -0,0 +1,62 @@
+package orchestrator
+
+import (
+	"slices"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/config"
+)
+
+// repoConfig returns the engine configuration resolved for the repository
+// at repoPath: the settings of its .fastcode.yaml override the engine's,
+// which come from the global config, the environment and the defaults.
+// Include and Exclude globs given to the engine are kept and the file's are
+// added to them.
+func (e *Engine) repoConfig(repoPath string) (Config, error) {
+	rc, err := config.LoadRepoConfig(repoPath)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg := e.config
+	cfg.repo = rc
+	cfg.Include = append(slices.Clip(cfg.Include), rc.Include...)
+	cfg.Exclude = append(slices.Clip(cfg.Exclude), rc.Exclude...)
+	if rc.JSArrowFunctions != nil {
+		cfg.JSArrowFunctions = *rc.JSArrowFunctions
+	}
+	if rc.MarkdownSections != nil {
+		cfg.MarkdownSections = *rc.MarkdownSections
+	}
+	if rc.EmbeddingModel != "" && !cfg.ExplicitEmbeddingModel {
+		cfg.EmbeddingModel = rc.EmbeddingModel
+	}
+	return cfg, nil
+}
+
+// withLanguageDefaults returns c with the parser options suited to a
+// repository whose primary language is lang, where .fastcode.yaml does not
+// set them: JavaScript and TypeScript repositories, which define many of
+// their functions as arrow functions (React hooks, exported handlers), get
+// JSArrowFunctions.
+func (c Config) withLanguageDefaults(lang string) Config {
+	switch lang {
+	case "javascript", "typescript", "tsx":
+		if !c.JSArrowFunctions && (c.repo == nil || c.repo.JSArrowFunctions == nil) {
+			c.JSArrowFunctions = true
+			c.jsArrowDefault = true
+		}
+	}
+	return c
+}
+
+// repoConfigHash identifies the .fastcode.yaml settings and language
+// defaults c was resolved with, or is "" when there were none.
+func (c Config) repoConfigHash() string {
+	hash := ""
+	if c.repo != nil {
+		hash = c.repo.Hash
+	}
+	if c.jsArrowDefault {
+		hash += "+js_arrow_functions"
+	}
+	return hash
+}
@@ -0,0 +1,79 @@
+package orchestrator
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/graph"
+	"github.com/duyhunghd6/fastcode-cli/internal/loader"
+)
+
+// loadRepository loads the repository at repoPath with the file filters of
+// the configuration resolved for it (see repoConfig), which it also returns.
+// With Config.Since set it only loads the files changed since that git ref
+// and, if the repository has a cached index, the files of their direct graph
+// neighbors; it loads every file when git cannot list changes. The returned
+// configuration has the defaults of the loaded files' primary language (see
+// Config.withLanguageDefaults).
+func (e *Engine) loadRepository(repoPath string) (*loader.Repository, Config, error) {
+	repoCfg, err := e.repoConfig(repoPath)
+	if err != nil {
+		return nil, Config{}, err
+	}
+	cfg := repoCfg.loaderConfig()
+	if e.config.Since != "" {
+		changed, err := loader.ChangedFiles(repoPath, e.config.Since)
+		if err != nil {
+			logger.Warn("cannot list changed files, indexing all files", "since", e.config.Since, "err", err)
+		} else {
+			neighbors := e.neighborFiles(e.repoNameFor(repoPath), changed)
+			logger.Info("indexing changed files", "since", e.config.Since, "changed", len(changed), "neighbors", len(neighbors))
+			cfg.Files = append(changed, neighbors...)
+		}
+	}
+	repo, err := loader.LoadRepository(repoPath, cfg)
+	if err != nil {
+		return nil, Config{}, err
+	}
+	return repo, repoCfg.withLanguageDefaults(loader.PrimaryLanguage(repo.LanguageStats())), nil
+}
+
+// neighborFiles returns the files, other than files themselves, holding the
+// direct graph neighbors of their elements in the cached index of repoName.
+// It returns nil when there is no usable cache.
+func (e *Engine) neighborFiles(repoName string, files []string) []string {
+	if !e.cache.Exists(repoName) {
+		return nil
+	}
+	cached, err := e.cache.Load(repoName)
+	if err != nil {
+		logger.Warn("cannot load cached index for graph neighbors", "repo", repoName, "err", err)
+		return nil
+	}
+	g := graph.NewCodeGraphs()
+	g.BuildGraphs(cached.Elements)
+	paths := make(map[string]string, len(cached.Elements))
+	for _, elem := range cached.Elements {
+		paths[elem.ID] = filepath.ToSlash(elem.RelativePath)
+	}
+
+	inFiles := make(map[string]bool, len(files))
+	for _, f := range files {
+		inFiles[f] = true
+	}
+	seen := make(map[string]bool)
+	var neighbors []string
+	for _, elem := range cached.Elements {
+		if !inFiles[filepath.ToSlash(elem.RelativePath)] {
+			continue
+		}
+		for _, id := range g.GetRelatedElements(elem.ID, 1) {
+			if p := paths[id]; p != "" && !inFiles[p] && !seen[p] {
+				seen[p] = true
+				neighbors = append(neighbors, p)
+			}
+		}
+	}
+	sort.Strings(neighbors)
+	return neighbors
+}
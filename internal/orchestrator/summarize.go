@@ -0,0 +1,128 @@
+package orchestrator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/agent"
+	"github.com/duyhunghd6/fastcode-cli/internal/graph"
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+// maxOverviewSignatures caps the signatures skimmed from each central file.
+const maxOverviewSignatures = 20
+
+// RepoSummary is an overview of the loaded repository written from its most
+// central files.
+type RepoSummary struct {
+	RepoName        string               `json:"repo_name"`
+	PrimaryLanguage string               `json:"primary_language,omitempty"`
+	Files           []agent.OverviewFile `json:"files"`
+	Overview        *agent.Overview      `json:"overview,omitempty"`
+	Warning         string               `json:"warning,omitempty"`
+}
+
+// Summarize picks the topFiles most central files of the loaded repository,
+// by PageRank over the dependency graph and then by how many classes and
+// functions they define, and asks the model for an overview of their
+// signatures. Without an API key only the central files are listed.
+func (e *Engine) Summarize(topFiles int) (*RepoSummary, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.graphs == nil || len(e.elements) == 0 {
+		return nil, fmt.Errorf("no repository indexed — run 'fastcode index <path>' first")
+	}
+
+	summary := &RepoSummary{
+		RepoName:        e.repoName,
+		PrimaryLanguage: e.language,
+		Files:           e.centralFiles(topFiles),
+	}
+	if e.client.APIKey == "" {
+		summary.Warning = "no LLM API key configured: listing the central files without an overview"
+		return summary, nil
+	}
+
+	gen := agent.NewAnswerGenerator(e.client)
+	gen.RedactSecrets = !e.config.NoRedactSecrets
+	overview, err := gen.GenerateOverview(e.repoName, summary.Files)
+	if err != nil {
+		return nil, err
+	}
+	summary.Overview = overview
+	return summary, nil
+}
+
+// centralFiles ranks the indexed files by dependency PageRank, then by their
+// class and function count and length, returning the top n (all if n <= 0)
+// with the signatures of what they define.
+func (e *Engine) centralFiles(n int) []agent.OverviewFile {
+	scores := e.graphs.ImportanceScores(graph.DependencyGraph)
+	defined := make(map[string][]*types.CodeElement) // repo/path -> classes and functions
+	for i := range e.elements {
+		elem := &e.elements[i]
+		if elem.Type == "class" || elem.Type == "function" {
+			key := elem.RepoName + "/" + elem.RelativePath
+			defined[key] = append(defined[key], elem)
+		}
+	}
+
+	type rankedFile struct {
+		file  *types.CodeElement
+		score float64
+		defs  []*types.CodeElement
+	}
+	var ranked []rankedFile
+	for i := range e.elements {
+		elem := &e.elements[i]
+		if elem.Type != "file" || elem.Language == "markdown" {
+			continue
+		}
+		ranked = append(ranked, rankedFile{elem, scores[elem.ID], defined[elem.RepoName+"/"+elem.RelativePath]})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if len(a.defs) != len(b.defs) {
+			return len(a.defs) > len(b.defs)
+		}
+		if a.file.EndLine != b.file.EndLine {
+			return a.file.EndLine > b.file.EndLine
+		}
+		return a.file.ID < b.file.ID
+	})
+	if n > 0 && len(ranked) > n {
+		ranked = ranked[:n]
+	}
+
+	files := make([]agent.OverviewFile, len(ranked))
+	for i, r := range ranked {
+		files[i] = agent.OverviewFile{
+			Path:     r.file.RelativePath,
+			Language: r.file.Language,
+			Score:    r.score,
+			Elements: len(r.defs),
+			Summary:  r.file.Summary,
+		}
+		for _, def := range r.defs {
+			if len(files[i].Signatures) == maxOverviewSignatures {
+				break
+			}
+			files[i].Signatures = append(files[i].Signatures, elementSignature(def))
+		}
+	}
+	return files
+}
+
+// elementSignature returns an element's signature with its line, falling
+// back to its type and name when the parser recorded no signature.
+func elementSignature(elem *types.CodeElement) string {
+	sig := elem.Signature
+	if sig == "" {
+		sig = elem.Type + " " + elem.Name
+	}
+	return fmt.Sprintf("%s (line %d)", sig, elem.StartLine)
+}
@@ -0,0 +1,167 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/cache"
+	"github.com/duyhunghd6/fastcode-cli/internal/index"
+	"github.com/duyhunghd6/fastcode-cli/internal/loader"
+)
+
+// IndexWorkspace indexes several repositories and loads them together as one
+// workspace, so queries, searches and graphs span all of them. Each
+// repository is cached separately under its own name, and elements keep
+// their RepoName so answers can tell the repositories apart.
+//
+// Filesystem-based agent tools (search_codebase, list_directory and reading
+// unindexed files) work on a single repository root and are not available in
+// a workspace; the agent searches the index instead. Every repository is
+// embedded with the engine's model, so an embedding_model set in a
+// repository's .fastcode.yaml is ignored.
+func (e *Engine) IndexWorkspace(ctx context.Context, repoPaths []string, forceReindex bool) (*IndexResult, error) {
+	repoPaths = workspaceRepoPaths(repoPaths)
+	if len(repoPaths) == 1 {
+		return e.IndexContext(ctx, repoPaths[0], forceReindex)
+	}
+	if len(repoPaths) == 0 {
+		return nil, fmt.Errorf("workspace needs at least one repository")
+	}
+	if e.config.RepoName != "" {
+		return nil, fmt.Errorf("a repository name cannot be set for a workspace of %d repositories", len(repoPaths))
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.useEmbeddingModel(e.config)
+	combined := &cache.CachedIndex{
+		Vectors:      make(map[string][]float32),
+		ChunkVectors: make(map[string][][]float32),
+	}
+	result := &IndexResult{Cached: true, Languages: make(map[string]int)}
+	seen := make(map[string]string)
+	for _, repoPath := range repoPaths {
+		repo, cfg, err := e.loadRepository(repoPath)
+		if err != nil {
+			return nil, fmt.Errorf("load repository %s: %w", repoPath, err)
+		}
+		if cfg.EmbeddingModel != e.config.EmbeddingModel {
+			logger.Warn("ignoring the repository embedding model in a workspace", "repo", repo.Name,
+				"model", cfg.EmbeddingModel, "using", e.config.EmbeddingModel)
+			cfg.EmbeddingModel = e.config.EmbeddingModel
+		}
+		// Element IDs and cache entries are keyed by the repository name
+		if other, ok := seen[repo.Name]; ok {
+			return nil, fmt.Errorf("repositories %s and %s are both named %q", other, repoPath, repo.Name)
+		}
+		seen[repo.Name] = repoPath
+
+		data, cached, err := e.loadOrIndexRepo(ctx, repo, cfg, forceReindex)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("loaded workspace repository", "repo", repo.Name, "elements", len(data.Elements), "cached", cached)
+
+		combined.Elements = append(combined.Elements, data.Elements...)
+		for id, vec := range data.Vectors {
+			combined.Vectors[id] = vec
+		}
+		for id, chunks := range data.ChunkVectors {
+			combined.ChunkVectors[id] = chunks
+		}
+		// The oldest repository decides how current the workspace is
+		if combined.IndexedAt.IsZero() || data.IndexedAt.Before(combined.IndexedAt) {
+			combined.IndexedAt = data.IndexedAt
+		}
+		for _, f := range data.ParseErrorFiles {
+			result.ParseErrorFiles = append(result.ParseErrorFiles, path.Join(repo.Name, f))
+		}
+		for _, f := range data.TruncatedFiles {
+			result.TruncatedFiles = append(result.TruncatedFiles, path.Join(repo.Name, f))
+		}
+
+		embedded, skipped := e.embeddingCounts(data, cfg)
+		result.Embedded += embedded
+		result.EmbedSkipped += skipped
+
+		result.Repos = append(result.Repos, repo.Name)
+		result.TotalFiles += len(repo.Files)
+		result.Cached = result.Cached && cached
+		for lang, n := range repo.LanguageStats() {
+			result.Languages[lang] += n
+		}
+	}
+
+	e.repoName = strings.Join(result.Repos, "+")
+	e.repoPath = ""
+	e.language = loader.PrimaryLanguage(result.Languages)
+	e.elements = combined.Elements
+	e.indexed = combined.IndexedAt
+	e.rebuildFromCache(combined)
+	e.progress(IndexProgress{Phase: PhaseGraphs, Done: 1, Total: 1, Elements: len(e.elements)})
+
+	result.RepoName = e.repoName
+	result.TotalElements = len(e.elements)
+	result.GraphStats = e.graphs.Stats()
+	result.PrimaryLanguage = e.language
+	result.ParseErrors = len(result.ParseErrorFiles)
+	return result, nil
+}
+
+// loadOrIndexRepo returns the cached index of repo or, if there is none (or
+// forceReindex is set), parses and embeds the repository under cfg and
+// caches it.
+func (e *Engine) loadOrIndexRepo(ctx context.Context, repo *loader.Repository, cfg Config, forceReindex bool) (*cache.CachedIndex, bool, error) {
+	if !forceReindex && e.cacheUsable() && e.cache.Exists(repo.Name) {
+		cached, err := e.loadCache(repo.Name, cfg)
+		if err == nil {
+			return cached, true, nil
+		}
+		logger.Warn("cache load failed, re-indexing", "repo", repo.Name, "err", err)
+	}
+
+	e.progress(IndexProgress{Phase: PhaseLoaded, Done: len(repo.Files), Total: len(repo.Files)})
+	indexer := cfg.newIndexer(repo.Name)
+	indexer.Progress = func(done, total, elements int) {
+		e.progress(IndexProgress{Phase: PhaseParsing, Done: done, Total: total, Elements: elements})
+	}
+	elements, err := indexer.IndexRepository(repo)
+	if err != nil {
+		return nil, false, fmt.Errorf("index repository %s: %w", repo.Name, err)
+	}
+
+	// Embed into a store of this repository alone, so it is cached by itself
+	vs := index.NewVectorStore()
+	hybrid := index.NewHybridRetriever(vs, e.newBM25())
+	hybrid.EmbedMaxLines = cfg.EmbedMaxElementLines
+	err = hybrid.IndexElementsWithChunks(ctx, elements, indexer.Chunks, e.embedder)
+	if ctx.Err() != nil {
+		return nil, false, fmt.Errorf("indexing cancelled: %w", ctx.Err())
+	}
+	if err != nil {
+		logger.Warn("embedding failed, using BM25 only", "repo", repo.Name, "err", err)
+	}
+	return e.saveIndex(repo.Name, cfg, indexer, vs), false, nil
+}
+
+// workspaceRepoPaths resolves repository paths to absolute paths, dropping
+// duplicates while keeping their order.
+func workspaceRepoPaths(repoPaths []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, p := range repoPaths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			abs = p
+		}
+		if !seen[abs] {
+			seen[abs] = true
+			out = append(out, abs)
+		}
+	}
+	return out
+}
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"reflect"
 	"testing"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
 )
 
 // Test nodeText utility function
@@ -250,7 +253,10 @@ function process(items) {
 		t.Fatal("nil")
 	}
 	if len(result.Functions) < 1 {
-		t.Errorf("expected at least 1 function, got %d", len(result.Functions))
+		t.Fatalf("expected at least 1 function, got %d", len(result.Functions))
+	}
+	if doc := result.Functions[0].Docstring; doc != "Process items and return results" {
+		t.Errorf("docstring = %q, want the JSDoc summary without tags", doc)
 	}
 }
 
@@ -392,3 +398,96 @@ docstring
 		}
 	}
 }
+
+func TestExportedNames(t *testing.T) {
+	for name, want := range map[string]bool{
+		"LoadConfig": true, "loadConfig": false, "Ünicode": true, "_x": false, "": false,
+	} {
+		if got := isGoExported(name); got != want {
+			t.Errorf("isGoExported(%q) = %v, want %v", name, got, want)
+		}
+	}
+	for name, want := range map[string]bool{
+		"load": true, "_load": false, "__private": false, "__init__": true,
+	} {
+		if got := isPythonPublic(name); got != want {
+			t.Errorf("isPythonPublic(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// parseGoSource runs the Go extractor directly, since ParseFile treats Go
+// files as non-code.
+func parseGoSource(t *testing.T, content string) *types.FileParseResult {
+	t.Helper()
+	p := New()
+	code := []byte(content)
+	tree, err := p.tsParser.Parse(code, "go")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	defer tree.Close()
+	result := &types.FileParseResult{FilePath: "generic.go", Language: "go"}
+	parseGo(tree.RootNode(), code, result)
+	return result
+}
+
+func TestParseGoGenericFunctions(t *testing.T) {
+	result := parseGoSource(t, `package slices
+
+func Map[T, U any](s []T, f func(T) U) []U {
+	return nil
+}
+
+func Lookup[K comparable, V any](m map[K]V, k K) (V, bool) {
+	v, ok := m[k]
+	return v, ok
+}
+
+func (l *List[T]) Push(v T) {}
+`)
+	if len(result.Functions) != 3 {
+		t.Fatalf("got %d functions, want 3: %+v", len(result.Functions), result.Functions)
+	}
+	m := result.Functions[0]
+	if m.Name != "Map" || m.TypeParams != "[T, U any]" || m.ReturnType != "[]U" ||
+		!reflect.DeepEqual(m.Parameters, []string{"s []T", "f func(T) U"}) {
+		t.Errorf("Map = %+v", m)
+	}
+	l := result.Functions[1]
+	if l.Name != "Lookup" || l.TypeParams != "[K comparable, V any]" || l.ReturnType != "(V, bool)" ||
+		!reflect.DeepEqual(l.Parameters, []string{"m map[K]V", "k K"}) {
+		t.Errorf("Lookup = %+v", l)
+	}
+	if push := result.Functions[2]; push.Name != "Push" || push.ClassName != "List" {
+		t.Errorf("method on a generic type = %+v, want Push on List", push)
+	}
+}
+
+func TestParseGoGenericTypes(t *testing.T) {
+	result := parseGoSource(t, `package list
+
+type List[T any] struct {
+	items []T
+}
+
+type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}
+
+type Celsius Temperature
+`)
+	if len(result.Classes) != 3 {
+		t.Fatalf("got %d types, want 3: %+v", len(result.Classes), result.Classes)
+	}
+	if c := result.Classes[0]; c.Name != "List" || c.TypeParams != "[T any]" || c.Kind != "struct" {
+		t.Errorf("List = %+v", c)
+	}
+	if c := result.Classes[1]; c.Name != "Pair" || c.TypeParams != "[K comparable, V any]" {
+		t.Errorf("Pair = %+v", c)
+	}
+	if c := result.Classes[2]; c.Name != "Celsius" {
+		t.Errorf("defined type named %q, want Celsius", c.Name)
+	}
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -236,6 +237,34 @@ function syncFunc() {
 	}
 }
 
+// TestParseJSCallCounts tests that repeated calls are counted per callee
+func TestParseJSCallCounts(t *testing.T) {
+	p := New()
+	content := `function run(items) {
+    validate(items);
+    for (const item of items) {
+        save(item);
+        save(item.copy());
+    }
+    save(items[0]);
+}
+`
+	result := p.ParseFile("run.js", content)
+	if result == nil || len(result.Functions) != 1 {
+		t.Fatalf("expected 1 function, got %+v", result)
+	}
+	fn := result.Functions[0]
+	if len(fn.Calls) != 3 {
+		t.Errorf("expected 3 distinct callees, got %v", fn.Calls)
+	}
+	if fn.CallCounts["save"] != 3 {
+		t.Errorf("CallCounts[save] = %d, want 3", fn.CallCounts["save"])
+	}
+	if fn.CallCounts["validate"] != 1 {
+		t.Errorf("CallCounts[validate] = %d, want 1", fn.CallCounts["validate"])
+	}
+}
+
 // === Python class edge cases ===
 
 // TestParsePythonClassDecorators tests Python class with decorators
@@ -253,10 +282,8 @@ class Config:
 		t.Fatal("expected parse result")
 	}
 
-	for _, cls := range result.Classes {
-		if cls.Name == "Config" {
-			t.Logf("Config decorators: %v", cls.Decorators)
-		}
+	if len(result.Classes) != 1 || !reflect.DeepEqual(result.Classes[0].Decorators, []string{"dataclass"}) {
+		t.Errorf("classes = %+v, want Config decorated with dataclass", result.Classes)
 	}
 }
 
@@ -363,6 +390,34 @@ int main() {
 	t.Logf("c: %d classes, %d functions, %d imports", len(result.Classes), len(result.Functions), len(result.Imports))
 }
 
+func TestParseCIncludes(t *testing.T) {
+	p := New()
+	content := `#include <stdio.h>
+#include "util.h"
+#ifdef DEBUG
+#include "debug/log.h"
+#endif
+
+int main(void) { return 0; }
+`
+	result := p.ParseFile("main.c", content)
+	if result == nil {
+		t.Fatal("expected parse result")
+	}
+	if len(result.Imports) != 3 {
+		t.Fatalf("expected 3 includes, got %d", len(result.Imports))
+	}
+	if result.Imports[0].Module != "stdio.h" || !result.Imports[0].IsSystem {
+		t.Errorf("include[0] = %+v, want system stdio.h", result.Imports[0])
+	}
+	if result.Imports[1].Module != "util.h" || result.Imports[1].IsSystem {
+		t.Errorf("include[1] = %+v, want local util.h", result.Imports[1])
+	}
+	if result.Imports[2].Module != "debug/log.h" {
+		t.Errorf("include[2] = %+v, want debug/log.h", result.Imports[2])
+	}
+}
+
 // === Java parser ===
 
 func TestParseJavaBasic(t *testing.T) {
@@ -425,6 +480,45 @@ function createServer(config: Config): Server {
 	t.Logf("ts: %d classes, %d functions, %d imports", len(result.Classes), len(result.Functions), len(result.Imports))
 }
 
+// TestParseTSDecorators tests that class and method decorators are collected,
+// both on exported and plain classes
+func TestParseTSDecorators(t *testing.T) {
+	p := New()
+	content := `@Injectable()
+export class UserService {
+    @Cron("0 * * * *")
+    refresh(): void {}
+
+    find(): void {}
+}
+
+@Component({ selector: "app-root" })
+class AppComponent {}
+`
+	result := p.ParseFile("user.service.ts", content)
+	if result == nil {
+		t.Fatal("expected parse result")
+	}
+	decorators := make(map[string][]string)
+	for _, cls := range result.Classes {
+		decorators[cls.Name] = cls.Decorators
+		for _, m := range cls.Methods {
+			decorators[cls.Name+"."+m.Name] = m.Decorators
+		}
+	}
+	want := map[string][]string{
+		"UserService":         {"Injectable"},
+		"UserService.refresh": {"Cron"},
+		"UserService.find":    nil,
+		"AppComponent":        {"Component"},
+	}
+	for name, w := range want {
+		if got := decorators[name]; !reflect.DeepEqual(got, w) {
+			t.Errorf("%s decorators = %q, want %q", name, got, w)
+		}
+	}
+}
+
 // === TSX parser ===
 
 func TestParseTSXComponent(t *testing.T) {
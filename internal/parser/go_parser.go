@@ -2,6 +2,8 @@ package parser
 
 import (
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
 	sitter "github.com/smacker/go-tree-sitter"
@@ -92,17 +94,27 @@ func extractGoFunction(node *sitter.Node, code []byte, className string) types.F
 		ClassName: className,
 		IsMethod:  className != "",
 	}
+	params := false
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
 		switch child.Type() {
 		case "identifier":
 			fn.Name = child.Content(code)
+		case "type_parameter_list":
+			fn.TypeParams = child.Content(code)
 		case "parameter_list":
-			fn.Parameters = extractGoParams(child, code)
-		case "type_identifier", "pointer_type", "qualified_type", "slice_type", "map_type", "array_type":
+			// The parameters, then a parenthesized result list
+			if !params {
+				fn.Parameters = extractGoParams(child, code)
+				params = true
+			} else {
+				fn.ReturnType = child.Content(code)
+			}
+		case "type_identifier", "pointer_type", "qualified_type", "slice_type", "map_type", "array_type", "generic_type":
 			fn.ReturnType = child.Content(code)
 		}
 	}
+	fn.IsExported = isGoExported(fn.Name)
 	fn.Docstring = extractGoLeadingComment(node.Parent(), code, int(node.StartPoint().Row))
 	return fn
 }
@@ -113,23 +125,29 @@ func extractGoMethod(node *sitter.Node, code []byte) types.FunctionInfo {
 		EndLine:   int(node.EndPoint().Row) + 1,
 		IsMethod:  true,
 	}
+	lists := 0
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
 		switch child.Type() {
 		case "parameter_list":
-			if fn.Receiver == "" {
-				// First parameter_list is the receiver
+			// The receiver, the parameters, then a parenthesized result list
+			switch lists {
+			case 0:
 				fn.Receiver = child.Content(code)
 				fn.ClassName = extractReceiverType(child, code)
-			} else {
+			case 1:
 				fn.Parameters = extractGoParams(child, code)
+			default:
+				fn.ReturnType = child.Content(code)
 			}
+			lists++
 		case "field_identifier":
 			fn.Name = child.Content(code)
-		case "type_identifier", "pointer_type", "qualified_type", "slice_type", "map_type", "array_type":
+		case "type_identifier", "pointer_type", "qualified_type", "slice_type", "map_type", "array_type", "generic_type":
 			fn.ReturnType = child.Content(code)
 		}
 	}
+	fn.IsExported = isGoExported(fn.Name)
 	fn.Docstring = extractGoLeadingComment(node.Parent(), code, int(node.StartPoint().Row))
 	return fn
 }
@@ -143,7 +161,12 @@ func extractGoTypeSpec(node *sitter.Node, code []byte) *types.ClassInfo {
 		child := node.Child(i)
 		switch child.Type() {
 		case "type_identifier":
-			ci.Name = child.Content(code)
+			// The defined name; a later one is the underlying type (type A B)
+			if ci.Name == "" {
+				ci.Name = child.Content(code)
+			}
+		case "type_parameter_list":
+			ci.TypeParams = child.Content(code)
 		case "struct_type":
 			ci.Kind = "struct"
 			ci.Bases = extractGoEmbeddedTypes(child, code)
@@ -155,6 +178,7 @@ func extractGoTypeSpec(node *sitter.Node, code []byte) *types.ClassInfo {
 	if ci.Name == "" {
 		return nil
 	}
+	ci.IsExported = isGoExported(ci.Name)
 	ci.Docstring = extractGoLeadingComment(node.Parent(), code, int(node.StartPoint().Row))
 	return ci
 }
@@ -175,16 +199,8 @@ func extractReceiverType(node *sitter.Node, code []byte) string {
 		child := node.Child(i)
 		if child.Type() == "parameter_declaration" {
 			for j := 0; j < int(child.ChildCount()); j++ {
-				c := child.Child(j)
-				switch c.Type() {
-				case "type_identifier":
-					return c.Content(code)
-				case "pointer_type":
-					for k := 0; k < int(c.ChildCount()); k++ {
-						if c.Child(k).Type() == "type_identifier" {
-							return c.Child(k).Content(code)
-						}
-					}
+				if name := goTypeName(child.Child(j), code); name != "" {
+					return name
 				}
 			}
 		}
@@ -192,6 +208,22 @@ func extractReceiverType(node *sitter.Node, code []byte) string {
 	return ""
 }
 
+// goTypeName returns the name of the type a receiver type node refers to,
+// looking through pointers and type arguments: "*List[T]" gives "List".
+func goTypeName(node *sitter.Node, code []byte) string {
+	switch node.Type() {
+	case "type_identifier":
+		return node.Content(code)
+	case "pointer_type", "generic_type":
+		for i := 0; i < int(node.ChildCount()); i++ {
+			if name := goTypeName(node.Child(i), code); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
 func extractGoEmbeddedTypes(node *sitter.Node, code []byte) []string {
 	var bases []string
 	for i := 0; i < int(node.ChildCount()); i++ {
@@ -229,6 +261,7 @@ func extractGoInterfaceMethods(node *sitter.Node, code []byte, interfaceName str
 					fn.Parameters = extractGoParams(c, code)
 				}
 			}
+			fn.IsExported = isGoExported(fn.Name)
 			methods = append(methods, fn)
 		}
 	}
@@ -257,3 +290,10 @@ func extractGoLeadingComment(parent *sitter.Node, code []byte, targetRow int) st
 	}
 	return strings.Join(comments, "\n")
 }
+
+// isGoExported reports whether a Go identifier is exported: it starts with
+// an upper-case letter.
+func isGoExported(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
@@ -0,0 +1,300 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// parseJava extracts the package, imports, classes, interfaces, enums, records
+// and their methods from Java source.
+func parseJava(root *sitter.Node, code []byte, result *types.FileParseResult) {
+	for i := 0; i < int(root.ChildCount()); i++ {
+		child := root.Child(i)
+		switch child.Type() {
+		case "comment", "block_comment", "line_comment":
+			// Leading file comment (license header or file Javadoc) before any declaration
+			if result.ModuleDocstring == "" && result.Package == "" && len(result.Imports) == 0 {
+				result.ModuleDocstring = cleanCComment(child.Content(code))
+			}
+
+		case "package_declaration":
+			for j := 0; j < int(child.ChildCount()); j++ {
+				c := child.Child(j)
+				if c.Type() == "scoped_identifier" || c.Type() == "identifier" {
+					result.Package = c.Content(code)
+					break
+				}
+			}
+
+		case "import_declaration":
+			result.Imports = append(result.Imports, extractJavaImport(child, code))
+
+		case "class_declaration", "interface_declaration", "enum_declaration",
+			"record_declaration", "annotation_type_declaration":
+			extractJavaType(child, code, javaDocBefore(root, i, code), result)
+		}
+	}
+}
+
+// extractJavaImport converts an import_declaration into an ImportInfo.
+// Wildcard imports keep the package as the module and record "*" as the name,
+// so "com.example.*" can still be resolved to the com/example directory.
+func extractJavaImport(node *sitter.Node, code []byte) types.ImportInfo {
+	imp := types.ImportInfo{
+		Line: int(node.StartPoint().Row) + 1,
+	}
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "static":
+			imp.IsStatic = true
+		case "scoped_identifier", "identifier":
+			imp.Module = child.Content(code)
+		case "asterisk":
+			imp.Names = append(imp.Names, "*")
+		}
+	}
+	if len(imp.Names) == 0 && imp.Module != "" {
+		name := imp.Module
+		if idx := strings.LastIndex(name, "."); idx >= 0 {
+			name = name[idx+1:]
+		}
+		imp.Names = []string{name}
+	}
+	return imp
+}
+
+// extractJavaType extracts a class-like declaration and appends it (and any
+// nested type declarations) to result.Classes.
+func extractJavaType(node *sitter.Node, code []byte, docstring string, result *types.FileParseResult) {
+	ci := types.ClassInfo{
+		StartLine: int(node.StartPoint().Row) + 1,
+		EndLine:   int(node.EndPoint().Row) + 1,
+		Docstring: docstring,
+		Kind:      strings.TrimSuffix(node.Type(), "_declaration"),
+	}
+
+	var body *sitter.Node
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "modifiers":
+			ci.Decorators = extractJavaAnnotations(child, code)
+			ci.IsExported = hasJavaModifier(child, "public")
+		case "identifier":
+			if ci.Name == "" {
+				ci.Name = child.Content(code)
+			}
+		case "superclass", "super_interfaces", "extends_interfaces":
+			ci.Bases = append(ci.Bases, extractJavaTypeNames(child, code)...)
+		case "class_body", "interface_body", "enum_body", "annotation_type_body":
+			body = child
+		}
+	}
+	if ci.Name == "" {
+		return
+	}
+
+	// Nested types are appended after their enclosing class
+	var nested []*sitter.Node
+	var nestedDocs []string
+	if body != nil {
+		members := []*sitter.Node{body}
+		// enum bodies keep their members in a trailing enum_body_declarations node
+		for i := 0; i < int(body.ChildCount()); i++ {
+			if body.Child(i).Type() == "enum_body_declarations" {
+				members = append(members, body.Child(i))
+			}
+		}
+		for _, m := range members {
+			for i := 0; i < int(m.ChildCount()); i++ {
+				member := m.Child(i)
+				switch member.Type() {
+				case "method_declaration", "constructor_declaration", "compact_constructor_declaration":
+					fn := extractJavaMethod(member, code, ci.Name)
+					if fn.Name != "" {
+						// Interface members are public unless declared private
+						if ci.Kind == "interface" || ci.Kind == "annotation_type" {
+							fn.IsExported = !javaMemberHasModifier(member, "private")
+						}
+						fn.Docstring = javaDocBefore(m, i, code)
+						ci.Methods = append(ci.Methods, fn)
+					}
+				case "class_declaration", "interface_declaration", "enum_declaration",
+					"record_declaration", "annotation_type_declaration":
+					nested = append(nested, member)
+					nestedDocs = append(nestedDocs, javaDocBefore(m, i, code))
+				}
+			}
+		}
+	}
+
+	result.Classes = append(result.Classes, ci)
+	for i, n := range nested {
+		extractJavaType(n, code, nestedDocs[i], result)
+	}
+}
+
+// extractJavaMethod extracts a method or constructor declaration.
+func extractJavaMethod(node *sitter.Node, code []byte, className string) types.FunctionInfo {
+	fn := types.FunctionInfo{
+		StartLine: int(node.StartPoint().Row) + 1,
+		EndLine:   int(node.EndPoint().Row) + 1,
+		ClassName: className,
+		IsMethod:  true,
+	}
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "modifiers":
+			fn.Decorators = extractJavaAnnotations(child, code)
+			fn.IsExported = hasJavaModifier(child, "public")
+		case "type_parameters", "throws", "dimensions":
+			// not part of the signature we index
+		case "identifier":
+			fn.Name = child.Content(code)
+		case "formal_parameters":
+			for j := 0; j < int(child.ChildCount()); j++ {
+				p := child.Child(j)
+				if p.Type() == "formal_parameter" || p.Type() == "spread_parameter" {
+					fn.Parameters = append(fn.Parameters, p.Content(code))
+				}
+			}
+		case "block", "constructor_body":
+			fn.Calls, fn.CallCounts = extractJavaCalls(child, code)
+		default:
+			// The return type is the only other named node before the method name
+			if fn.Name == "" && child.IsNamed() {
+				fn.ReturnType = child.Content(code)
+			}
+		}
+	}
+	return fn
+}
+
+// hasJavaModifier reports whether a modifiers node holds the keyword
+// modifier, e.g. "public" or "static".
+func hasJavaModifier(modifiers *sitter.Node, modifier string) bool {
+	for i := 0; i < int(modifiers.ChildCount()); i++ {
+		if modifiers.Child(i).Type() == modifier {
+			return true
+		}
+	}
+	return false
+}
+
+// javaMemberHasModifier reports whether a declaration's modifiers include
+// modifier.
+func javaMemberHasModifier(node *sitter.Node, modifier string) bool {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if c := node.Child(i); c.Type() == "modifiers" {
+			return hasJavaModifier(c, modifier)
+		}
+	}
+	return false
+}
+
+// extractJavaAnnotations returns the names of the annotations found in a
+// modifiers node (e.g. "GetMapping" for `@GetMapping("/users")`, see
+// decoratorName).
+func extractJavaAnnotations(modifiers *sitter.Node, code []byte) []string {
+	var annotations []string
+	for i := 0; i < int(modifiers.ChildCount()); i++ {
+		c := modifiers.Child(i)
+		if c.Type() == "marker_annotation" || c.Type() == "annotation" {
+			annotations = append(annotations, decoratorName(c.Content(code)))
+		}
+	}
+	return annotations
+}
+
+// extractJavaTypeNames collects type names from extends/implements clauses,
+// dropping generic arguments so they can match class names in the inheritance graph.
+func extractJavaTypeNames(node *sitter.Node, code []byte) []string {
+	var names []string
+	for i := 0; i < int(node.ChildCount()); i++ {
+		c := node.Child(i)
+		switch c.Type() {
+		case "type_identifier", "scoped_type_identifier":
+			names = append(names, c.Content(code))
+		case "generic_type":
+			name := c.Content(code)
+			if idx := strings.Index(name, "<"); idx >= 0 {
+				name = name[:idx]
+			}
+			names = append(names, name)
+		case "type_list":
+			names = append(names, extractJavaTypeNames(c, code)...)
+		}
+	}
+	return names
+}
+
+// javaDocBefore returns the cleaned Javadoc comment directly preceding the
+// child at index i of parent, or "" if there is none.
+func javaDocBefore(parent *sitter.Node, i int, code []byte) string {
+	if i == 0 {
+		return ""
+	}
+	prev := parent.Child(i - 1)
+	switch prev.Type() {
+	case "comment", "block_comment":
+		text := prev.Content(code)
+		if strings.HasPrefix(text, "/**") {
+			return cleanJavadoc(text)
+		}
+	}
+	return ""
+}
+
+// cleanJavadoc strips the comment delimiters and leading asterisks from a Javadoc block.
+func cleanJavadoc(comment string) string {
+	comment = strings.TrimPrefix(comment, "/**")
+	comment = strings.TrimSuffix(comment, "*/")
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		line = strings.TrimPrefix(line, "*")
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// extractJavaCalls collects the names of methods invoked within a method body,
+// in first-call order, along with how many times each is invoked.
+func extractJavaCalls(node *sitter.Node, code []byte) ([]string, map[string]int) {
+	counts := make(map[string]int)
+	var calls []string
+	var walk func(*sitter.Node)
+	walk = func(n *sitter.Node) {
+		for i := 0; i < int(n.ChildCount()); i++ {
+			child := n.Child(i)
+			if child.Type() == "method_invocation" {
+				// The method name is the last identifier before the argument list
+				var name string
+				for j := 0; j < int(child.ChildCount()); j++ {
+					c := child.Child(j)
+					if c.Type() == "identifier" {
+						name = c.Content(code)
+					} else if c.Type() == "argument_list" {
+						break
+					}
+				}
+				if name != "" {
+					if counts[name] == 0 {
+						calls = append(calls, name)
+					}
+					counts[name]++
+				}
+			}
+			walk(child)
+		}
+	}
+	walk(node)
+	if len(calls) == 0 {
+		return nil, nil
+	}
+	return calls, counts
+}
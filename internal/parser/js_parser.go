@@ -7,7 +7,10 @@ import (
 	sitter "github.com/smacker/go-tree-sitter"
 )
 
-func parseJS(root *sitter.Node, code []byte, result *types.FileParseResult) {
+// parseJS extracts imports, classes, interfaces and functions from JS/TS
+// source. With arrowFunctions set, functions assigned to variables and object
+// keys are extracted too.
+func parseJS(root *sitter.Node, code []byte, result *types.FileParseResult, arrowFunctions bool) {
 	// Extract module docstring (mimic Python: only check root-level children, no recursion!)
 	for i := 0; i < int(root.ChildCount()); i++ {
 		child := root.Child(i)
@@ -79,6 +82,20 @@ func parseJS(root *sitter.Node, code []byte, result *types.FileParseResult) {
 			if fn.Name != "" {
 				result.Functions = append(result.Functions, fn)
 			}
+		} else if arrowFunctions && (typ == "lexical_declaration" || typ == "variable_declaration") {
+			result.Functions = append(result.Functions, extractJSArrowFunctions(n, code)...)
+			// Keep walking: initializers may hold objects with function members
+			for i := 0; i < int(n.ChildCount()); i++ {
+				visit(n.Child(i), currentClass)
+			}
+		} else if arrowFunctions && typ == "pair" {
+			if fn := extractJSPairFunction(n, code); fn.Name != "" {
+				result.Functions = append(result.Functions, fn)
+			} else {
+				for i := 0; i < int(n.ChildCount()); i++ {
+					visit(n.Child(i), currentClass)
+				}
+			}
 		} else {
 			for i := 0; i < int(n.ChildCount()); i++ {
 				visit(n.Child(i), currentClass)
@@ -158,6 +175,7 @@ func extractJSInterface(node *sitter.Node, code []byte) types.ClassInfo {
 			ci.Methods = extractJSInterfaceMethods(child, code, ci.Name)
 		}
 	}
+	ci.IsExported = jsExported(node)
 	return ci
 }
 
@@ -168,10 +186,12 @@ func extractJSInterfaceMethods(body *sitter.Node, code []byte, className string)
 		child := body.Child(i)
 		if child.Type() == "method_signature" || child.Type() == "method_definition" {
 			fn := types.FunctionInfo{
-				StartLine: int(child.StartPoint().Row) + 1,
-				EndLine:   int(child.EndPoint().Row) + 1,
-				IsMethod:  true,
-				ClassName: className,
+				StartLine:  int(child.StartPoint().Row) + 1,
+				EndLine:    int(child.EndPoint().Row) + 1,
+				IsMethod:   true,
+				ClassName:  className,
+				Docstring:  jsDocBefore(child, code),
+				Decorators: extractJSDecorators(child, code),
 			}
 			for j := 0; j < int(child.ChildCount()); j++ {
 				c := child.Child(j)
@@ -182,6 +202,7 @@ func extractJSInterfaceMethods(body *sitter.Node, code []byte, className string)
 					fn.Parameters = extractJSParams(c, code)
 				}
 			}
+			fn.IsExported = jsMemberExported(child, code)
 			if fn.Name != "" {
 				methods = append(methods, fn)
 			}
@@ -192,9 +213,10 @@ func extractJSInterfaceMethods(body *sitter.Node, code []byte, className string)
 
 func extractJSClass(node *sitter.Node, code []byte) types.ClassInfo {
 	ci := types.ClassInfo{
-		StartLine: int(node.StartPoint().Row) + 1,
-		EndLine:   int(node.EndPoint().Row) + 1,
-		Kind:      "class",
+		StartLine:  int(node.StartPoint().Row) + 1,
+		EndLine:    int(node.EndPoint().Row) + 1,
+		Kind:       "class",
+		Decorators: extractJSDecorators(node, code),
 	}
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
@@ -212,19 +234,131 @@ func extractJSClass(node *sitter.Node, code []byte) types.ClassInfo {
 			ci.Methods = extractJSClassMethods(child, code, ci.Name)
 		}
 	}
+	ci.IsExported = jsExported(node)
 	return ci
 }
 
+// jsExported reports whether a declaration is exported: it sits in an
+// export statement, directly or as a variable declared by one
+// ("export const f = () => …").
+func jsExported(node *sitter.Node) bool {
+	for n := node.Parent(); n != nil; n = n.Parent() {
+		switch n.Type() {
+		case "export_statement":
+			return true
+		case "variable_declarator", "lexical_declaration", "variable_declaration":
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// jsMemberExported reports whether a class or interface member is part of
+// the public API: its class is exported and the member is not private
+// ("#name", or TypeScript's private and protected).
+func jsMemberExported(node *sitter.Node, code []byte) bool {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		c := node.Child(i)
+		switch c.Type() {
+		case "private_property_identifier":
+			return false
+		case "accessibility_modifier":
+			if m := c.Content(code); m == "private" || m == "protected" {
+				return false
+			}
+		}
+	}
+	for n := node.Parent(); n != nil; n = n.Parent() {
+		switch n.Type() {
+		case "class_declaration", "interface_declaration":
+			return jsExported(n)
+		case "class_body", "interface_body", "object_type":
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// extractJSDecorators returns the names of the decorators (e.g. "Injectable"
+// for "@Injectable()", see decoratorName) applied to a class or method.
+// Depending on the grammar they are children of the node itself, siblings
+// directly before it (TypeScript class bodies), or children of an enclosing
+// export statement.
+func extractJSDecorators(node *sitter.Node, code []byte) []string {
+	var decorators []string
+	for prev := node.PrevSibling(); prev != nil && prev.Type() == "decorator"; prev = prev.PrevSibling() {
+		decorators = append([]string{decoratorName(prev.Content(code))}, decorators...)
+	}
+	if parent := node.Parent(); parent != nil && parent.Type() == "export_statement" {
+		for i := 0; i < int(parent.ChildCount()); i++ {
+			if c := parent.Child(i); c.Type() == "decorator" {
+				decorators = append(decorators, decoratorName(c.Content(code)))
+			}
+		}
+	}
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if c := node.Child(i); c.Type() == "decorator" {
+			decorators = append(decorators, decoratorName(c.Content(code)))
+		}
+	}
+	return decorators
+}
+
+// jsDocBefore returns the cleaned JSDoc block directly preceding a function or
+// method, or "" if there is none. Decorators between the comment and the node
+// are skipped, and the search climbs out of export statements and variable
+// declarations so "export function f" and "const f = () => …" find their docs.
+func jsDocBefore(node *sitter.Node, code []byte) string {
+	for n := node; n != nil; n = n.Parent() {
+		prev := n.PrevSibling()
+		for prev != nil && prev.Type() == "decorator" {
+			prev = prev.PrevSibling()
+		}
+		if prev != nil && prev.Type() == "comment" {
+			if text := prev.Content(code); strings.HasPrefix(text, "/**") {
+				return cleanJSDoc(text)
+			}
+			return ""
+		}
+		parent := n.Parent()
+		if parent == nil {
+			return ""
+		}
+		switch parent.Type() {
+		case "export_statement", "variable_declarator", "lexical_declaration", "variable_declaration":
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// cleanJSDoc returns the description of a JSDoc block: the text before its
+// first @tag, without the comment delimiters and "*" gutter.
+func cleanJSDoc(comment string) string {
+	var lines []string
+	for _, line := range strings.Split(cleanJavadoc(comment), "\n") {
+		if strings.HasPrefix(line, "@") {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
 func extractJSClassMethods(body *sitter.Node, code []byte, className string) []types.FunctionInfo {
 	var methods []types.FunctionInfo
 	for i := 0; i < int(body.ChildCount()); i++ {
 		child := body.Child(i)
 		if child.Type() == "method_definition" {
 			fn := types.FunctionInfo{
-				StartLine: int(child.StartPoint().Row) + 1,
-				EndLine:   int(child.EndPoint().Row) + 1,
-				IsMethod:  true,
-				ClassName: className,
+				StartLine:  int(child.StartPoint().Row) + 1,
+				EndLine:    int(child.EndPoint().Row) + 1,
+				IsMethod:   true,
+				ClassName:  className,
+				Decorators: extractJSDecorators(child, code),
 			}
 			for j := 0; j < int(child.ChildCount()); j++ {
 				c := child.Child(j)
@@ -234,7 +368,7 @@ func extractJSClassMethods(body *sitter.Node, code []byte, className string) []t
 				case "formal_parameters":
 					fn.Parameters = extractJSParams(c, code)
 				case "statement_block":
-					fn.Calls = extractJSCalls(c, code)
+					fn.Calls, fn.CallCounts = extractJSCalls(c, code)
 				}
 			}
 			// Check async
@@ -242,6 +376,7 @@ func extractJSClassMethods(body *sitter.Node, code []byte, className string) []t
 			if len(text) > 5 && text[:5] == "async" {
 				fn.IsAsync = true
 			}
+			fn.IsExported = jsMemberExported(child, code)
 			methods = append(methods, fn)
 		}
 	}
@@ -250,10 +385,13 @@ func extractJSClassMethods(body *sitter.Node, code []byte, className string) []t
 
 func extractJSMethod(node *sitter.Node, code []byte, className string) types.FunctionInfo {
 	fn := types.FunctionInfo{
-		StartLine: int(node.StartPoint().Row) + 1,
-		EndLine:   int(node.EndPoint().Row) + 1,
-		ClassName: className,
-		IsMethod:  true,
+		StartLine:  int(node.StartPoint().Row) + 1,
+		EndLine:    int(node.EndPoint().Row) + 1,
+		ClassName:  className,
+		IsMethod:   true,
+		IsExported: jsMemberExported(node, code),
+		Docstring:  jsDocBefore(node, code),
+		Decorators: extractJSDecorators(node, code),
 	}
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
@@ -265,7 +403,7 @@ func extractJSMethod(node *sitter.Node, code []byte, className string) types.Fun
 		case "type_annotation":
 			fn.ReturnType = child.Content(code)
 		case "statement_block":
-			fn.Calls = extractJSCalls(child, code)
+			fn.Calls, fn.CallCounts = extractJSCalls(child, code)
 		}
 	}
 	text := node.Content(code)
@@ -277,10 +415,12 @@ func extractJSMethod(node *sitter.Node, code []byte, className string) types.Fun
 
 func extractJSFunction(node *sitter.Node, code []byte, className string) types.FunctionInfo {
 	fn := types.FunctionInfo{
-		StartLine: int(node.StartPoint().Row) + 1,
-		EndLine:   int(node.EndPoint().Row) + 1,
-		ClassName: className,
-		IsMethod:  className != "",
+		StartLine:  int(node.StartPoint().Row) + 1,
+		EndLine:    int(node.EndPoint().Row) + 1,
+		ClassName:  className,
+		IsMethod:   className != "",
+		IsExported: jsExported(node),
+		Docstring:  jsDocBefore(node, code),
 	}
 
 	for i := 0; i < int(node.ChildCount()); i++ {
@@ -293,7 +433,7 @@ func extractJSFunction(node *sitter.Node, code []byte, className string) types.F
 		case "type_annotation":
 			fn.ReturnType = child.Content(code)
 		case "statement_block":
-			fn.Calls = extractJSCalls(child, code)
+			fn.Calls, fn.CallCounts = extractJSCalls(child, code)
 		}
 	}
 	text := node.Content(code)
@@ -303,40 +443,96 @@ func extractJSFunction(node *sitter.Node, code []byte, className string) types.F
 	return fn
 }
 
+// extractJSArrowFunctions extracts the functions assigned in a variable
+// declaration ("const greet = (name) => …", "let f = function () {…}"),
+// named after the variable. Declarators holding other values are skipped.
 func extractJSArrowFunctions(node *sitter.Node, code []byte) []types.FunctionInfo {
 	var fns []types.FunctionInfo
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
-		if child.Type() == "variable_declarator" {
-			fn := types.FunctionInfo{
-				StartLine: int(child.StartPoint().Row) + 1,
-				EndLine:   int(child.EndPoint().Row) + 1,
-			}
-			for j := 0; j < int(child.ChildCount()); j++ {
-				c := child.Child(j)
-				switch c.Type() {
-				case "identifier":
-					fn.Name = c.Content(code)
-				case "arrow_function", "function":
-					for k := 0; k < int(c.ChildCount()); k++ {
-						p := c.Child(k)
-						if p.Type() == "formal_parameters" {
-							fn.Parameters = extractJSParams(p, code)
-						}
-						if p.Type() == "statement_block" {
-							fn.Calls = extractJSCalls(p, code)
-						}
-					}
-				}
-			}
-			if fn.Name != "" {
-				fns = append(fns, fn)
+		if child.Type() != "variable_declarator" {
+			continue
+		}
+		var name string
+		var value *sitter.Node
+		for j := 0; j < int(child.ChildCount()); j++ {
+			c := child.Child(j)
+			switch c.Type() {
+			case "identifier":
+				name = c.Content(code)
+			case "arrow_function", "function", "function_expression":
+				value = c
 			}
 		}
+		if name == "" || value == nil {
+			continue
+		}
+		fn := extractJSFunctionValue(value, code, name)
+		fn.StartLine = int(child.StartPoint().Row) + 1
+		fn.Docstring = jsDocBefore(node, code)
+		fn.IsExported = jsExported(node)
+		fns = append(fns, fn)
 	}
 	return fns
 }
 
+// extractJSPairFunction extracts a function stored under an object key
+// ("{ onClick: () => … }"), named after the key. It returns a FunctionInfo
+// without a name when the value is not a function.
+func extractJSPairFunction(node *sitter.Node, code []byte) types.FunctionInfo {
+	var name string
+	var value *sitter.Node
+	for i := 0; i < int(node.ChildCount()); i++ {
+		c := node.Child(i)
+		switch c.Type() {
+		case "property_identifier", "identifier":
+			name = c.Content(code)
+		case "string":
+			name = trimQuotes(c.Content(code))
+		case "arrow_function", "function", "function_expression":
+			value = c
+		}
+	}
+	if value == nil {
+		return types.FunctionInfo{}
+	}
+	fn := extractJSFunctionValue(value, code, name)
+	fn.StartLine = int(node.StartPoint().Row) + 1
+	fn.Docstring = jsDocBefore(node, code)
+	return fn
+}
+
+// extractJSFunctionValue extracts an anonymous function or arrow function
+// under the given name. Calls are collected from the whole node so that
+// expression-bodied arrows ("(x) => f(x)") record theirs too.
+func extractJSFunctionValue(node *sitter.Node, code []byte, name string) types.FunctionInfo {
+	fn := types.FunctionInfo{
+		Name:      name,
+		StartLine: int(node.StartPoint().Row) + 1,
+		EndLine:   int(node.EndPoint().Row) + 1,
+	}
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "formal_parameters":
+			fn.Parameters = extractJSParams(child, code)
+		case "identifier":
+			// A single unparenthesised arrow parameter: "x => x * 2"
+			if node.Type() == "arrow_function" {
+				fn.Parameters = []string{child.Content(code)}
+			}
+		case "type_annotation":
+			fn.ReturnType = child.Content(code)
+		}
+	}
+	fn.Calls, fn.CallCounts = extractJSCalls(node, code)
+	text := node.Content(code)
+	if len(text) > 5 && text[:5] == "async" {
+		fn.IsAsync = true
+	}
+	return fn
+}
+
 func extractJSParams(node *sitter.Node, code []byte) []string {
 	var params []string
 	for i := 0; i < int(node.ChildCount()); i++ {
@@ -371,34 +567,35 @@ var jsBuiltins = map[string]bool{
 }
 
 // extractJSCalls recursively walks a function body to extract call_expression nodes.
-// Returns a deduplicated list of callee names (function/method names being called).
-func extractJSCalls(node *sitter.Node, code []byte) []string {
-	seen := make(map[string]bool)
-	collectJSCalls(node, code, seen)
+// Returns a deduplicated list of callee names (function/method names being called)
+// along with how many times each callee is invoked.
+func extractJSCalls(node *sitter.Node, code []byte) ([]string, map[string]int) {
+	counts := make(map[string]int)
+	collectJSCalls(node, code, counts)
 
-	if len(seen) == 0 {
-		return nil
+	if len(counts) == 0 {
+		return nil, nil
 	}
 
-	calls := make([]string, 0, len(seen))
-	for name := range seen {
+	calls := make([]string, 0, len(counts))
+	for name := range counts {
 		calls = append(calls, name)
 	}
-	return calls
+	return calls, counts
 }
 
-// collectJSCalls recursively walks the AST and collects function call names.
-func collectJSCalls(node *sitter.Node, code []byte, seen map[string]bool) {
+// collectJSCalls recursively walks the AST and counts function call names.
+func collectJSCalls(node *sitter.Node, code []byte, counts map[string]int) {
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
 		if child.Type() == "call_expression" {
 			name := extractJSCalleeName(child, code)
 			if name != "" && !jsBuiltins[name] {
-				seen[name] = true
+				counts[name]++
 			}
 		}
 		// Recurse into all children (call_expression can be nested)
-		collectJSCalls(child, code, seen)
+		collectJSCalls(child, code, counts)
 	}
 }
 
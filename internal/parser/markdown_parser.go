@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+// parseMarkdown splits a Markdown file into sections at its ATX headings
+// ("# Title" through "###### Title"). Headings inside fenced code blocks are
+// ignored, and text before the first heading becomes an untitled section
+// unless it is blank.
+func parseMarkdown(content string, result *types.FileParseResult) {
+	lines := strings.Split(content, "\n")
+	var sections []types.DocSection
+	current := types.DocSection{StartLine: 1}
+	blank := true
+	fence := ""
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			blank = false
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			blank = false
+			continue
+		}
+
+		level, title := markdownHeading(line)
+		if level == 0 {
+			if trimmed != "" {
+				blank = false
+			}
+			continue
+		}
+		if current.Title != "" || !blank {
+			current.EndLine = i
+			sections = append(sections, current)
+		}
+		current = types.DocSection{Title: title, Level: level, StartLine: i + 1}
+		blank = true
+	}
+	if current.Title != "" || !blank {
+		current.EndLine = len(lines)
+		sections = append(sections, current)
+	}
+	result.Sections = sections
+}
+
+// markdownHeading returns the level and text of an ATX heading line, or 0 if
+// the line is not a heading.
+func markdownHeading(line string) (int, string) {
+	// Up to three spaces of indentation are allowed
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, ""
+	}
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, ""
+	}
+	rest := trimmed[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, "" // "#hashtag" is not a heading
+	}
+	// Drop an optional closing sequence of #s
+	title := strings.TrimSpace(rest)
+	if stripped := strings.TrimRight(title, "#"); stripped != title && (stripped == "" || strings.HasSuffix(stripped, " ")) {
+		title = strings.TrimSpace(stripped)
+	}
+	return level, title
+}
@@ -7,12 +7,6 @@ import (
 	sitter "github.com/smacker/go-tree-sitter"
 )
 
-// parseJava extracts classes, methods, and imports from Java source.
-// Stub implementation — to be fully ported from Python parser.
-func parseJava(root *sitter.Node, code []byte, result *types.FileParseResult) {
-	visitGenericNode(root, code, result, "java")
-}
-
 // parseRust extracts structs, impl blocks, functions, and use statements from Rust source.
 // Matches Python's _parse_rust/_extract_rust_items behavior exactly.
 func parseRust(root *sitter.Node, code []byte, result *types.FileParseResult) {
@@ -90,10 +84,11 @@ func extractRustType(node *sitter.Node, code []byte) *types.ClassInfo {
 	}
 
 	ci := &types.ClassInfo{
-		Name:      name,
-		StartLine: int(node.StartPoint().Row) + 1,
-		EndLine:   int(node.EndPoint().Row) + 1,
-		Kind:      t,
+		Name:       name,
+		StartLine:  int(node.StartPoint().Row) + 1,
+		EndLine:    int(node.EndPoint().Row) + 1,
+		Kind:       t,
+		IsExported: isRustPub(node, code),
 	}
 
 	// Extract methods from impl/trait body
@@ -106,6 +101,10 @@ func extractRustType(node *sitter.Node, code []byte) *types.ClassInfo {
 					if member.Type() == "function_item" {
 						fn := extractRustFunction(member, code, name)
 						if fn != nil {
+							// Trait methods share the trait's visibility
+							if t == "trait_item" {
+								fn.IsExported = ci.IsExported
+							}
 							ci.Methods = append(ci.Methods, *fn)
 						}
 					}
@@ -113,10 +112,28 @@ func extractRustType(node *sitter.Node, code []byte) *types.ClassInfo {
 			}
 		}
 	}
+	// An impl block has no visibility of its own; it is public API when it
+	// provides public methods
+	if t == "impl_item" {
+		for _, m := range ci.Methods {
+			ci.IsExported = ci.IsExported || m.IsExported
+		}
+	}
 
 	return ci
 }
 
+// isRustPub reports whether a Rust item is declared plain "pub"; pub(crate)
+// and similar restricted visibilities are not public API.
+func isRustPub(node *sitter.Node, code []byte) bool {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if c := node.Child(i); c.Type() == "visibility_modifier" {
+			return c.Content(code) == "pub"
+		}
+	}
+	return false
+}
+
 // extractRustFunction extracts function info from a Rust function_item node.
 func extractRustFunction(node *sitter.Node, code []byte, className string) *types.FunctionInfo {
 	var funcName string
@@ -132,11 +149,12 @@ func extractRustFunction(node *sitter.Node, code []byte, className string) *type
 	}
 
 	fn := &types.FunctionInfo{
-		Name:      funcName,
-		StartLine: int(node.StartPoint().Row) + 1,
-		EndLine:   int(node.EndPoint().Row) + 1,
-		ClassName: className,
-		IsMethod:  className != "",
+		Name:       funcName,
+		StartLine:  int(node.StartPoint().Row) + 1,
+		EndLine:    int(node.EndPoint().Row) + 1,
+		ClassName:  className,
+		IsMethod:   className != "",
+		IsExported: isRustPub(node, code),
 	}
 	return fn
 }
@@ -167,10 +185,43 @@ func parseC(root *sitter.Node, code []byte, result *types.FileParseResult, lang
 		}
 	}
 
+	// Extract #include directives, including those nested in #if/#ifdef blocks
+	extractCIncludes(root, code, result)
+
 	// Extract classes/structs and functions with docstrings
 	visitCNode(root, code, result, lang)
 }
 
+// extractCIncludes records preproc_include nodes as imports. System includes
+// (<stdio.h>) are flagged IsSystem so the dependency graph can skip them;
+// local includes ("util.h") keep their path for resolution against indexed files.
+func extractCIncludes(node *sitter.Node, code []byte, result *types.FileParseResult) {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "preproc_include":
+			imp := types.ImportInfo{
+				Line: int(child.StartPoint().Row) + 1,
+			}
+			for j := 0; j < int(child.ChildCount()); j++ {
+				c := child.Child(j)
+				switch c.Type() {
+				case "system_lib_string":
+					imp.Module = strings.TrimSuffix(strings.TrimPrefix(c.Content(code), "<"), ">")
+					imp.IsSystem = true
+				case "string_literal":
+					imp.Module = strings.Trim(c.Content(code), `"`)
+				}
+			}
+			if imp.Module != "" {
+				result.Imports = append(result.Imports, imp)
+			}
+		case "preproc_if", "preproc_ifdef", "preproc_else", "preproc_elif", "preproc_elifdef":
+			extractCIncludes(child, code, result)
+		}
+	}
+}
+
 // visitCNode extracts functions and classes from C/C++ AST,
 // matching Python's visit_node behavior exactly:
 // - function_definition: requires function_declarator child (else skip)
@@ -247,21 +298,29 @@ func extractCClass(node *sitter.Node, code []byte, result *types.FileParseResult
 	}
 
 	ci := &types.ClassInfo{
-		Name:      name,
-		StartLine: int(node.StartPoint().Row) + 1,
-		EndLine:   int(node.EndPoint().Row) + 1,
-		Kind:      node.Type(),
+		Name:       name,
+		StartLine:  int(node.StartPoint().Row) + 1,
+		EndLine:    int(node.EndPoint().Row) + 1,
+		Kind:       node.Type(),
+		IsExported: true,
 	}
 
-	// Extract methods from field_declaration_list (matches Python)
+	// Extract methods from field_declaration_list (matches Python). C++
+	// class members are private until an access specifier says otherwise,
+	// struct members public.
 	for j := 0; j < int(node.ChildCount()); j++ {
 		c := node.Child(j)
 		if c.Type() == "field_declaration_list" {
+			public := node.Type() != "class_specifier"
 			for k := 0; k < int(c.ChildCount()); k++ {
 				member := c.Child(k)
-				if member.Type() == "function_definition" {
+				switch member.Type() {
+				case "access_specifier":
+					public = strings.TrimSuffix(strings.TrimSpace(member.Content(code)), ":") == "public"
+				case "function_definition":
 					fn := extractCFunction(member, code, name)
 					if fn != nil {
+						fn.IsExported = fn.IsExported && public
 						ci.Methods = append(ci.Methods, *fn)
 					}
 				}
@@ -302,16 +361,28 @@ func extractCFunction(node *sitter.Node, code []byte, className string) *types.F
 	}
 
 	fn := &types.FunctionInfo{
-		Name:      funcName,
-		StartLine: int(node.StartPoint().Row) + 1,
-		EndLine:   int(node.EndPoint().Row) + 1,
-		ClassName: className,
-		IsMethod:  className != "",
+		Name:       funcName,
+		StartLine:  int(node.StartPoint().Row) + 1,
+		EndLine:    int(node.EndPoint().Row) + 1,
+		ClassName:  className,
+		IsMethod:   className != "",
+		IsExported: !hasCStorageClass(node, code, "static"),
 	}
 
 	return fn
 }
 
+// hasCStorageClass reports whether a C/C++ declaration has the storage class
+// specifier, e.g. "static" for functions private to their file.
+func hasCStorageClass(node *sitter.Node, code []byte, specifier string) bool {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if c := node.Child(i); c.Type() == "storage_class_specifier" && c.Content(code) == specifier {
+			return true
+		}
+	}
+	return false
+}
+
 // cleanCComment removes comment markers from C-style comments.
 func cleanCComment(comment string) string {
 	if len(comment) >= 5 && comment[:3] == "/**" && comment[len(comment)-2:] == "*/" {
@@ -341,9 +412,11 @@ func visitGenericNode(node *sitter.Node, code []byte, result *types.FileParseRes
 		// Function-like declarations
 		case t == "function_definition" || t == "function_item" || t == "method_declaration" ||
 			t == "function_declaration":
+			// No visibility rules are known here, so everything counts as public
 			fn := types.FunctionInfo{
-				StartLine: int(child.StartPoint().Row) + 1,
-				EndLine:   int(child.EndPoint().Row) + 1,
+				StartLine:  int(child.StartPoint().Row) + 1,
+				EndLine:    int(child.EndPoint().Row) + 1,
+				IsExported: true,
 			}
 			// Try to find function name
 			for j := 0; j < int(child.ChildCount()); j++ {
@@ -361,9 +434,10 @@ func visitGenericNode(node *sitter.Node, code []byte, result *types.FileParseRes
 		case t == "class_declaration" || t == "struct_item" || t == "struct_specifier" ||
 			t == "impl_item":
 			ci := types.ClassInfo{
-				StartLine: int(child.StartPoint().Row) + 1,
-				EndLine:   int(child.EndPoint().Row) + 1,
-				Kind:      t,
+				StartLine:  int(child.StartPoint().Row) + 1,
+				EndLine:    int(child.EndPoint().Row) + 1,
+				Kind:       t,
+				IsExported: true,
 			}
 			for j := 0; j < int(child.ChildCount()); j++ {
 				c := child.Child(j)
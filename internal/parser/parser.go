@@ -1,31 +1,68 @@
 package parser
 
 import (
-	"log"
+	"strings"
 
+	"github.com/duyhunghd6/fastcode-cli/internal/logging"
 	"github.com/duyhunghd6/fastcode-cli/internal/types"
 	"github.com/duyhunghd6/fastcode-cli/internal/util"
 	ts "github.com/duyhunghd6/fastcode-cli/pkg/treesitter"
+	sitter "github.com/smacker/go-tree-sitter"
 )
 
+var logger = logging.New("parser")
+
 // Parser dispatches parsing to language-specific extractors.
 type Parser struct {
 	tsParser *ts.Parser
+
+	// jsArrowFunctions keeps functions assigned to variables and object keys
+	// in JS/TS (see WithJSArrowFunctions).
+	jsArrowFunctions bool
+
+	// languages overrides the language of file extensions (see WithLanguages).
+	languages map[string]string
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// WithJSArrowFunctions makes the JS/TS parser extract functions assigned to
+// variables ("const useAuth = () => …") and object keys ("{ onClick: () => … }")
+// as named functions. It is off by default to match the Python implementation,
+// which only extracts function declarations and methods.
+func WithJSArrowFunctions(enabled bool) Option {
+	return func(p *Parser) {
+		p.jsArrowFunctions = enabled
+	}
+}
+
+// WithLanguages parses files with the extensions in languages, lower-case
+// and dot-prefixed (".mjs"), as the mapped language instead of the built-in
+// one, as loader.Config.Languages loads them.
+func WithLanguages(languages map[string]string) Option {
+	return func(p *Parser) {
+		p.languages = languages
+	}
 }
 
 // New creates a new code parser.
-func New() *Parser {
+func New(opts ...Option) *Parser {
 	// Initialize with Go as default; will switch per file
-	p, err := ts.New("go")
+	tp, err := ts.New("go")
 	if err != nil {
-		log.Printf("[parser] warning: failed to initialize tree-sitter: %v", err)
+		logger.Warn("failed to initialize tree-sitter", "err", err)
 	}
-	return &Parser{tsParser: p}
+	p := &Parser{tsParser: tp}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // ParseFile parses a source file and extracts structured information.
 func (p *Parser) ParseFile(filePath, content string) *types.FileParseResult {
-	language := util.GetLanguageFromPath(filePath)
+	language := util.LanguageForPath(filePath, p.languages)
 	if language == "" {
 		return nil
 	}
@@ -39,6 +76,15 @@ func (p *Parser) ParseFile(filePath, content string) *types.FileParseResult {
 	// Non-code files (markdown, json, yaml, etc.) don't need tree-sitter parsing.
 	// They're indexed as file-level elements for BM25 keyword search.
 	if !isCodeLanguage(language) {
+		if language == "markdown" {
+			parseMarkdown(content, result)
+		}
+		return result
+	}
+
+	// Vue and Svelte components embed their logic in <script> blocks
+	if language == "vue" || language == "svelte" {
+		p.parseSFC(filePath, content, result)
 		return result
 	}
 
@@ -46,18 +92,19 @@ func (p *Parser) ParseFile(filePath, content string) *types.FileParseResult {
 
 	tree, err := p.tsParser.Parse(code, language)
 	if err != nil {
-		log.Printf("[parser] failed to parse %s: %v", filePath, err)
+		logger.Warn("failed to parse file", "path", filePath, "err", err)
 		return result
 	}
 	defer tree.Close()
 
 	rootNode := tree.RootNode()
+	checkParseErrors(rootNode, result)
 
 	switch language {
 	case "python":
 		parsePython(rootNode, code, result)
 	case "javascript", "typescript", "tsx":
-		parseJS(rootNode, code, result)
+		parseJS(rootNode, code, result, p.jsArrowFunctions)
 	case "java":
 		parseJava(rootNode, code, result)
 	case "rust":
@@ -71,18 +118,41 @@ func (p *Parser) ParseFile(filePath, content string) *types.FileParseResult {
 	return result
 }
 
+// checkParseErrors flags result and logs the file when the syntax tree
+// contains ERROR or missing nodes. Parsing carries on, so the elements
+// tree-sitter could still recognize are kept.
+func checkParseErrors(root *sitter.Node, result *types.FileParseResult) {
+	if !root.HasError() {
+		return
+	}
+	result.HasErrors = true
+	logger.Debug("syntax errors; indexing what could be parsed", "path", result.FilePath)
+}
+
 // isCodeLanguage returns true if the language has a tree-sitter grammar
 // and should be parsed for classes, functions, and imports.
 func isCodeLanguage(lang string) bool {
 	switch lang {
 	case "python", "javascript", "typescript", "tsx",
 		"java", "rust", "c", "cpp", "csharp", "ruby", "php",
-		"swift", "kotlin", "scala":
+		"swift", "kotlin", "scala", "vue", "svelte":
 		return true
 	}
 	return false
 }
 
+// decoratorName returns the name a decorator or annotation applies, without
+// its "@" and arguments: `@Component({...})` gives "Component" and
+// `@org.junit.Test` gives "org.junit.Test". It is the form every parser
+// records in Decorators.
+func decoratorName(text string) string {
+	text = strings.TrimPrefix(strings.TrimSpace(text), "@")
+	if i := strings.IndexByte(text, '('); i >= 0 {
+		text = text[:i]
+	}
+	return strings.Join(strings.Fields(text), "")
+}
+
 // nodeText returns the UTF-8 text content of a tree-sitter node.
 func nodeText(node interface{ Content([]byte) string }, code []byte) string {
 	return node.Content(code)
@@ -1,7 +1,11 @@
 package parser
 
 import (
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
 )
 
 func TestParseGoFile(t *testing.T) {
@@ -50,6 +54,30 @@ func TestParseNonCodeFile(t *testing.T) {
 	}
 }
 
+func TestParseMarkdownSections(t *testing.T) {
+	content := "Intro text\n\n# Project\nSummary\n## Architecture ##\n```sh\n# not a heading\n```\n#hashtag\n### Storage\nDetails"
+	result := New().ParseFile("README.md", content)
+
+	want := []types.DocSection{
+		{Title: "", Level: 0, StartLine: 1, EndLine: 2},
+		{Title: "Project", Level: 1, StartLine: 3, EndLine: 4},
+		{Title: "Architecture", Level: 2, StartLine: 5, EndLine: 9},
+		{Title: "Storage", Level: 3, StartLine: 10, EndLine: 11},
+	}
+	if len(result.Sections) != len(want) {
+		t.Fatalf("sections = %+v, want %d", result.Sections, len(want))
+	}
+	for i, w := range want {
+		if result.Sections[i] != w {
+			t.Errorf("section %d = %+v, want %+v", i, result.Sections[i], w)
+		}
+	}
+
+	if result := New().ParseFile("notes.md", "\n\n# Only\n"); len(result.Sections) != 1 || result.Sections[0].Title != "Only" {
+		t.Errorf("blank preamble should be dropped, got %+v", result.Sections)
+	}
+}
+
 // --- Go Parser Tests ---
 // Go files are treated as non-code files (matching Python's _parse_generic behavior)
 // so they produce file-level results only: no functions, classes, or imports.
@@ -292,6 +320,30 @@ class Dog(Animal):
 	}
 }
 
+func TestParseSyntaxErrors(t *testing.T) {
+	p := New()
+	content := `def ok(a):
+    return a
+
+def broken(:
+    pass
+`
+	result := p.ParseFile("broken.py", content)
+	if result == nil {
+		t.Fatal("nil")
+	}
+	if !result.HasErrors {
+		t.Error("HasErrors = false for a file with a syntax error")
+	}
+	if len(result.Functions) == 0 || result.Functions[0].Name != "ok" {
+		t.Errorf("functions = %+v, want the valid function ok kept", result.Functions)
+	}
+
+	if clean := p.ParseFile("clean.py", "def ok(a):\n    return a\n"); clean.HasErrors {
+		t.Error("HasErrors = true for a valid file")
+	}
+}
+
 func TestParsePythonFunctions(t *testing.T) {
 	p := New()
 	content := `def hello(name):
@@ -358,7 +410,37 @@ def my_prop(self):
 		t.Fatal("nil")
 	}
 	if len(result.Functions) < 2 {
-		t.Errorf("expected at least 2 functions, got %d", len(result.Functions))
+		t.Fatalf("expected at least 2 functions, got %d", len(result.Functions))
+	}
+	if d := result.Functions[0].Decorators; len(d) != 1 || d[0] != "staticmethod" {
+		t.Errorf("my_static decorators = %q, want [staticmethod]", d)
+	}
+}
+
+func TestParsePythonDecoratorNames(t *testing.T) {
+	p := New()
+	content := `@app.route("/users/<id>", methods=["GET"])
+@login_required
+def get_user(id):
+    pass
+
+class Views:
+    @pytest.mark.parametrize("x", [1, 2])
+    def test_x(self, x):
+        pass
+`
+	result := p.ParseFile("views.py", content)
+	if len(result.Functions) != 1 {
+		t.Fatalf("expected 1 function, got %d", len(result.Functions))
+	}
+	if got := result.Functions[0].Decorators; strings.Join(got, ",") != "app.route,login_required" {
+		t.Errorf("get_user decorators = %q", got)
+	}
+	if len(result.Classes) != 1 || len(result.Classes[0].Methods) != 1 {
+		t.Fatalf("expected class Views with 1 method, got %+v", result.Classes)
+	}
+	if got := result.Classes[0].Methods[0].Decorators; strings.Join(got, ",") != "pytest.mark.parametrize" {
+		t.Errorf("test_x decorators = %q", got)
 	}
 }
 
@@ -366,14 +448,33 @@ func TestParsePythonAsync(t *testing.T) {
 	p := New()
 	content := `async def fetch_data(url):
     pass
+
+def parse(data):
+    pass
+
+class Client:
+    @retry
+    async def get(self, path):
+        pass
 `
 	result := p.ParseFile("async.py", content)
 	if result == nil {
 		t.Fatal("nil")
 	}
-	// Note: tree-sitter might parse async differently
-	if len(result.Functions) == 0 {
-		t.Log("async functions may not be extracted by current parser")
+	async := make(map[string]bool)
+	for _, fn := range result.Functions {
+		async[fn.Name] = fn.IsAsync
+	}
+	for _, cls := range result.Classes {
+		for _, m := range cls.Methods {
+			async[cls.Name+"."+m.Name] = m.IsAsync
+		}
+	}
+	want := map[string]bool{"fetch_data": true, "parse": false, "Client.get": true}
+	for name, isAsync := range want {
+		if got, ok := async[name]; !ok || got != isAsync {
+			t.Errorf("%s: IsAsync = %v (found %v), want %v", name, got, ok, isAsync)
+		}
 	}
 }
 
@@ -443,6 +544,49 @@ const double = (x) => x * 2;
 	}
 }
 
+func TestParseJSArrowFunctionsOption(t *testing.T) {
+	p := New(WithJSArrowFunctions(true))
+	content := `/** Greets someone. */
+export const greet = (name) => {
+  return format("Hello " + name);
+};
+
+const double = x => twice(x);
+
+const handlers = {
+  onClick: () => submit(),
+  label: "ok",
+};
+`
+	result := p.ParseFile("arrow.js", content)
+	if result == nil {
+		t.Fatal("nil")
+	}
+	byName := make(map[string]types.FunctionInfo)
+	for _, fn := range result.Functions {
+		byName[fn.Name] = fn
+	}
+	for name, call := range map[string]string{"greet": "format", "double": "twice", "onClick": "submit"} {
+		fn, ok := byName[name]
+		if !ok {
+			t.Errorf("arrow function %s not extracted (got %v)", name, result.Functions)
+			continue
+		}
+		if fn.CallCounts[call] != 1 {
+			t.Errorf("%s calls = %v, want %s", name, fn.Calls, call)
+		}
+	}
+	if byName["greet"].Docstring != "Greets someone." {
+		t.Errorf("greet docstring = %q", byName["greet"].Docstring)
+	}
+	if params := byName["double"].Parameters; len(params) != 1 || params[0] != "x" {
+		t.Errorf("double parameters = %v, want [x]", params)
+	}
+	if _, ok := byName["label"]; ok {
+		t.Error("non-function object key extracted as a function")
+	}
+}
+
 func TestParseJSImports(t *testing.T) {
 	p := New()
 	content := `import React from 'react';
@@ -501,6 +645,67 @@ func TestParseJavaClass(t *testing.T) {
 	}
 }
 
+func TestParseJavaPackageAndImports(t *testing.T) {
+	p := New()
+	content := `package com.example.server;
+
+import java.util.List;
+import static org.junit.Assert.assertEquals;
+import com.example.util.*;
+
+/** Handles incoming requests. */
+public class Handler extends Base implements Runnable {
+    /**
+     * Runs the handler.
+     */
+    @Override
+    public void run() {
+        process(List.of());
+    }
+}
+
+enum Mode { FAST, SLOW; void apply() {} }
+`
+	result := p.ParseFile("Handler.java", content)
+	if result == nil {
+		t.Fatal("nil")
+	}
+	if result.Package != "com.example.server" {
+		t.Errorf("package = %q, want com.example.server", result.Package)
+	}
+	if len(result.Imports) != 3 {
+		t.Fatalf("expected 3 imports, got %d", len(result.Imports))
+	}
+	if result.Imports[0].Module != "java.util.List" || result.Imports[0].IsStatic {
+		t.Errorf("import[0] = %+v", result.Imports[0])
+	}
+	if !result.Imports[1].IsStatic {
+		t.Errorf("import[1] should be static: %+v", result.Imports[1])
+	}
+	if result.Imports[2].Module != "com.example.util" || len(result.Imports[2].Names) != 1 || result.Imports[2].Names[0] != "*" {
+		t.Errorf("import[2] = %+v, want wildcard com.example.util", result.Imports[2])
+	}
+
+	if len(result.Classes) != 2 {
+		t.Fatalf("expected 2 classes (class + enum), got %d", len(result.Classes))
+	}
+	handler := result.Classes[0]
+	if handler.Name != "Handler" || handler.Docstring != "Handles incoming requests." {
+		t.Errorf("class = %q doc %q", handler.Name, handler.Docstring)
+	}
+	if len(handler.Bases) != 2 {
+		t.Errorf("bases = %v, want [Base Runnable]", handler.Bases)
+	}
+	if len(handler.Methods) != 1 || handler.Methods[0].Docstring != "Runs the handler." {
+		t.Errorf("methods = %+v", handler.Methods)
+	} else if d := handler.Methods[0].Decorators; len(d) != 1 || d[0] != "Override" {
+		t.Errorf("run decorators = %q, want [Override] like the other parsers' names", d)
+	}
+	if result.Classes[1].Kind != "enum" || len(result.Classes[1].Methods) != 1 {
+		t.Errorf("enum = %+v", result.Classes[1])
+	}
+}
+
 // --- Rust Parser Test ---
 
 func TestParseRustFunction(t *testing.T) {
@@ -641,3 +846,98 @@ func TestCleanPythonDocstring(t *testing.T) {
 		}
 	}
 }
+
+func TestCleanJSDoc(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"/** Returns the user. */", "Returns the user."},
+		{"/**\n * Process items\n * in order.\n * @param {Array} items - Input items\n * @returns {Array}\n */", "Process items\nin order."},
+		{"/**\n * @deprecated\n */", ""},
+	}
+	for _, tt := range tests {
+		got := cleanJSDoc(tt.input)
+		if got != tt.want {
+			t.Errorf("cleanJSDoc(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// --- Single-file components ---
+
+func TestSFCScript(t *testing.T) {
+	content := "<template>\n  <p>{{ msg }}</p>\n</template>\n<script lang=\"ts\">\nexport default {}\n</script>\n<style>p { color: red }</style>\n"
+	code, language, ok := sfcScript(content)
+	if !ok {
+		t.Fatal("script block not found")
+	}
+	if language != "typescript" {
+		t.Errorf("language = %q, want typescript", language)
+	}
+	if len(code) != len(content) {
+		t.Fatalf("script length %d, want %d so positions match the file", len(code), len(content))
+	}
+	lines := strings.Split(string(code), "\n")
+	if strings.TrimSpace(lines[1]) != "" || lines[4] != "export default {}" || strings.TrimSpace(lines[6]) != "" {
+		t.Errorf("unexpected script text: %q", code)
+	}
+
+	if _, language, _ := sfcScript("<script>\nlet n = 0\n</script>"); language != "javascript" {
+		t.Errorf("language without lang attribute = %q, want javascript", language)
+	}
+	if _, _, ok := sfcScript("<div>no script</div>"); ok {
+		t.Error("found a script block in a component without one")
+	}
+}
+
+func TestParseSvelteComponent(t *testing.T) {
+	p := New()
+	content := `<script>
+  function increment() {
+    count += 1;
+  }
+</script>
+
+<button on:click={increment}>{count}</button>
+`
+	result := p.ParseFile("src/Counter.svelte", content)
+	if result == nil {
+		t.Fatal("nil")
+	}
+	if len(result.Classes) != 1 || result.Classes[0].Name != "Counter" || result.Classes[0].Kind != "component" {
+		t.Errorf("classes = %+v, want the Counter component", result.Classes)
+	}
+	if len(result.Functions) != 1 || result.Functions[0].Name != "increment" || result.Functions[0].StartLine != 2 {
+		t.Errorf("functions = %+v, want increment at line 2", result.Functions)
+	}
+}
+
+func TestParsePythonTypeHints(t *testing.T) {
+	p := New()
+	content := `def find(tree: "Tree", key: Optional[str] = None, *args: int, flag=False) -> Optional["Node"]:
+    pass
+
+def mode(m: Literal["r", "w"]) -> int | None:
+    pass
+`
+	result := p.ParseFile("tree.py", content)
+	if len(result.Functions) != 2 {
+		t.Fatalf("expected 2 functions, got %d", len(result.Functions))
+	}
+	find := result.Functions[0]
+	wantTypes := map[string]string{"tree": "Tree", "key": "Optional[str]", "*args": "int"}
+	if !reflect.DeepEqual(find.ParamTypes, wantTypes) {
+		t.Errorf("find param types = %v, want %v", find.ParamTypes, wantTypes)
+	}
+	if find.ReturnType != "Optional[Node]" {
+		t.Errorf("find return type = %q, want Optional[Node]", find.ReturnType)
+	}
+	if len(find.Parameters) != 4 {
+		t.Errorf("find parameters = %q, want 4", find.Parameters)
+	}
+	mode := result.Functions[1]
+	if mode.ParamTypes["m"] != `Literal["r", "w"]` || mode.ReturnType != "int | None" {
+		t.Errorf("mode = %v -> %q", mode.ParamTypes, mode.ReturnType)
+	}
+}
@@ -131,7 +131,7 @@ func extractPythonClass(node *sitter.Node, code []byte) types.ClassInfo {
 		for i := 0; i < int(node.ChildCount()); i++ {
 			child := node.Child(i)
 			if child.Type() == "decorator" {
-				ci.Decorators = append(ci.Decorators, child.Content(code))
+				ci.Decorators = append(ci.Decorators, pythonDecoratorName(child, code))
 			} else if child.Type() == "class_definition" {
 				actual = child
 			}
@@ -149,9 +149,10 @@ func extractPythonClass(node *sitter.Node, code []byte) types.ClassInfo {
 			ci.Docstring = extractPythonBlockDocstring(child, code)
 			ci.Methods = extractPythonMethods(child, code, ci.Name)
 		case "decorator":
-			ci.Decorators = append(ci.Decorators, child.Content(code))
+			ci.Decorators = append(ci.Decorators, pythonDecoratorName(child, code))
 		}
 	}
+	ci.IsExported = isPythonPublic(ci.Name)
 	return ci
 }
 
@@ -177,7 +178,7 @@ func extractPythonFunction(node *sitter.Node, code []byte, className string) typ
 		for i := 0; i < int(node.ChildCount()); i++ {
 			child := node.Child(i)
 			if child.Type() == "decorator" {
-				fn.Decorators = append(fn.Decorators, child.Content(code))
+				fn.Decorators = append(fn.Decorators, pythonDecoratorName(child, code))
 			} else if child.Type() == "function_definition" {
 				actual = child
 			}
@@ -187,26 +188,53 @@ func extractPythonFunction(node *sitter.Node, code []byte, className string) typ
 	for i := 0; i < int(actual.ChildCount()); i++ {
 		child := actual.Child(i)
 		switch child.Type() {
+		case "async":
+			fn.IsAsync = true
 		case "identifier":
 			fn.Name = child.Content(code)
 		case "parameters":
-			fn.Parameters = extractPythonParams(child, code)
+			fn.Parameters, fn.ParamTypes = extractPythonParams(child, code)
 		case "type":
-			fn.ReturnType = child.Content(code)
+			fn.ReturnType = pythonAnnotation(child, code)
 		case "block":
 			fn.Docstring = extractPythonBlockDocstring(child, code)
 		}
 	}
 
-	// Check if async
-	text := actual.Content(code)
-	if strings.HasPrefix(text, "async ") {
-		fn.IsAsync = true
-	}
+	fn.IsExported = isPythonPublic(fn.Name)
 
 	return fn
 }
 
+// pythonDecoratorName returns the name of the decorator a decorator node
+// applies, without its arguments: "@app.route("/")" gives "app.route" and
+// "@dataclass" gives "dataclass". Unlike decoratorName it skips comments
+// inside the decorator.
+func pythonDecoratorName(node *sitter.Node, code []byte) string {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		expr := node.Child(i)
+		if expr.Type() == "@" || expr.Type() == "comment" {
+			continue
+		}
+		if expr.Type() == "call" {
+			if fn := expr.ChildByFieldName("function"); fn != nil {
+				expr = fn
+			}
+		}
+		return strings.Join(strings.Fields(expr.Content(code)), "")
+	}
+	return strings.TrimPrefix(strings.TrimSpace(node.Content(code)), "@")
+}
+
+// isPythonPublic reports whether a Python name is public by convention: it
+// has no leading underscore, or it is a dunder such as __init__.
+func isPythonPublic(name string) bool {
+	if strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__") && len(name) > 4 {
+		return true
+	}
+	return !strings.HasPrefix(name, "_")
+}
+
 func extractPythonBases(node *sitter.Node, code []byte) []string {
 	var bases []string
 	for i := 0; i < int(node.ChildCount()); i++ {
@@ -218,19 +246,61 @@ func extractPythonBases(node *sitter.Node, code []byte) []string {
 	return bases
 }
 
-func extractPythonParams(node *sitter.Node, code []byte) []string {
+// extractPythonParams returns the parameters as written and the type
+// annotations of those that have one, keyed by name ("*args" for splats).
+func extractPythonParams(node *sitter.Node, code []byte) ([]string, map[string]string) {
 	var params []string
+	var paramTypes map[string]string
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
 		switch child.Type() {
-		case "identifier":
+		case "identifier", "default_parameter", "list_splat_pattern", "dictionary_splat_pattern":
 			params = append(params, child.Content(code))
-		case "typed_parameter", "default_parameter", "typed_default_parameter",
-			"list_splat_pattern", "dictionary_splat_pattern":
+		case "typed_parameter", "typed_default_parameter":
 			params = append(params, child.Content(code))
+			name, typ := child.ChildByFieldName("name"), child.ChildByFieldName("type")
+			if name == nil && child.ChildCount() > 0 {
+				name = child.Child(0) // typed_parameter leaves its name unlabeled
+			}
+			if name != nil && typ != nil {
+				if paramTypes == nil {
+					paramTypes = make(map[string]string)
+				}
+				paramTypes[name.Content(code)] = pythonAnnotation(typ, code)
+			}
+		}
+	}
+	return params, paramTypes
+}
+
+// pythonAnnotation returns the text of a type annotation with forward
+// references unquoted, so "Node" and Optional["Node"] give Node and
+// Optional[Node]. Strings inside Literal[...] are values and stay quoted.
+func pythonAnnotation(node *sitter.Node, code []byte) string {
+	var sb strings.Builder
+	pos := node.StartByte()
+	var walk func(n *sitter.Node, literal bool)
+	walk = func(n *sitter.Node, literal bool) {
+		switch n.Type() {
+		case "string":
+			if !literal {
+				sb.Write(code[pos:n.StartByte()])
+				sb.WriteString(strings.Trim(n.Content(code), `"'`))
+				pos = n.EndByte()
+			}
+			return
+		case "subscript", "generic_type":
+			if n.ChildCount() > 0 && strings.HasSuffix(n.Child(0).Content(code), "Literal") {
+				literal = true
+			}
+		}
+		for i := 0; i < int(n.ChildCount()); i++ {
+			walk(n.Child(i), literal)
 		}
 	}
-	return params
+	walk(node, false)
+	sb.Write(code[pos:node.EndByte()])
+	return sb.String()
 }
 
 func extractPythonMethods(block *sitter.Node, code []byte, className string) []types.FunctionInfo {
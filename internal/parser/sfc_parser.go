@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/types"
+)
+
+var (
+	// sfcScriptRe matches a <script> block of a Vue or Svelte component,
+	// capturing its attributes and body.
+	sfcScriptRe = regexp.MustCompile(`(?is)<script\b([^>]*)>(.*?)</script\s*>`)
+	// sfcTSLangRe matches a lang attribute selecting TypeScript.
+	sfcTSLangRe = regexp.MustCompile(`(?i)\blang\s*=\s*["']?(ts|typescript)\b`)
+)
+
+// sfcScript blanks everything outside the <script> blocks of a Vue or Svelte
+// single-file component, keeping newlines so that line numbers in the JS/TS
+// parse match the original file. It reports the script language
+// ("typescript" if any block declares lang="ts") and whether a script was found.
+func sfcScript(content string) (code []byte, language string, ok bool) {
+	matches := sfcScriptRe.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return nil, "", false
+	}
+	code = []byte(content)
+	keep := make([]bool, len(code))
+	language = "javascript"
+	for _, m := range matches {
+		for i := m[4]; i < m[5]; i++ {
+			keep[i] = true
+		}
+		if sfcTSLangRe.MatchString(content[m[2]:m[3]]) {
+			language = "typescript"
+		}
+	}
+	for i, b := range code {
+		if !keep[i] && b != '\n' {
+			code[i] = ' '
+		}
+	}
+	return code, language, true
+}
+
+// parseSFC parses the script of a Vue or Svelte single-file component as
+// JS/TS and records the component itself, named after the file, as a class
+// of kind "component". Template and style blocks are ignored.
+func (p *Parser) parseSFC(filePath, content string, result *types.FileParseResult) {
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	result.Classes = append(result.Classes, types.ClassInfo{
+		Name:       name,
+		StartLine:  1,
+		EndLine:    result.TotalLines,
+		Kind:       "component",
+		IsExported: true, // the component is the file's default export
+	})
+
+	code, language, ok := sfcScript(content)
+	if !ok {
+		return
+	}
+	tree, err := p.tsParser.Parse(code, language)
+	if err != nil {
+		return
+	}
+	defer tree.Close()
+	checkParseErrors(tree.RootNode(), result)
+	parseJS(tree.RootNode(), code, result, p.jsArrowFunctions)
+}
@@ -0,0 +1,133 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Turn is a single question/answer exchange within a session.
+type Turn struct {
+	Question   string    `json:"question"`
+	Answer     string    `json:"answer"`
+	ElementIDs []string  `json:"element_ids,omitempty"` // elements retrieved to answer the question
+	Confidence int       `json:"confidence"`
+	Time       time.Time `json:"time"`
+}
+
+// Session is a named conversation against one repository.
+type Session struct {
+	ID        string    `json:"id"`
+	RepoName  string    `json:"repo_name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Turns     []Turn    `json:"turns"`
+}
+
+// Store persists sessions as JSON files, one per session, in Dir.
+type Store struct {
+	Dir string
+}
+
+// NewStore creates a session store rooted at dir.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Save writes the session to disk, replacing any previous version.
+func (s *Store) Save(sess *Session) error {
+	path, err := s.path(sess.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("create session dir: %w", err)
+	}
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode session: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write session: %w", err)
+	}
+	return nil
+}
+
+// Load reads a session from disk.
+func (s *Store) Load(id string) (*Session, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read session %s: %w", id, err)
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("decode session %s: %w", id, err)
+	}
+	return &sess, nil
+}
+
+// Append adds a turn to the session, creating the session if it does not exist yet.
+func (s *Store) Append(id, repoName string, turn Turn) (*Session, error) {
+	sess, err := s.Load(id)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		sess = &Session{ID: id, RepoName: repoName, CreatedAt: turn.Time}
+	}
+	if sess.RepoName == "" {
+		sess.RepoName = repoName
+	}
+	sess.Turns = append(sess.Turns, turn)
+	sess.UpdatedAt = turn.Time
+	if err := s.Save(sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// List returns all stored sessions, most recently updated first.
+func (s *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read session dir: %w", err)
+	}
+	var sessions []*Session
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		sess, err := s.Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue // skip unreadable files rather than failing the listing
+		}
+		sessions = append(sessions, sess)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+func (s *Store) path(id string) (string, error) {
+	if !validID.MatchString(id) {
+		return "", fmt.Errorf("invalid session id %q (use letters, digits, '.', '_' or '-')", id)
+	}
+	return filepath.Join(s.Dir, id+".json"), nil
+}
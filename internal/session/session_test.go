@@ -0,0 +1,64 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoreAppendAndLoad(t *testing.T) {
+	store := NewStore(t.TempDir())
+	t0 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if _, err := store.Append("work", "repo", Turn{Question: "q1", Answer: "a1", ElementIDs: []string{"e1"}, Time: t0}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if _, err := store.Append("work", "repo", Turn{Question: "q2", Answer: "a2", Time: t0.Add(time.Minute)}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	sess, err := store.Load("work")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if sess.RepoName != "repo" || len(sess.Turns) != 2 {
+		t.Fatalf("unexpected session: %+v", sess)
+	}
+	if sess.Turns[0].Question != "q1" || sess.Turns[0].ElementIDs[0] != "e1" || sess.Turns[1].Answer != "a2" {
+		t.Errorf("turns not preserved: %+v", sess.Turns)
+	}
+	if !sess.CreatedAt.Equal(t0) || !sess.UpdatedAt.Equal(t0.Add(time.Minute)) {
+		t.Errorf("timestamps = %v / %v", sess.CreatedAt, sess.UpdatedAt)
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	store := NewStore(t.TempDir())
+	t0 := time.Now()
+	store.Append("old", "r", Turn{Question: "q", Time: t0})
+	store.Append("new", "r", Turn{Question: "q", Time: t0.Add(time.Hour)})
+
+	sessions, err := store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "new" || sessions[1].ID != "old" {
+		t.Errorf("expected [new old], got %v", sessions)
+	}
+
+	empty, err := NewStore(t.TempDir() + "/missing").List()
+	if err != nil || len(empty) != 0 {
+		t.Errorf("expected empty list for missing dir, got %v, %v", empty, err)
+	}
+}
+
+func TestStoreInvalidID(t *testing.T) {
+	store := NewStore(t.TempDir())
+	for _, id := range []string{"", "../escape", "a/b", ".hidden"} {
+		if _, err := store.Append(id, "r", Turn{}); err == nil {
+			t.Errorf("expected error for session id %q", id)
+		}
+	}
+	if _, err := store.Load("missing"); err == nil {
+		t.Error("expected error loading a missing session")
+	}
+}
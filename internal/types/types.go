@@ -21,19 +21,23 @@ type CodeElement struct {
 
 // FunctionInfo holds extracted function/method metadata.
 type FunctionInfo struct {
-	Name       string   `json:"name"`
-	StartLine  int      `json:"start_line"`
-	EndLine    int      `json:"end_line"`
-	Docstring  string   `json:"docstring,omitempty"`
-	Parameters []string `json:"parameters,omitempty"`
-	ReturnType string   `json:"return_type,omitempty"`
-	IsAsync    bool     `json:"is_async,omitempty"`
-	IsMethod   bool     `json:"is_method,omitempty"`
-	ClassName  string   `json:"class_name,omitempty"`
-	Decorators []string `json:"decorators,omitempty"`
-	Complexity int      `json:"complexity,omitempty"`
-	Receiver   string   `json:"receiver,omitempty"` // Go-specific: method receiver
-	Calls      []string `json:"calls,omitempty"`    // function/method names called within this function
+	Name       string            `json:"name"`
+	StartLine  int               `json:"start_line"`
+	EndLine    int               `json:"end_line"`
+	Docstring  string            `json:"docstring,omitempty"`
+	Parameters []string          `json:"parameters,omitempty"`
+	ParamTypes map[string]string `json:"param_types,omitempty"` // parameter name → declared type, for annotated parameters
+	TypeParams string            `json:"type_params,omitempty"` // generic type parameters as written, e.g. "[K comparable, V any]"
+	ReturnType string            `json:"return_type,omitempty"`
+	IsAsync    bool              `json:"is_async,omitempty"`
+	IsMethod   bool              `json:"is_method,omitempty"`
+	IsExported bool              `json:"is_exported,omitempty"` // part of the public API, by the language's rules
+	ClassName  string            `json:"class_name,omitempty"`
+	Decorators []string          `json:"decorators,omitempty"` // decorator/annotation names without "@" or arguments, e.g. "app.route"
+	Complexity int               `json:"complexity,omitempty"`
+	Receiver   string            `json:"receiver,omitempty"`    // Go-specific: method receiver
+	Calls      []string          `json:"calls,omitempty"`       // function/method names called within this function
+	CallCounts map[string]int    `json:"call_counts,omitempty"` // callee name → number of call sites, when the parser counts them
 }
 
 // ClassInfo holds extracted class/struct/interface metadata.
@@ -44,18 +48,22 @@ type ClassInfo struct {
 	Docstring  string         `json:"docstring,omitempty"`
 	Bases      []string       `json:"bases,omitempty"` // parent classes / embedded types
 	Methods    []FunctionInfo `json:"methods,omitempty"`
-	Decorators []string       `json:"decorators,omitempty"`
-	Kind       string         `json:"kind,omitempty"` // "class", "struct", "interface"
+	Decorators []string       `json:"decorators,omitempty"`  // like FunctionInfo.Decorators, e.g. "dataclass", "Injectable"
+	Kind       string         `json:"kind,omitempty"`        // "class", "struct", "interface"
+	TypeParams string         `json:"type_params,omitempty"` // generic type parameters as written, e.g. "[T any]"
+	IsExported bool           `json:"is_exported,omitempty"` // part of the public API, by the language's rules
 }
 
 // ImportInfo holds extracted import statement metadata.
 type ImportInfo struct {
-	Module string   `json:"module"`
-	Names  []string `json:"names,omitempty"`
-	IsFrom bool     `json:"is_from,omitempty"` // Python: from X import Y
-	Line   int      `json:"line"`
-	Level  int      `json:"level,omitempty"` // Python relative import level
-	Alias  string   `json:"alias,omitempty"`
+	Module   string   `json:"module"`
+	Names    []string `json:"names,omitempty"`
+	IsFrom   bool     `json:"is_from,omitempty"` // Python: from X import Y
+	Line     int      `json:"line"`
+	Level    int      `json:"level,omitempty"` // Python relative import level
+	Alias    string   `json:"alias,omitempty"`
+	IsStatic bool     `json:"is_static,omitempty"` // Java: import static X.Y
+	IsSystem bool     `json:"is_system,omitempty"` // C/C++: #include <...>
 }
 
 // FileParseResult is the result of parsing a single source file.
@@ -65,8 +73,25 @@ type FileParseResult struct {
 	Classes         []ClassInfo    `json:"classes,omitempty"`
 	Functions       []FunctionInfo `json:"functions,omitempty"`
 	Imports         []ImportInfo   `json:"imports,omitempty"`
+	Package         string         `json:"package,omitempty"` // Java: package declaration
 	ModuleDocstring string         `json:"module_docstring,omitempty"`
 	TotalLines      int            `json:"total_lines"`
 	CodeLines       int            `json:"code_lines"`
 	CommentLines    int            `json:"comment_lines"`
+
+	// HasErrors is set when tree-sitter found syntax errors; the classes,
+	// functions and imports hold whatever could still be extracted.
+	HasErrors bool `json:"has_errors,omitempty"`
+
+	// Sections are the heading-delimited sections of a Markdown file.
+	Sections []DocSection `json:"sections,omitempty"`
+}
+
+// DocSection is a section of a documentation file, from its heading up to
+// the next heading.
+type DocSection struct {
+	Title     string `json:"title"` // "" for text before the first heading
+	Level     int    `json:"level"` // heading level, 1 for "#"
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
 }
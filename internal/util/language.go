@@ -1,7 +1,10 @@
 package util
 
 import (
+	"fmt"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 )
 
@@ -25,6 +28,9 @@ var languageExtensions = map[string]string{
 	".php":   "php",
 	".swift": "swift",
 	".kt":    "kotlin",
+	// Single-file components; their <script> blocks are parsed as JS/TS
+	".vue":    "vue",
+	".svelte": "svelte",
 
 	".pyx": "python",
 	// Non-code context files (indexed as file-level elements for BM25)
@@ -39,6 +45,71 @@ var languageExtensions = map[string]string{
 	".rst":  "rst",
 }
 
+// contextLanguages are the non-code languages indexed only for keyword search.
+var contextLanguages = map[string]bool{
+	"markdown": true, "text": true, "json": true, "yaml": true, "toml": true,
+	"html": true, "css": true, "xml": true, "rst": true,
+}
+
+// parsedLanguages are the code languages parser.ParseFile extracts elements from;
+// the others are indexed as file-level elements only.
+var parsedLanguages = map[string]bool{
+	"python": true, "javascript": true, "typescript": true, "tsx": true,
+	"java": true, "rust": true, "c": true, "cpp": true, "vue": true, "svelte": true,
+}
+
+// overrideLanguages returns the sorted languages an extension override may
+// map to: those the parser extracts elements from, plus the file-level ones
+// it indexes whole (Go and the context languages). Languages recognized by
+// extension but without a parser, such as ruby, are left out.
+func overrideLanguages() []string {
+	langs := []string{"go"}
+	for lang := range parsedLanguages {
+		langs = append(langs, lang)
+	}
+	for lang := range contextLanguages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// IsContextLanguage reports whether lang is a non-code context language
+// (documentation or configuration) rather than source code.
+func IsContextLanguage(lang string) bool {
+	return contextLanguages[lang]
+}
+
+// ExtensionForLanguage returns the canonical file extension of a language,
+// the shortest (then alphabetically first) one mapped to it, e.g. ".py" for
+// "python". Returns "" for unknown languages.
+func ExtensionForLanguage(lang string) string {
+	best := ""
+	for ext, l := range languageExtensions {
+		if l != lang {
+			continue
+		}
+		if best == "" || len(ext) < len(best) || (len(ext) == len(best) && ext < best) {
+			best = ext
+		}
+	}
+	return best
+}
+
+// NormalizeLanguage maps a user-supplied language name or extension to the
+// language name used in the index: "ts" and ".ts" become "typescript", "Go"
+// becomes "go". Returns "" for unsupported languages.
+func NormalizeLanguage(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if lang := GetLanguageFromExtension("." + strings.TrimPrefix(name, ".")); lang != "" {
+		return lang
+	}
+	if ExtensionForLanguage(name) != "" {
+		return name
+	}
+	return ""
+}
+
 // GetLanguageFromExtension returns the language name for a file extension.
 // Returns empty string if unsupported.
 func GetLanguageFromExtension(ext string) string {
@@ -51,6 +122,40 @@ func GetLanguageFromPath(filePath string) string {
 	return GetLanguageFromExtension(ext)
 }
 
+// LanguageForPath is GetLanguageFromPath with overrides, a map of lower-case
+// extensions (".mjs") to language names, consulted before the built-in map.
+func LanguageForPath(filePath string, overrides map[string]string) string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if lang, ok := overrides[ext]; ok {
+		return lang
+	}
+	return GetLanguageFromExtension(ext)
+}
+
+// NormalizeLanguageOverrides validates a map of file extensions to language
+// names, e.g. {"mjs": "js", ".tpl": "html"}, returning it with extensions
+// lower-cased and dot-prefixed and languages normalized (see
+// NormalizeLanguage). A language the parser cannot index, such as "ruby", is an
+// error.
+func NormalizeLanguageOverrides(overrides map[string]string) (map[string]string, error) {
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]string, len(overrides))
+	for ext, name := range overrides {
+		ext = "." + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+		if ext == "." || strings.ContainsAny(ext[1:], `./\ `) {
+			return nil, fmt.Errorf("invalid file extension %q", ext)
+		}
+		lang := NormalizeLanguage(name)
+		if supported := overrideLanguages(); !slices.Contains(supported, lang) {
+			return nil, fmt.Errorf("extension %s: unsupported language %q (supported: %s)", ext, name, strings.Join(supported, ", "))
+		}
+		normalized[ext] = lang
+	}
+	return normalized, nil
+}
+
 // IsSupportedFile returns true if the file extension is a supported language.
 func IsSupportedFile(filePath string) bool {
 	return GetLanguageFromPath(filePath) != ""
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -56,3 +57,80 @@ func ExtractLines(content string, startLine, endLine int) string {
 	}
 	return strings.Join(lines[startLine-1:endLine], "\n")
 }
+
+// MatchGlob reports whether a slash-separated relative path matches a glob
+// pattern. "**" matches zero or more whole directories, so "src/**/*.go" and
+// "**/test_*.py" match at any depth. A pattern without "/" (e.g. "*.ts") is
+// matched against the file name alone. Malformed patterns never match.
+func MatchGlob(pattern, relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	if !strings.Contains(pattern, "/") {
+		ok, err := path.Match(pattern, path.Base(relPath))
+		return err == nil && ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse repeated "**" and try every possible number of skipped directories
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		ok, err := path.Match(pattern[0], parts[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// testDirs are directory names whose files are all tests.
+var testDirs = map[string]bool{"__tests__": true, "test": true, "tests": true, "spec": true}
+
+// IsTestFile reports whether a slash-separated relative path names a test
+// file by the usual conventions: "*_test.go", "test_*.py" and "*_test.py",
+// "conftest.py", "*.spec.ts" and "*.test.js" (any JavaScript or TypeScript
+// extension), "*Test.java", "*_spec.rb", or any file under a "__tests__",
+// "test", "tests" or "spec" directory.
+func IsTestFile(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	dirs := strings.Split(relPath, "/")
+	name := dirs[len(dirs)-1]
+	for _, dir := range dirs[:len(dirs)-1] {
+		if testDirs[dir] {
+			return true
+		}
+	}
+
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	switch ext {
+	case ".go":
+		return strings.HasSuffix(stem, "_test")
+	case ".py":
+		return strings.HasPrefix(stem, "test_") || strings.HasSuffix(stem, "_test") || stem == "conftest"
+	case ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx":
+		return strings.HasSuffix(stem, ".spec") || strings.HasSuffix(stem, ".test")
+	case ".java", ".kt", ".cs":
+		return strings.HasSuffix(stem, "Test") || strings.HasSuffix(stem, "Tests")
+	case ".rb":
+		return strings.HasSuffix(stem, "_spec") || strings.HasSuffix(stem, "_test")
+	}
+	return false
+}
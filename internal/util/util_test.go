@@ -30,6 +30,8 @@ func TestGetLanguageFromPath(t *testing.T) {
 		{"file.swift", "swift"},
 		{"file.kt", "kotlin"},
 		{"file.scala", "scala"},
+		{"UserCard.vue", "vue"},
+		{"Counter.svelte", "svelte"},
 		{"README.md", "markdown"},
 		{"Makefile", ""},
 		{"styles.css", "css"},
@@ -97,6 +99,41 @@ func TestSupportedExtensions(t *testing.T) {
 	}
 }
 
+func TestExtensionForLanguage(t *testing.T) {
+	tests := map[string]string{
+		"go":         ".go",
+		"python":     ".py",
+		"javascript": ".js",
+		"c":          ".c",
+		"cobol":      "",
+	}
+	for lang, want := range tests {
+		if got := ExtensionForLanguage(lang); got != want {
+			t.Errorf("ExtensionForLanguage(%q) = %q, want %q", lang, got, want)
+		}
+	}
+	if !IsContextLanguage("markdown") || IsContextLanguage("go") {
+		t.Error("markdown should be a context language and go should not")
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := map[string]string{
+		"go":         "go",
+		"Go":         "go",
+		"ts":         "typescript",
+		".py":        "python",
+		"typescript": "typescript",
+		"cobol":      "",
+		"":           "",
+	}
+	for in, want := range tests {
+		if got := NormalizeLanguage(in); got != want {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
 func TestCountLines(t *testing.T) {
 	tests := []struct {
 		input string
@@ -191,3 +228,96 @@ func TestRelativePathError(t *testing.T) {
 		t.Error("RelativePath should return something")
 	}
 }
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		// Extension-only and name patterns match at any depth
+		{"*.go", "main.go", true},
+		{"*.go", "internal/agent/tools.go", true},
+		{"*.go", "internal/agent/tools.py", false},
+		{"test_*.py", "tests/unit/test_api.py", true},
+		// "*" does not cross directories
+		{"src/*.go", "src/main.go", true},
+		{"src/*.go", "src/pkg/main.go", false},
+		// "**" matches zero or more directories
+		{"src/**/*.go", "src/main.go", true},
+		{"src/**/*.go", "src/a/b/c.go", true},
+		{"src/**/*.go", "lib/a/c.go", false},
+		{"**/test_*.py", "test_root.py", true},
+		{"**/test_*.py", "pkg/tests/test_x.py", true},
+		{"**/test_*.py", "pkg/tests/x_test.py", false},
+		{"**/*.ts", "web/components/index.ts", true},
+		{"docs/**", "docs/guide/intro.md", true},
+		{"internal/**/parser/*.go", "internal/parser/go.go", true},
+		{"internal/**/parser/*.go", "internal/x/y/parser/go.go", true},
+		// Directory-scoped exact paths
+		{"cmd/fastcode/main.go", "cmd/fastcode/main.go", true},
+		{"cmd/fastcode/main.go", "cmd/other/main.go", false},
+		// Malformed patterns never match
+		{"[*.go", "a.go", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIsTestFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"internal/agent/tools_test.go", true},
+		{"internal/agent/tools.go", false},
+		{"internal/agent/testdata.go", false},
+		{"pkg/test_api.py", true},
+		{"pkg/api_test.py", true},
+		{"conftest.py", true},
+		{"pkg/testing.py", false},
+		{"src/app.spec.ts", true},
+		{"src/app.test.jsx", true},
+		{"src/spec.ts", false},
+		{"src/__tests__/app.js", true},
+		{"tests/fixtures/data.json", true},
+		{"test/helpers.rb", true},
+		{"lib/user_spec.rb", true},
+		{"src/main/java/UserServiceTest.java", true},
+		{"src/main/java/Contest.java", false},
+		{"latest/main.go", false},
+	}
+	for _, tt := range tests {
+		if got := IsTestFile(tt.path); got != tt.want {
+			t.Errorf("IsTestFile(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageOverrides(t *testing.T) {
+	overrides, err := NormalizeLanguageOverrides(map[string]string{"MJS": "js", ".tpl": "HTML", ".py": "text"})
+	if err != nil {
+		t.Fatalf("NormalizeLanguageOverrides: %v", err)
+	}
+	tests := map[string]string{
+		"src/app.mjs":     "javascript",
+		"views/page.TPL":  "html",
+		"scripts/run.py":  "text",
+		"main.go":         "go",
+		"assets/logo.svg": "",
+	}
+	for path, want := range tests {
+		if got := LanguageForPath(path, overrides); got != want {
+			t.Errorf("LanguageForPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	for _, bad := range []map[string]string{{".cbl": "cobol"}, {".foo": "ruby"}, {"": "go"}, {".tar.gz": "text"}} {
+		if _, err := NormalizeLanguageOverrides(bad); err == nil {
+			t.Errorf("NormalizeLanguageOverrides(%v) should fail", bad)
+		}
+	}
+}
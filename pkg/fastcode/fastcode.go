@@ -0,0 +1,207 @@
+// Package fastcode is the public Go API of FastCode. It lets other programs
+// index a repository and ask questions about it without going through the CLI.
+//
+// The package is a thin facade over the internal engine: the configuration,
+// option and result types are the engine's own, re-exported here, so they
+// stay in step with the CLI and MCP server.
+//
+//	engine := fastcode.NewEngine(fastcode.DefaultConfig())
+//	if _, err := engine.Index("./myrepo", false); err != nil {
+//		log.Fatal(err)
+//	}
+//	result, err := engine.Query("How is authentication handled?")
+//
+// Like the CLI, the engine reads its LLM settings from the OPENAI_API_KEY,
+// MODEL and BASE_URL environment variables. Without an API key, queries fall
+// back to a plain hybrid-search listing (QueryModeDirect).
+package fastcode
+
+import (
+	"context"
+
+	"github.com/duyhunghd6/fastcode-cli/internal/agent"
+	"github.com/duyhunghd6/fastcode-cli/internal/logging"
+	"github.com/duyhunghd6/fastcode-cli/internal/orchestrator"
+)
+
+// Config holds engine configuration.
+type Config = orchestrator.Config
+
+// IndexResult holds the result of an indexing operation.
+type IndexResult = orchestrator.IndexResult
+
+// IndexPlan lists the files indexing would parse, as returned by Plan.
+type IndexPlan = orchestrator.IndexPlan
+
+// IndexProgress is a progress event reported through Config.Progress.
+type IndexProgress = orchestrator.IndexProgress
+
+// IndexPhase names a stage of indexing.
+type IndexPhase = orchestrator.IndexPhase
+
+// Indexing phases reported in IndexProgress.Phase.
+const (
+	PhaseLoaded    = orchestrator.PhaseLoaded
+	PhaseParsing   = orchestrator.PhaseParsing
+	PhaseGraphs    = orchestrator.PhaseGraphs
+	PhaseEmbedding = orchestrator.PhaseEmbedding
+)
+
+// QueryOptions tunes a single query; zero fields use their defaults.
+type QueryOptions = orchestrator.QueryOptions
+
+// QueryResult holds the result of a query.
+type QueryResult = orchestrator.QueryResult
+
+// RetrievedCode is an element an answer was based on, with its code,
+// returned in QueryResult.Code when QueryOptions.IncludeCode is set.
+type RetrievedCode = orchestrator.RetrievedCode
+
+// Query modes reported in QueryResult.Mode.
+const (
+	QueryModeAgent  = orchestrator.QueryModeAgent
+	QueryModeDirect = orchestrator.QueryModeDirect
+)
+
+// AnswerFormat selects the layout of a generated answer.
+type AnswerFormat = agent.AnswerFormat
+
+// Answer formats accepted in QueryOptions.Format.
+const (
+	AnswerFormatMarkdown = agent.AnswerFormatMarkdown
+	AnswerFormatPlain    = agent.AnswerFormatPlain
+	AnswerFormatBullet   = agent.AnswerFormatBullet
+)
+
+// Verbosity selects the length of a generated answer.
+type Verbosity = agent.Verbosity
+
+// Verbosity levels accepted in QueryOptions.Verbosity.
+const (
+	VerbosityBrief    = agent.VerbosityBrief
+	VerbosityNormal   = agent.VerbosityNormal
+	VerbosityDetailed = agent.VerbosityDetailed
+)
+
+// BudgetMode is the unit the retrieval agent budgets gathered code in.
+type BudgetMode = agent.BudgetMode
+
+// Budget modes accepted in QueryOptions.BudgetMode.
+const (
+	BudgetLines  = agent.BudgetLines
+	BudgetTokens = agent.BudgetTokens
+)
+
+// TestFilter selects elements by whether they come from test files.
+type TestFilter = agent.TestFilter
+
+// Test filters accepted in QueryOptions.Tests.
+const (
+	TestsInclude = agent.TestsInclude
+	TestsExclude = agent.TestsExclude
+	TestsOnly    = agent.TestsOnly
+)
+
+// RoundTrace records one retrieval round, returned in QueryResult.Trace
+// when QueryOptions.Trace is set.
+type RoundTrace = agent.RoundTrace
+
+// SearchResult is one page of Search results; SearchHit is one result.
+type (
+	SearchResult = orchestrator.SearchResult
+	SearchHit    = orchestrator.SearchHit
+)
+
+// EngineStatus reports whether an engine has an index loaded and which
+// models it is configured with.
+type EngineStatus = orchestrator.EngineStatus
+
+// SetLogLevel sets the minimum level ("debug", "info", "warn" or "error") of
+// the logs written to stderr. The default is warn, or $FASTCODE_LOG_LEVEL.
+func SetLogLevel(name string) error {
+	level, err := logging.ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	logging.SetLevel(level)
+	return nil
+}
+
+// ParseAnswerFormat converts a format name ("markdown", "plain" or "bullet")
+// to an AnswerFormat; "" selects markdown.
+func ParseAnswerFormat(name string) (AnswerFormat, error) {
+	return agent.ParseAnswerFormat(name)
+}
+
+// ParseVerbosity converts a verbosity name ("brief", "normal" or "detailed")
+// to a Verbosity; "" selects normal.
+func ParseVerbosity(name string) (Verbosity, error) {
+	return agent.ParseVerbosity(name)
+}
+
+// DefaultConfig returns the default engine configuration, caching indexes
+// under ~/.fastcode/cache.
+func DefaultConfig() Config {
+	return orchestrator.DefaultConfig()
+}
+
+// DefaultQueryOptions returns the options Query uses.
+func DefaultQueryOptions() QueryOptions {
+	return orchestrator.DefaultQueryOptions()
+}
+
+// Engine indexes one repository at a time and answers questions about it.
+// It is safe for concurrent use: queries wait while a repository is indexed.
+type Engine struct {
+	engine *orchestrator.Engine
+}
+
+// NewEngine creates an engine with the given configuration.
+func NewEngine(cfg Config) *Engine {
+	return &Engine{engine: orchestrator.NewEngine(cfg)}
+}
+
+// Index parses, indexes and optionally embeds the repository at repoPath,
+// reusing a cached index unless forceReindex is set.
+func (e *Engine) Index(repoPath string, forceReindex bool) (*IndexResult, error) {
+	return e.engine.Index(repoPath, forceReindex)
+}
+
+// IndexContext is like Index but can be cancelled between embedding batches.
+func (e *Engine) IndexContext(ctx context.Context, repoPath string, forceReindex bool) (*IndexResult, error) {
+	return e.engine.IndexContext(ctx, repoPath, forceReindex)
+}
+
+// Plan lists the files Index would load from repoPath, without parsing or
+// embedding them.
+func (e *Engine) Plan(repoPath string) (*IndexPlan, error) {
+	return e.engine.Plan(repoPath)
+}
+
+// IndexWorkspace indexes several repositories and loads them together, so
+// queries span all of them. Each repository is cached separately.
+func (e *Engine) IndexWorkspace(ctx context.Context, repoPaths []string, forceReindex bool) (*IndexResult, error) {
+	return e.engine.IndexWorkspace(ctx, repoPaths, forceReindex)
+}
+
+// Query answers a question about the indexed repository with
+// DefaultQueryOptions.
+func (e *Engine) Query(question string) (*QueryResult, error) {
+	return e.engine.Query(question)
+}
+
+// QueryWithOptions answers a question about the indexed repository, tuned by opts.
+func (e *Engine) QueryWithOptions(question string, opts QueryOptions) (*QueryResult, error) {
+	return e.engine.QueryWithOptions(question, opts)
+}
+
+// Search ranks the indexed elements against query without the agent and
+// returns limit of them from offset; pass NextOffset to get the next page.
+func (e *Engine) Search(query string, offset, limit int) (*SearchResult, error) {
+	return e.engine.Search(query, offset, limit)
+}
+
+// Status reports whether the engine is ready to answer queries.
+func (e *Engine) Status() EngineStatus {
+	return e.engine.Status()
+}
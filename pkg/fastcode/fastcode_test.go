@@ -0,0 +1,72 @@
+package fastcode
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEngineIndexAndQuery(t *testing.T) {
+	repoDir := t.TempDir()
+	files := map[string]string{
+		"config.go": "package main\n\n// LoadConfig reads configuration\nfunc LoadConfig(path string) error {\n\treturn nil\n}\n",
+		"server.go": "package main\n\nfunc serve() {}\n",
+		"util.go":   "package main\n\nfunc helper() {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// No API key: queries are answered by direct search
+	origKey := os.Getenv("OPENAI_API_KEY")
+	os.Unsetenv("OPENAI_API_KEY")
+	defer os.Setenv("OPENAI_API_KEY", origKey)
+
+	cfg := DefaultConfig()
+	cfg.CacheDir = t.TempDir()
+	cfg.NoEmbeddings = true
+	engine := NewEngine(cfg)
+
+	indexed, err := engine.Index(repoDir, false)
+	if err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if indexed.TotalFiles != 3 {
+		t.Errorf("TotalFiles = %d, want 3", indexed.TotalFiles)
+	}
+
+	opts := DefaultQueryOptions()
+	opts.TopK = 1
+	result, err := engine.QueryWithOptions("LoadConfig", opts)
+	if err != nil {
+		t.Fatalf("QueryWithOptions: %v", err)
+	}
+	if result.Mode != QueryModeDirect {
+		t.Errorf("Mode = %q, want %q", result.Mode, QueryModeDirect)
+	}
+	if !strings.Contains(result.Answer, "config.go") {
+		t.Errorf("answer does not mention config.go:\n%s", result.Answer)
+	}
+}
+
+func TestParseAnswerFormat(t *testing.T) {
+	if f, err := ParseAnswerFormat("bullet"); err != nil || f != AnswerFormatBullet {
+		t.Errorf("ParseAnswerFormat(bullet) = %q, %v", f, err)
+	}
+	if _, err := ParseAnswerFormat("html"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	defer SetLogLevel("warn")
+	if err := SetLogLevel("debug"); err != nil {
+		t.Errorf("SetLogLevel(debug): %v", err)
+	}
+	if err := SetLogLevel("verbose"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}